@@ -0,0 +1,247 @@
+package cryptoballot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/phayes/errors"
+)
+
+var (
+	ErrCommitmentInvalid       = errors.New("Cannot read commitment. Invalid format")
+	ErrCommitmentNoLog         = errors.New("Cannot commit to an election without an audit log entry")
+	ErrCommitmentNotEnded      = errors.New("Cannot make a final commitment before the election has ended")
+	ErrCommitmentWrongElection = errors.New("Commitment is for a different election")
+	ErrCommitmentElection      = errors.New("Election does not match the anchored commitment")
+	ErrCommitmentLog           = errors.New("Audit log does not match the anchored commitment")
+	ErrCommitmentBallots       = errors.New("Ballots do not match the anchored commitment")
+	ErrAnchorNotFound          = errors.New("No commitment was found for the anchor receipt")
+	ErrAnchorReceiptInvalid    = errors.New("Invalid anchor receipt")
+)
+
+// An Anchor records commitments on a ledger that the election's servers cannot rewrite. Once anchored, the election,
+// the clerk's audit log and the ballots cannot be changed without the change being detected. See Commitment.Verify
+type Anchor interface {
+	// Commit records the commitment, and returns a receipt that locates it on the ledger
+	Commit(commitment *Commitment) (string, error)
+
+	// Lookup gets the commitment recorded with the receipt. ErrAnchorNotFound is returned if there is none.
+	Lookup(receipt string) (*Commitment, error)
+}
+
+// A Commitment records the state of an election at a point in time. A final commitment is made once the election
+// has ended and the ballotbox has published every ballot, and fixes the exact set of ballots. Interim commitments
+// made before then only fix the election and the audit log up to their entry: the ballots they record are for
+// reference, since the Merkle root of a growing set of ballots cannot show that earlier ballots were kept.
+//
+// The text format is as follows, with each section separated by a double line break:
+//  1. Election ID
+//  2. Time of the commitment, in RFC1123Z
+//  3. The hex encoded SHA256 of the election
+//  4. The sequence number of the latest entry in the clerk's audit log
+//  5. The hex encoded hash of that entry. See LogEntry.Hash
+//  6. The number of ballots published by the ballotbox
+//  7. The hex encoded Merkle root of those ballots. See BallotsMerkleRoot
+//  8. "final" or "interim"
+type Commitment struct {
+	ElectionID   string
+	Time         time.Time
+	ElectionHash []byte
+	LogSequence  uint64
+	LogHead      []byte
+	Ballots      int
+	BallotRoot   []byte
+	Final        bool
+}
+
+const (
+	commitmentFinal   = "final"
+	commitmentInterim = "interim"
+)
+
+// NewCommitmentForElection creates a commitment to the election, the latest entry of the clerk's audit log and the
+// ballots. A final commitment should only be made once every ballot has been published, which may be a while after
+// the election ends if the ballotbox mixes ballots or has mirrors.
+func NewCommitmentForElection(election *Election, log []*LogEntry, ballots []Ballot, at time.Time, final bool) (*Commitment, error) {
+	if len(log) == 0 {
+		return nil, ErrCommitmentNoLog
+	}
+	if final && at.Before(election.End) {
+		return nil, ErrCommitmentNotEnded
+	}
+	electionHash := sha256.Sum256([]byte(election.String()))
+	head := log[len(log)-1]
+	return &Commitment{
+		ElectionID:   election.ElectionID,
+		Time:         at,
+		ElectionHash: electionHash[:],
+		LogSequence:  head.Sequence,
+		LogHead:      head.Hash(),
+		Ballots:      len(ballots),
+		BallotRoot:   BallotsMerkleRoot(ballots),
+		Final:        final,
+	}, nil
+}
+
+// NewCommitment parses a commitment, as read back from an anchor
+func NewCommitment(rawCommitment []byte) (*Commitment, error) {
+	parts := bytes.Split(rawCommitment, []byte("\n\n"))
+	if len(parts) != 8 {
+		return nil, ErrCommitmentInvalid
+	}
+
+	var (
+		commitment Commitment
+		err        error
+	)
+	commitment.ElectionID = string(parts[0])
+	if !ValidElectionID.MatchString(commitment.ElectionID) {
+		return nil, errors.Wrap(ErrElectionIDInvalid, ErrCommitmentInvalid)
+	}
+	if commitment.Time, err = time.Parse(time.RFC1123Z, string(parts[1])); err != nil {
+		return nil, errors.Wrap(err, ErrCommitmentInvalid)
+	}
+	if commitment.ElectionHash, err = decodeHash(parts[2]); err != nil {
+		return nil, errors.Wraps(ErrCommitmentInvalid, "Invalid election hash")
+	}
+	if commitment.LogSequence, err = strconv.ParseUint(string(parts[3]), 10, 64); err != nil {
+		return nil, errors.Wraps(ErrCommitmentInvalid, "Invalid log sequence number")
+	}
+	if commitment.LogHead, err = decodeHash(parts[4]); err != nil {
+		return nil, errors.Wraps(ErrCommitmentInvalid, "Invalid log head")
+	}
+	if commitment.Ballots, err = strconv.Atoi(string(parts[5])); err != nil || commitment.Ballots < 0 {
+		return nil, errors.Wraps(ErrCommitmentInvalid, "Invalid number of ballots")
+	}
+	if commitment.BallotRoot, err = decodeHash(parts[6]); err != nil {
+		return nil, errors.Wraps(ErrCommitmentInvalid, "Invalid ballot Merkle root")
+	}
+	switch string(parts[7]) {
+	case commitmentFinal:
+		commitment.Final = true
+	case commitmentInterim:
+	default:
+		return nil, errors.Wraps(ErrCommitmentInvalid, "Must be final or interim")
+	}
+	return &commitment, nil
+}
+
+// decodeHash decodes a hex encoded SHA256 hash
+func decodeHash(rawHash []byte) ([]byte, error) {
+	hash, err := hex.DecodeString(string(rawHash))
+	if err == nil && len(hash) != sha256.Size {
+		err = ErrCommitmentInvalid
+	}
+	return hash, err
+}
+
+// Verify checks that the published election data matches the commitment. The log is the clerk's full audit log,
+// which should already be verified with VerifyAuditLog. It may be nil to skip checking the log.
+//   - The election must be the one committed to
+//   - The log must still hold the committed entry, unchanged
+//   - If the commitment is final, the ballots must be exactly the ones committed to. The ballots of interim
+//     commitments are not checked
+func (commitment *Commitment) Verify(bundle *Bundle, log []*LogEntry) error {
+	if commitment.ElectionID != bundle.Election.ElectionID {
+		return ErrCommitmentWrongElection
+	}
+	electionHash := sha256.Sum256([]byte(bundle.Election.String()))
+	if !bytes.Equal(commitment.ElectionHash, electionHash[:]) {
+		return ErrCommitmentElection
+	}
+	if log != nil {
+		if commitment.LogSequence >= uint64(len(log)) {
+			return errors.Wrapf(ErrCommitmentLog, "The log has %d entries, but entry %d was anchored", len(log), commitment.LogSequence)
+		}
+		if !bytes.Equal(log[commitment.LogSequence].Hash(), commitment.LogHead) {
+			return errors.Wrapf(ErrCommitmentLog, "Entry %d has changed", commitment.LogSequence)
+		}
+	}
+	if !commitment.Final {
+		return nil
+	}
+	if len(bundle.Ballots) != commitment.Ballots {
+		return errors.Wrapf(ErrCommitmentBallots, "%d ballots were anchored, but %d were published", commitment.Ballots, len(bundle.Ballots))
+	}
+	if !bytes.Equal(bundle.ComputeMerkleRoot(), commitment.BallotRoot) {
+		return errors.Wraps(ErrCommitmentBallots, "The Merkle root of the ballots has changed")
+	}
+	return nil
+}
+
+// Implements Stringer. Outputs the commitment in the format expected by NewCommitment
+func (commitment Commitment) String() string {
+	kind := commitmentInterim
+	if commitment.Final {
+		kind = commitmentFinal
+	}
+	return commitment.ElectionID + "\n\n" +
+		commitment.Time.Format(time.RFC1123Z) + "\n\n" +
+		hex.EncodeToString(commitment.ElectionHash) + "\n\n" +
+		strconv.FormatUint(commitment.LogSequence, 10) + "\n\n" +
+		hex.EncodeToString(commitment.LogHead) + "\n\n" +
+		strconv.Itoa(commitment.Ballots) + "\n\n" +
+		hex.EncodeToString(commitment.BallotRoot) + "\n\n" +
+		kind
+}
+
+// FileAnchor is an Anchor that appends commitments to a local file, separated by a triple line break.
+// It is intended for testing, and for elections run without access to the Elastos sidechain.
+// Receipts are the position of the commitment in the file, counting from zero.
+type FileAnchor struct {
+	Path string
+}
+
+// Commit appends the commitment to the file
+func (anchor *FileAnchor) Commit(commitment *Commitment) (string, error) {
+	commitments, err := anchor.read()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(anchor.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	s := commitment.String()
+	if len(commitments) != 0 {
+		s = "\n\n\n" + s
+	}
+	if _, err = f.WriteString(s); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(len(commitments)), nil
+}
+
+// Lookup reads the commitment at the receipt's position in the file
+func (anchor *FileAnchor) Lookup(receipt string) (*Commitment, error) {
+	i, err := strconv.Atoi(receipt)
+	if err != nil || i < 0 {
+		return nil, ErrAnchorReceiptInvalid
+	}
+	commitments, err := anchor.read()
+	if err != nil {
+		return nil, err
+	}
+	if i >= len(commitments) {
+		return nil, ErrAnchorNotFound
+	}
+	return NewCommitment(commitments[i])
+}
+
+// read gets the text of every commitment in the file. A missing file has none.
+func (anchor *FileAnchor) read() ([][]byte, error) {
+	content, err := ioutil.ReadFile(anchor.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil || len(content) == 0 {
+		return nil, err
+	}
+	return bytes.Split(content, []byte("\n\n\n")), nil
+}
@@ -0,0 +1,149 @@
+package cryptoballot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/phayes/errors"
+)
+
+func TestCommitment(t *testing.T) {
+	bundle, _ := newTestBundle(t)
+	clerkPriv, err := GeneratePrivateKey(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := newTestAuditLog(t, clerkPriv, bundle)
+
+	// Anchored once the election ended
+	commitment, err := NewCommitmentForElection(&bundle.Election, log, bundle.Ballots, bundle.Election.End, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := NewCommitment([]byte(commitment.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != commitment.String() {
+		t.Errorf("Commitment did not round trip:\n%s\n%s", commitment, parsed)
+	}
+	if err = parsed.Verify(bundle, log); err != nil {
+		t.Error(err)
+	}
+	if err = parsed.Verify(bundle, nil); err != nil {
+		t.Error(err)
+	}
+
+	// The log has been truncated or rewritten
+	if err = parsed.Verify(bundle, log[:1]); !errors.Is(err, ErrCommitmentLog) {
+		t.Errorf("Expected ErrCommitmentLog for a truncated log, got %v", err)
+	}
+	rewritten := append([]*LogEntry{}, log...)
+	last := *rewritten[len(rewritten)-1]
+	last.Time = last.Time.Add(time.Hour)
+	rewritten[len(rewritten)-1] = &last
+	if err = parsed.Verify(bundle, rewritten); !errors.Is(err, ErrCommitmentLog) {
+		t.Errorf("Expected ErrCommitmentLog for a rewritten log, got %v", err)
+	}
+
+	// A ballot has been removed, added or replaced since the final commitment
+	removed := *bundle
+	removed.Ballots = nil
+	if err = parsed.Verify(&removed, log); !errors.Is(err, ErrCommitmentBallots) {
+		t.Errorf("Expected ErrCommitmentBallots for a removed ballot, got %v", err)
+	}
+	added := *bundle
+	extra := bundle.Ballots[0]
+	extra.BallotID = "stuffed"
+	added.Ballots = append(added.Ballots, extra)
+	if err = parsed.Verify(&added, log); !errors.Is(err, ErrCommitmentBallots) {
+		t.Errorf("Expected ErrCommitmentBallots for an added ballot, got %v", err)
+	}
+	replaced := *bundle
+	replaced.Ballots = []Ballot{extra}
+	if err = parsed.Verify(&replaced, log); !errors.Is(err, ErrCommitmentBallots) {
+		t.Errorf("Expected ErrCommitmentBallots for a replaced ballot, got %v", err)
+	}
+
+	// Interim commitments do not check the ballots, only the election and the log
+	interim, err := NewCommitmentForElection(&bundle.Election, log, bundle.Ballots, bundle.Election.Start, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed, err = NewCommitment([]byte(interim.String())); err != nil || parsed.Final {
+		t.Errorf("Interim commitment did not round trip: %v", err)
+	}
+	if err = interim.Verify(&replaced, log); err != nil {
+		t.Error(err)
+	}
+	if err = interim.Verify(bundle, log[:1]); !errors.Is(err, ErrCommitmentLog) {
+		t.Errorf("Expected ErrCommitmentLog for an interim commitment to a truncated log, got %v", err)
+	}
+	if _, err = NewCommitmentForElection(&bundle.Election, log, bundle.Ballots, bundle.Election.Start, true); err != ErrCommitmentNotEnded {
+		t.Errorf("Expected ErrCommitmentNotEnded, got %v", err)
+	}
+
+	// The election has been changed
+	changed := *bundle
+	changed.Election.End = changed.Election.End.Add(time.Hour)
+	if err = parsed.Verify(&changed, log); err != ErrCommitmentElection {
+		t.Errorf("Expected ErrCommitmentElection, got %v", err)
+	}
+
+	if _, err = NewCommitmentForElection(&bundle.Election, nil, nil, time.Now(), false); err != ErrCommitmentNoLog {
+		t.Errorf("Expected ErrCommitmentNoLog, got %v", err)
+	}
+	if _, err = NewCommitment([]byte("election12345\n\nnot a time")); err == nil {
+		t.Error("Expected error parsing a bad commitment")
+	}
+}
+
+func TestFileAnchor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anchor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	anchor := &FileAnchor{Path: filepath.Join(dir, "anchor")}
+
+	bundle, _ := newTestBundle(t)
+	clerkPriv, err := GeneratePrivateKey(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := newTestAuditLog(t, clerkPriv, bundle)
+
+	var commitments []*Commitment
+	for i := 0; i < 3; i++ {
+		commitment, err := NewCommitmentForElection(&bundle.Election, log[:i+1], bundle.Ballots, bundle.Election.Start.Add(time.Duration(i)*time.Minute), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		receipt, err := anchor.Commit(commitment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if receipt != string('0'+rune(i)) {
+			t.Errorf("Expected receipt %d, got %s", i, receipt)
+		}
+		commitments = append(commitments, commitment)
+	}
+	for i, commitment := range commitments {
+		found, err := anchor.Lookup(string('0' + rune(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.String() != commitment.String() {
+			t.Errorf("Commitment %d did not round trip", i)
+		}
+	}
+	if _, err = anchor.Lookup("3"); err != ErrAnchorNotFound {
+		t.Errorf("Expected ErrAnchorNotFound, got %v", err)
+	}
+	if _, err = anchor.Lookup("x"); err != ErrAnchorReceiptInvalid {
+		t.Errorf("Expected ErrAnchorReceiptInvalid, got %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package cryptoballot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/phayes/errors"
+)
+
+// noLogTime stands in for the time of signature entries, which are not timestamped. See LogEntry
+const noLogTime = "-"
+
+// The kinds of event recorded in the election clerk's audit log
+const (
+	LogElectionCreated = "election"  // An election was created. The subject is the hash of the election
+	LogSignatureIssued = "signature" // A ballot was blind-signed. The subject is the hash of the fulfilled signature request
+	LogResultPublished = "result"    // The election's result was published. The subject is the hash of the result
+)
+
+var (
+	ErrLogEntryInvalid      = errors.New("Cannot read audit log entry. Invalid format")
+	ErrLogEntryInvalidType  = errors.New("Cannot read audit log entry. Unknown type. Must be election, signature or result")
+	ErrLogEntryInvalidTime  = errors.New("Cannot read audit log entry. Signature entries must not have a time, and other entries must")
+	ErrLogEntryInvalidSig   = errors.New("Invalid audit log entry signature")
+	ErrLogEntrySigNotFound  = errors.New("Could not verify audit log entry signature: Signature does not exist")
+	ErrLogSequence          = errors.New("Audit log entries are out of sequence. Entries have been inserted, deleted or reordered")
+	ErrLogChain             = errors.New("Audit log entry does not follow the entry before it. Entries have been altered, deleted or reordered")
+	ErrLogNotLogged         = errors.New("Signature request was not recorded in the audit log")
+	ErrLogMissingSignatures = errors.New("Audit log records signature requests that are missing")
+)
+
+// A LogEntry is one event in the election clerk's audit log. Each entry carries the hash of the entry before it,
+// and is signed by the clerk, so that entries cannot be inserted, deleted, altered or reordered without the
+// change being detected. See VerifyAuditLog
+//
+// The text format is as follows, with each section separated by a double line break:
+//  1. Sequence number, counting up from zero
+//  2. Time, in RFC1123Z. Signature entries have "-" instead, see below
+//  3. Type. See LogElectionCreated, LogSignatureIssued and LogResultPublished
+//  4. Election ID
+//  5. Subject: the hex encoded SHA256 of the document the event is about
+//  6. The hex encoded SHA256 of the previous entry, without its signature. All zeros for the first entry
+//  7. The clerk's base64 encoded signature of everything above, made with the clerk's ballot signing key
+//
+// Signature entries are not timestamped, since the time a ballot was signed could be matched against the published
+// signature requests to tell when each voter signed their ballot. Their Time is zero.
+type LogEntry struct {
+	Sequence   uint64
+	Time       time.Time
+	Type       string
+	ElectionID string
+	Subject    []byte
+	PrevHash   []byte
+	Signature  Signature
+}
+
+// NewLogEntry parses an audit log entry. The signature is not verified.
+func NewLogEntry(rawEntry []byte) (*LogEntry, error) {
+	parts := bytes.Split(rawEntry, []byte("\n\n"))
+	if len(parts) != 6 && len(parts) != 7 {
+		return nil, ErrLogEntryInvalid
+	}
+
+	var (
+		entry LogEntry
+		err   error
+	)
+	if entry.Sequence, err = strconv.ParseUint(string(parts[0]), 10, 64); err != nil {
+		return nil, errors.Wraps(ErrLogEntryInvalid, "Invalid sequence number")
+	}
+	entry.Type = string(parts[2])
+	switch entry.Type {
+	case LogElectionCreated, LogSignatureIssued, LogResultPublished:
+	default:
+		return nil, ErrLogEntryInvalidType
+	}
+	if (string(parts[1]) == noLogTime) != (entry.Type == LogSignatureIssued) {
+		return nil, ErrLogEntryInvalidTime
+	}
+	if entry.Type != LogSignatureIssued {
+		if entry.Time, err = time.Parse(time.RFC1123Z, string(parts[1])); err != nil {
+			return nil, errors.Wrap(err, ErrLogEntryInvalid)
+		}
+	}
+	entry.ElectionID = string(parts[3])
+	if !ValidElectionID.MatchString(entry.ElectionID) {
+		return nil, errors.Wrap(ErrElectionIDInvalid, ErrLogEntryInvalid)
+	}
+	if entry.Subject, err = hex.DecodeString(string(parts[4])); err != nil || len(entry.Subject) != sha256.Size {
+		return nil, errors.Wraps(ErrLogEntryInvalid, "Invalid subject")
+	}
+	if entry.PrevHash, err = hex.DecodeString(string(parts[5])); err != nil || len(entry.PrevHash) != sha256.Size {
+		return nil, errors.Wraps(ErrLogEntryInvalid, "Invalid previous hash")
+	}
+	if len(parts) == 7 {
+		if entry.Signature, err = NewSignature(parts[6]); err != nil {
+			return nil, errors.Wrap(err, ErrLogEntryInvalidSig)
+		}
+	}
+	return &entry, nil
+}
+
+// NextLogEntry creates the unsigned entry that follows prev in the audit log. Prev is nil for the first entry.
+// The subject is the text of the document the event is about, which is hashed. The time is dropped for signature
+// entries.
+func NextLogEntry(prev *LogEntry, at time.Time, entryType string, electionID string, subject string) *LogEntry {
+	hash := sha256.Sum256([]byte(subject))
+	entry := &LogEntry{
+		Time:       at,
+		Type:       entryType,
+		ElectionID: electionID,
+		Subject:    hash[:],
+		PrevHash:   make([]byte, sha256.Size),
+	}
+	if entryType == LogSignatureIssued {
+		entry.Time = time.Time{}
+	}
+	if prev != nil {
+		entry.Sequence = prev.Sequence + 1
+		entry.PrevHash = prev.Hash()
+	}
+	return entry
+}
+
+// Hash gets the SHA256 of the entry without its signature. The next entry in the log carries this hash.
+func (entry *LogEntry) Hash() []byte {
+	hash := sha256.Sum256([]byte(entry.StringWithoutSignature()))
+	return hash[:]
+}
+
+// VerifySignature verifies that the entry was signed by the election clerk
+func (entry *LogEntry) VerifySignature(clerkKey PublicKey) error {
+	if !entry.HasSignature() {
+		return ErrLogEntrySigNotFound
+	}
+	err := entry.Signature.VerifySignature(clerkKey, []byte(entry.StringWithoutSignature()))
+	if err != nil {
+		return errors.Wrap(err, ErrLogEntryInvalidSig)
+	}
+	return nil
+}
+
+// HasSignature checks to see if the entry has been signed. It does not verify the signature.
+func (entry *LogEntry) HasSignature() bool {
+	return len(entry.Signature) != 0
+}
+
+// Implements Stringer. Outputs the entry in the format expected by NewLogEntry
+func (entry LogEntry) String() string {
+	s := entry.StringWithoutSignature()
+	if entry.HasSignature() {
+		s += "\n\n" + entry.Signature.String()
+	}
+	return s
+}
+
+// StringWithoutSignature gets the entry without the signature, OK for signing and hashing
+func (entry LogEntry) StringWithoutSignature() string {
+	at := noLogTime
+	if entry.Type != LogSignatureIssued {
+		at = entry.Time.Format(time.RFC1123Z)
+	}
+	return strconv.FormatUint(entry.Sequence, 10) + "\n\n" +
+		at + "\n\n" +
+		entry.Type + "\n\n" +
+		entry.ElectionID + "\n\n" +
+		hex.EncodeToString(entry.Subject) + "\n\n" +
+		hex.EncodeToString(entry.PrevHash)
+}
+
+// VerifyAuditLog verifies a segment of the election clerk's audit log. Every entry must be signed by the clerk, and
+// must follow the entry before it in sequence and by hash. Prev is the entry before the segment, which should
+// already be verified. If prev is nil the segment must start at the beginning of the log.
+func VerifyAuditLog(entries []*LogEntry, clerkKey PublicKey, prev *LogEntry) error {
+	for _, entry := range entries {
+		if err := entry.VerifySignature(clerkKey); err != nil {
+			return errors.Wrapf(err, "Entry %d", entry.Sequence)
+		}
+		expected := NextLogEntry(prev, entry.Time, entry.Type, entry.ElectionID, "")
+		if entry.Sequence != expected.Sequence {
+			return errors.Wrapf(ErrLogSequence, "Expected entry %d, found entry %d", expected.Sequence, entry.Sequence)
+		}
+		if !bytes.Equal(entry.PrevHash, expected.PrevHash) {
+			return errors.Wrapf(ErrLogChain, "Entry %d", entry.Sequence)
+		}
+		prev = entry
+	}
+	return nil
+}
+
+// CheckLoggedSignatureRequests checks that the fulfilled signature requests published for an election are exactly
+// those the audit log records the clerk issuing. The log should already be verified, and must be complete.
+func CheckLoggedSignatureRequests(entries []*LogEntry, electionID string, fulfilled []FulfilledSignatureRequest) error {
+	logged := map[string]int{}
+	for _, entry := range entries {
+		if entry.Type == LogSignatureIssued && entry.ElectionID == electionID {
+			logged[string(entry.Subject)]++
+		}
+	}
+	for _, sigReq := range fulfilled {
+		hash := sha256.Sum256([]byte(sigReq.String()))
+		if logged[string(hash[:])] == 0 {
+			return errors.Wrapf(ErrLogNotLogged, "Request ID %x", sigReq.RequestID)
+		}
+		logged[string(hash[:])]--
+	}
+	for _, count := range logged {
+		if count != 0 {
+			return ErrLogMissingSignatures
+		}
+	}
+	return nil
+}
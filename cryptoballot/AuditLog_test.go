@@ -0,0 +1,135 @@
+package cryptoballot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/phayes/errors"
+)
+
+// newTestAuditLog creates a log of an election being created and a signature being issued for each of the
+// bundle's signature requests, signed by the given clerk key
+func newTestAuditLog(t *testing.T, clerkPriv PrivateKey, bundle *Bundle) []*LogEntry {
+	var log []*LogEntry
+	var prev *LogEntry
+	add := func(entryType, subject string) {
+		entry := NextLogEntry(prev, time.Now(), entryType, bundle.Election.ElectionID, subject)
+		var err error
+		entry.Signature, err = clerkPriv.SignString(entry.StringWithoutSignature())
+		if err != nil {
+			t.Fatal(err)
+		}
+		log = append(log, entry)
+		prev = entry
+	}
+	add(LogElectionCreated, bundle.Election.String())
+	for _, sigReq := range bundle.SignatureRequests {
+		add(LogSignatureIssued, sigReq.String())
+	}
+	add(LogResultPublished, "result")
+	return log
+}
+
+func TestAuditLog(t *testing.T) {
+	bundle, _ := newTestBundle(t)
+	clerkPriv, err := GeneratePrivateKey(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clerkPub, err := clerkPriv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := newTestAuditLog(t, clerkPriv, bundle)
+
+	// Round trip, as a third party would read the log
+	for i, entry := range log {
+		parsed, err := NewLogEntry([]byte(entry.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.String() != entry.String() {
+			t.Errorf("Log entry did not round trip:\n%s\n%s", entry, parsed)
+		}
+		log[i] = parsed
+	}
+	if err = VerifyAuditLog(log, clerkPub, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyAuditLog(log[1:], clerkPub, log[0]); err != nil {
+		t.Error(err)
+	}
+	if err = CheckLoggedSignatureRequests(log, bundle.Election.ElectionID, bundle.SignatureRequests); err != nil {
+		t.Error(err)
+	}
+
+	// Deleting an entry
+	deleted := []*LogEntry{log[0], log[2]}
+	if err = VerifyAuditLog(deleted, clerkPub, nil); !errors.Is(err, ErrLogSequence) {
+		t.Errorf("Expected ErrLogSequence for a deleted entry, got %v", err)
+	}
+
+	// Reordering entries
+	reordered := []*LogEntry{log[0], log[2], log[1]}
+	if err = VerifyAuditLog(reordered, clerkPub, nil); !errors.Is(err, ErrLogSequence) {
+		t.Errorf("Expected ErrLogSequence for reordered entries, got %v", err)
+	}
+
+	// Starting a segment part way through the log without the entry before it
+	if err = VerifyAuditLog(log[1:], clerkPub, nil); !errors.Is(err, ErrLogSequence) {
+		t.Errorf("Expected ErrLogSequence for a segment without its previous entry, got %v", err)
+	}
+
+	// Altering an entry breaks its signature
+	altered := *log[1]
+	altered.ElectionID = "other"
+	if err = VerifyAuditLog([]*LogEntry{log[0], &altered, log[2]}, clerkPub, nil); !errors.Is(err, ErrLogEntryInvalidSig) {
+		t.Errorf("Expected ErrLogEntryInvalidSig for an altered entry, got %v", err)
+	}
+
+	// Even the clerk cannot replace an entry without breaking the chain
+	replaced := NextLogEntry(log[0], time.Now(), LogSignatureIssued, bundle.Election.ElectionID, "forged")
+	replaced.Signature, err = clerkPriv.SignString(replaced.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyAuditLog([]*LogEntry{log[0], replaced, log[2]}, clerkPub, nil); !errors.Is(err, ErrLogChain) {
+		t.Errorf("Expected ErrLogChain for a replaced entry, got %v", err)
+	}
+
+	// Signature requests must match the log
+	if err = CheckLoggedSignatureRequests(log, bundle.Election.ElectionID, nil); err != ErrLogMissingSignatures {
+		t.Errorf("Expected ErrLogMissingSignatures, got %v", err)
+	}
+	extra := bundle.SignatureRequests[0]
+	extra.RequestID = []byte("inserted")
+	if err = CheckLoggedSignatureRequests(log, bundle.Election.ElectionID, append(bundle.SignatureRequests, extra)); !errors.Is(err, ErrLogNotLogged) {
+		t.Errorf("Expected ErrLogNotLogged, got %v", err)
+	}
+}
+
+func TestBadLogEntry(t *testing.T) {
+	zeros := "0000000000000000000000000000000000000000000000000000000000000000"
+	badEntries := []string{
+		"0\n\nMon, 02 Jan 2006 15:04:05 -0700\n\nelection\n\n12345\n\n" + zeros,
+		"x\n\nMon, 02 Jan 2006 15:04:05 -0700\n\nelection\n\n12345\n\n" + zeros + "\n\n" + zeros,
+		"0\n\nyesterday\n\nelection\n\n12345\n\n" + zeros + "\n\n" + zeros,
+		"0\n\nMon, 02 Jan 2006 15:04:05 -0700\n\nunknown\n\n12345\n\n" + zeros + "\n\n" + zeros,
+		"0\n\nMon, 02 Jan 2006 15:04:05 -0700\n\nelection\n\nbad id\n\n" + zeros + "\n\n" + zeros,
+		"0\n\nMon, 02 Jan 2006 15:04:05 -0700\n\nelection\n\n12345\n\n00\n\n" + zeros,
+		"0\n\nMon, 02 Jan 2006 15:04:05 -0700\n\nelection\n\n12345\n\n" + zeros + "\n\n" + zeros + "\n\nnot-base64",
+		"0\n\n-\n\nelection\n\n12345\n\n" + zeros + "\n\n" + zeros,
+		"0\n\nMon, 02 Jan 2006 15:04:05 -0700\n\nsignature\n\n12345\n\n" + zeros + "\n\n" + zeros,
+	}
+	for _, raw := range badEntries {
+		if _, err := NewLogEntry([]byte(raw)); err == nil {
+			t.Errorf("Expected error parsing %q", raw)
+		}
+	}
+	if _, err := NewLogEntry([]byte("0\n\nMon, 02 Jan 2006 15:04:05 -0700\n\nelection\n\n12345\n\n" + zeros + "\n\n" + zeros)); err != nil {
+		t.Error(err)
+	}
+	if _, err := NewLogEntry([]byte("1\n\n-\n\nsignature\n\n12345\n\n" + zeros + "\n\n" + zeros)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,242 @@
+package cryptoballot
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/pem"
+	"strings"
+
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"github.com/phayes/errors"
+)
+
+// A Bundle is a self-contained export of an election. It holds everything needed to audit and tally the
+// election offline: the election itself, the election clerk's public key, every fulfilled signature request,
+// every ballot and the Merkle root of the ballots. The bundle is signed by the DID key of whoever exported it.
+//
+// A bundle is a series of PEM blocks, in the following order:
+//  1. ELECTION: The election, in the same format as accepted by NewElection
+//  2. PUBLIC KEY: The election clerk's public key, followed by a PUBLIC KEY block with a Denomination header for
+//     each of the clerk's denomination keys if the election is weighted. See DenominationKeys
+//  3. FULFILLED SIGNATURE REQUEST: One block per fulfilled signature request
+//  4. BALLOT: One block per ballot
+//  5. ADJUDICATION: Optional. The election admin's signed rulings on write-ins. See Adjudication
+//  6. MERKLE ROOT: Optional. The root of the Merkle tree over all ballots. See BallotsMerkleRoot
+//  7. BUNDLE SIGNATURE: Optional. The exporter's signature over all previous blocks, with their hex encoded DID public key in a Public-Key header
+type Bundle struct {
+	Election          Election
+	ClerkKey          PublicKey
+	DenominationKeys  DenominationKeys // Clerk keys by denomination for weighted elections, including ClerkKey as denomination 1. Nil otherwise
+	SignatureRequests []FulfilledSignatureRequest
+	Ballots           []Ballot
+	Adjudication      *Adjudication // Rulings on write-ins, if the admin made any
+	MerkleRoot        []byte
+	PublicKey         []byte // DID public key of the exporter
+	Signature         []byte // Exporter's signature over the bundle
+}
+
+const (
+	bundleElectionType     = "ELECTION"
+	bundleClerkKeyType     = "PUBLIC KEY"
+	bundleSigReqType       = "FULFILLED SIGNATURE REQUEST"
+	bundleBallotType       = "BALLOT"
+	bundleAdjudicationType = "ADJUDICATION"
+	bundleMerkleRootType   = "MERKLE ROOT"
+	bundleSignatureType    = "BUNDLE SIGNATURE"
+)
+
+var (
+	ErrBundleInvalid             = errors.New("Cannot parse bundle. Invalid format")
+	ErrBundleExtraData           = errors.New("Cannot parse bundle. Found data that is not a PEM block")
+	ErrBundleBlockOrder          = errors.New("Cannot parse bundle. PEM blocks are missing or out of order")
+	ErrBundleInvalidElection     = errors.New("Cannot parse election in bundle")
+	ErrBundleInvalidKey          = errors.New("Cannot parse clerk public key in bundle")
+	ErrBundleInvalidSigReq       = errors.New("Cannot parse fulfilled signature request in bundle")
+	ErrBundleInvalidBallot       = errors.New("Cannot parse ballot in bundle")
+	ErrBundleInvalidAdjudication = errors.New("Cannot parse adjudication in bundle")
+	ErrBundleInvalidSigner       = errors.New("Cannot parse Public-Key of bundle signer")
+	ErrBundleSigNotFound         = errors.New("Could not verify bundle signature: Signature does not exist")
+)
+
+// NewBundle parses a bundle from its PEM encoded form
+func NewBundle(rawBundle []byte) (*Bundle, error) {
+	var (
+		bundle Bundle
+		block  *pem.Block
+		stage  int
+	)
+
+	// Blocks must appear in order, so we track which stage of the bundle we are at.
+	// Only signature requests, ballots and denomination keys may repeat, and the election and clerk key are required.
+	stages := map[string]int{
+		bundleElectionType:     1,
+		bundleClerkKeyType:     2,
+		bundleSigReqType:       3,
+		bundleBallotType:       4,
+		bundleAdjudicationType: 5,
+		bundleMerkleRootType:   6,
+		bundleSignatureType:    7,
+	}
+
+	rest := rawBundle
+	for {
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			if len(bytes.TrimSpace(rest)) != 0 {
+				return nil, ErrBundleExtraData
+			}
+			break
+		}
+
+		blockStage, ok := stages[block.Type]
+		if !ok {
+			return nil, errors.Wraps(ErrBundleInvalid, "Found unexpected "+block.Type+" block")
+		}
+		_, isDenomination := block.Headers["Denomination"]
+		repeatable := block.Type == bundleSigReqType || block.Type == bundleBallotType || (block.Type == bundleClerkKeyType && isDenomination)
+		if block.Type == bundleClerkKeyType && isDenomination != (blockStage == stage) {
+			return nil, ErrBundleBlockOrder
+		}
+		if blockStage < stage || (blockStage == stage && !repeatable) {
+			return nil, ErrBundleBlockOrder
+		}
+		if stage < 2 && blockStage != stage+1 {
+			return nil, ErrBundleBlockOrder
+		}
+		stage = blockStage
+
+		switch block.Type {
+		case bundleElectionType:
+			election, err := NewElection(block.Bytes)
+			if err != nil {
+				return nil, errors.Wrap(err, ErrBundleInvalidElection)
+			}
+			bundle.Election = *election
+		case bundleClerkKeyType:
+			if isDenomination {
+				keys, err := NewDenominationKeys(pem.EncodeToMemory(block))
+				if err != nil {
+					return nil, errors.Wrap(err, ErrBundleInvalidKey)
+				}
+				if bundle.DenominationKeys == nil {
+					bundle.DenominationKeys = DenominationKeys{1: bundle.ClerkKey}
+				}
+				for denomination, key := range keys {
+					if _, ok := bundle.DenominationKeys[denomination]; ok {
+						return nil, errors.Wraps(ErrBundleInvalidKey, "Denomination listed more than once")
+					}
+					bundle.DenominationKeys[denomination] = key
+				}
+				break
+			}
+			clerkKey, err := NewPublicKeyFromBlock(block)
+			if err != nil {
+				return nil, errors.Wrap(err, ErrBundleInvalidKey)
+			}
+			bundle.ClerkKey = clerkKey
+		case bundleSigReqType:
+			fulfilled, err := NewFulfilledSignatureRequest(block.Bytes)
+			if err != nil {
+				return nil, errors.Wrap(err, ErrBundleInvalidSigReq)
+			}
+			bundle.SignatureRequests = append(bundle.SignatureRequests, *fulfilled)
+		case bundleBallotType:
+			ballot, err := NewBallot(block.Bytes)
+			if err != nil {
+				return nil, errors.Wrap(err, ErrBundleInvalidBallot)
+			}
+			bundle.Ballots = append(bundle.Ballots, *ballot)
+		case bundleAdjudicationType:
+			adjudication, err := NewAdjudication(block.Bytes)
+			if err != nil {
+				return nil, errors.Wrap(err, ErrBundleInvalidAdjudication)
+			}
+			bundle.Adjudication = adjudication
+		case bundleMerkleRootType:
+			bundle.MerkleRoot = block.Bytes
+		case bundleSignatureType:
+			publicKey, err := hex.DecodeString(block.Headers["Public-Key"])
+			if err != nil || len(publicKey) == 0 {
+				return nil, ErrBundleInvalidSigner
+			}
+			bundle.PublicKey = publicKey
+			bundle.Signature = block.Bytes
+		}
+	}
+
+	if stage < 2 {
+		return nil, ErrBundleBlockOrder
+	}
+
+	return &bundle, nil
+}
+
+// ComputeMerkleRoot computes the Merkle root of the ballots in the bundle
+func (bundle *Bundle) ComputeMerkleRoot() []byte {
+	return BallotsMerkleRoot(bundle.Ballots)
+}
+
+// VerifySignature verifies that the bundle has been properly signed by the DID key in bundle.PublicKey.
+// It does not check who that key belongs to.
+func (bundle *Bundle) VerifySignature() error {
+	if !bundle.HasSignature() {
+		return ErrBundleSigNotFound
+	}
+	publicKey, err := crypto.DecodePoint(bundle.PublicKey)
+	if err != nil {
+		return errors.Wrap(err, ErrBundleInvalidSigner)
+	}
+	didPublicKey := DIDPublicKey{PublicKey: *publicKey}
+	return didPublicKey.VerifySignature(bundle.Signature, []byte(bundle.StringWithoutSignature()))
+}
+
+// HasSignature checks to see if the bundle has been signed. It does not verify the signature.
+func (bundle *Bundle) HasSignature() bool {
+	return bundle.Signature != nil
+}
+
+// Implements Stringer. The returned string is the same format as expected by NewBundle
+func (bundle Bundle) String() string {
+	s := bundle.StringWithoutSignature()
+
+	if bundle.HasSignature() {
+		s += string(pem.EncodeToMemory(&pem.Block{
+			Type:    bundleSignatureType,
+			Headers: map[string]string{"Public-Key": hex.EncodeToString(bundle.PublicKey)},
+			Bytes:   bundle.Signature,
+		}))
+	}
+
+	return s
+}
+
+// StringWithoutSignature gets the PEM encoded bundle without the exporter's signature, OK for signing.
+func (bundle Bundle) StringWithoutSignature() string {
+	var s strings.Builder
+
+	s.Write(pem.EncodeToMemory(&pem.Block{Type: bundleElectionType, Bytes: []byte(bundle.Election.String())}))
+	s.Write(pem.EncodeToMemory(&pem.Block{Type: bundleClerkKeyType, Bytes: bundle.ClerkKey.Bytes()}))
+	if bundle.DenominationKeys != nil {
+		keys := DenominationKeys{}
+		for denomination, key := range bundle.DenominationKeys {
+			if denomination != 1 {
+				keys[denomination] = key
+			}
+		}
+		s.WriteString(keys.String())
+	}
+	for _, fulfilled := range bundle.SignatureRequests {
+		s.Write(pem.EncodeToMemory(&pem.Block{Type: bundleSigReqType, Bytes: []byte(fulfilled.String())}))
+	}
+	for _, ballot := range bundle.Ballots {
+		s.Write(pem.EncodeToMemory(&pem.Block{Type: bundleBallotType, Bytes: []byte(ballot.String())}))
+	}
+	if bundle.Adjudication != nil {
+		s.Write(pem.EncodeToMemory(&pem.Block{Type: bundleAdjudicationType, Bytes: []byte(bundle.Adjudication.String())}))
+	}
+	if bundle.MerkleRoot != nil {
+		s.Write(pem.EncodeToMemory(&pem.Block{Type: bundleMerkleRootType, Bytes: bundle.MerkleRoot}))
+	}
+
+	return s.String()
+}
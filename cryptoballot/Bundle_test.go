@@ -0,0 +1,161 @@
+package cryptoballot
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+func newTestBundle(t *testing.T) (*Bundle, DIDPrivateKey) {
+	adminPriv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminPub, err := adminPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	voterPriv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	voterPub, err := voterPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clerkPriv, err := GeneratePrivateKey(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clerkPub, err := clerkPriv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	election := Election{
+		ElectionID: "election12345",
+		Start:      time.Now().Truncate(time.Second),
+		End:        time.Now().Add(time.Hour).Truncate(time.Second),
+		PublicKey:  adminPub.Bytes(),
+	}
+	election.Signature, err = adminPriv.SignString(election.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requestID := common.Sha256D(voterPub.Bytes())
+	blindBallot, _ := NewBlindBallot([]byte(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("b", 256)))))
+	signatureRequest := SignatureRequest{
+		ElectionID:  election.ElectionID,
+		RequestID:   requestID[:],
+		PublicKey:   voterPub.Bytes(),
+		BlindBallot: blindBallot,
+	}
+	signatureRequest.Signature, err = voterPriv.SignString(signatureRequest.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ballotSignature, _ := NewSignature([]byte(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("s", 256)))))
+
+	ballot, err := NewBallot(goodBallot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := &Bundle{
+		Election:          election,
+		ClerkKey:          clerkPub,
+		SignatureRequests: []FulfilledSignatureRequest{*NewFulfilledSignatureRequestFromParts(signatureRequest, ballotSignature)},
+		Ballots:           []Ballot{*ballot},
+	}
+	bundle.MerkleRoot = bundle.ComputeMerkleRoot()
+
+	exporterPriv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporterPub, err := exporterPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle.PublicKey = exporterPub.Bytes()
+	bundle.Signature, err = exporterPriv.SignString(bundle.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return bundle, exporterPriv
+}
+
+func TestBundle(t *testing.T) {
+	bundle, _ := newTestBundle(t)
+
+	if err := bundle.VerifySignature(); err != nil {
+		t.Error(err)
+	}
+
+	// Round trip to string and back
+	parsed, err := NewBundle([]byte(bundle.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != bundle.String() {
+		t.Error("Bundle round-trip from string and back again failed")
+	}
+	if !reflect.DeepEqual(parsed.MerkleRoot, bundle.ComputeMerkleRoot()) {
+		t.Error("Merkle root changed in round trip")
+	}
+	if err := parsed.VerifySignature(); err != nil {
+		t.Error(err)
+	}
+	if err := parsed.Election.VerifySignature(); err != nil {
+		t.Error(err)
+	}
+	if err := parsed.SignatureRequests[0].VerifySignature(); err != nil {
+		t.Error(err)
+	}
+
+	// Tampering with the bundle should break the signature
+	parsed.Ballots = nil
+	if err := parsed.VerifySignature(); err == nil {
+		t.Error("Tampered bundle should not verify")
+	}
+
+	// An unsigned bundle can still be parsed, but not verified
+	parsed.Signature = nil
+	unsigned, err := NewBundle([]byte(parsed.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unsigned.VerifySignature(); err != ErrBundleSigNotFound {
+		t.Error("Expected ErrBundleSigNotFound, got", err)
+	}
+}
+
+func TestBadBundle(t *testing.T) {
+	bundle, _ := newTestBundle(t)
+	raw := bundle.String()
+
+	// The election must come first
+	electionEnd := strings.Index(raw, "-----END ELECTION-----\n") + len("-----END ELECTION-----\n")
+	keyEnd := strings.Index(raw, "-----END PUBLIC KEY-----\n") + len("-----END PUBLIC KEY-----\n")
+	swapped := raw[electionEnd:keyEnd] + raw[:electionEnd] + raw[keyEnd:]
+	if _, err := NewBundle([]byte(swapped)); err != ErrBundleBlockOrder {
+		t.Error("Expected ErrBundleBlockOrder, got", err)
+	}
+
+	// The clerk key is required
+	if _, err := NewBundle([]byte(raw[:electionEnd])); err != ErrBundleBlockOrder {
+		t.Error("Expected ErrBundleBlockOrder, got", err)
+	}
+
+	// Trailing garbage is not allowed
+	if _, err := NewBundle([]byte(raw + "garbage")); err != ErrBundleExtraData {
+		t.Error("Expected ErrBundleExtraData, got", err)
+	}
+}
@@ -0,0 +1,510 @@
+package cryptoballot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/phayes/errors"
+)
+
+// Verifiable credentials and presentations, in the W3C JSON form used by Elastos DIDs.
+//
+// A proof is made over the canonical form of the credential or presentation: the compact JSON with object keys sorted,
+// with the proof's "signature" field left out. The signature is a hex encoded DID signature, the same as used everywhere
+// else in cryptoballot. Credential proofs may be made with any key in the issuer's DID document. Presentation proofs
+// must be made with one of the holder's authentication keys.
+
+const (
+	CredentialType   = "VerifiableCredential"
+	PresentationType = "VerifiablePresentation"
+
+	ElectionCredentialTypesTag   = "credential-types"   // Election tag listing the credential types voters must present, comma separated
+	ElectionCredentialIssuersTag = "credential-issuers" // Election tag listing the DIDs of trusted credential issuers, comma separated
+)
+
+var (
+	ErrCredentialInvalid         = errors.New("Cannot parse credential. Invalid format")
+	ErrCredentialProof           = errors.New("Could not verify credential proof")
+	ErrCredentialNotYetValid     = errors.New("Credential is not valid yet")
+	ErrCredentialExpired         = errors.New("Credential has expired")
+	ErrCredentialRevoked         = errors.New("Credential has been revoked")
+	ErrCredentialNoID            = errors.New("Credential has no id, so it cannot be checked for revocation")
+	ErrCredentialUntrustedIssuer = errors.New("Credential was not issued by a trusted issuer")
+	ErrCredentialWrongSubject    = errors.New("Credential was not issued to the holder of the presentation")
+	ErrCredentialMissing         = errors.New("No valid credential of a required type was presented")
+	ErrPresentationInvalid       = errors.New("Cannot parse verifiable presentation. Invalid format")
+	ErrPresentationProof         = errors.New("Could not verify verifiable presentation proof")
+	ErrPresentationRealm         = errors.New("Verifiable presentation was made for a different election or signature request")
+	ErrPresentationRequired      = errors.New("This election requires voters to present credentials")
+)
+
+// Credential is a verifiable credential issued to a DID
+type Credential struct {
+	ID             string
+	Types          []string
+	Issuer         string
+	Subject        string                 // DID the credential was issued to
+	Claims         map[string]interface{} // Everything else in the credentialSubject
+	IssuanceDate   time.Time
+	ExpirationDate time.Time // Zero if the credential does not expire
+	raw            map[string]interface{}
+}
+
+// NewCredential parses a JSON verifiable credential. The proof is not verified. See Verify.
+func NewCredential(rawCredential []byte) (*Credential, error) {
+	raw, err := decodeJSONObject(rawCredential)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrCredentialInvalid)
+	}
+	return newCredentialFromJSON(raw)
+}
+
+func newCredentialFromJSON(raw map[string]interface{}) (*Credential, error) {
+	cred := &Credential{raw: raw}
+	cred.ID, _ = raw["id"].(string)
+	cred.Issuer, _ = raw["issuer"].(string)
+	cred.Types = jsonStrings(raw["type"])
+	if !ValidDIDString(cred.Issuer) {
+		return nil, errors.Wraps(ErrCredentialInvalid, "Invalid issuer")
+	}
+	if !cred.HasType(CredentialType) {
+		return nil, errors.Wraps(ErrCredentialInvalid, "Missing type "+CredentialType)
+	}
+
+	subject, ok := raw["credentialSubject"].(map[string]interface{})
+	if !ok {
+		return nil, errors.Wraps(ErrCredentialInvalid, "Missing credentialSubject")
+	}
+	cred.Claims = map[string]interface{}{}
+	for key, value := range subject {
+		if key == "id" {
+			cred.Subject, _ = value.(string)
+		} else {
+			cred.Claims[key] = value
+		}
+	}
+	if !ValidDIDString(cred.Subject) {
+		return nil, errors.Wraps(ErrCredentialInvalid, "Invalid credentialSubject id")
+	}
+
+	var err error
+	if cred.IssuanceDate, err = jsonTime(raw["issuanceDate"]); err != nil {
+		return nil, errors.Wrap(err, ErrCredentialInvalid)
+	}
+	if cred.ExpirationDate, err = jsonTime(raw["expirationDate"]); err != nil {
+		return nil, errors.Wrap(err, ErrCredentialInvalid)
+	}
+	return cred, nil
+}
+
+// NewUnsignedCredential creates a credential, ready to be signed by the issuer with Sign
+func NewUnsignedCredential(id, issuer, subject string, types []string, claims map[string]interface{}, issued, expires time.Time) (*Credential, error) {
+	credentialSubject := map[string]interface{}{"id": subject}
+	for key, value := range claims {
+		credentialSubject[key] = value
+	}
+	allTypes := []interface{}{CredentialType}
+	for _, t := range types {
+		if t != CredentialType {
+			allTypes = append(allTypes, t)
+		}
+	}
+	raw := map[string]interface{}{
+		"type":              allTypes,
+		"issuer":            issuer,
+		"issuanceDate":      issued.UTC().Format(time.RFC3339),
+		"credentialSubject": credentialSubject,
+	}
+	if id != "" {
+		raw["id"] = id
+	}
+	if !expires.IsZero() {
+		raw["expirationDate"] = expires.UTC().Format(time.RFC3339)
+	}
+
+	// Round-trip through JSON so the credential is exactly as it will be parsed
+	return NewCredential(mustCompactJSON(raw))
+}
+
+// HasType checks if the credential is of the given type
+func (cred *Credential) HasType(credentialType string) bool {
+	for _, t := range cred.Types {
+		if t == credentialType {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign adds a proof made by the issuer. verificationMethod is the ID of the key in the issuer's DID document.
+func (cred *Credential) Sign(key DIDPrivateKey, verificationMethod string) error {
+	return signJSON(cred.raw, key, verificationMethod, nil)
+}
+
+// Verify checks the credential's proof against the issuer's DID document, and that it is valid at the given time
+func (cred *Credential) Verify(resolver DIDResolver, at time.Time) error {
+	if !cred.IssuanceDate.IsZero() && at.Before(cred.IssuanceDate) {
+		return ErrCredentialNotYetValid
+	}
+	if !cred.ExpirationDate.IsZero() && !at.Before(cred.ExpirationDate) {
+		return ErrCredentialExpired
+	}
+	err := verifyJSON(cred.raw, resolver, cred.Issuer, false, at)
+	if err != nil {
+		return errors.Wrap(err, ErrCredentialProof)
+	}
+	return nil
+}
+
+// Implements Stringer. Returns compact JSON
+func (cred Credential) String() string {
+	return string(mustCompactJSON(cred.raw))
+}
+
+// Presentation is a verifiable presentation, in which a holder presents their credentials.
+// The proof's realm is the election-id and its nonce is the hex encoded request-id of the SignatureRequest
+// carrying the presentation, so that a presentation cannot be replayed in another request.
+type Presentation struct {
+	Holder      string
+	Credentials []*Credential
+	Realm       string
+	Nonce       string
+	raw         map[string]interface{}
+}
+
+// NewPresentation parses a JSON verifiable presentation. The proof is not verified. See Verify.
+func NewPresentation(rawPresentation []byte) (*Presentation, error) {
+	raw, err := decodeJSONObject(rawPresentation)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrPresentationInvalid)
+	}
+
+	p := &Presentation{raw: raw}
+	p.Holder, _ = raw["holder"].(string)
+	if !ValidDIDString(p.Holder) {
+		return nil, errors.Wraps(ErrPresentationInvalid, "Invalid holder")
+	}
+	found := false
+	for _, t := range jsonStrings(raw["type"]) {
+		found = found || t == PresentationType
+	}
+	if !found {
+		return nil, errors.Wraps(ErrPresentationInvalid, "Missing type "+PresentationType)
+	}
+	rawCredentials, _ := raw["verifiableCredential"].([]interface{})
+	for _, rawCredential := range rawCredentials {
+		credential, ok := rawCredential.(map[string]interface{})
+		if !ok {
+			return nil, ErrPresentationInvalid
+		}
+		cred, err := newCredentialFromJSON(credential)
+		if err != nil {
+			return nil, errors.Wrap(err, ErrPresentationInvalid)
+		}
+		p.Credentials = append(p.Credentials, cred)
+	}
+	if proof, ok := raw["proof"].(map[string]interface{}); ok {
+		p.Realm, _ = proof["realm"].(string)
+		p.Nonce, _ = proof["nonce"].(string)
+	}
+	return p, nil
+}
+
+// CreatePresentation creates a presentation of the credentials for the given election and request-id, signed by the holder.
+// verificationMethod is the ID of the holder's key, or just the holder's DID to have any authentication key match.
+func CreatePresentation(holder string, credentials []*Credential, electionID string, requestID []byte, key DIDPrivateKey, verificationMethod string) (*Presentation, error) {
+	rawCredentials := make([]interface{}, len(credentials))
+	for i, cred := range credentials {
+		rawCredentials[i] = cred.raw
+	}
+	raw := map[string]interface{}{
+		"type":                 []interface{}{PresentationType},
+		"holder":               holder,
+		"verifiableCredential": rawCredentials,
+	}
+	err := signJSON(raw, key, verificationMethod, map[string]interface{}{
+		"realm": electionID,
+		"nonce": hex.EncodeToString(requestID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewPresentation(mustCompactJSON(raw))
+}
+
+// Verify checks the holder's proof, and that the presentation was made for the given election and request-id.
+// The credentials are not checked. See CredentialRequirements.Check.
+func (p *Presentation) Verify(resolver DIDResolver, electionID string, requestID []byte, at time.Time) error {
+	if p.Realm != electionID || p.Nonce != hex.EncodeToString(requestID) {
+		return ErrPresentationRealm
+	}
+	err := verifyJSON(p.raw, resolver, p.Holder, true, at)
+	if err != nil {
+		return errors.Wrap(err, ErrPresentationProof)
+	}
+	return nil
+}
+
+// Implements Stringer. Returns compact JSON, which never contains a line break
+func (p Presentation) String() string {
+	return string(mustCompactJSON(p.raw))
+}
+
+// CredentialRequirements are the credentials an election requires voters to present.
+// For each type, the voter must present a valid credential of that type from one of the issuers.
+type CredentialRequirements struct {
+	Types   []string
+	Issuers []string
+}
+
+// CredentialRequirements gets the credential requirements from the election's credential-types and credential-issuers tags
+func (election *Election) CredentialRequirements() CredentialRequirements {
+	tags := election.TagSet.Map()
+	return CredentialRequirements{
+		Types:   splitTagList(tags[ElectionCredentialTypesTag]),
+		Issuers: splitTagList(tags[ElectionCredentialIssuersTag]),
+	}
+}
+
+// Required checks if voters must present credentials at all
+func (req CredentialRequirements) Required() bool {
+	return len(req.Types) != 0
+}
+
+// Check verifies that a presentation meets the requirements. The presentation must have been verified already.
+// Each credential's proof, validity period, subject, issuer and revocation status is checked. revocations may be nil.
+func (req CredentialRequirements) Check(p *Presentation, resolver DIDResolver, revocations RevocationSource, at time.Time) error {
+	for _, credentialType := range req.Types {
+		if _, err := req.Find(p, credentialType, resolver, revocations, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Find gets the first credential of the given type in the presentation that meets the requirements, checking it as Check does
+func (req CredentialRequirements) Find(p *Presentation, credentialType string, resolver DIDResolver, revocations RevocationSource, at time.Time) (*Credential, error) {
+	var lastErr error = ErrCredentialMissing
+	for _, cred := range p.Credentials {
+		if !cred.HasType(credentialType) {
+			continue
+		}
+		if lastErr = req.checkCredential(cred, p.Holder, resolver, revocations, at); lastErr == nil {
+			return cred, nil
+		}
+	}
+	return nil, errors.Wraps(lastErr, "Credential type "+credentialType)
+}
+
+func (req CredentialRequirements) checkCredential(cred *Credential, holder string, resolver DIDResolver, revocations RevocationSource, at time.Time) error {
+	if cred.Subject != holder {
+		return ErrCredentialWrongSubject
+	}
+	trusted := false
+	for _, issuer := range req.Issuers {
+		trusted = trusted || issuer == cred.Issuer
+	}
+	if !trusted {
+		return ErrCredentialUntrustedIssuer
+	}
+	if err := cred.Verify(resolver, at); err != nil {
+		return err
+	}
+	if revocations != nil {
+		if cred.ID == "" {
+			return ErrCredentialNoID
+		}
+		revoked, err := revocations.IsRevoked(cred.ID)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrCredentialRevoked
+		}
+	}
+	return nil
+}
+
+// A RevocationSource reports whether credentials have been revoked by their issuer
+type RevocationSource interface {
+	IsRevoked(credentialID string) (bool, error)
+}
+
+// FileRevocationList is a RevocationSource backed by a file listing one revoked credential id per line.
+// Blank lines and lines starting with # are ignored. The file is read on every check, so it can be updated in place.
+type FileRevocationList struct {
+	Path string
+}
+
+// IsRevoked checks if the credential id is in the file. A missing file revokes nothing.
+func (list *FileRevocationList) IsRevoked(credentialID string) (bool, error) {
+	f, err := os.Open(list.Path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == credentialID {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// signJSON adds a proof to a JSON object. Extra fields are added to the proof before signing.
+func signJSON(raw map[string]interface{}, key DIDPrivateKey, verificationMethod string, extra map[string]interface{}) error {
+	proof := map[string]interface{}{
+		"type":               DIDKeyType,
+		"verificationMethod": verificationMethod,
+	}
+	for k, v := range extra {
+		proof[k] = v
+	}
+	raw["proof"] = proof
+	signature, err := key.SignString(string(canonicalJSON(raw)))
+	if err != nil {
+		delete(raw, "proof")
+		return err
+	}
+	proof["signature"] = hex.EncodeToString(signature)
+	return nil
+}
+
+// verifyJSON checks the proof on a JSON object was made by the DID. If authOnly is set the key must be one of the
+// DID's authentication keys, otherwise any of its keys may be used.
+func verifyJSON(raw map[string]interface{}, resolver DIDResolver, did string, authOnly bool, at time.Time) error {
+	proof, ok := raw["proof"].(map[string]interface{})
+	if !ok {
+		return errors.New("Missing proof")
+	}
+	verificationMethod, _ := proof["verificationMethod"].(string)
+	rawSignature, _ := proof["signature"].(string)
+	signature, err := hex.DecodeString(rawSignature)
+	if err != nil || len(signature) == 0 {
+		return errors.New("Invalid proof signature")
+	}
+	if verificationMethod != did && !strings.HasPrefix(verificationMethod, did+"#") {
+		return errors.New("Proof was not made with a key of " + did)
+	}
+	if resolver == nil {
+		return ErrDIDResolverNotConfig
+	}
+	doc, err := resolver.Resolve(did)
+	if err != nil {
+		return err
+	}
+	if !doc.Expires.IsZero() && at.After(doc.Expires) {
+		return ErrDIDDocumentExpired
+	}
+
+	data := canonicalJSON(raw)
+	for _, key := range doc.PublicKeys {
+		if verificationMethod != did && key.ID != verificationMethod {
+			continue
+		}
+		if authOnly && doc.Authenticates(key.PublicKey.Bytes(), at) != nil {
+			continue
+		}
+		if key.PublicKey.VerifySignature(signature, data) == nil {
+			return nil
+		}
+	}
+	return errors.New("Proof signature is invalid")
+}
+
+// canonicalJSON gets the data a proof signs: compact JSON with sorted keys, without the proof's signature
+func canonicalJSON(raw map[string]interface{}) []byte {
+	unsigned := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		unsigned[k] = v
+	}
+	if proof, ok := raw["proof"].(map[string]interface{}); ok {
+		unsignedProof := make(map[string]interface{}, len(proof))
+		for k, v := range proof {
+			if k != "signature" {
+				unsignedProof[k] = v
+			}
+		}
+		unsigned["proof"] = unsignedProof
+	}
+	return mustCompactJSON(unsigned)
+}
+
+// mustCompactJSON encodes a decoded JSON value. Maps are written with sorted keys, and HTML characters are not escaped.
+func mustCompactJSON(value interface{}) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		// Values decoded from JSON can always be encoded again
+		panic(err)
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n")
+}
+
+// decodeJSONObject decodes a JSON object, keeping numbers exactly as written
+func decodeJSONObject(rawJSON []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(rawJSON))
+	dec.UseNumber()
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, errors.New("Expected a JSON object")
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("Unexpected data after JSON object")
+	}
+	return raw, nil
+}
+
+// jsonStrings gets a JSON string, or array of strings, as a slice
+func jsonStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// jsonTime parses an optional RFC3339 time
+func jsonTime(value interface{}) (time.Time, error) {
+	if value == nil {
+		return time.Time{}, nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, errors.New("Invalid date")
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// splitTagList splits a comma separated tag value
+func splitTagList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,233 @@
+package cryptoballot
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testIssuerDID = "did:elastos:iSsuer1ssuer1ssuer1ssuer1ssuer1"
+
+// newTestCredentialResolver creates a resolver holding the documents of an issuer and a holder
+func newTestCredentialResolver(t *testing.T) (*FileDIDResolver, DIDPrivateKey, DIDPrivateKey, func()) {
+	dir, err := ioutil.TempDir("", "credential")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := NewFileDIDResolver(dir)
+	issuerKey, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerPub, err := issuerKey.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	holderKey, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	holderPub, err := holderKey.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = resolver.Save(NewDIDDocumentForKeys(testIssuerDID, issuerPub)); err != nil {
+		t.Fatal(err)
+	}
+	if err = resolver.Save(NewDIDDocumentForKeys(testDID, holderPub)); err != nil {
+		t.Fatal(err)
+	}
+	return resolver, issuerKey, holderKey, func() { os.RemoveAll(dir) }
+}
+
+func newTestCredential(t *testing.T, issuerKey DIDPrivateKey, id, subject string, issued, expires time.Time) *Credential {
+	cred, err := NewUnsignedCredential(id, testIssuerDID, subject, []string{"MemberCredential"}, map[string]interface{}{"shares": 100}, issued, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cred.Sign(issuerKey, testIssuerDID+"#primary"); err != nil {
+		t.Fatal(err)
+	}
+	return cred
+}
+
+func TestCredential(t *testing.T) {
+	resolver, issuerKey, _, cleanup := newTestCredentialResolver(t)
+	defer cleanup()
+
+	issued := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	expires := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cred := newTestCredential(t, issuerKey, "urn:member:1", testDID, issued, expires)
+
+	// Round trip through JSON
+	cred2, err := NewCredential([]byte(cred.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred2.String() != cred.String() {
+		t.Errorf("Credential did not round trip:\n%s\n%s", cred, cred2)
+	}
+	if cred2.ID != "urn:member:1" || cred2.Issuer != testIssuerDID || cred2.Subject != testDID || !cred2.HasType("MemberCredential") {
+		t.Errorf("Credential parsed incorrectly: %+v", cred2)
+	}
+	if cred2.Claims["shares"].(interface{ String() string }).String() != "100" {
+		t.Errorf("Claim parsed incorrectly: %v", cred2.Claims["shares"])
+	}
+
+	// Key order and whitespace do not matter to the proof
+	raw, err := decodeJSONObject([]byte(cred.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	indented := strings.Replace(string(mustCompactJSON(raw)), ",", ",\n  ", -1)
+	cred3, err := NewCredential([]byte(indented))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range []*Credential{cred, cred2, cred3} {
+		if err := c.Verify(resolver, at); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := cred.Verify(resolver, issued.Add(-time.Second)); err != ErrCredentialNotYetValid {
+		t.Errorf("Expected ErrCredentialNotYetValid, got %v", err)
+	}
+	if err := cred.Verify(resolver, expires); err != ErrCredentialExpired {
+		t.Errorf("Expected ErrCredentialExpired, got %v", err)
+	}
+
+	// Tampering with a claim breaks the proof
+	tampered, err := NewCredential([]byte(strings.Replace(cred.String(), `"shares":100`, `"shares":1000`, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tampered.Verify(resolver, at); err == nil {
+		t.Error("Tampered credential verified")
+	}
+
+	// A credential signed by someone other than the issuer does not verify
+	otherKey, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := newTestCredential(t, otherKey, "urn:member:2", testDID, issued, expires)
+	if err := forged.Verify(resolver, at); err == nil {
+		t.Error("Forged credential verified")
+	}
+}
+
+func TestBadCredential(t *testing.T) {
+	bad := []string{
+		``,
+		`[]`,
+		`{"type":["VerifiableCredential"],"issuer":"nope","credentialSubject":{"id":"` + testDID + `"}}`,
+		`{"type":["Other"],"issuer":"` + testIssuerDID + `","credentialSubject":{"id":"` + testDID + `"}}`,
+		`{"type":["VerifiableCredential"],"issuer":"` + testIssuerDID + `"}`,
+		`{"type":["VerifiableCredential"],"issuer":"` + testIssuerDID + `","credentialSubject":{"id":"` + testDID + `"},"expirationDate":"tomorrow"}`,
+	}
+	for _, raw := range bad {
+		if _, err := NewCredential([]byte(raw)); err == nil {
+			t.Errorf("Bad credential parsed: %s", raw)
+		}
+	}
+}
+
+func TestPresentation(t *testing.T) {
+	resolver, issuerKey, holderKey, cleanup := newTestCredentialResolver(t)
+	defer cleanup()
+
+	at := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	member := newTestCredential(t, issuerKey, "urn:member:1", testDID, at.Add(-time.Hour), at.Add(time.Hour))
+	requestID := DIDRequestID(testDID)
+
+	p, err := CreatePresentation(testDID, []*Credential{member}, "election", requestID, holderKey, testDID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(p.String(), "\n") {
+		t.Error("Presentation contains a line break")
+	}
+	p, err = NewPresentation([]byte(p.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Holder != testDID || len(p.Credentials) != 1 || p.Nonce != hex.EncodeToString(requestID) {
+		t.Errorf("Presentation parsed incorrectly: %+v", p)
+	}
+	if err := p.Verify(resolver, "election", requestID, at); err != nil {
+		t.Error(err)
+	}
+	if err := p.Verify(resolver, "other-election", requestID, at); err != ErrPresentationRealm {
+		t.Errorf("Expected ErrPresentationRealm, got %v", err)
+	}
+
+	// Someone else cannot present the holder's credentials
+	otherKey, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stolen, err := CreatePresentation(testDID, []*Credential{member}, "election", requestID, otherKey, testDID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stolen.Verify(resolver, "election", requestID, at); err == nil {
+		t.Error("Presentation signed with another key verified")
+	}
+
+	// Requirements
+	req := CredentialRequirements{Types: []string{"MemberCredential"}, Issuers: []string{testIssuerDID}}
+	if err := req.Check(p, resolver, nil, at); err != nil {
+		t.Error(err)
+	}
+	if err := req.Check(p, resolver, nil, at.Add(2*time.Hour)); err == nil {
+		t.Error("Expired credential met requirements")
+	}
+	untrusted := CredentialRequirements{Types: []string{"MemberCredential"}, Issuers: []string{"did:elastos:iAnother"}}
+	if err := untrusted.checkCredential(member, testDID, resolver, nil, at); err != ErrCredentialUntrustedIssuer {
+		t.Errorf("Expected ErrCredentialUntrustedIssuer, got %v", err)
+	}
+	if err := req.checkCredential(member, "did:elastos:iAnother", resolver, nil, at); err != ErrCredentialWrongSubject {
+		t.Errorf("Expected ErrCredentialWrongSubject, got %v", err)
+	}
+	shareholder := CredentialRequirements{Types: []string{"MemberCredential", "ShareholderCredential"}, Issuers: []string{testIssuerDID}}
+	if err := shareholder.Check(p, resolver, nil, at); err == nil {
+		t.Error("Presentation without a required type met requirements")
+	}
+
+	// Revocation
+	dir, err := ioutil.TempDir("", "revocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	revocations := &FileRevocationList{Path: filepath.Join(dir, "revoked")}
+	if err := req.checkCredential(member, testDID, resolver, revocations, at); err != nil {
+		t.Error(err)
+	}
+	if err = ioutil.WriteFile(revocations.Path, []byte("# revoked\nurn:member:0\nurn:member:1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := req.checkCredential(member, testDID, resolver, revocations, at); err != ErrCredentialRevoked {
+		t.Errorf("Expected ErrCredentialRevoked, got %v", err)
+	}
+}
+
+func TestElectionCredentialRequirements(t *testing.T) {
+	election := Election{TagSet: TagSet{
+		{Key: []byte(ElectionCredentialTypesTag), Value: []byte("MemberCredential, ShareholderCredential")},
+		{Key: []byte(ElectionCredentialIssuersTag), Value: []byte(testIssuerDID)},
+	}}
+	req := election.CredentialRequirements()
+	if !req.Required() || len(req.Types) != 2 || req.Types[1] != "ShareholderCredential" || len(req.Issuers) != 1 {
+		t.Errorf("Credential requirements parsed incorrectly: %+v", req)
+	}
+	if (&Election{}).CredentialRequirements().Required() {
+		t.Error("Election without tags requires credentials")
+	}
+}
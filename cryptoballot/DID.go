@@ -0,0 +1,320 @@
+package cryptoballot
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/phayes/errors"
+)
+
+const (
+	DIDPrefix      = "did:elastos:"
+	MaxDIDSize     = 128
+	DIDKeyType     = "ECDSAsecp256r1"
+	ElectionDIDTag = "admin-did" // Election tag naming the DID of the admin that created the election
+)
+
+var (
+	ValidDID = regexp.MustCompile(`^did:elastos:[1-9A-HJ-NP-Za-km-z]+$`) // The method specific id is base58 encoded
+
+	ErrDIDInvalid           = errors.New("Invalid DID. Must be of the form did:elastos:<base58 id>")
+	ErrDIDNotFound          = errors.New("Could not resolve DID. No DID document found")
+	ErrDIDDocumentInvalid   = errors.New("Cannot parse DID document. Invalid format")
+	ErrDIDDocumentMismatch  = errors.New("DID document is for a different DID")
+	ErrDIDDocumentExpired   = errors.New("DID document has expired")
+	ErrDIDKeyNotAuthorized  = errors.New("Public key is not an authentication key of the DID")
+	ErrDIDResolverNotConfig = errors.New("DID resolution is not configured")
+)
+
+// A DIDResolver maps a DID to its DID document. Keys are rotated by updating the document, so the DID stays the same.
+type DIDResolver interface {
+	// Resolve gets the current DID document. ErrDIDNotFound is returned if the DID does not exist.
+	Resolve(did string) (*DIDDocument, error)
+}
+
+// DIDDocument is an Elastos DID document. Only the parts needed to authenticate the DID are kept.
+type DIDDocument struct {
+	ID             string
+	PublicKeys     []DIDDocumentKey
+	Authentication []string  // IDs of the keys that may sign on behalf of the DID
+	Expires        time.Time // Zero if the document does not expire
+}
+
+// DIDDocumentKey is a public key listed in a DID document
+type DIDDocumentKey struct {
+	ID         string // Full key ID, for example did:elastos:icJ4z2DULrHEzYSvjKNJpKyhqFDxvYV7pN#primary
+	Controller string
+	PublicKey  DIDPublicKey
+}
+
+// didDocumentJSON is the JSON form of a DID document. Authentication entries may be key IDs, or keys embedded in place.
+type didDocumentJSON struct {
+	ID             string            `json:"id"`
+	PublicKey      []didKeyJSON      `json:"publicKey"`
+	Authentication []json.RawMessage `json:"authentication"`
+	Expires        string            `json:"expires,omitempty"`
+	Proof          json.RawMessage   `json:"proof,omitempty"`
+}
+
+type didKeyJSON struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	Controller      string `json:"controller,omitempty"`
+	PublicKeyBase58 string `json:"publicKeyBase58"`
+}
+
+// NewDIDDocument parses a JSON DID document.
+// The document's proof is not checked. Resolvers are responsible for only returning documents they trust.
+func NewDIDDocument(rawDocument []byte) (*DIDDocument, error) {
+	var raw didDocumentJSON
+	err := json.Unmarshal(rawDocument, &raw)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrDIDDocumentInvalid)
+	}
+	if !ValidDIDString(raw.ID) {
+		return nil, errors.Wrap(ErrDIDInvalid, ErrDIDDocumentInvalid)
+	}
+
+	doc := &DIDDocument{ID: raw.ID}
+	if raw.Expires != "" {
+		doc.Expires, err = time.Parse(time.RFC3339, raw.Expires)
+		if err != nil {
+			return nil, errors.Wrap(err, ErrDIDDocumentInvalid)
+		}
+	}
+	for _, rawKey := range raw.PublicKey {
+		key, err := doc.newKey(rawKey)
+		if err != nil {
+			return nil, err
+		}
+		doc.PublicKeys = append(doc.PublicKeys, key)
+	}
+	for _, rawAuth := range raw.Authentication {
+		var keyID string
+		if json.Unmarshal(rawAuth, &keyID) != nil {
+			var rawKey didKeyJSON
+			if err := json.Unmarshal(rawAuth, &rawKey); err != nil {
+				return nil, errors.Wrap(err, ErrDIDDocumentInvalid)
+			}
+			key, err := doc.newKey(rawKey)
+			if err != nil {
+				return nil, err
+			}
+			doc.PublicKeys = append(doc.PublicKeys, key)
+			keyID = key.ID
+		}
+		doc.Authentication = append(doc.Authentication, doc.fullKeyID(keyID))
+	}
+	return doc, nil
+}
+
+func (doc *DIDDocument) newKey(rawKey didKeyJSON) (DIDDocumentKey, error) {
+	if rawKey.Type != "" && rawKey.Type != DIDKeyType {
+		return DIDDocumentKey{}, errors.Wraps(ErrDIDDocumentInvalid, "Unsupported key type "+rawKey.Type)
+	}
+	rawPublicKey, err := base58Decode(rawKey.PublicKeyBase58)
+	if err != nil {
+		return DIDDocumentKey{}, errors.Wrap(err, ErrDIDDocumentInvalid)
+	}
+	publicKey, err := NewDIDPublicKey(rawPublicKey)
+	if err != nil {
+		return DIDDocumentKey{}, errors.Wrap(err, ErrDIDDocumentInvalid)
+	}
+	key := DIDDocumentKey{
+		ID:         doc.fullKeyID(rawKey.ID),
+		Controller: rawKey.Controller,
+		PublicKey:  publicKey,
+	}
+	if key.Controller == "" {
+		key.Controller = doc.ID
+	}
+	return key, nil
+}
+
+// fullKeyID expands a relative key ID such as "#primary"
+func (doc *DIDDocument) fullKeyID(keyID string) string {
+	if strings.HasPrefix(keyID, "#") {
+		return doc.ID + keyID
+	}
+	return keyID
+}
+
+// Authenticates checks that the public key is one of the document's authentication keys, and that the document
+// has not expired at the given time.
+func (doc *DIDDocument) Authenticates(publicKey []byte, at time.Time) error {
+	if !doc.Expires.IsZero() && at.After(doc.Expires) {
+		return ErrDIDDocumentExpired
+	}
+	for _, keyID := range doc.Authentication {
+		for _, key := range doc.PublicKeys {
+			if key.ID == keyID && bytes.Equal(key.PublicKey.Bytes(), publicKey) {
+				return nil
+			}
+		}
+	}
+	return ErrDIDKeyNotAuthorized
+}
+
+// String returns the document as JSON. Embedded authentication keys are written out as references.
+func (doc DIDDocument) String() string {
+	raw := didDocumentJSON{ID: doc.ID}
+	for _, key := range doc.PublicKeys {
+		raw.PublicKey = append(raw.PublicKey, didKeyJSON{
+			ID:              key.ID,
+			Type:            DIDKeyType,
+			Controller:      key.Controller,
+			PublicKeyBase58: base58Encode(key.PublicKey.Bytes()),
+		})
+	}
+	for _, keyID := range doc.Authentication {
+		rawKeyID, _ := json.Marshal(keyID)
+		raw.Authentication = append(raw.Authentication, rawKeyID)
+	}
+	if !doc.Expires.IsZero() {
+		raw.Expires = doc.Expires.UTC().Format(time.RFC3339)
+	}
+	out, _ := json.MarshalIndent(raw, "", "  ")
+	return string(out)
+}
+
+// NewDIDDocumentForKeys creates a document for the DID, with each of the keys as an authentication key.
+// The first key is named #primary and the rest #key-2, #key-3 and so on.
+func NewDIDDocumentForKeys(did string, publicKeys ...DIDPublicKey) *DIDDocument {
+	doc := &DIDDocument{ID: did}
+	for i, publicKey := range publicKeys {
+		keyID := did + "#primary"
+		if i != 0 {
+			keyID = did + "#key-" + strconv.Itoa(i+1)
+		}
+		doc.PublicKeys = append(doc.PublicKeys, DIDDocumentKey{ID: keyID, Controller: did, PublicKey: publicKey})
+		doc.Authentication = append(doc.Authentication, keyID)
+	}
+	return doc
+}
+
+// ValidDIDString checks that a DID is well formed
+func ValidDIDString(did string) bool {
+	return len(did) <= MaxDIDSize && ValidDID.MatchString(did)
+}
+
+// DIDRequestID gets the ID used for the signature requests of a voter identified by a DID. It is the double SHA256
+// of the DID, so that a voter keeps the same request ID when they rotate their keys.
+func DIDRequestID(did string) []byte {
+	requestID := common.Sha256D([]byte(did))
+	return requestID[:]
+}
+
+// AuthenticateDID resolves the DID and checks that the public key may currently sign on its behalf
+func AuthenticateDID(resolver DIDResolver, did string, publicKey []byte, at time.Time) error {
+	if resolver == nil {
+		return ErrDIDResolverNotConfig
+	}
+	doc, err := resolver.Resolve(did)
+	if err != nil {
+		return err
+	}
+	return doc.Authenticates(publicKey, at)
+}
+
+// FileDIDResolver resolves DIDs from a directory of JSON DID documents. It is intended for testing and for
+// elections that are run without access to the DID sidechain.
+//
+// The document for did:elastos:<id> is kept in <id>.json. Documents are read every time a DID is resolved,
+// so keys can be rotated by replacing the file.
+type FileDIDResolver struct {
+	Dir string
+}
+
+// NewFileDIDResolver creates a resolver for the documents in dir
+func NewFileDIDResolver(dir string) *FileDIDResolver {
+	return &FileDIDResolver{Dir: dir}
+}
+
+// Resolve reads and parses the document for the DID
+func (resolver *FileDIDResolver) Resolve(did string) (*DIDDocument, error) {
+	if !ValidDIDString(did) {
+		return nil, ErrDIDInvalid
+	}
+	rawDocument, err := ioutil.ReadFile(resolver.path(did))
+	if os.IsNotExist(err) {
+		return nil, ErrDIDNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc, err := NewDIDDocument(rawDocument)
+	if err != nil {
+		return nil, err
+	}
+	if doc.ID != did {
+		return nil, ErrDIDDocumentMismatch
+	}
+	return doc, nil
+}
+
+// Save writes a document to the directory, replacing any previous document for the same DID
+func (resolver *FileDIDResolver) Save(doc *DIDDocument) error {
+	if !ValidDIDString(doc.ID) {
+		return ErrDIDInvalid
+	}
+	tmp := resolver.path(doc.ID) + ".tmp"
+	err := ioutil.WriteFile(tmp, []byte(doc.String()), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, resolver.path(doc.ID))
+}
+
+func (resolver *FileDIDResolver) path(did string) string {
+	return filepath.Join(resolver.Dir, strings.TrimPrefix(did, DIDPrefix)+".json")
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58Encode encodes bytes with the bitcoin base58 alphabet, as used for keys in DID documents
+func base58Encode(input []byte) string {
+	n := new(big.Int).SetBytes(input)
+	radix, mod := big.NewInt(58), new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range input {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+// base58Decode decodes a bitcoin base58 string
+func base58Decode(input string) ([]byte, error) {
+	n, radix := new(big.Int), big.NewInt(58)
+	zeros := 0
+	for zeros < len(input) && input[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	for _, c := range input {
+		digit := strings.IndexRune(base58Alphabet, c)
+		if digit < 0 {
+			return nil, errors.New("Invalid base58 character")
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(digit)))
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
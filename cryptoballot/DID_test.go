@@ -0,0 +1,223 @@
+package cryptoballot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testDID = "did:elastos:icJ4z2DULrHEzYSvjKNJpKyhqFDxvYV7pN"
+
+func TestDIDDocument(t *testing.T) {
+	primaryPriv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary, err := primaryPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupPriv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup, err := backupPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPriv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := otherPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A document in the form published on the DID sidechain, with a relative key reference and an embedded key
+	rawDocument := []byte(`{
+  "id": "` + testDID + `",
+  "publicKey": [{
+    "id": "#primary",
+    "type": "ECDSAsecp256r1",
+    "controller": "` + testDID + `",
+    "publicKeyBase58": "` + base58Encode(primary.Bytes()) + `"
+  }],
+  "authentication": [
+    "#primary",
+    {
+      "id": "` + testDID + `#backup",
+      "type": "ECDSAsecp256r1",
+      "publicKeyBase58": "` + base58Encode(backup.Bytes()) + `"
+    }
+  ],
+  "expires": "2030-01-01T00:00:00Z",
+  "proof": {"type": "ECDSAsecp256r1", "signatureValue": "ignored"}
+}`)
+
+	doc, err := NewDIDDocument(rawDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.ID != testDID || len(doc.PublicKeys) != 2 || len(doc.Authentication) != 2 {
+		t.Fatalf("DID document parsed incorrectly: %+v", doc)
+	}
+	if doc.Authentication[0] != testDID+"#primary" {
+		t.Errorf("Relative key ID not expanded: %s", doc.Authentication[0])
+	}
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := doc.Authenticates(primary.Bytes(), now); err != nil {
+		t.Error(err)
+	}
+	if err := doc.Authenticates(backup.Bytes(), now); err != nil {
+		t.Error(err)
+	}
+	if err := doc.Authenticates(other.Bytes(), now); err != ErrDIDKeyNotAuthorized {
+		t.Errorf("Expected ErrDIDKeyNotAuthorized, got %v", err)
+	}
+	if err := doc.Authenticates(primary.Bytes(), now.AddDate(10, 0, 0)); err != ErrDIDDocumentExpired {
+		t.Errorf("Expected ErrDIDDocumentExpired, got %v", err)
+	}
+
+	// Round-trip
+	again, err := NewDIDDocument([]byte(doc.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != doc.String() {
+		t.Error("DID document failed string round-trip")
+	}
+}
+
+func TestBadDIDDocument(t *testing.T) {
+	priv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := priv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := NewDIDDocumentForKeys(testDID, pub).String()
+
+	bad := []string{
+		"not json",
+		`{"id": "did:example:123"}`,
+		`{"id": "did:elastos:0OIl"}`,
+		`{"id": "` + testDID + `", "publicKey": [{"id": "#primary", "type": "RSA", "publicKeyBase58": "` + base58Encode(pub.Bytes()) + `"}]}`,
+		`{"id": "` + testDID + `", "publicKey": [{"id": "#primary", "publicKeyBase58": "0OIl"}]}`,
+		`{"id": "` + testDID + `", "publicKey": [{"id": "#primary", "publicKeyBase58": "2g"}]}`,
+		`{"id": "` + testDID + `", "expires": "tomorrow"}`,
+	}
+	for _, rawDocument := range bad {
+		_, err := NewDIDDocument([]byte(rawDocument))
+		if err == nil {
+			t.Errorf("Invalid DID document produced no error: %s", rawDocument)
+		}
+	}
+	_, err = NewDIDDocument([]byte(good))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFileDIDResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "didresolver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	resolver := NewFileDIDResolver(dir)
+
+	_, err = resolver.Resolve(testDID)
+	if err != ErrDIDNotFound {
+		t.Errorf("Expected ErrDIDNotFound, got %v", err)
+	}
+	_, err = resolver.Resolve("did:example:123")
+	if err != ErrDIDInvalid {
+		t.Errorf("Expected ErrDIDInvalid, got %v", err)
+	}
+
+	// Rotating the key changes the document but not the DID
+	oldPriv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey, err := oldPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPriv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := newPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = resolver.Save(NewDIDDocumentForKeys(testDID, oldKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = AuthenticateDID(resolver, testDID, oldKey.Bytes(), time.Now()); err != nil {
+		t.Error(err)
+	}
+	err = resolver.Save(NewDIDDocumentForKeys(testDID, newKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = AuthenticateDID(resolver, testDID, oldKey.Bytes(), time.Now()); err != ErrDIDKeyNotAuthorized {
+		t.Errorf("Rotated key still authenticates: %v", err)
+	}
+	if err = AuthenticateDID(resolver, testDID, newKey.Bytes(), time.Now()); err != nil {
+		t.Error(err)
+	}
+
+	// A document stored under the wrong name is rejected
+	otherDID := "did:elastos:iXyz"
+	rawDocument, err := ioutil.ReadFile(filepath.Join(dir, "icJ4z2DULrHEzYSvjKNJpKyhqFDxvYV7pN.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "iXyz.json"), rawDocument, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = resolver.Resolve(otherDID)
+	if err != ErrDIDDocumentMismatch {
+		t.Errorf("Expected ErrDIDDocumentMismatch, got %v", err)
+	}
+
+	if err = AuthenticateDID(nil, testDID, newKey.Bytes(), time.Now()); err != ErrDIDResolverNotConfig {
+		t.Errorf("Expected ErrDIDResolverNotConfig, got %v", err)
+	}
+}
+
+func TestBase58(t *testing.T) {
+	vectors := map[string][]byte{
+		"":                {},
+		"StV1DL6CwTryKyV": []byte("hello world"),
+		"112":             {0, 0, 1},
+		"1111":            {0, 0, 0, 0},
+	}
+	for encoded, decoded := range vectors {
+		if base58Encode(decoded) != encoded {
+			t.Errorf("base58Encode(%x) = %s, expected %s", decoded, base58Encode(decoded), encoded)
+		}
+		out, err := base58Decode(encoded)
+		if err != nil {
+			t.Error(err)
+		}
+		if !bytes.Equal(out, decoded) {
+			t.Errorf("base58Decode(%s) = %x, expected %x", encoded, out, decoded)
+		}
+	}
+	_, err := base58Decode("0OIl")
+	if err == nil {
+		t.Error("Invalid base58 produced no error")
+	}
+}
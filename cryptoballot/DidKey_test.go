@@ -1,2 +1,123 @@
 package cryptoballot
 
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+func TestDIDKeyGenerate(t *testing.T) {
+	priv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(priv) != DIDPrivateKeySize {
+		t.Fatalf("Expected a %d byte key, got %d bytes", DIDPrivateKeySize, len(priv))
+	}
+	pub, err := priv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sign and verify
+	sig, err := priv.SignString("PUT /election/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = pub.VerifySignature(sig, []byte("PUT /election/test"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	// The request ID is the double SHA256 of the compressed public key
+	requestID := common.Sha256D(pub.Bytes())
+	if !bytes.Equal(pub.RequestID(), requestID[:]) {
+		t.Error("Bad request ID")
+	}
+}
+
+func TestDIDKeyEncoding(t *testing.T) {
+	priv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromHex, err := NewDIDPrivateKeyFromHex(priv.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fromHex, priv) {
+		t.Error("Hex encoding does not round-trip")
+	}
+
+	PEMKey, err := priv.PEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromPEM, err := NewDIDPrivateKeyFromPEM(PEMKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fromPEM, priv) {
+		t.Error("PEM encoding does not round-trip")
+	}
+
+	pub, err := priv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := NewDIDPublicKey(pub.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Hex() != pub.Hex() {
+		t.Error("Public key encoding does not round-trip")
+	}
+	PEMPub, err := pub.PEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromPEMPub, err := NewDIDPublicKeyFromPEM(PEMPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fromPEMPub.Hex() != pub.Hex() {
+		t.Error("Public key PEM encoding does not round-trip")
+	}
+}
+
+func TestBadDIDKey(t *testing.T) {
+	_, err := NewDIDPrivateKeyFromHex("zz")
+	if err == nil {
+		t.Error("Invalid hex key produced no error")
+	}
+	_, err = NewDIDPrivateKeyFromHex("CC6FA0F0E191AD47A430FE04411C079F07D5C1EE47C3AA55F0E0204C8FE36D")
+	if err == nil {
+		t.Error("Short key produced no error")
+	}
+	_, err = NewDIDPrivateKey(make([]byte, DIDPrivateKeySize))
+	if err == nil {
+		t.Error("Zero key produced no error")
+	}
+	_, err = NewDIDPrivateKeyFromPEM([]byte("not a pem"))
+	if err == nil {
+		t.Error("Invalid PEM produced no error")
+	}
+	priv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	PEMKey, err := priv.PEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewDIDPublicKeyFromPEM(PEMKey)
+	if err == nil {
+		t.Error("Private key PEM parsed as a public key")
+	}
+	_, err = NewDIDPublicKey([]byte{2, 3, 4})
+	if err == nil {
+		t.Error("Invalid public key produced no error")
+	}
+}
@@ -3,41 +3,198 @@ package cryptoballot
 import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
-	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
 	"math/big"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"github.com/phayes/errors"
+)
+
+const (
+	DIDPrivateKeySize = 32 // Size of a DID private key in bytes
+
+	didPrivateKeyPEMType = "EC PRIVATE KEY"
+	didPublicKeyPEMType  = "PUBLIC KEY"
+)
+
+var (
+	ErrDIDKeyInvalid       = errors.New("Invalid DID private key. Must be a 32 byte secp256r1 private key")
+	ErrDIDKeyInvalidHex    = errors.New("Invalid DID private key. Could not decode hex")
+	ErrDIDKeyInvalidPEM    = errors.New("Invalid DID private key. Could not parse PEM")
+	ErrDIDPublicKeyInvalid = errors.New("Invalid DID public key")
 )
 
+// DIDPrivateKey is a secp256r1 (P-256) private key, as used by Elastos DIDs
 type DIDPrivateKey []byte
 
 type DIDPublicKey struct {
 	crypto.PublicKey
 }
 
-//SignString signing string using did private key
-func (didPrivateKey DIDPrivateKey) SignString(str string) ([]byte,error){
-	signature , err := crypto.Sign(didPrivateKey,[]byte(str))
+// GenerateDIDPrivateKey generates a new random DID private key
+func GenerateDIDPrivateKey() (DIDPrivateKey, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
-		return []byte{},err
+		return nil, err
 	}
-	return signature,nil
+	key := make([]byte, DIDPrivateKeySize)
+	d := priv.D.Bytes()
+	copy(key[DIDPrivateKeySize-len(d):], d)
+	return DIDPrivateKey(key), nil
 }
 
-//VerifyString verify String using did public key
-func (didPublicKey DIDPublicKey) VerifySignature(signature []byte,data []byte) error{
-	return crypto.Verify(didPublicKey.PublicKey,data,signature)
+// NewDIDPrivateKeyFromHex parses a hex encoded DID private key
+func NewDIDPrivateKeyFromHex(hexKey string) (DIDPrivateKey, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrDIDKeyInvalidHex)
+	}
+	return NewDIDPrivateKey(key)
+}
+
+// NewDIDPrivateKeyFromPEM parses a DID private key from a PEM encoded "EC PRIVATE KEY" block
+func NewDIDPrivateKeyFromPEM(PEMKey []byte) (DIDPrivateKey, error) {
+	block, _ := pem.Decode(PEMKey)
+	if block == nil || block.Type != didPrivateKeyPEMType {
+		return nil, ErrDIDKeyInvalidPEM
+	}
+	priv, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrDIDKeyInvalidPEM)
+	}
+	if priv.Curve != elliptic.P256() {
+		return nil, ErrDIDKeyInvalid
+	}
+	key := make([]byte, DIDPrivateKeySize)
+	d := priv.D.Bytes()
+	copy(key[DIDPrivateKeySize-len(d):], d)
+	return NewDIDPrivateKey(key)
+}
+
+// NewDIDPrivateKey checks that the given bytes are a valid DID private key
+func NewDIDPrivateKey(key []byte) (DIDPrivateKey, error) {
+	if len(key) != DIDPrivateKeySize {
+		return nil, ErrDIDKeyInvalid
+	}
+	k := new(big.Int).SetBytes(key)
+	if k.Sign() == 0 || k.Cmp(elliptic.P256().Params().N) >= 0 {
+		return nil, ErrDIDKeyInvalid
+	}
+	return DIDPrivateKey(key), nil
+}
+
+// NewDIDPublicKey parses a compressed DID public key
+func NewDIDPublicKey(key []byte) (DIDPublicKey, error) {
+	// crypto.DecodePoint panics on some malformed input, so check the length and prefix first
+	if len(key) != crypto.COMPRESSEDLEN || (key[0] != 0x02 && key[0] != 0x03) {
+		return DIDPublicKey{}, ErrDIDPublicKeyInvalid
+	}
+	publicKey, err := crypto.DecodePoint(key)
+	if err != nil {
+		return DIDPublicKey{}, errors.Wrap(err, ErrDIDPublicKeyInvalid)
+	}
+	return DIDPublicKey{PublicKey: *publicKey}, nil
+}
+
+// NewDIDPublicKeyFromPEM parses a DID public key from a PEM encoded "PUBLIC KEY" block, as written by DIDPublicKey.PEM
+func NewDIDPublicKeyFromPEM(PEMKey []byte) (DIDPublicKey, error) {
+	block, _ := pem.Decode(PEMKey)
+	if block == nil || block.Type != didPublicKeyPEMType {
+		return DIDPublicKey{}, ErrDIDPublicKeyInvalid
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return DIDPublicKey{}, errors.Wrap(err, ErrDIDPublicKeyInvalid)
+	}
+	ecdsaPub, ok := pub.(*ecdsa.PublicKey)
+	if !ok || ecdsaPub.Curve != elliptic.P256() {
+		return DIDPublicKey{}, ErrDIDPublicKeyInvalid
+	}
+	publicKey := crypto.PublicKey{X: ecdsaPub.X, Y: ecdsaPub.Y}
+	return DIDPublicKey{PublicKey: publicKey}, nil
 }
 
-//Bytes get public key in bytes
+// Hex gets the private key hex encoded
+func (didPrivateKey DIDPrivateKey) Hex() string {
+	return hex.EncodeToString(didPrivateKey)
+}
+
+// PEM gets the private key as a PEM encoded "EC PRIVATE KEY" block
+func (didPrivateKey DIDPrivateKey) PEM() ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(didPrivateKey.ecdsaKey())
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: didPrivateKeyPEMType, Bytes: der}), nil
+}
+
+// SignString signing string using did private key
+// The signature is the same 64 byte r || s format produced by crypto.Sign, but the public half of
+// the key is filled in before signing since newer versions of crypto/ecdsa require it.
+func (didPrivateKey DIDPrivateKey) SignString(str string) ([]byte, error) {
+	digest := sha256.Sum256([]byte(str))
+	r, s, err := ecdsa.Sign(rand.Reader, didPrivateKey.ecdsaKey(), digest[:])
+	if err != nil {
+		return []byte{}, err
+	}
+
+	signature := make([]byte, crypto.SignatureLength)
+	rBytes, sBytes := r.Bytes(), s.Bytes()
+	copy(signature[crypto.SignerLength-len(rBytes):], rBytes)
+	copy(signature[crypto.SignatureLength-len(sBytes):], sBytes)
+	return signature, nil
+}
+
+// VerifyString verify String using did public key
+func (didPublicKey DIDPublicKey) VerifySignature(signature []byte, data []byte) error {
+	return crypto.Verify(didPublicKey.PublicKey, data, signature)
+}
+
+// Hex gets the compressed public key hex encoded. This is the form used in the X-Public-Key header
+func (didPublicKey DIDPublicKey) Hex() string {
+	return hex.EncodeToString(didPublicKey.Bytes())
+}
+
+// PEM gets the public key as a PEM encoded "PUBLIC KEY" block
+func (didPublicKey DIDPublicKey) PEM() ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(&ecdsa.PublicKey{Curve: elliptic.P256(), X: didPublicKey.X, Y: didPublicKey.Y})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: didPublicKeyPEMType, Bytes: der}), nil
+}
+
+// RequestID gets the ID used for a voter's signature requests. It is the double SHA256 of the compressed public key
+func (didPublicKey DIDPublicKey) RequestID() []byte {
+	requestID := common.Sha256D(didPublicKey.Bytes())
+	return requestID[:]
+}
+
+// Bytes get public key in bytes
 func (didPublicKey DIDPublicKey) Bytes() []byte {
-	pub , err := didPublicKey.EncodePoint(true)
+	pub, err := didPublicKey.EncodePoint(true)
 	if err != nil {
 		return []byte{}
 	}
 	return pub
 }
 
-//GetPublicKeyFromPrivateKey get did public key from did private key
-func (didPrivateKey DIDPrivateKey) GetPublicKeyFromPrivateKey() (DIDPublicKey,error){
+// GetPublicKeyFromPrivateKey get did public key from did private key
+func (didPrivateKey DIDPrivateKey) GetPublicKeyFromPrivateKey() (DIDPublicKey, error) {
+	priv := didPrivateKey.ecdsaKey()
+	publicKey := new(crypto.PublicKey)
+	publicKey.X = new(big.Int).Set(priv.PublicKey.X)
+	publicKey.Y = new(big.Int).Set(priv.PublicKey.Y)
+	return DIDPublicKey{*publicKey}, nil
+}
+
+// ecdsaKey gets the private key, with it's public half, as an ecdsa.PrivateKey
+func (didPrivateKey DIDPrivateKey) ecdsaKey() *ecdsa.PrivateKey {
 	priv := new(ecdsa.PrivateKey)
 	c := elliptic.P256()
 	priv.PublicKey.Curve = c
@@ -45,8 +202,5 @@ func (didPrivateKey DIDPrivateKey) GetPublicKeyFromPrivateKey() (DIDPublicKey,er
 	k.SetBytes(didPrivateKey)
 	priv.D = k
 	priv.PublicKey.X, priv.PublicKey.Y = c.ScalarBaseMult(k.Bytes())
-	publicKey := new(crypto.PublicKey)
-	publicKey.X = new(big.Int).Set(priv.PublicKey.X)
-	publicKey.Y = new(big.Int).Set(priv.PublicKey.Y)
-	return DIDPublicKey{*publicKey} , nil
-}
\ No newline at end of file
+	return priv
+}
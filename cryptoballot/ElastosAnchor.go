@@ -0,0 +1,126 @@
+package cryptoballot
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/phayes/errors"
+)
+
+var (
+	ErrAnchorRPC         = errors.New("Elastos sidechain node returned an error")
+	ErrAnchorPending     = errors.New("Anchoring transaction has not been included in a block yet")
+	ErrAnchorWrongSender = errors.New("Anchoring transaction was not sent by the election's anchoring account")
+	ErrAnchorNoAccount   = errors.New("No anchoring account given. Commitments are only accepted from the election's anchoring account")
+)
+
+// ElastosAnchor is an Anchor that records commitments in transactions on the Elastos smart contract sidechain (ESC).
+// Each commitment is sent as the data of a transaction from the anchoring account to itself, through a node that
+// holds the account unlocked. Receipts are transaction hashes, and anyone can look a commitment up through any node.
+type ElastosAnchor struct {
+	RPCURL     string // JSON-RPC endpoint of a sidechain node, eg: https://api.elastos.io/esc
+	Account    string // Hex address of the anchoring account. Required. Lookups only accept commitments sent by this account
+	HTTPClient http.Client
+}
+
+// NewElastosAnchor creates an anchor for the sidechain node at rpcURL, using the given anchoring account.
+// ErrAnchorNoAccount is returned if the account is empty, since anyone could then anchor for the election.
+func NewElastosAnchor(rpcURL string, account string) (*ElastosAnchor, error) {
+	if account == "" {
+		return nil, ErrAnchorNoAccount
+	}
+	return &ElastosAnchor{RPCURL: rpcURL, Account: account}, nil
+}
+
+// Commit sends a transaction carrying the commitment, and returns its hash. The transaction may not be included in a
+// block yet, in which case Lookup returns ErrAnchorPending until it is.
+func (anchor *ElastosAnchor) Commit(commitment *Commitment) (string, error) {
+	if anchor.Account == "" {
+		return "", ErrAnchorNoAccount
+	}
+	tx := map[string]string{
+		"from": anchor.Account,
+		"to":   anchor.Account,
+		"data": "0x" + hex.EncodeToString([]byte(commitment.String())),
+	}
+	var txHash string
+	if err := anchor.call("eth_sendTransaction", []interface{}{tx}, &txHash); err != nil {
+		return "", err
+	}
+	return txHash, nil
+}
+
+// Lookup gets the commitment carried by the transaction with the receipt's hash. It must have been sent by the
+// anchoring account.
+func (anchor *ElastosAnchor) Lookup(receipt string) (*Commitment, error) {
+	if anchor.Account == "" {
+		return nil, ErrAnchorNoAccount
+	}
+	if _, err := hex.DecodeString(strings.TrimPrefix(receipt, "0x")); err != nil || !strings.HasPrefix(receipt, "0x") {
+		return nil, ErrAnchorReceiptInvalid
+	}
+	var tx *struct {
+		From        string  `json:"from"`
+		Input       string  `json:"input"`
+		BlockNumber *string `json:"blockNumber"`
+	}
+	if err := anchor.call("eth_getTransactionByHash", []interface{}{receipt}, &tx); err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, ErrAnchorNotFound
+	}
+	if tx.BlockNumber == nil {
+		return nil, ErrAnchorPending
+	}
+	if !strings.EqualFold(tx.From, anchor.Account) {
+		return nil, ErrAnchorWrongSender
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(tx.Input, "0x"))
+	if err != nil {
+		return nil, errors.Wrap(err, ErrCommitmentInvalid)
+	}
+	return NewCommitment(data)
+}
+
+// call makes a JSON-RPC call to the sidechain node, and decodes the result into result
+func (anchor *ElastosAnchor) call(method string, params []interface{}, result interface{}) error {
+	request, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := anchor.HTTPClient.Post(anchor.RPCURL, "application/json", bytes.NewReader(request))
+	if err != nil {
+		return errors.Wrap(err, ErrAnchorRPC)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Wraps(ErrAnchorRPC, resp.Status)
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return errors.Wrap(err, ErrAnchorRPC)
+	}
+	if response.Error != nil {
+		return errors.Wrapf(ErrAnchorRPC, "%s (code %d)", response.Error.Message, response.Error.Code)
+	}
+	if err = json.Unmarshal(response.Result, result); err != nil {
+		return errors.Wrap(err, ErrAnchorRPC)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package cryptoballot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/phayes/errors"
+)
+
+// newTestSidechain starts a fake sidechain node that keeps the transactions sent to it. Transactions are included in
+// a block once mined is set.
+func newTestSidechain(t *testing.T, mined *bool) *httptest.Server {
+	txs := map[string]map[string]interface{}{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		var result interface{}
+		switch request.Method {
+		case "eth_sendTransaction":
+			var tx map[string]string
+			json.Unmarshal(request.Params[0], &tx)
+			hash := fmt.Sprintf("0x%064x", len(txs)+1)
+			txs[hash] = map[string]interface{}{"from": tx["from"], "input": tx["data"], "blockNumber": nil}
+			result = hash
+		case "eth_getTransactionByHash":
+			var hash string
+			json.Unmarshal(request.Params[0], &hash)
+			if tx, ok := txs[hash]; ok {
+				if *mined {
+					tx["blockNumber"] = "0x1"
+				}
+				result = tx
+			}
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": -32601, "message": "method not found"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": result})
+	}))
+}
+
+func TestElastosAnchor(t *testing.T) {
+	mined := false
+	node := newTestSidechain(t, &mined)
+	defer node.Close()
+
+	bundle, _ := newTestBundle(t)
+	clerkPriv, err := GeneratePrivateKey(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitment, err := NewCommitmentForElection(&bundle.Election, newTestAuditLog(t, clerkPriv, bundle), bundle.Ballots, bundle.Election.End, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := "0x00000000000000000000000000000000000000aa"
+	anchor, err := NewElastosAnchor(node.URL, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := anchor.Commit(commitment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = anchor.Lookup(receipt); err != ErrAnchorPending {
+		t.Errorf("Expected ErrAnchorPending, got %v", err)
+	}
+
+	mined = true
+	found, err := anchor.Lookup(receipt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.String() != commitment.String() {
+		t.Errorf("Commitment did not round trip:\n%s\n%s", commitment, found)
+	}
+
+	// Commitments are only accepted from the anchoring account, so no one else can anchor for the election
+	other, err := NewElastosAnchor(node.URL, "0x00000000000000000000000000000000000000bb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = other.Lookup(receipt); err != ErrAnchorWrongSender {
+		t.Errorf("Expected ErrAnchorWrongSender, got %v", err)
+	}
+	if _, err = NewElastosAnchor(node.URL, ""); err != ErrAnchorNoAccount {
+		t.Errorf("Expected ErrAnchorNoAccount, got %v", err)
+	}
+	if _, err = (&ElastosAnchor{RPCURL: node.URL}).Lookup(receipt); err != ErrAnchorNoAccount {
+		t.Errorf("Expected ErrAnchorNoAccount without an account, got %v", err)
+	}
+	if _, err = anchor.Lookup("0xff"); err != ErrAnchorNotFound {
+		t.Errorf("Expected ErrAnchorNotFound, got %v", err)
+	}
+	if _, err = anchor.Lookup("not a hash"); err != ErrAnchorReceiptInvalid {
+		t.Errorf("Expected ErrAnchorReceiptInvalid, got %v", err)
+	}
+	if err = anchor.call("eth_unknown", nil, nil); !errors.Is(err, ErrAnchorRPC) {
+		t.Errorf("Expected ErrAnchorRPC, got %v", err)
+	}
+}
@@ -160,6 +160,11 @@ func (election *Election) HasTagSet() bool {
 	return election.TagSet != nil
 }
 
+// AdminDID gets the DID of the admin that created the election from the admin-did tag. It is empty if there is no such tag
+func (election *Election) AdminDID() string {
+	return election.TagSet.Map()[ElectionDIDTag]
+}
+
 // HasSignature hecks to see if the Election has been signed. It does not verify the signature, but merely checks to see if it exists.
 // Signatures are generally required, but are sometimes optional (for example, when working with an Election before it is signed by the admin)
 func (election *Election) HasSignature() bool {
@@ -0,0 +1,157 @@
+package cryptoballot
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"github.com/phayes/errors"
+)
+
+// ResultMethodSchulze is the counting method used by cryptoballot tally. See ElectionResult.Method
+const ResultMethodSchulze = "schulze"
+
+var (
+	ErrResultInvalid       = errors.New("Cannot parse election result. Invalid format")
+	ErrResultInvalidKey    = errors.New("Invalid election result public key")
+	ErrResultInvalidSig    = errors.New("Invalid election result signature")
+	ErrResultSigNotFound   = errors.New("Could not verify election result signature: Signature does not exist")
+	ErrResultWrongSigner   = errors.New("Election result is not signed by the election admin")
+	ErrResultWrongElection = errors.New("Election result is for a different election")
+)
+
+// An ElectionResult is the published, signed outcome of an election. Anyone holding the election's ballots can check
+// it by tallying them again, and comparing everything but the signature and software. See tally.VerifyElectionResult
+//
+// It is a JSON document. The admin's signature is made over its compact JSON with sorted keys and without the
+// "signature" field, the same way as credential proofs are. Schulze is decided in a single round from the pairwise
+// preferences between candidates, so those are given in place of rounds.
+type ElectionResult struct {
+	ElectionID string            `json:"electionId"`
+	Method     string            `json:"method"`
+	Software   string            `json:"software"`  // Name and version of the software that tallied the election
+	BallotSet  string            `json:"ballotSet"` // Hex encoded Merkle root of every ballot in the election. See BallotsMerkleRoot
+	Ballots    int               `json:"ballots"`   // Valid ballots counted, including marked ballots
+	Weight     uint64            `json:"weight,omitempty"`
+	Blank      int               `json:"blank"`
+	Abstain    int               `json:"abstain"`
+	Spoiled    int               `json:"spoiled"`
+	Winners    []string          `json:"winners"`
+	Candidates []CandidateResult `json:"candidates"`
+	Pairwise   []Preference      `json:"pairwise"`
+	Decision   *ResultDecision   `json:"decision,omitempty"` // Only for elections with a quorum or threshold
+	PublicKey  string            `json:"publicKey"`          // Hex encoded DID public key of the election admin
+	Signature  string            `json:"signature,omitempty"`
+}
+
+// CandidateResult is the score of a candidate in the tally
+type CandidateResult struct {
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+}
+
+// A Preference is the number, or weight, of ballots that rank one candidate above another
+type Preference struct {
+	For     string `json:"for"`
+	Against string `json:"against"`
+	Count   uint64 `json:"count"`
+}
+
+// ResultDecision is the outcome of an election under its quorum and threshold. See DecisionRules
+type ResultDecision struct {
+	Outcome    string `json:"outcome"`
+	Turnout    uint64 `json:"turnout"`
+	Electorate uint64 `json:"electorate,omitempty"`
+	Support    uint64 `json:"support"`
+	Votes      uint64 `json:"votes"`
+}
+
+// NewElectionResult parses an election result. The signature is not verified. See VerifyForElection
+func NewElectionResult(rawResult []byte) (*ElectionResult, error) {
+	var result ElectionResult
+	dec := json.NewDecoder(bytes.NewReader(rawResult))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&result); err != nil {
+		return nil, errors.Wrap(err, ErrResultInvalid)
+	}
+	if !ValidElectionID.MatchString(result.ElectionID) {
+		return nil, errors.Wrap(ErrElectionIDInvalid, ErrResultInvalid)
+	}
+	if result.Method == "" {
+		return nil, errors.Wraps(ErrResultInvalid, "Missing method")
+	}
+	if _, err := hex.DecodeString(result.BallotSet); err != nil {
+		return nil, errors.Wraps(ErrResultInvalid, "Invalid ballotSet")
+	}
+	if publicKey, err := hex.DecodeString(result.PublicKey); err != nil || len(publicKey) == 0 {
+		return nil, ErrResultInvalidKey
+	}
+	if _, err := hex.DecodeString(result.Signature); err != nil {
+		return nil, errors.Wrap(err, ErrResultInvalidSig)
+	}
+	return &result, nil
+}
+
+// VerifySignature verifies that the result has been properly signed by the DID key in result.PublicKey.
+// It does not check who that key belongs to.
+func (result *ElectionResult) VerifySignature() error {
+	if !result.HasSignature() {
+		return ErrResultSigNotFound
+	}
+	rawKey, err := hex.DecodeString(result.PublicKey)
+	if err != nil {
+		return errors.Wrap(err, ErrResultInvalidKey)
+	}
+	publicKey, err := crypto.DecodePoint(rawKey)
+	if err != nil {
+		return errors.Wrap(err, ErrResultInvalidKey)
+	}
+	signature, err := hex.DecodeString(result.Signature)
+	if err != nil {
+		return errors.Wrap(err, ErrResultInvalidSig)
+	}
+	didPublicKey := DIDPublicKey{PublicKey: *publicKey}
+	return didPublicKey.VerifySignature(signature, []byte(result.StringWithoutSignature()))
+}
+
+// VerifyForElection verifies that the result is for the election and is signed by the election's admin
+func (result *ElectionResult) VerifyForElection(election *Election) error {
+	if result.ElectionID != election.ElectionID {
+		return ErrResultWrongElection
+	}
+	if result.PublicKey != hex.EncodeToString(election.PublicKey) {
+		return ErrResultWrongSigner
+	}
+	return result.VerifySignature()
+}
+
+// HasSignature checks to see if the result has been signed. It does not verify the signature.
+func (result *ElectionResult) HasSignature() bool {
+	return result.Signature != ""
+}
+
+// Implements Stringer. Returns compact JSON with sorted keys, in the format expected by NewElectionResult
+func (result ElectionResult) String() string {
+	return string(result.canonical())
+}
+
+// StringWithoutSignature gets the result without the signature, OK for signing
+func (result ElectionResult) StringWithoutSignature() string {
+	result.Signature = ""
+	return string(result.canonical())
+}
+
+// canonical gets the compact JSON of the result with sorted keys
+func (result ElectionResult) canonical() []byte {
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		// Results are made of strings and numbers, which can always be encoded
+		panic(err)
+	}
+	raw, err := decodeJSONObject(encoded)
+	if err != nil {
+		panic(err)
+	}
+	return mustCompactJSON(raw)
+}
@@ -0,0 +1,107 @@
+package cryptoballot
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func newTestElectionResult(t *testing.T, priv DIDPrivateKey, pub DIDPublicKey) *ElectionResult {
+	result := &ElectionResult{
+		ElectionID: "12345",
+		Method:     ResultMethodSchulze,
+		Software:   "cryptoballot/0.1",
+		BallotSet:  "00ff",
+		Ballots:    3,
+		Blank:      1,
+		Winners:    []string{"Alice"},
+		Candidates: []CandidateResult{{Name: "Alice", Score: 1}, {Name: "Bob", Score: 0}},
+		Pairwise:   []Preference{{For: "Alice", Against: "Bob", Count: 2}, {For: "Bob", Against: "Alice", Count: 0}},
+		PublicKey:  pub.Hex(),
+	}
+	signature, err := priv.SignString(result.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.Signature = hex.EncodeToString(signature)
+	return result
+}
+
+func TestElectionResult(t *testing.T) {
+	priv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := priv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := newTestElectionResult(t, priv, pub)
+	if err := result.VerifySignature(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reformatting the document does not break the signature
+	indented := strings.Replace(result.String(), ",", ",\n  ", -1)
+	result2, err := NewElectionResult([]byte(indented))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result2.String() != result.String() {
+		t.Errorf("Election result did not round trip:\n%s\n%s", result, result2)
+	}
+	if err = result2.VerifySignature(); err != nil {
+		t.Error(err)
+	}
+	if strings.Contains(result.StringWithoutSignature(), "signature") {
+		t.Error("StringWithoutSignature should leave out the signature")
+	}
+
+	// Tampering breaks the signature
+	result2.Winners = []string{"Bob"}
+	if err = result2.VerifySignature(); err == nil {
+		t.Error("Tampered election result should not verify")
+	}
+
+	// Must be signed by the election admin
+	election := &Election{ElectionID: "12345", PublicKey: pub.Bytes()}
+	if err = result.VerifyForElection(election); err != nil {
+		t.Error(err)
+	}
+	otherPriv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, err := otherPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = result.VerifyForElection(&Election{ElectionID: "12345", PublicKey: otherPub.Bytes()}); err != ErrResultWrongSigner {
+		t.Errorf("Expected ErrResultWrongSigner, got %v", err)
+	}
+	if err = result.VerifyForElection(&Election{ElectionID: "other", PublicKey: pub.Bytes()}); err != ErrResultWrongElection {
+		t.Errorf("Expected ErrResultWrongElection, got %v", err)
+	}
+
+	result.Signature = ""
+	if err = result.VerifySignature(); err != ErrResultSigNotFound {
+		t.Errorf("Expected ErrResultSigNotFound, got %v", err)
+	}
+}
+
+func TestBadElectionResult(t *testing.T) {
+	badResults := []string{
+		`not json`,
+		`{"electionId":"12345","method":"schulze","ballotSet":"00","publicKey":"00","extra":true}`,
+		`{"electionId":"bad id","method":"schulze","ballotSet":"00","publicKey":"00"}`,
+		`{"electionId":"12345","ballotSet":"00","publicKey":"00"}`,
+		`{"electionId":"12345","method":"schulze","ballotSet":"zz","publicKey":"00"}`,
+		`{"electionId":"12345","method":"schulze","ballotSet":"00","publicKey":""}`,
+		`{"electionId":"12345","method":"schulze","ballotSet":"00","publicKey":"00","signature":"zz"}`,
+	}
+	for _, raw := range badResults {
+		if _, err := NewElectionResult([]byte(raw)); err == nil {
+			t.Errorf("Expected error parsing %s", raw)
+		}
+	}
+}
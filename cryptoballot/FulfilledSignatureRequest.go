@@ -2,6 +2,8 @@ package cryptoballot
 
 import (
 	"bytes"
+
+	"github.com/cryptoballot/rsablind"
 	"github.com/phayes/errors"
 )
 
@@ -18,15 +20,20 @@ var (
 func NewFulfilledSignatureRequest(rawBytes []byte) (*FulfilledSignatureRequest, error) {
 	parts := bytes.Split(rawBytes, []byte("\n\n"))
 
-	if len(parts) != 6 {
+	// The ballot signature is last. Before it is a signed SignatureRequest, which has extra parts for a DID, presentation and denomination
+	if len(parts) < 6 || len(parts) > 9 {
 		return &FulfilledSignatureRequest{}, ErrFulfilledSignatureRequestInvalid
 	}
 
-	signatureRequest, err := NewSignatureRequest(bytes.Join(parts[:5], []byte("\n\n")))
+	last := len(parts) - 1
+	signatureRequest, err := NewSignatureRequest(bytes.Join(parts[:last], []byte("\n\n")))
 	if err != nil {
 		return &FulfilledSignatureRequest{}, err
 	}
-	ballotSignature, err := NewSignature(parts[5])
+	if !signatureRequest.HasSignature() {
+		return &FulfilledSignatureRequest{}, ErrFulfilledSignatureRequestInvalid
+	}
+	ballotSignature, err := NewSignature(parts[last])
 	if err != nil {
 		return &FulfilledSignatureRequest{}, err
 	}
@@ -45,6 +52,19 @@ func NewFulfilledSignatureRequestFromParts(sigReq SignatureRequest, sig Signatur
 	}
 }
 
+// VerifyBallotSignature verifies that the BallotSignature is the clerk's blind signature on the BlindBallot
+func (fulfilled *FulfilledSignatureRequest) VerifyBallotSignature(pk PublicKey) error {
+	pubkey, err := pk.GetCryptoKey()
+	if err != nil {
+		return errors.Wrap(err, ErrSignatureVerify)
+	}
+	err = rsablind.VerifyBlindSignature(pubkey, fulfilled.BlindBallot.Bytes(), fulfilled.BallotSignature.Bytes())
+	if err != nil {
+		return errors.Wrap(err, ErrSignatureVerify)
+	}
+	return nil
+}
+
 // Implements Stringer
 func (fulfilled FulfilledSignatureRequest) String() string {
 	return fulfilled.SignatureRequest.String() + "\n\n" + fulfilled.BallotSignature.String()
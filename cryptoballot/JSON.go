@@ -0,0 +1,323 @@
+package cryptoballot
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/phayes/errors"
+)
+
+// JSON forms
+//
+// Elections, ballots and signature requests are also accepted and served as JSON, for clients that would rather not
+// deal with the text format. In JSON every binary field is hex encoded, including the RSA signatures and blind
+// ballots that are base64 encoded in the text format, and times are given in RFC3339.
+//
+// The text format stays canonical: signatures are always made over StringWithoutSignature, never over the JSON.
+// A JSON value is converted to its text form and parsed again, so it is validated exactly as the text would be.
+// A value whose text form would parse differently, such as a vote with a line break in it, is rejected.
+
+var ErrJSONInvalid = errors.New("Cannot convert JSON to the text format. A field may not contain line breaks")
+
+// TagJSON is the JSON form of a Tag. Tags are kept as a list since their order is signed.
+type TagJSON struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ElectionJSON is the JSON form of an Election
+type ElectionJSON struct {
+	ElectionID string    `json:"electionId"`
+	Start      string    `json:"start" description:"RFC3339"`
+	End        string    `json:"end" description:"RFC3339"`
+	Tags       []TagJSON `json:"tags,omitempty"`
+	PublicKey  string    `json:"publicKey" description:"Hex encoded DID public key of the election admin"`
+	Signature  string    `json:"signature,omitempty" description:"Hex encoded DID signature of the text form of the election"`
+}
+
+// BallotJSON is the JSON form of a Ballot
+type BallotJSON struct {
+	ElectionID string    `json:"electionId"`
+	BallotID   string    `json:"ballotId"`
+	Vote       []string  `json:"vote"`
+	Tags       []TagJSON `json:"tags,omitempty"`
+	Signature  string    `json:"signature,omitempty" description:"Hex encoded unblinded RSA signature of the election clerk"`
+}
+
+// SignatureRequestJSON is the JSON form of a SignatureRequest
+type SignatureRequestJSON struct {
+	ElectionID   string          `json:"electionId"`
+	RequestID    string          `json:"requestId" description:"Hex encoded double SHA256 of the voter's DID if given, otherwise of their public key"`
+	PublicKey    string          `json:"publicKey" description:"Hex encoded DID public key of the voter"`
+	DID          string          `json:"did,omitempty"`
+	Presentation json.RawMessage `json:"presentation,omitempty" description:"Verifiable presentation of the voter's credentials"`
+	Denomination uint64          `json:"denomination,omitempty"`
+	BlindBallot  string          `json:"blindBallot" description:"Hex encoded blinded ballot"`
+	Signature    string          `json:"signature,omitempty" description:"Hex encoded DID signature of the text form of the signature request"`
+}
+
+// FulfilledSignatureRequestJSON is the JSON form of a FulfilledSignatureRequest
+type FulfilledSignatureRequestJSON struct {
+	SignatureRequestJSON
+	BallotSignature string `json:"ballotSignature" description:"Hex encoded RSA blind signature of the election clerk"`
+}
+
+// JSON gets the JSON form of the election
+func (election Election) JSON() ElectionJSON {
+	return ElectionJSON{
+		ElectionID: election.ElectionID,
+		Start:      election.Start.Format(time.RFC3339),
+		End:        election.End.Format(time.RFC3339),
+		Tags:       tagSetJSON(election.TagSet),
+		PublicKey:  hex.EncodeToString(election.PublicKey),
+		Signature:  hex.EncodeToString(election.Signature),
+	}
+}
+
+// Election converts the JSON form back to an Election, validating it as NewElection would
+func (j ElectionJSON) Election() (*Election, error) {
+	var (
+		election = Election{ElectionID: j.ElectionID, TagSet: jsonTagSet(j.Tags)}
+		err      error
+	)
+	if election.Start, err = time.Parse(time.RFC3339, j.Start); err != nil {
+		return nil, errors.Wrap(err, ErrElectionStartInvalid)
+	}
+	if election.End, err = time.Parse(time.RFC3339, j.End); err != nil {
+		return nil, errors.Wrap(err, ErrElectionEndInvalid)
+	}
+	if election.PublicKey, err = hex.DecodeString(j.PublicKey); err != nil {
+		return nil, errors.Wrap(err, ErrElectionInvalidKey)
+	}
+	if election.Signature, err = decodeOptionalHex(j.Signature); err != nil {
+		return nil, errors.Wrap(err, ErrElectionInvalidSig)
+	}
+
+	parsed, err := NewElection([]byte(election.String()))
+	if err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(parsed.JSON(), election.JSON()) {
+		return nil, errors.Wrap(ErrJSONInvalid, ErrEelectionInvalid)
+	}
+	return parsed, nil
+}
+
+// MarshalJSON implements json.Marshaler. See ElectionJSON
+func (election Election) MarshalJSON() ([]byte, error) {
+	return json.Marshal(election.JSON())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. See ElectionJSON
+func (election *Election) UnmarshalJSON(data []byte) error {
+	var j ElectionJSON
+	if err := decodeStrictJSON(data, &j); err != nil {
+		return errors.Wrap(err, ErrEelectionInvalid)
+	}
+	parsed, err := j.Election()
+	if err != nil {
+		return err
+	}
+	*election = *parsed
+	return nil
+}
+
+// JSON gets the JSON form of the ballot
+func (ballot Ballot) JSON() BallotJSON {
+	vote := []string(ballot.Vote)
+	if vote == nil {
+		vote = []string{}
+	}
+	return BallotJSON{
+		ElectionID: ballot.ElectionID,
+		BallotID:   ballot.BallotID,
+		Vote:       vote,
+		Tags:       tagSetJSON(ballot.TagSet),
+		Signature:  hex.EncodeToString(ballot.Signature),
+	}
+}
+
+// Ballot converts the JSON form back to a Ballot, validating it as NewBallot would
+func (j BallotJSON) Ballot() (*Ballot, error) {
+	ballot := Ballot{ElectionID: j.ElectionID, BallotID: j.BallotID, Vote: Vote(j.Vote), TagSet: jsonTagSet(j.Tags)}
+	signature, err := decodeOptionalHex(j.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrBallotInvalidSig)
+	}
+	if signature != nil {
+		ballot.Signature = Signature(signature)
+	}
+
+	parsed, err := NewBallot([]byte(ballot.String()))
+	if err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(parsed.JSON(), ballot.JSON()) {
+		return nil, errors.Wrap(ErrJSONInvalid, ErrBallotInvalid)
+	}
+	return parsed, nil
+}
+
+// MarshalJSON implements json.Marshaler. See BallotJSON
+func (ballot Ballot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ballot.JSON())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. See BallotJSON
+func (ballot *Ballot) UnmarshalJSON(data []byte) error {
+	var j BallotJSON
+	if err := decodeStrictJSON(data, &j); err != nil {
+		return errors.Wrap(err, ErrBallotInvalid)
+	}
+	parsed, err := j.Ballot()
+	if err != nil {
+		return err
+	}
+	*ballot = *parsed
+	return nil
+}
+
+// JSON gets the JSON form of the signature request
+func (sigReq SignatureRequest) JSON() SignatureRequestJSON {
+	return SignatureRequestJSON{
+		ElectionID:   sigReq.ElectionID,
+		RequestID:    hex.EncodeToString(sigReq.RequestID),
+		PublicKey:    hex.EncodeToString(sigReq.PublicKey),
+		DID:          sigReq.DID,
+		Presentation: json.RawMessage(sigReq.Presentation),
+		Denomination: sigReq.Denomination,
+		BlindBallot:  hex.EncodeToString(sigReq.BlindBallot),
+		Signature:    hex.EncodeToString(sigReq.Signature),
+	}
+}
+
+// SignatureRequest converts the JSON form back to a SignatureRequest, validating it as NewSignatureRequest would.
+// The presentation is compacted, so it must be signed in its compact form.
+func (j SignatureRequestJSON) SignatureRequest() (*SignatureRequest, error) {
+	var (
+		sigReq = SignatureRequest{ElectionID: j.ElectionID, DID: j.DID, Denomination: j.Denomination}
+		err    error
+	)
+	if sigReq.RequestID, err = hex.DecodeString(j.RequestID); err != nil {
+		return nil, errors.Wrap(err, ErrSignatureRequestID)
+	}
+	if sigReq.PublicKey, err = hex.DecodeString(j.PublicKey); err != nil {
+		return nil, errors.Wrap(err, ErrSignatureRequestPublicKey)
+	}
+	if len(j.Presentation) != 0 && string(j.Presentation) != "null" {
+		var compact bytes.Buffer
+		if err = json.Compact(&compact, j.Presentation); err != nil {
+			return nil, errors.Wrap(err, ErrSignatureRequestVP)
+		}
+		sigReq.Presentation = compact.Bytes()
+	}
+	if sigReq.BlindBallot, err = hex.DecodeString(j.BlindBallot); err != nil {
+		return nil, errors.Wrap(err, ErrSignatureRequestBallotHash)
+	}
+	if sigReq.Signature, err = decodeOptionalHex(j.Signature); err != nil {
+		return nil, errors.Wrap(err, ErrSignatureRequestSigInvalid)
+	}
+
+	parsed, err := NewSignatureRequest([]byte(sigReq.String()))
+	if err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(parsed.JSON(), sigReq.JSON()) {
+		return nil, errors.Wrap(ErrJSONInvalid, ErrSignatureRequestInvalid)
+	}
+	return parsed, nil
+}
+
+// MarshalJSON implements json.Marshaler. See SignatureRequestJSON
+func (sigReq SignatureRequest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sigReq.JSON())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. See SignatureRequestJSON
+func (sigReq *SignatureRequest) UnmarshalJSON(data []byte) error {
+	var j SignatureRequestJSON
+	if err := decodeStrictJSON(data, &j); err != nil {
+		return errors.Wrap(err, ErrSignatureRequestInvalid)
+	}
+	parsed, err := j.SignatureRequest()
+	if err != nil {
+		return err
+	}
+	*sigReq = *parsed
+	return nil
+}
+
+// JSON gets the JSON form of the fulfilled signature request
+func (fulfilled FulfilledSignatureRequest) JSON() FulfilledSignatureRequestJSON {
+	return FulfilledSignatureRequestJSON{
+		SignatureRequestJSON: fulfilled.SignatureRequest.JSON(),
+		BallotSignature:      hex.EncodeToString(fulfilled.BallotSignature),
+	}
+}
+
+// FulfilledSignatureRequest converts the JSON form back to a FulfilledSignatureRequest, validating it as
+// NewFulfilledSignatureRequest would
+func (j FulfilledSignatureRequestJSON) FulfilledSignatureRequest() (*FulfilledSignatureRequest, error) {
+	sigReq, err := j.SignatureRequestJSON.SignatureRequest()
+	if err != nil {
+		return nil, err
+	}
+	ballotSignature, err := hex.DecodeString(j.BallotSignature)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrSignatureRequestInvalid)
+	}
+	return NewFulfilledSignatureRequest([]byte(NewFulfilledSignatureRequestFromParts(*sigReq, ballotSignature).String()))
+}
+
+// MarshalJSON implements json.Marshaler. See FulfilledSignatureRequestJSON
+func (fulfilled FulfilledSignatureRequest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fulfilled.JSON())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. See FulfilledSignatureRequestJSON
+func (fulfilled *FulfilledSignatureRequest) UnmarshalJSON(data []byte) error {
+	var j FulfilledSignatureRequestJSON
+	if err := decodeStrictJSON(data, &j); err != nil {
+		return errors.Wrap(err, ErrFulfilledSignatureRequestInvalid)
+	}
+	parsed, err := j.FulfilledSignatureRequest()
+	if err != nil {
+		return err
+	}
+	*fulfilled = *parsed
+	return nil
+}
+
+func tagSetJSON(tagSet TagSet) []TagJSON {
+	var tags []TagJSON
+	for _, tag := range tagSet {
+		tags = append(tags, TagJSON{Key: string(tag.Key), Value: string(tag.Value)})
+	}
+	return tags
+}
+
+func jsonTagSet(tags []TagJSON) TagSet {
+	var tagSet TagSet
+	for _, tag := range tags {
+		tagSet = append(tagSet, Tag{Key: []byte(tag.Key), Value: []byte(tag.Value)})
+	}
+	return tagSet
+}
+
+// decodeOptionalHex decodes a hex field that may be left out. An empty field is nil.
+func decodeOptionalHex(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return hex.DecodeString(s)
+}
+
+// decodeStrictJSON decodes a single JSON value, rejecting unknown fields
+func decodeStrictJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
@@ -0,0 +1,100 @@
+package cryptoballot
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/phayes/errors"
+)
+
+func TestJSON(t *testing.T) {
+	bundle, _ := newTestBundle(t)
+	bundle.Election.TagSet = TagSet{{Key: []byte("title"), Value: []byte("Board")}, {Key: []byte("seats"), Value: []byte("2")}}
+
+	// Each JSON form converts back to exactly the same text
+	var (
+		election  Election
+		ballot    Ballot
+		sigReq    SignatureRequest
+		fulfilled FulfilledSignatureRequest
+	)
+	roundTrip := func(in interface{}, out interface{}, text string) {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = json.Unmarshal(encoded, out); err != nil {
+			t.Fatalf("%s: %v", encoded, err)
+		}
+		if got := out.(interface{ String() string }).String(); got != text {
+			t.Errorf("JSON did not round trip:\n%s\n%s", text, got)
+		}
+	}
+	roundTrip(bundle.Election, &election, bundle.Election.String())
+	roundTrip(bundle.Ballots[0], &ballot, bundle.Ballots[0].String())
+	roundTrip(bundle.SignatureRequests[0].SignatureRequest, &sigReq, bundle.SignatureRequests[0].SignatureRequest.String())
+	roundTrip(bundle.SignatureRequests[0], &fulfilled, bundle.SignatureRequests[0].String())
+
+	if err := sigReq.VerifySignature(); err != nil {
+		t.Error(err)
+	}
+
+	// Binary fields are hex encoded, including the ones that are base64 in the text format
+	encoded, _ := json.Marshal(fulfilled)
+	var raw map[string]interface{}
+	json.Unmarshal(encoded, &raw)
+	if raw["blindBallot"] != strings.Repeat("62", 256) || raw["ballotSignature"] != strings.Repeat("73", 256) {
+		t.Errorf("Expected hex encoded blind ballot and ballot signature, got %s", encoded)
+	}
+
+	// A presentation is compacted, as it must be in the text format
+	withPresentation := bundle.SignatureRequests[0].SignatureRequest.JSON()
+	withPresentation.DID = testDID
+	withPresentation.RequestID = hex.EncodeToString(DIDRequestID(testDID))
+	withPresentation.Presentation = json.RawMessage("{\n  \"holder\": \"" + testDID + "\",\n  \"type\": \"VerifiablePresentation\"\n}")
+	withPresentation.Signature = ""
+	parsed, err := withPresentation.SignatureRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(parsed.Presentation) != `{"holder":"`+testDID+`","type":"VerifiablePresentation"}` {
+		t.Errorf("Expected a compacted presentation, got %s", parsed.Presentation)
+	}
+}
+
+func TestBadJSON(t *testing.T) {
+	bundle, _ := newTestBundle(t)
+
+	// A vote with a line break would be a different vote in the text format
+	ballot := bundle.Ballots[0].JSON()
+	ballot.Vote = []string{"Alice\nBob"}
+	if _, err := ballot.Ballot(); !errors.Is(err, ErrJSONInvalid) {
+		t.Errorf("Expected ErrJSONInvalid, got %v", err)
+	}
+
+	// Fields are validated as they would be in the text format
+	election := bundle.Election.JSON()
+	election.ElectionID = "Not Valid"
+	if _, err := election.Election(); err == nil {
+		t.Error("Election with an invalid ID produced no error")
+	}
+	election = bundle.Election.JSON()
+	election.Start = bundle.Election.Start.String()
+	if _, err := election.Election(); !errors.Is(err, ErrElectionStartInvalid) {
+		t.Errorf("Expected ErrElectionStartInvalid, got %v", err)
+	}
+
+	sigReq := bundle.SignatureRequests[0].SignatureRequest.JSON()
+	sigReq.BlindBallot = "not hex"
+	if _, err := sigReq.SignatureRequest(); !errors.Is(err, ErrSignatureRequestBallotHash) {
+		t.Errorf("Expected ErrSignatureRequestBallotHash, got %v", err)
+	}
+
+	// Unknown fields are rejected
+	var parsed Ballot
+	if err := json.Unmarshal([]byte(`{"electionId":"election12345","ballotId":"a","vote":["Alice"],"extra":1}`), &parsed); !errors.Is(err, ErrBallotInvalid) {
+		t.Errorf("Expected ErrBallotInvalid, got %v", err)
+	}
+}
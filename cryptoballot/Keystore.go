@@ -0,0 +1,279 @@
+package cryptoballot
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/pem"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/phayes/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// A Keystore holds named DID private keys, each encrypted with a key derived from a passphrase.
+//
+// A keystore file is a series of "DID KEY" PEM blocks. The public key is stored in the clear so that keys can be
+// listed and shown without a passphrase. The private key is encrypted with AES-256-GCM, using a key derived from
+// the passphrase with scrypt, and with the public key as additional authenticated data. Each block has the headers:
+//   - Name: The name used to refer to the key
+//   - Public-Key: The hex encoded compressed public key
+//   - Kdf: Always "scrypt"
+//   - Kdf-Params: The scrypt N, r and p parameters, comma separated
+//   - Salt: The hex encoded scrypt salt
+//   - Nonce: The hex encoded AES-GCM nonce
+type Keystore struct {
+	keys []*KeystoreKey
+}
+
+// KeystoreKey is an encrypted DID private key in a Keystore
+type KeystoreKey struct {
+	Name       string
+	PublicKey  DIDPublicKey
+	scryptN    int
+	scryptR    int
+	scryptP    int
+	salt       []byte
+	nonce      []byte
+	ciphertext []byte
+}
+
+const (
+	MaxKeystoreNameSize = 64
+
+	keystoreKeyType = "DID KEY"
+	keystoreKdf     = "scrypt"
+	keystoreSaltLen = 32
+
+	// Keys read from a keystore must have a salt of at least keystoreMinSaltLen bytes and a nonce of the standard
+	// AES-GCM size. Their scrypt parameters are bounded so that a crafted keystore cannot exhaust memory or CPU.
+	// scrypt needs 128*N*r bytes of memory, and hashes them p times, so 128*N*r*p is kept to 32 times the default.
+	keystoreMinSaltLen    = 16
+	keystoreNonceLen      = 12
+	keystoreMaxScryptCost = 1 << 30
+	keystoreMaxScryptR    = 32
+	keystoreMaxScryptP    = 16
+
+	// scrypt parameters recommended for interactive logins
+	keystoreScryptN = 1 << 15
+	keystoreScryptR = 8
+	keystoreScryptP = 1
+)
+
+var (
+	ValidKeystoreName = regexp.MustCompile(`^[0-9a-zA-Z\-\._]+$`)
+
+	ErrKeystoreInvalid     = errors.New("Cannot parse keystore. Invalid format")
+	ErrKeystoreInvalidKey  = errors.New("Cannot parse key in keystore")
+	ErrKeystoreInvalidName = errors.Newf("Invalid key name. Key names may be up to %d characters and contain only letters, numbers, '-', '.' and '_'", MaxKeystoreNameSize)
+	ErrKeystoreKeyExists   = errors.New("A key with this name already exists in the keystore")
+	ErrKeystoreKeyNotFound = errors.New("Could not find a key with this name in the keystore")
+	ErrKeystorePassphrase  = errors.New("Could not decrypt key. Wrong passphrase or corrupted keystore")
+)
+
+// NewKeystore parses a keystore. An empty keystore is valid.
+func NewKeystore(rawKeystore []byte) (*Keystore, error) {
+	ks := &Keystore{}
+	rest := bytes.TrimSpace(rawKeystore)
+	for len(rest) != 0 {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil || block.Type != keystoreKeyType {
+			return nil, ErrKeystoreInvalid
+		}
+		rest = bytes.TrimSpace(rest)
+
+		key, err := newKeystoreKey(block)
+		if err != nil {
+			return nil, errors.Wrap(err, ErrKeystoreInvalidKey)
+		}
+		if _, err := ks.Get(key.Name); err == nil {
+			return nil, errors.Wrap(ErrKeystoreKeyExists, ErrKeystoreInvalidKey)
+		}
+		ks.keys = append(ks.keys, key)
+	}
+	return ks, nil
+}
+
+func newKeystoreKey(block *pem.Block) (*KeystoreKey, error) {
+	var err error
+	key := &KeystoreKey{
+		Name:       block.Headers["Name"],
+		ciphertext: block.Bytes,
+	}
+	if len(key.Name) > MaxKeystoreNameSize || !ValidKeystoreName.MatchString(key.Name) {
+		return nil, ErrKeystoreInvalidName
+	}
+	rawPublicKey, err := hex.DecodeString(block.Headers["Public-Key"])
+	if err != nil {
+		return nil, err
+	}
+	key.PublicKey, err = NewDIDPublicKey(rawPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if block.Headers["Kdf"] != keystoreKdf {
+		return nil, errors.New("Unsupported Kdf " + block.Headers["Kdf"])
+	}
+	params := strings.Split(block.Headers["Kdf-Params"], ",")
+	if len(params) != 3 {
+		return nil, errors.New("Invalid Kdf-Params")
+	}
+	if key.scryptN, err = strconv.Atoi(params[0]); err != nil {
+		return nil, err
+	}
+	if key.scryptR, err = strconv.Atoi(params[1]); err != nil {
+		return nil, err
+	}
+	if key.scryptP, err = strconv.Atoi(params[2]); err != nil {
+		return nil, err
+	}
+	if key.scryptN < 2 || key.scryptN > keystoreMaxScryptCost/128 || key.scryptN&(key.scryptN-1) != 0 || key.scryptR < 1 || key.scryptR > keystoreMaxScryptR || key.scryptP < 1 || key.scryptP > keystoreMaxScryptP {
+		return nil, errors.New("Invalid Kdf-Params")
+	}
+	if 128*uint64(key.scryptN)*uint64(key.scryptR)*uint64(key.scryptP) > keystoreMaxScryptCost {
+		return nil, errors.New("Kdf-Params are too costly")
+	}
+	if key.salt, err = hex.DecodeString(block.Headers["Salt"]); err != nil {
+		return nil, err
+	}
+	if len(key.salt) < keystoreMinSaltLen {
+		return nil, errors.New("Salt is too short")
+	}
+	if key.nonce, err = hex.DecodeString(block.Headers["Nonce"]); err != nil {
+		return nil, err
+	}
+	if len(key.nonce) != keystoreNonceLen {
+		return nil, errors.New("Invalid Nonce size")
+	}
+	return key, nil
+}
+
+// Keys gets all the keys in the keystore, in the order they were added
+func (ks *Keystore) Keys() []*KeystoreKey {
+	return ks.keys
+}
+
+// Get gets a key by name
+func (ks *Keystore) Get(name string) (*KeystoreKey, error) {
+	for _, key := range ks.keys {
+		if key.Name == name {
+			return key, nil
+		}
+	}
+	return nil, ErrKeystoreKeyNotFound
+}
+
+// Add encrypts a private key with the passphrase and adds it to the keystore under the given name
+func (ks *Keystore) Add(name string, privateKey DIDPrivateKey, passphrase []byte) (*KeystoreKey, error) {
+	if len(name) > MaxKeystoreNameSize || !ValidKeystoreName.MatchString(name) {
+		return nil, ErrKeystoreInvalidName
+	}
+	if _, err := ks.Get(name); err == nil {
+		return nil, ErrKeystoreKeyExists
+	}
+	publicKey, err := privateKey.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &KeystoreKey{
+		Name:      name,
+		PublicKey: publicKey,
+		scryptN:   keystoreScryptN,
+		scryptR:   keystoreScryptR,
+		scryptP:   keystoreScryptP,
+		salt:      make([]byte, keystoreSaltLen),
+	}
+	_, err = rand.Read(key.salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := key.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	key.nonce = make([]byte, aead.NonceSize())
+	_, err = rand.Read(key.nonce)
+	if err != nil {
+		return nil, err
+	}
+	key.ciphertext = aead.Seal(nil, key.nonce, privateKey, publicKey.Bytes())
+
+	ks.keys = append(ks.keys, key)
+	return key, nil
+}
+
+// Remove removes a key from the keystore
+func (ks *Keystore) Remove(name string) error {
+	for i, key := range ks.keys {
+		if key.Name == name {
+			ks.keys = append(ks.keys[:i], ks.keys[i+1:]...)
+			return nil
+		}
+	}
+	return ErrKeystoreKeyNotFound
+}
+
+// Decrypt decrypts the private key using the passphrase
+func (key *KeystoreKey) Decrypt(passphrase []byte) (DIDPrivateKey, error) {
+	aead, err := key.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(key.nonce) != aead.NonceSize() {
+		return nil, ErrKeystoreInvalidKey
+	}
+	plaintext, err := aead.Open(nil, key.nonce, key.ciphertext, key.PublicKey.Bytes())
+	if err != nil {
+		return nil, ErrKeystorePassphrase
+	}
+	privateKey, err := NewDIDPrivateKey(plaintext)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrKeystorePassphrase)
+	}
+
+	// Make sure the private key belongs to the public key we have on record
+	publicKey, err := privateKey.GetPublicKeyFromPrivateKey()
+	if err != nil || !bytes.Equal(publicKey.Bytes(), key.PublicKey.Bytes()) {
+		return nil, ErrKeystorePassphrase
+	}
+	return privateKey, nil
+}
+
+// aead derives the encryption key from the passphrase
+func (key *KeystoreKey) aead(passphrase []byte) (cipher.AEAD, error) {
+	derived, err := scrypt.Key(passphrase, key.salt, key.scryptN, key.scryptR, key.scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// String returns the keystore as a series of PEM blocks
+func (ks *Keystore) String() string {
+	var buf bytes.Buffer
+	for _, key := range ks.keys {
+		pem.Encode(&buf, &pem.Block{
+			Type: keystoreKeyType,
+			Headers: map[string]string{
+				"Name":       key.Name,
+				"Public-Key": key.PublicKey.Hex(),
+				"Kdf":        keystoreKdf,
+				"Kdf-Params": strconv.Itoa(key.scryptN) + "," + strconv.Itoa(key.scryptR) + "," + strconv.Itoa(key.scryptP),
+				"Salt":       hex.EncodeToString(key.salt),
+				"Nonce":      hex.EncodeToString(key.nonce),
+			},
+			Bytes: key.ciphertext,
+		})
+	}
+	return buf.String()
+}
@@ -0,0 +1,124 @@
+package cryptoballot
+
+import (
+	"bytes"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/phayes/errors"
+)
+
+func TestKeystore(t *testing.T) {
+	priv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := priv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := NewKeystore(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ks.Add("alice", priv, []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ks.Add("alice", priv, []byte("correct horse"))
+	if err != ErrKeystoreKeyExists {
+		t.Error("Expected ErrKeystoreKeyExists, got", err)
+	}
+	_, err = ks.Add("not/valid", priv, []byte("correct horse"))
+	if err != ErrKeystoreInvalidName {
+		t.Error("Expected ErrKeystoreInvalidName, got", err)
+	}
+
+	// The private key is not stored in the clear
+	if bytes.Contains([]byte(ks.String()), []byte(priv.Hex())) {
+		t.Error("Keystore contains the unencrypted private key")
+	}
+
+	// Parse it back
+	ks, err = NewKeystore([]byte(ks.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ks.Get("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.PublicKey.Hex() != pub.Hex() {
+		t.Error("Public key does not round-trip")
+	}
+	decrypted, err := key.Decrypt([]byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, priv) {
+		t.Error("Decrypted key does not match")
+	}
+	_, err = key.Decrypt([]byte("wrong horse"))
+	if err != ErrKeystorePassphrase {
+		t.Error("Expected ErrKeystorePassphrase, got", err)
+	}
+
+	// Remove it
+	err = ks.Remove("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ks.Get("alice")
+	if err != ErrKeystoreKeyNotFound {
+		t.Error("Expected ErrKeystoreKeyNotFound, got", err)
+	}
+	if ks.String() != "" {
+		t.Error("Empty keystore should be empty")
+	}
+}
+
+func TestBadKeystore(t *testing.T) {
+	_, err := NewKeystore([]byte("not a keystore"))
+	if err == nil {
+		t.Error("Invalid keystore produced no error")
+	}
+	_, err = NewKeystore([]byte("-----BEGIN DID KEY-----\nName: alice\n\nAAAA\n-----END DID KEY-----\n"))
+	if err == nil {
+		t.Error("Keystore key without a public key produced no error")
+	}
+
+	// Start with a good key, and break one header at a time
+	priv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks, err := NewKeystore(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ks.Add("alice", priv, []byte("correct horse")); err != nil {
+		t.Fatal(err)
+	}
+	good, _ := pem.Decode([]byte(ks.String()))
+	badHeaders := map[string][]string{
+		"Nonce":      {"", "00", strings.Repeat("00", 16)},
+		"Salt":       {"", strings.Repeat("00", 8)},
+		"Kdf-Params": {"0,8,1", "1000,8,1", "32768,0,1", "32768,8,0", "32768,1000,1", "32768,8,1000", "1073741824,8,1", "262144,8,8", "-32768,8,1", "4611686018427387904,8,1"},
+	}
+	for header, values := range badHeaders {
+		for _, value := range values {
+			block := *good
+			block.Headers = map[string]string{}
+			for k, v := range good.Headers {
+				block.Headers[k] = v
+			}
+			block.Headers[header] = value
+			_, err = NewKeystore(pem.EncodeToMemory(&block))
+			if !errors.Is(err, ErrKeystoreInvalidKey) {
+				t.Errorf("%s: %s: expected ErrKeystoreInvalidKey, got %v", header, value, err)
+			}
+		}
+	}
+}
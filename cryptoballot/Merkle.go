@@ -0,0 +1,75 @@
+package cryptoballot
+
+import (
+	"crypto/sha256"
+	"sort"
+)
+
+// Prefixes used to distinguish leaf hashes from node hashes, so that a node can never be passed off as a leaf
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleRoot computes the root of a SHA256 Merkle tree over the given leaves.
+// Leaves are hashed as SHA256(0x00 || leaf) and nodes as SHA256(0x01 || left || right).
+// When a level has an odd number of hashes, the last hash is promoted to the next level unchanged.
+// The root of an empty tree is the SHA256 of nothing.
+func MerkleRoot(leaves [][]byte) []byte {
+	hashes := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		hashes[i] = MerkleLeafHash(leaf)
+	}
+	return MerkleRootOfHashes(hashes)
+}
+
+// MerkleLeafHash hashes a leaf of a Merkle tree, as SHA256(0x00 || leaf)
+func MerkleLeafHash(leaf []byte) []byte {
+	h := sha256.Sum256(append([]byte{merkleLeafPrefix}, leaf...))
+	return h[:]
+}
+
+// MerkleRootOfHashes computes the root of a Merkle tree from the hashes of its leaves, as given by MerkleLeafHash.
+// This lets a caller that keeps the leaf hashes recompute the root without rehashing every leaf.
+func MerkleRootOfHashes(hashes [][]byte) []byte {
+	if len(hashes) == 0 {
+		h := sha256.Sum256(nil)
+		return h[:]
+	}
+
+	level := hashes
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			buf := make([]byte, 0, 1+len(level[i])+len(level[i+1]))
+			buf = append(buf, merkleNodePrefix)
+			buf = append(buf, level[i]...)
+			buf = append(buf, level[i+1]...)
+			h := sha256.Sum256(buf)
+			next = append(next, h[:])
+		}
+		level = next
+	}
+
+	return level[0]
+}
+
+// BallotsMerkleRoot computes the Merkle root of a set of ballots.
+// Ballots are ordered by ballot-id, so the root does not depend on the order in which they were retrieved.
+func BallotsMerkleRoot(ballots []Ballot) []byte {
+	sorted := make([]Ballot, len(ballots))
+	copy(sorted, ballots)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].BallotID < sorted[j].BallotID
+	})
+
+	leaves := make([][]byte, len(sorted))
+	for i, ballot := range sorted {
+		leaves[i] = []byte(ballot.String())
+	}
+	return MerkleRoot(leaves)
+}
@@ -0,0 +1,67 @@
+package cryptoballot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestMerkleRoot(t *testing.T) {
+	leaf := func(data string) []byte {
+		h := sha256.Sum256(append([]byte{0x00}, data...))
+		return h[:]
+	}
+	node := func(left, right []byte) []byte {
+		h := sha256.Sum256(append(append([]byte{0x01}, left...), right...))
+		return h[:]
+	}
+
+	empty := sha256.Sum256(nil)
+	if !bytes.Equal(MerkleRoot(nil), empty[:]) {
+		t.Error("Wrong Merkle root for empty tree")
+	}
+
+	if !bytes.Equal(MerkleRoot([][]byte{[]byte("a")}), leaf("a")) {
+		t.Error("Wrong Merkle root for single leaf")
+	}
+
+	// With three leaves the last leaf is promoted
+	expected := node(node(leaf("a"), leaf("b")), leaf("c"))
+	root := MerkleRoot([][]byte{[]byte("a"), []byte("b"), []byte("c")})
+	if !bytes.Equal(root, expected) {
+		t.Errorf("Wrong Merkle root for three leaves. Expected %s, got %s", hex.EncodeToString(expected), hex.EncodeToString(root))
+	}
+
+	// Changing any leaf changes the root
+	if bytes.Equal(root, MerkleRoot([][]byte{[]byte("a"), []byte("b"), []byte("d")})) {
+		t.Error("Merkle root did not change when a leaf changed")
+	}
+
+	// The root may also be computed from the leaf hashes
+	hashes := [][]byte{MerkleLeafHash([]byte("a")), MerkleLeafHash([]byte("b")), MerkleLeafHash([]byte("c"))}
+	if !bytes.Equal(MerkleRootOfHashes(hashes), expected) {
+		t.Error("Wrong Merkle root computed from leaf hashes")
+	}
+	if !bytes.Equal(hashes[0], leaf("a")) {
+		t.Error("Wrong leaf hash")
+	}
+}
+
+func TestBallotsMerkleRoot(t *testing.T) {
+	ballots := []Ballot{
+		{ElectionID: "election1", BallotID: "ccc", Vote: Vote{"Alice"}},
+		{ElectionID: "election1", BallotID: "aaa", Vote: Vote{"Bob"}},
+		{ElectionID: "election1", BallotID: "bbb", Vote: Vote{"Alice"}},
+	}
+	reordered := []Ballot{ballots[1], ballots[2], ballots[0]}
+
+	if !bytes.Equal(BallotsMerkleRoot(ballots), BallotsMerkleRoot(reordered)) {
+		t.Error("Ballots Merkle root should not depend on ballot order")
+	}
+
+	expected := MerkleRoot([][]byte{[]byte(ballots[1].String()), []byte(ballots[2].String()), []byte(ballots[0].String())})
+	if !bytes.Equal(BallotsMerkleRoot(ballots), expected) {
+		t.Error("Ballots Merkle root should be computed over ballots sorted by ballot-id")
+	}
+}
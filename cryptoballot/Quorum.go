@@ -0,0 +1,127 @@
+package cryptoballot
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/phayes/errors"
+)
+
+const (
+	// ElectionQuorumTag gives the minimum turnout for the election's result to stand, as a share of the
+	// electorate (quorum=50% or quorum=1/2) or as a number of voters (quorum=25). See Threshold
+	ElectionQuorumTag = "quorum"
+
+	// ElectionThresholdTag gives the share of the votes the winner needs for a motion to pass. eg: threshold=2/3
+	ElectionThresholdTag = "threshold"
+
+	// ElectionElectorateTag gives the number of eligible voters, or their total weight in weighted elections.
+	// It is needed for quorums given as a share of the electorate.
+	ElectionElectorateTag = "electorate"
+)
+
+var (
+	ErrThresholdInvalid  = errors.New("Invalid threshold. Must be a percentage (60%), a fraction (2/3) or a whole number (25), and no more than the whole")
+	ErrElectorateInvalid = errors.New("Invalid electorate. Must be a positive whole number")
+)
+
+// A Threshold is either a share, given as a percentage or a fraction, or an absolute count.
+// Shares are met by counts of at least the share of the total, so a threshold of 1/2 is met by exactly half.
+type Threshold struct {
+	Numerator   uint64
+	Denominator uint64 // Zero for absolute counts
+}
+
+// NewThreshold parses a threshold such as 60%, 2/3 or 25
+func NewThreshold(rawThreshold string) (*Threshold, error) {
+	var threshold Threshold
+	var err error
+	switch {
+	case strings.HasSuffix(rawThreshold, "%"):
+		threshold.Denominator = 100
+		threshold.Numerator, err = strconv.ParseUint(strings.TrimSuffix(rawThreshold, "%"), 10, 64)
+	case strings.Contains(rawThreshold, "/"):
+		parts := strings.SplitN(rawThreshold, "/", 2)
+		threshold.Numerator, err = strconv.ParseUint(parts[0], 10, 64)
+		if err == nil {
+			threshold.Denominator, err = strconv.ParseUint(parts[1], 10, 64)
+		}
+		if threshold.Denominator == 0 {
+			err = ErrThresholdInvalid
+		}
+	default:
+		threshold.Numerator, err = strconv.ParseUint(rawThreshold, 10, 64)
+	}
+	if err != nil || threshold.Numerator == 0 || (threshold.IsShare() && threshold.Numerator > threshold.Denominator) {
+		return nil, errors.Wraps(ErrThresholdInvalid, rawThreshold)
+	}
+	return &threshold, nil
+}
+
+// IsShare checks if the threshold is a share of a total, rather than an absolute count
+func (threshold Threshold) IsShare() bool {
+	return threshold.Denominator != 0
+}
+
+// Met checks if a count meets the threshold. Shares are of the total, which is ignored for absolute counts.
+func (threshold Threshold) Met(count, total uint64) bool {
+	if !threshold.IsShare() {
+		return count >= threshold.Numerator
+	}
+	// count/total >= numerator/denominator, without rounding or overflow
+	left := new(big.Int).Mul(new(big.Int).SetUint64(count), new(big.Int).SetUint64(threshold.Denominator))
+	right := new(big.Int).Mul(new(big.Int).SetUint64(total), new(big.Int).SetUint64(threshold.Numerator))
+	return left.Cmp(right) >= 0
+}
+
+// Implements Stringer. Returns the threshold as it was written in the election tag
+func (threshold Threshold) String() string {
+	switch threshold.Denominator {
+	case 0:
+		return strconv.FormatUint(threshold.Numerator, 10)
+	case 100:
+		return strconv.FormatUint(threshold.Numerator, 10) + "%"
+	default:
+		return strconv.FormatUint(threshold.Numerator, 10) + "/" + strconv.FormatUint(threshold.Denominator, 10)
+	}
+}
+
+// DecisionRules are the quorum and pass threshold an election declares in its tags.
+// Either may be nil, in which case the election has no such rule.
+type DecisionRules struct {
+	Quorum     *Threshold
+	Threshold  *Threshold // Always a share
+	Electorate uint64     // Zero if not declared
+}
+
+// DecisionRules gets the quorum, threshold and electorate declared by the election's tags
+func (election *Election) DecisionRules() (DecisionRules, error) {
+	var rules DecisionRules
+	var err error
+	tags := election.TagSet.Map()
+	if value, ok := tags[ElectionQuorumTag]; ok {
+		if rules.Quorum, err = NewThreshold(value); err != nil {
+			return rules, err
+		}
+	}
+	if value, ok := tags[ElectionThresholdTag]; ok {
+		if rules.Threshold, err = NewThreshold(value); err != nil {
+			return rules, err
+		}
+		if !rules.Threshold.IsShare() {
+			return rules, errors.Wraps(ErrThresholdInvalid, "The threshold must be a share of the votes, such as 50% or 2/3")
+		}
+	}
+	if value, ok := tags[ElectionElectorateTag]; ok {
+		if rules.Electorate, err = strconv.ParseUint(value, 10, 64); err != nil || rules.Electorate == 0 {
+			return rules, ErrElectorateInvalid
+		}
+	}
+	return rules, nil
+}
+
+// Declared checks if the election has a quorum or a threshold
+func (rules DecisionRules) Declared() bool {
+	return rules.Quorum != nil || rules.Threshold != nil
+}
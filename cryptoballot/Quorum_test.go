@@ -0,0 +1,74 @@
+package cryptoballot
+
+import (
+	"testing"
+)
+
+func TestThreshold(t *testing.T) {
+	thresholds := []struct {
+		raw          string
+		count, total uint64
+		met          bool
+	}{
+		{"50%", 5, 10, true},
+		{"50%", 4, 10, false},
+		{"2/3", 2, 3, true},
+		{"2/3", 66, 100, false},
+		{"2/3", 67, 100, true},
+		{"100%", 10, 10, true},
+		{"25", 25, 0, true},
+		{"25", 24, 1000, false},
+		{"1/2", 1 << 63, ^uint64(0), true},
+	}
+	for _, test := range thresholds {
+		threshold, err := NewThreshold(test.raw)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %s", test.raw, err)
+			continue
+		}
+		if threshold.String() != test.raw {
+			t.Errorf("Threshold %s changed in round trip to %s", test.raw, threshold)
+		}
+		if threshold.Met(test.count, test.total) != test.met {
+			t.Errorf("Expected %d of %d meeting %s to be %v", test.count, test.total, test.raw, test.met)
+		}
+	}
+
+	bad := []string{"", "0", "0%", "101%", "3/2", "1/0", "-1", "half", "50 %"}
+	for _, raw := range bad {
+		if _, err := NewThreshold(raw); err == nil {
+			t.Errorf("Expected error for threshold %q", raw)
+		}
+	}
+}
+
+func TestDecisionRules(t *testing.T) {
+	tagSet, err := NewTagSet([]byte("quorum=50%\nthreshold=2/3\nelectorate=120"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	election := Election{ElectionID: "motion", TagSet: tagSet}
+	rules, err := election.DecisionRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rules.Declared() || rules.Quorum.String() != "50%" || rules.Threshold.String() != "2/3" || rules.Electorate != 120 {
+		t.Errorf("Unexpected rules %v", rules)
+	}
+
+	rules, err = (&Election{ElectionID: "plain"}).DecisionRules()
+	if err != nil || rules.Declared() {
+		t.Error("Expected an election without tags to have no rules", err)
+	}
+
+	bad := []string{"quorum=half", "threshold=25", "threshold=3/2", "electorate=0", "electorate=many"}
+	for _, raw := range bad {
+		tagSet, err := NewTagSet([]byte(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := (&Election{ElectionID: "bad", TagSet: tagSet}).DecisionRules(); err == nil {
+			t.Errorf("Expected error for tag %s", raw)
+		}
+	}
+}
@@ -3,24 +3,38 @@ package cryptoballot
 import (
 	"bytes"
 	"encoding/hex"
+	"strconv"
+
 	"github.com/elastos/Elastos.ELA.Utility/common"
 	"github.com/elastos/Elastos.ELA.Utility/crypto"
 
 	"github.com/phayes/errors"
 )
 
+const signatureRequestDenominationPrefix = "denomination:"
+
+// MaxSignatureRequestSize is the largest Signature Request accepted, in bytes. It leaves room for a verifiable
+// presentation of several credentials.
+const MaxSignatureRequestSize = 64 * 1024
+
 type SignatureRequest struct {
-	ElectionID  string
-	RequestID   []byte // SHA256 (hex) of base64 encoded public-key
-	PublicKey   []byte // did public-key of voter
-	BlindBallot        // Blinded ballot (blinded full-domain-hash of the ballot).
-	Signature   []byte // Voter signature for the ballot request
+	ElectionID   string
+	RequestID    []byte // Double SHA256 of the voter's DID if given, otherwise of their public key
+	PublicKey    []byte // did public-key of voter
+	DID          string // Optional DID of the voter. PublicKey must be one of its authentication keys
+	Presentation []byte // Optional verifiable presentation of the voter's credentials, as compact JSON. Requires a DID
+	Denomination uint64 // Optional denomination of the ballot in a weighted election. Zero if not given. See Weight.go
+	BlindBallot         // Blinded ballot (blinded full-domain-hash of the ballot).
+	Signature    []byte // Voter signature for the ballot request
 }
 
 var (
 	ErrSignatureRequestInvalid    = errors.New("Cannot read Signature Request. Invalid format")
+	ErrSignatureRequestTooBig     = errors.Newf("This Signature Request is too big. Maximum size is %d bytes", MaxSignatureRequestSize)
 	ErrSignatureRequestPublicKey  = errors.New("Cannot read Signature Request. Invalid Public Key")
-	ErrSignatureRequestID         = errors.New("Invalid SignatureRequest ID. A SignatureRequest ID must be the (hex encoded) SHA256 of the voters public key.")
+	ErrSignatureRequestID         = errors.New("Invalid SignatureRequest ID. A SignatureRequest ID must be the double SHA256 of the voter's DID, or of their public key if no DID is given.")
+	ErrSignatureRequestDID        = errors.New("Invalid Signature Request. Invalid voter DID")
+	ErrSignatureRequestVP         = errors.New("Invalid Signature Request. A verifiable presentation must be made by the voter's DID")
 	ErrSignatureRequestBallotHash = errors.New("Invalid Signature Request. Ballot hash must be hex encoded.")
 	ErrSignatureRequestHashBits   = errors.New("Invalid Signature Request. You must provide exactly 256 bits for the blinded SHA256 ballot hash")
 	ErrSignatureRequestSigInvalid = errors.New("Invalid Signature Request. Could not parse voter signature")
@@ -33,18 +47,60 @@ var (
 // This will also verify the signature on the SignatureRequest and return an error if the request does not pass crypto verification
 func NewSignatureRequest(rawSignatureRequest []byte) (*SignatureRequest, error) {
 	var (
-		err         error
-		hasSign     bool
-		electionID  string
-		requestID   []byte
-		publicKey   []byte
-		blindBallot BlindBallot
-		signature   []byte
+		err          error
+		hasSign      bool
+		electionID   string
+		requestID    []byte
+		publicKey    []byte
+		did          string
+		presentation []byte
+		denomination uint64
+		blindBallot  BlindBallot
+		signature    []byte
 	)
 
+	// Check it's size
+	if len(rawSignatureRequest) > MaxSignatureRequestSize {
+		return nil, ErrSignatureRequestTooBig
+	}
+
 	// The SignatureRequest is composed of individual components seperated by double linebreaks
 	parts := bytes.Split(rawSignatureRequest, []byte("\n\n"))
 
+	// The voter's DID is optional and comes after the public key. A blind ballot never starts with "did:"
+	if len(parts) > 4 && bytes.HasPrefix(parts[3], []byte("did:")) {
+		did = string(parts[3])
+		if !ValidDIDString(did) {
+			return &SignatureRequest{}, ErrSignatureRequestDID
+		}
+		parts = append(parts[:3:3], parts[4:]...)
+	}
+
+	// The verifiable presentation is optional and comes after the DID. It is compact JSON, so it starts with "{"
+	if len(parts) > 4 && bytes.HasPrefix(parts[3], []byte("{")) {
+		if did == "" {
+			return &SignatureRequest{}, ErrSignatureRequestVP
+		}
+		vp, err := NewPresentation(parts[3])
+		if err != nil {
+			return &SignatureRequest{}, err
+		}
+		if vp.Holder != did {
+			return &SignatureRequest{}, ErrSignatureRequestVP
+		}
+		presentation = parts[3]
+		parts = append(parts[:3:3], parts[4:]...)
+	}
+
+	// The denomination is optional and comes last before the blind ballot
+	if len(parts) > 4 && bytes.HasPrefix(parts[3], []byte(signatureRequestDenominationPrefix)) {
+		denomination, err = parseDenomination(string(bytes.TrimPrefix(parts[3], []byte(signatureRequestDenominationPrefix))))
+		if err != nil {
+			return &SignatureRequest{}, err
+		}
+		parts = append(parts[:3:3], parts[4:]...)
+	}
+
 	numParts := len(parts)
 
 	switch {
@@ -63,9 +119,17 @@ func NewSignatureRequest(rawSignatureRequest []byte) (*SignatureRequest, error)
 		return &SignatureRequest{}, errors.Wrap(err, ErrSignatureRequestPublicKey)
 	}
 
-	requestID = parts[1]
-	pub := common.Sha256D(publicKey)
-	if !bytes.Equal(requestID, pub[:]) {
+	// The request ID is hex encoded. Before version 0.2 it was the raw bytes, which voters signed, so requests
+	// from older clients fail here rather than failing signature verification
+	requestID, err = hex.DecodeString(string(parts[1]))
+	if err != nil {
+		return &SignatureRequest{}, errors.Wrap(err, ErrSignatureRequestID)
+	}
+	expectedID := common.Sha256D(publicKey)
+	if did != "" {
+		expectedID = common.Sha256D([]byte(did))
+	}
+	if !bytes.Equal(requestID, expectedID[:]) {
 		return &SignatureRequest{}, ErrSignatureRequestID
 	}
 
@@ -84,17 +148,28 @@ func NewSignatureRequest(rawSignatureRequest []byte) (*SignatureRequest, error)
 	}
 
 	sigReq := SignatureRequest{
-		electionID,
-		requestID,
-		publicKey,
-		blindBallot,
-		signature,
+		ElectionID:   electionID,
+		RequestID:    requestID,
+		PublicKey:    publicKey,
+		DID:          did,
+		Presentation: presentation,
+		Denomination: denomination,
+		BlindBallot:  blindBallot,
+		Signature:    signature,
 	}
 
 	// All checks pass
 	return &sigReq, nil
 }
 
+// GetPresentation parses the voter's verifiable presentation. It is nil if the request does not carry one.
+func (sigReq *SignatureRequest) GetPresentation() (*Presentation, error) {
+	if len(sigReq.Presentation) == 0 {
+		return nil, nil
+	}
+	return NewPresentation(sigReq.Presentation)
+}
+
 // Verify the voter's signature attached to the SignatureRequest
 func (sigReq *SignatureRequest) VerifySignature() error {
 	if !sigReq.HasSignature() {
@@ -128,6 +203,16 @@ func (sigReq SignatureRequest) String() string {
 
 // StringWithoutSignature returns the SignatureRequest as a string, without the signature of the requesting client.
 func (sigReq SignatureRequest) StringWithoutSignature() string {
-	s := sigReq.ElectionID + "\n\n" + string(sigReq.RequestID) + "\n\n" + hex.EncodeToString(sigReq.PublicKey) + "\n\n" + sigReq.BlindBallot.String()
+	s := sigReq.ElectionID + "\n\n" + hex.EncodeToString(sigReq.RequestID) + "\n\n" + hex.EncodeToString(sigReq.PublicKey)
+	if sigReq.DID != "" {
+		s += "\n\n" + sigReq.DID
+	}
+	if len(sigReq.Presentation) != 0 {
+		s += "\n\n" + string(sigReq.Presentation)
+	}
+	if sigReq.Denomination != 0 {
+		s += "\n\n" + signatureRequestDenominationPrefix + strconv.FormatUint(sigReq.Denomination, 10)
+	}
+	s += "\n\n" + sigReq.BlindBallot.String()
 	return s
 }
@@ -1,8 +1,12 @@
 package cryptoballot
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 var (
@@ -54,3 +58,168 @@ func TestGoodSignatureRequest(t *testing.T) {
 		t.Errorf("FulfilledSignatureRequest failed string round-trip")
 	}
 }
+
+func TestDIDSignatureRequest(t *testing.T) {
+	voterPriv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	voterPub, err := voterPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	did := "did:elastos:icJ4z2DULrHEzYSvjKNJpKyhqFDxvYV7pN"
+
+	blindBallot, _ := NewBlindBallot([]byte(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("b", 256)))))
+	req := SignatureRequest{
+		ElectionID:  "testelection",
+		RequestID:   DIDRequestID(did),
+		PublicKey:   voterPub.Bytes(),
+		DID:         did,
+		BlindBallot: blindBallot,
+	}
+	req.Signature, err = voterPriv.SignString(req.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := NewSignatureRequest([]byte(req.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*parsed, req) {
+		t.Error("SignatureRequest with a DID failed string round-trip")
+	}
+	if err = parsed.VerifySignature(); err != nil {
+		t.Error(err)
+	}
+
+	// Also without the voter signature
+	unsigned := req
+	unsigned.Signature = nil
+	parsed, err = NewSignatureRequest([]byte(unsigned.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.DID != did || parsed.HasSignature() {
+		t.Error("Unsigned SignatureRequest with a DID failed string round-trip")
+	}
+
+	ballotSignature, _ := NewSignature([]byte(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("s", 256)))))
+	fulfilled := NewFulfilledSignatureRequestFromParts(req, ballotSignature)
+	parsedFulfilled, err := NewFulfilledSignatureRequest([]byte(fulfilled.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*parsedFulfilled, *fulfilled) {
+		t.Error("FulfilledSignatureRequest with a DID failed string round-trip")
+	}
+
+	// The request ID must be derived from the DID, not the public key
+	req.RequestID = voterPub.RequestID()
+	_, err = NewSignatureRequest([]byte(req.String()))
+	if err == nil {
+		t.Error("SignatureRequest with a DID but a public key request ID produced no error")
+	}
+
+	// Requests from clients before 0.2, with the raw bytes of the request ID, are refused
+	old := strings.Replace(unsigned.String(), hex.EncodeToString(unsigned.RequestID), string(unsigned.RequestID), 1)
+	if _, err = NewSignatureRequest([]byte(old)); err == nil {
+		t.Error("SignatureRequest with a raw request ID produced no error")
+	}
+
+	// The DID must be well formed
+	req.RequestID = DIDRequestID("did:elastos:0OIl")
+	req.DID = "did:elastos:0OIl"
+	_, err = NewSignatureRequest([]byte(req.String()))
+	if err == nil {
+		t.Error("SignatureRequest with an invalid DID produced no error")
+	}
+}
+
+func TestPresentationSignatureRequest(t *testing.T) {
+	voterPriv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	voterPub, err := voterPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerPriv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	did := "did:elastos:icJ4z2DULrHEzYSvjKNJpKyhqFDxvYV7pN"
+
+	cred, err := NewUnsignedCredential("urn:member:1", "did:elastos:iSsuer", did, []string{"MemberCredential"}, nil, time.Now(), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cred.Sign(issuerPriv, "did:elastos:iSsuer#primary"); err != nil {
+		t.Fatal(err)
+	}
+	presentation, err := CreatePresentation(did, []*Credential{cred}, "testelection", DIDRequestID(did), voterPriv, did)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blindBallot, _ := NewBlindBallot([]byte(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("b", 256)))))
+	req := SignatureRequest{
+		ElectionID:   "testelection",
+		RequestID:    DIDRequestID(did),
+		PublicKey:    voterPub.Bytes(),
+		DID:          did,
+		Presentation: []byte(presentation.String()),
+		BlindBallot:  blindBallot,
+	}
+	req.Signature, err = voterPriv.SignString(req.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := NewSignatureRequest([]byte(req.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*parsed, req) {
+		t.Error("SignatureRequest with a presentation failed string round-trip")
+	}
+	if err = parsed.VerifySignature(); err != nil {
+		t.Error(err)
+	}
+	parsedPresentation, err := parsed.GetPresentation()
+	if err != nil || parsedPresentation.Holder != did || len(parsedPresentation.Credentials) != 1 {
+		t.Errorf("Presentation parsed incorrectly: %v", err)
+	}
+
+	ballotSignature, _ := NewSignature([]byte(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("s", 256)))))
+	fulfilled := NewFulfilledSignatureRequestFromParts(req, ballotSignature)
+	parsedFulfilled, err := NewFulfilledSignatureRequest([]byte(fulfilled.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*parsedFulfilled, *fulfilled) {
+		t.Error("FulfilledSignatureRequest with a presentation failed string round-trip")
+	}
+
+	// The presentation must be made by the voter's DID
+	req.DID = "did:elastos:iAnother"
+	req.RequestID = DIDRequestID(req.DID)
+	if _, err = NewSignatureRequest([]byte(req.String())); err != ErrSignatureRequestVP {
+		t.Errorf("Expected ErrSignatureRequestVP, got %v", err)
+	}
+	req.DID = ""
+	req.RequestID = voterPub.RequestID()
+	if _, err = NewSignatureRequest([]byte(req.String())); err != ErrSignatureRequestVP {
+		t.Errorf("Expected ErrSignatureRequestVP, got %v", err)
+	}
+
+	// Presentations are bounded by the maximum Signature Request size
+	req.DID = did
+	req.RequestID = DIDRequestID(did)
+	req.Presentation = append(req.Presentation, strings.Repeat(" ", MaxSignatureRequestSize)...)
+	if _, err = NewSignatureRequest([]byte(req.String())); err != ErrSignatureRequestTooBig {
+		t.Errorf("Expected ErrSignatureRequestTooBig, got %v", err)
+	}
+}
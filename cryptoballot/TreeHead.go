@@ -0,0 +1,186 @@
+package cryptoballot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"github.com/phayes/errors"
+)
+
+var (
+	ErrTreeHeadInvalid       = errors.New("Cannot read tree head. Invalid format")
+	ErrTreeHeadInvalidSigner = errors.New("Invalid tree head public key")
+	ErrTreeHeadInvalidSig    = errors.New("Invalid tree head signature")
+	ErrTreeHeadSigNotFound   = errors.New("Could not verify tree head signature: Signature does not exist")
+	ErrTreeHeadBallots       = errors.New("Ballots do not match the tree head")
+)
+
+// A TreeHead is a ballotbox mirror's signed statement of the ballots it has published for an election. A mirror
+// that later drops or alters one of those ballots can be shown to have done so with its own tree head.
+//
+// The text format is as follows, with each section separated by a double line break:
+//  1. Election ID
+//  2. Time, in RFC1123Z
+//  3. The number of ballots published
+//  4. The hex encoded Merkle root of those ballots. See BallotsMerkleRoot
+//  5. The mirror's hex encoded DID public key
+//  6. The mirror's hex encoded signature of everything above
+type TreeHead struct {
+	ElectionID string
+	Time       time.Time
+	Ballots    int
+	Root       []byte
+	PublicKey  []byte
+	Signature  []byte
+}
+
+// NewTreeHeadForBallots creates an unsigned tree head for the ballots a mirror has published. The caller sets
+// PublicKey and signs StringWithoutSignature.
+func NewTreeHeadForBallots(electionID string, ballots []Ballot, at time.Time) *TreeHead {
+	return &TreeHead{
+		ElectionID: electionID,
+		Time:       at,
+		Ballots:    len(ballots),
+		Root:       BallotsMerkleRoot(ballots),
+	}
+}
+
+// NewTreeHead parses a tree head. The signature is not verified.
+func NewTreeHead(rawTreeHead []byte) (*TreeHead, error) {
+	parts := bytes.Split(rawTreeHead, []byte("\n\n"))
+	if len(parts) != 5 && len(parts) != 6 {
+		return nil, ErrTreeHeadInvalid
+	}
+
+	var (
+		head TreeHead
+		err  error
+	)
+	head.ElectionID = string(parts[0])
+	if !ValidElectionID.MatchString(head.ElectionID) {
+		return nil, errors.Wrap(ErrElectionIDInvalid, ErrTreeHeadInvalid)
+	}
+	if head.Time, err = time.Parse(time.RFC1123Z, string(parts[1])); err != nil {
+		return nil, errors.Wrap(err, ErrTreeHeadInvalid)
+	}
+	if head.Ballots, err = strconv.Atoi(string(parts[2])); err != nil || head.Ballots < 0 {
+		return nil, errors.Wraps(ErrTreeHeadInvalid, "Invalid number of ballots")
+	}
+	if head.Root, err = hex.DecodeString(string(parts[3])); err != nil || len(head.Root) != sha256.Size {
+		return nil, errors.Wraps(ErrTreeHeadInvalid, "Invalid Merkle root")
+	}
+	if head.PublicKey, err = hex.DecodeString(string(parts[4])); err != nil {
+		return nil, errors.Wrap(err, ErrTreeHeadInvalidSigner)
+	}
+	if len(parts) == 6 {
+		if head.Signature, err = hex.DecodeString(string(parts[5])); err != nil {
+			return nil, errors.Wrap(err, ErrTreeHeadInvalidSig)
+		}
+	}
+	return &head, nil
+}
+
+// VerifySignature verifies that the tree head has been signed by the DID key in head.PublicKey.
+// It does not check which mirror that key belongs to.
+func (head *TreeHead) VerifySignature() error {
+	if !head.HasSignature() {
+		return ErrTreeHeadSigNotFound
+	}
+	publicKey, err := crypto.DecodePoint(head.PublicKey)
+	if err != nil {
+		return errors.Wrap(err, ErrTreeHeadInvalidSigner)
+	}
+	didPublicKey := DIDPublicKey{PublicKey: *publicKey}
+	if err = didPublicKey.VerifySignature(head.Signature, []byte(head.StringWithoutSignature())); err != nil {
+		return errors.Wrap(err, ErrTreeHeadInvalidSig)
+	}
+	return nil
+}
+
+// VerifyBallots checks that the ballots are exactly the ones the tree head was made for
+func (head *TreeHead) VerifyBallots(ballots []Ballot) error {
+	if len(ballots) != head.Ballots {
+		return errors.Wrapf(ErrTreeHeadBallots, "The tree head covers %d ballots, but %d were given", head.Ballots, len(ballots))
+	}
+	if !bytes.Equal(BallotsMerkleRoot(ballots), head.Root) {
+		return errors.Wraps(ErrTreeHeadBallots, "The Merkle root of the ballots does not match")
+	}
+	return nil
+}
+
+// HasSignature checks to see if the tree head has been signed. It does not verify the signature.
+func (head *TreeHead) HasSignature() bool {
+	return len(head.Signature) != 0
+}
+
+// Implements Stringer. Outputs the tree head in the format expected by NewTreeHead
+func (head TreeHead) String() string {
+	s := head.StringWithoutSignature()
+	if head.HasSignature() {
+		s += "\n\n" + hex.EncodeToString(head.Signature)
+	}
+	return s
+}
+
+// StringWithoutSignature gets the tree head without the signature, OK for signing
+func (head TreeHead) StringWithoutSignature() string {
+	return head.ElectionID + "\n\n" +
+		head.Time.Format(time.RFC1123Z) + "\n\n" +
+		strconv.Itoa(head.Ballots) + "\n\n" +
+		hex.EncodeToString(head.Root) + "\n\n" +
+		hex.EncodeToString(head.PublicKey)
+}
+
+// A MirrorDiscrepancy is a ballot that some ballotbox mirrors publish and others do not
+type MirrorDiscrepancy struct {
+	BallotID    string
+	MissingFrom []string // The mirrors that do not publish the ballot, sorted
+}
+
+// CompareMirrors compares the ballots published by each mirror, keyed by the mirror's URL, and finds every
+// ballot that is missing from at least one of them. A ballot that two mirrors publish with different contents
+// is missing from each mirror that does not publish that exact text. Discrepancies are sorted by ballot ID.
+func CompareMirrors(mirrors map[string][]Ballot) []MirrorDiscrepancy {
+	type published struct {
+		ballotID string
+		mirrors  map[string]bool
+	}
+	ballots := map[string]*published{}
+	for mirror, mirrorBallots := range mirrors {
+		for _, ballot := range mirrorBallots {
+			text := ballot.String()
+			if ballots[text] == nil {
+				ballots[text] = &published{ballotID: ballot.BallotID, mirrors: map[string]bool{}}
+			}
+			ballots[text].mirrors[mirror] = true
+		}
+	}
+
+	var discrepancies []MirrorDiscrepancy
+	for _, ballot := range ballots {
+		if len(ballot.mirrors) == len(mirrors) {
+			continue
+		}
+		discrepancy := MirrorDiscrepancy{BallotID: ballot.ballotID}
+		for mirror := range mirrors {
+			if !ballot.mirrors[mirror] {
+				discrepancy.MissingFrom = append(discrepancy.MissingFrom, mirror)
+			}
+		}
+		sort.Strings(discrepancy.MissingFrom)
+		discrepancies = append(discrepancies, discrepancy)
+	}
+	sort.Slice(discrepancies, func(i, j int) bool {
+		if discrepancies[i].BallotID != discrepancies[j].BallotID {
+			return discrepancies[i].BallotID < discrepancies[j].BallotID
+		}
+		return strings.Join(discrepancies[i].MissingFrom, " ") < strings.Join(discrepancies[j].MissingFrom, " ")
+	})
+	return discrepancies
+}
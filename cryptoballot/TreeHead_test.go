@@ -0,0 +1,108 @@
+package cryptoballot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/phayes/errors"
+)
+
+func TestTreeHead(t *testing.T) {
+	priv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := priv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ballots := []Ballot{
+		{ElectionID: "election1", BallotID: "ccc", Vote: Vote{"Alice"}},
+		{ElectionID: "election1", BallotID: "aaa", Vote: Vote{"Bob"}},
+	}
+
+	head := NewTreeHeadForBallots("election1", ballots, time.Now())
+	head.PublicKey = pub.Bytes()
+	head.Signature, err = priv.SignString(head.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Round trip, as a voter or auditor would read it from a mirror
+	parsed, err := NewTreeHead([]byte(head.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != head.String() {
+		t.Errorf("Tree head did not round trip:\n%s\n%s", head, parsed)
+	}
+	if err = parsed.VerifySignature(); err != nil {
+		t.Error(err)
+	}
+	if err = parsed.VerifyBallots([]Ballot{ballots[1], ballots[0]}); err != nil {
+		t.Error(err)
+	}
+
+	// Dropping or altering a ballot is caught
+	if err = parsed.VerifyBallots(ballots[:1]); !errors.Is(err, ErrTreeHeadBallots) {
+		t.Errorf("Expected ErrTreeHeadBallots for a dropped ballot, got %v", err)
+	}
+	altered := []Ballot{ballots[0], {ElectionID: "election1", BallotID: "aaa", Vote: Vote{"Alice"}}}
+	if err = parsed.VerifyBallots(altered); !errors.Is(err, ErrTreeHeadBallots) {
+		t.Errorf("Expected ErrTreeHeadBallots for an altered ballot, got %v", err)
+	}
+
+	// Altering the tree head breaks its signature
+	parsed.Ballots = 1
+	if err = parsed.VerifySignature(); !errors.Is(err, ErrTreeHeadInvalidSig) {
+		t.Errorf("Expected ErrTreeHeadInvalidSig, got %v", err)
+	}
+	parsed.Signature = nil
+	if err = parsed.VerifySignature(); err != ErrTreeHeadSigNotFound {
+		t.Errorf("Expected ErrTreeHeadSigNotFound, got %v", err)
+	}
+}
+
+func TestBadTreeHead(t *testing.T) {
+	zeros := "0000000000000000000000000000000000000000000000000000000000000000"
+	badHeads := []string{
+		"election1\n\nMon, 02 Jan 2006 15:04:05 -0700\n\n2\n\n" + zeros,
+		"bad id\n\nMon, 02 Jan 2006 15:04:05 -0700\n\n2\n\n" + zeros + "\n\n00",
+		"election1\n\nyesterday\n\n2\n\n" + zeros + "\n\n00",
+		"election1\n\nMon, 02 Jan 2006 15:04:05 -0700\n\n-1\n\n" + zeros + "\n\n00",
+		"election1\n\nMon, 02 Jan 2006 15:04:05 -0700\n\n2\n\n00\n\n00",
+		"election1\n\nMon, 02 Jan 2006 15:04:05 -0700\n\n2\n\n" + zeros + "\n\nxyz",
+		"election1\n\nMon, 02 Jan 2006 15:04:05 -0700\n\n2\n\n" + zeros + "\n\n00\n\nxyz",
+	}
+	for _, raw := range badHeads {
+		if _, err := NewTreeHead([]byte(raw)); err == nil {
+			t.Errorf("Expected error parsing %q", raw)
+		}
+	}
+}
+
+func TestCompareMirrors(t *testing.T) {
+	a := Ballot{ElectionID: "election1", BallotID: "aaa", Vote: Vote{"Alice"}}
+	b := Ballot{ElectionID: "election1", BallotID: "bbb", Vote: Vote{"Bob"}}
+	c := Ballot{ElectionID: "election1", BallotID: "ccc", Vote: Vote{"Alice"}}
+	altered := Ballot{ElectionID: "election1", BallotID: "ccc", Vote: Vote{"Bob"}}
+
+	if discrepancies := CompareMirrors(map[string][]Ballot{"mirror1": {a, b}, "mirror2": {b, a}}); len(discrepancies) != 0 {
+		t.Errorf("Expected mirrors with the same ballots to agree, got %v", discrepancies)
+	}
+
+	discrepancies := CompareMirrors(map[string][]Ballot{
+		"mirror1": {a, b, c},
+		"mirror2": {a, altered},
+		"mirror3": {a, c},
+	})
+	expected := []MirrorDiscrepancy{
+		{BallotID: "bbb", MissingFrom: []string{"mirror2", "mirror3"}},
+		{BallotID: "ccc", MissingFrom: []string{"mirror1", "mirror3"}},
+		{BallotID: "ccc", MissingFrom: []string{"mirror2"}},
+	}
+	if !reflect.DeepEqual(discrepancies, expected) {
+		t.Errorf("Expected %v, got %v", expected, discrepancies)
+	}
+}
@@ -15,6 +15,14 @@ const MaxVoteBytes = 256
 // The total maximum vote-size is options*chracters + seperator characters
 var maxVoteSize = (MaxVoteOptions * MaxVoteBytes) + MaxVoteOptions
 
+// Markers may be given as the only line of a vote instead of choosing candidates.
+// A vote with no lines at all is blank. Marked votes are counted separately from candidate votes. See Vote.Marker
+const (
+	VoteBlank   = "@blank"   // The voter chooses none of the candidates
+	VoteAbstain = "@abstain" // The voter takes part in the election, but abstains from the decision
+	VoteSpoiled = "@spoiled" // The voter deliberately spoils their ballot
+)
+
 // A Vote is an ordered list of choices as strings
 // It's up to the counting / tallying applications to assign meaning to these strings
 type Vote []string
@@ -23,6 +31,9 @@ var (
 	ErrVoteTooBig         = errors.Newf("Vote has too many bytes. A vote may have a maximum of %d characters, including seperators", maxVoteSize)
 	ErrVoteTooManyOptions = errors.Newf("Vote has too many options")
 	ErrVoteOptionTooBig   = errors.Newf("Vote option has too many characters")
+	ErrVoteOptionEmpty    = errors.Newf("Vote option is empty. Leave the whole vote empty to cast a blank vote")
+	ErrVoteMarkerNotAlone = errors.Newf("Vote markers must be the only line of a vote")
+	ErrVoteMarkerUnknown  = errors.Newf("Unknown vote marker. Markers are %s, %s and %s", VoteBlank, VoteAbstain, VoteSpoiled)
 )
 
 // Given a raw slice of bytes, construct a Vote
@@ -31,6 +42,9 @@ func NewVote(rawVote []byte) (Vote, error) {
 	if len(rawVote) > maxVoteSize {
 		return Vote{}, ErrVoteTooBig
 	}
+	if len(rawVote) == 0 {
+		return Vote{}, nil
+	}
 	vote := Vote(strings.Split(string(rawVote), "\n"))
 	if len(vote) > MaxVoteOptions {
 		return Vote{}, errors.Wrapf(ErrVoteTooManyOptions, "A vote may have a maximum of %d option lines", MaxVoteOptions)
@@ -39,10 +53,39 @@ func NewVote(rawVote []byte) (Vote, error) {
 		if len(voteItem) > MaxVoteBytes {
 			return Vote{}, errors.Wrapf(ErrVoteOptionTooBig, "Vote item as position %d is too large. Each vote-item line may have a maximum of %d bytes", i, MaxVoteBytes)
 		}
+		if voteItem == "" {
+			return Vote{}, errors.Wrapf(ErrVoteOptionEmpty, "Vote item at position %d is empty", i)
+		}
+		// Options starting with @ are reserved for markers
+		if strings.HasPrefix(voteItem, "@") {
+			if !isVoteMarker(voteItem) {
+				return Vote{}, errors.Wraps(ErrVoteMarkerUnknown, voteItem)
+			}
+			if len(vote) != 1 {
+				return Vote{}, ErrVoteMarkerNotAlone
+			}
+		}
 	}
 	return vote, nil
 }
 
+// isVoteMarker checks if a vote option is one of the vote markers
+func isVoteMarker(option string) bool {
+	return option == VoteBlank || option == VoteAbstain || option == VoteSpoiled
+}
+
+// Marker gets the marker the vote was cast with. An empty vote is blank. It is empty if the vote chooses candidates.
+func (vote Vote) Marker() string {
+	switch {
+	case len(vote) == 0:
+		return VoteBlank
+	case len(vote) == 1 && isVoteMarker(vote[0]):
+		return vote[0]
+	default:
+		return ""
+	}
+}
+
 // Get the string representation of a Vote
 func (vote Vote) String() string {
 	var output string
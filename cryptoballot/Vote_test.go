@@ -0,0 +1,52 @@
+package cryptoballot
+
+import (
+	"testing"
+
+	"github.com/phayes/errors"
+)
+
+func TestVoteMarkers(t *testing.T) {
+	markers := map[string]string{
+		"":            VoteBlank,
+		"@blank":      VoteBlank,
+		"@abstain":    VoteAbstain,
+		"@spoiled":    VoteSpoiled,
+		"Alice\nBob":  "",
+		"Alice":       "",
+		"alice@x.org": "",
+	}
+	for raw, marker := range markers {
+		vote, err := NewVote([]byte(raw))
+		if err != nil {
+			t.Errorf("Unexpected error parsing vote %q: %s", raw, err)
+			continue
+		}
+		if vote.Marker() != marker {
+			t.Errorf("Expected vote %q to have marker %q, got %q", raw, marker, vote.Marker())
+		}
+		if vote.String() != raw {
+			t.Errorf("Vote %q changed in round trip to %q", raw, vote.String())
+		}
+	}
+
+	// A blank vote has no options, rather than a single empty one
+	vote, _ := NewVote([]byte(""))
+	if len(vote) != 0 {
+		t.Error("Expected an empty vote to have no options, got", len(vote))
+	}
+
+	bad := map[string]error{
+		"Alice\n":         ErrVoteOptionEmpty,
+		"Alice\n\nBob":    ErrVoteOptionEmpty,
+		"@abstain\nAlice": ErrVoteMarkerNotAlone,
+		"Alice\n@spoiled": ErrVoteMarkerNotAlone,
+		"@none":           ErrVoteMarkerUnknown,
+		"@Blank":          ErrVoteMarkerUnknown,
+	}
+	for raw, expected := range bad {
+		if _, err := NewVote([]byte(raw)); !errors.Is(err, expected) {
+			t.Errorf("Expected %s for vote %q, got %v", expected, raw, err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package cryptoballot
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/phayes/errors"
+)
+
+// VoterRoll is the set of DIDs allowed to vote in an election, along with the weight of each voter.
+// The text form has one DID per line, optionally followed by whitespace and the voter's weight. The weight is 1
+// if not given, and is only used in elections weighted by the voter roll. See WeightSource.
+// Blank lines and lines starting with # are ignored.
+type VoterRoll map[string]uint64
+
+var (
+	ErrVoterRollInvalidDID    = errors.New("Cannot parse voter roll. Invalid DID")
+	ErrVoterRollInvalidWeight = errors.New("Cannot parse voter roll. Weight must be a positive whole number")
+	ErrVoterRollDuplicate     = errors.New("Cannot parse voter roll. DID listed more than once")
+)
+
+// NewVoterRoll parses a voter roll
+func NewVoterRoll(rawVoterRoll []byte) (VoterRoll, error) {
+	roll := VoterRoll{}
+	for i, line := range bytes.Split(rawVoterRoll, []byte("\n")) {
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		did := fields[0]
+		if !ValidDIDString(did) || len(fields) > 2 {
+			return nil, errors.Wrapf(ErrVoterRollInvalidDID, "Line %d", i+1)
+		}
+		weight := uint64(1)
+		if len(fields) == 2 {
+			var err error
+			weight, err = strconv.ParseUint(fields[1], 10, 64)
+			if err != nil || weight == 0 {
+				return nil, errors.Wrapf(ErrVoterRollInvalidWeight, "Line %d", i+1)
+			}
+		}
+		if roll[did] != 0 {
+			return nil, errors.Wrapf(ErrVoterRollDuplicate, "Line %d", i+1)
+		}
+		roll[did] = weight
+	}
+	return roll, nil
+}
+
+// Contains checks if the DID is on the voter roll
+func (roll VoterRoll) Contains(did string) bool {
+	return roll[did] != 0
+}
+
+// Weight gets the weight of the voter. It is zero if the DID is not on the voter roll
+func (roll VoterRoll) Weight(did string) uint64 {
+	return roll[did]
+}
+
+// TotalWeight gets the total weight of every voter on the roll
+func (roll VoterRoll) TotalWeight() uint64 {
+	var total uint64
+	for _, weight := range roll {
+		total += weight
+	}
+	return total
+}
+
+// Implements Stringer. The DIDs are sorted, and weights are only written if they are not 1
+func (roll VoterRoll) String() string {
+	dids := make([]string, 0, len(roll))
+	for did := range roll {
+		dids = append(dids, did)
+	}
+	sort.Strings(dids)
+	for i, did := range dids {
+		if roll[did] != 1 {
+			dids[i] += " " + strconv.FormatUint(roll[did], 10)
+		}
+	}
+	return strings.Join(dids, "\n")
+}
@@ -0,0 +1,51 @@
+package cryptoballot
+
+import (
+	"testing"
+)
+
+func TestVoterRoll(t *testing.T) {
+	roll, err := NewVoterRoll([]byte(`# Voters for testelection
+did:elastos:icJ4z2DULrHEzYSvjKNJpKyhqFDxvYV7pN
+
+did:elastos:iXyz
+did:elastos:iWeighted	250
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !roll.Contains("did:elastos:iXyz") || !roll.Contains("did:elastos:icJ4z2DULrHEzYSvjKNJpKyhqFDxvYV7pN") {
+		t.Error("Voter roll is missing a voter")
+	}
+	if roll.Contains("did:elastos:iAbc") {
+		t.Error("Voter roll contains a voter that is not on it")
+	}
+
+	if roll.Weight("did:elastos:iXyz") != 1 || roll.Weight("did:elastos:iWeighted") != 250 || roll.Weight("did:elastos:iAbc") != 0 {
+		t.Error("Voter roll has the wrong weights")
+	}
+
+	again, err := NewVoterRoll([]byte(roll.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != roll.String() {
+		t.Error("Voter roll failed string round-trip")
+	}
+}
+
+func TestBadVoterRoll(t *testing.T) {
+	_, err := NewVoterRoll([]byte("did:elastos:iXyz\nnot a did"))
+	if err == nil {
+		t.Error("Voter roll with an invalid DID produced no error")
+	}
+	_, err = NewVoterRoll([]byte("did:elastos:iXyz\ndid:elastos:iXyz"))
+	if err == nil {
+		t.Error("Voter roll with a duplicate DID produced no error")
+	}
+	for _, bad := range []string{"did:elastos:iXyz 0", "did:elastos:iXyz -1", "did:elastos:iXyz 1.5", "did:elastos:iXyz 1 2"} {
+		if _, err = NewVoterRoll([]byte(bad)); err == nil {
+			t.Errorf("Voter roll %q produced no error", bad)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package cryptoballot
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/phayes/errors"
+)
+
+// Weighted voting.
+//
+// In a weighted election each voter has a weight, taken from the voter roll or from a claim in one of their
+// credentials. The election clerk has one blind signing key per denomination, and its main key is denomination 1.
+// A voter splits their weight into denominations and casts one ballot per denomination, each tagged with its
+// denomination and blind signed by the matching key. Every ballot of a denomination looks the same, so ballots
+// remain anonymous, and the total weight of ballots signed for a voter can never exceed the voter's weight.
+
+const (
+	ElectionWeightSourceTag = "weight-source" // Election tag saying where voter weights come from. See WeightSource
+	BallotDenominationTag   = "denomination"  // Ballot tag giving the ballot's weight. Ballots without it have a weight of 1
+	WeightSourceVoterRoll   = "voter-roll"
+
+	weightSourceCredentialPrefix = "credential:"
+)
+
+var (
+	ErrWeightSourceInvalid     = errors.New("Invalid weight-source. Must be voter-roll or credential:<credential-type>/<claim>")
+	ErrDenominationInvalid     = errors.New("Invalid denomination. Must be a positive whole number")
+	ErrDenominationUnknown     = errors.New("The election clerk has no key for this denomination")
+	ErrDenominationKeysInvalid = errors.New("Cannot parse denomination keys. Invalid format")
+	ErrWeightClaimInvalid      = errors.New("Credential weight claim is missing, or is not a positive whole number")
+)
+
+// WeightSource says where the weights of voters in an election come from.
+// In the election's weight-source tag it is either voter-roll, or credential:<credential-type>/<claim> for the
+// numeric claim in a credential of the given type. The credential type must be one the election requires.
+type WeightSource struct {
+	CredentialType string // Empty if weights come from the voter roll
+	Claim          string
+}
+
+// WeightSource gets the source of voter weights from the election's weight-source tag. It is nil if the election is not weighted.
+func (election *Election) WeightSource() (*WeightSource, error) {
+	value, ok := election.TagSet.Map()[ElectionWeightSourceTag]
+	if !ok {
+		return nil, nil
+	}
+	if value == WeightSourceVoterRoll {
+		return &WeightSource{}, nil
+	}
+	if !strings.HasPrefix(value, weightSourceCredentialPrefix) {
+		return nil, ErrWeightSourceInvalid
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, weightSourceCredentialPrefix), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, ErrWeightSourceInvalid
+	}
+	source := &WeightSource{CredentialType: parts[0], Claim: parts[1]}
+	required := false
+	for _, credentialType := range election.CredentialRequirements().Types {
+		required = required || credentialType == source.CredentialType
+	}
+	if !required {
+		return nil, errors.Wraps(ErrWeightSourceInvalid, "The credential type must be listed in "+ElectionCredentialTypesTag)
+	}
+	return source, nil
+}
+
+// FromVoterRoll checks if voter weights come from the voter roll
+func (source WeightSource) FromVoterRoll() bool {
+	return source.CredentialType == ""
+}
+
+// Implements Stringer. Returns the value of the weight-source tag
+func (source WeightSource) String() string {
+	if source.FromVoterRoll() {
+		return WeightSourceVoterRoll
+	}
+	return weightSourceCredentialPrefix + source.CredentialType + "/" + source.Claim
+}
+
+// ClaimWeight gets a weight from a numeric claim in the credential
+func (cred *Credential) ClaimWeight(claim string) (uint64, error) {
+	var raw string
+	switch value := cred.Claims[claim].(type) {
+	case json.Number:
+		raw = value.String()
+	case string:
+		raw = value
+	default:
+		return 0, ErrWeightClaimInvalid
+	}
+	weight, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || weight == 0 {
+		return 0, ErrWeightClaimInvalid
+	}
+	return weight, nil
+}
+
+// Weight gets the weight of the ballot the signature request is for. Requests without a denomination have a weight of 1
+func (sigReq *SignatureRequest) Weight() uint64 {
+	if sigReq.Denomination == 0 {
+		return 1
+	}
+	return sigReq.Denomination
+}
+
+// Denomination gets the weight of the ballot from its denomination tag. Ballots without the tag have a weight of 1
+func (ballot *Ballot) Denomination() (uint64, error) {
+	value, ok := ballot.TagSet.Map()[BallotDenominationTag]
+	if !ok {
+		return 1, nil
+	}
+	return parseDenomination(value)
+}
+
+// SetDenomination tags the ballot with its denomination. Denomination 1 is left untagged.
+func (ballot *Ballot) SetDenomination(denomination uint64) {
+	tags := TagSet{}
+	for _, tag := range ballot.TagSet {
+		if string(tag.Key) != BallotDenominationTag {
+			tags = append(tags, tag)
+		}
+	}
+	if denomination > 1 {
+		tags = append(tags, Tag{Key: []byte(BallotDenominationTag), Value: []byte(strconv.FormatUint(denomination, 10))})
+	}
+	if len(tags) == 0 {
+		tags = nil
+	}
+	ballot.TagSet = tags
+}
+
+// VerifyWeightedSignature verifies the clerk's signature on the ballot with the key for the ballot's denomination,
+// and returns the ballot's weight
+func (ballot *Ballot) VerifyWeightedSignature(keys DenominationKeys) (uint64, error) {
+	denomination, err := ballot.Denomination()
+	if err != nil {
+		return 0, err
+	}
+	key, err := keys.Key(denomination)
+	if err != nil {
+		return 0, err
+	}
+	return denomination, ballot.VerifyBlindSignature(key)
+}
+
+// VerifyWeightedBallotSignature verifies that the clerk's signature was made with the key for the request's denomination
+func (fulfilled *FulfilledSignatureRequest) VerifyWeightedBallotSignature(keys DenominationKeys) error {
+	key, err := keys.Key(fulfilled.Weight())
+	if err != nil {
+		return err
+	}
+	return fulfilled.VerifyBallotSignature(key)
+}
+
+func parseDenomination(value string) (uint64, error) {
+	denomination, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || denomination == 0 {
+		return 0, ErrDenominationInvalid
+	}
+	return denomination, nil
+}
+
+// DenominationKeys are the election clerk's public keys for blind signing ballots, by denomination.
+// Denomination 1 is the clerk's main key.
+//
+// The text form is a series of PUBLIC KEY PEM blocks, each with a Denomination header.
+type DenominationKeys map[uint64]PublicKey
+
+// NewDenominationKeys parses denomination keys. A block without a Denomination header is denomination 1.
+func NewDenominationKeys(rawKeys []byte) (DenominationKeys, error) {
+	keys := DenominationKeys{}
+	rest := rawKeys
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			return nil, errors.Wraps(ErrDenominationKeysInvalid, "Found unexpected "+block.Type+" block")
+		}
+		denomination := uint64(1)
+		if value, ok := block.Headers["Denomination"]; ok {
+			var err error
+			if denomination, err = parseDenomination(value); err != nil {
+				return nil, errors.Wrap(err, ErrDenominationKeysInvalid)
+			}
+		}
+		if _, ok := keys[denomination]; ok {
+			return nil, errors.Wraps(ErrDenominationKeysInvalid, "Denomination listed more than once")
+		}
+		key, err := NewPublicKeyFromBlock(block)
+		if err != nil {
+			return nil, errors.Wrap(err, ErrDenominationKeysInvalid)
+		}
+		keys[denomination] = key
+	}
+	if len(bytes.TrimSpace(rest)) != 0 || len(keys) == 0 {
+		return nil, ErrDenominationKeysInvalid
+	}
+	return keys, nil
+}
+
+// Key gets the key for a denomination
+func (keys DenominationKeys) Key(denomination uint64) (PublicKey, error) {
+	key, ok := keys[denomination]
+	if !ok {
+		return nil, ErrDenominationUnknown
+	}
+	return key, nil
+}
+
+// Denominations lists the denominations, largest first
+func (keys DenominationKeys) Denominations() []uint64 {
+	denominations := make([]uint64, 0, len(keys))
+	for denomination := range keys {
+		denominations = append(denominations, denomination)
+	}
+	sort.Slice(denominations, func(i, j int) bool { return denominations[i] > denominations[j] })
+	return denominations
+}
+
+// Split splits a weight into as few denominations as possible, largest first.
+// For example with denominations 1, 10 and 100 a weight of 213 is split into 100, 100, 10, 1, 1 and 1.
+func (keys DenominationKeys) Split(weight uint64) ([]uint64, error) {
+	var split []uint64
+	for _, denomination := range keys.Denominations() {
+		for weight >= denomination {
+			split = append(split, denomination)
+			weight -= denomination
+		}
+	}
+	if weight != 0 {
+		return nil, errors.Wraps(ErrDenominationUnknown, "The weight cannot be made from the clerk's denominations")
+	}
+	return split, nil
+}
+
+// Implements Stringer. Returns the keys as PEM blocks, smallest denomination first
+func (keys DenominationKeys) String() string {
+	denominations := keys.Denominations()
+	var s strings.Builder
+	for i := len(denominations) - 1; i >= 0; i-- {
+		s.Write(pem.EncodeToMemory(&pem.Block{
+			Type:    "PUBLIC KEY",
+			Headers: map[string]string{"Denomination": strconv.FormatUint(denominations[i], 10)},
+			Bytes:   keys[denominations[i]].Bytes(),
+		}))
+	}
+	return s.String()
+}
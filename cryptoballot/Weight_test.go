@@ -0,0 +1,232 @@
+package cryptoballot
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestDenominationKeys generates clerk keys for the given denominations
+func newTestDenominationKeys(t *testing.T, denominations ...uint64) DenominationKeys {
+	keys := DenominationKeys{}
+	for _, denomination := range denominations {
+		priv, err := GeneratePrivateKey(2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[denomination], err = priv.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return keys
+}
+
+func TestWeightSource(t *testing.T) {
+	tags := func(pairs ...string) *Election {
+		election := &Election{}
+		for i := 0; i < len(pairs); i += 2 {
+			election.TagSet = append(election.TagSet, Tag{Key: []byte(pairs[i]), Value: []byte(pairs[i+1])})
+		}
+		return election
+	}
+
+	source, err := tags().WeightSource()
+	if source != nil || err != nil {
+		t.Errorf("Election without a weight-source is weighted: %v %v", source, err)
+	}
+	source, err = tags(ElectionWeightSourceTag, "voter-roll").WeightSource()
+	if err != nil || !source.FromVoterRoll() || source.String() != "voter-roll" {
+		t.Errorf("voter-roll weight-source parsed incorrectly: %v %v", source, err)
+	}
+	source, err = tags(ElectionCredentialTypesTag, "ShareholderCredential", ElectionWeightSourceTag, "credential:ShareholderCredential/shares").WeightSource()
+	if err != nil || source.FromVoterRoll() || source.CredentialType != "ShareholderCredential" || source.Claim != "shares" {
+		t.Errorf("credential weight-source parsed incorrectly: %v %v", source, err)
+	}
+	if source.String() != "credential:ShareholderCredential/shares" {
+		t.Errorf("credential weight-source failed string round-trip: %s", source)
+	}
+
+	for _, bad := range []string{"shares", "credential:", "credential:ShareholderCredential", "credential:/shares", "credential:OtherCredential/shares"} {
+		if _, err := tags(ElectionCredentialTypesTag, "ShareholderCredential", ElectionWeightSourceTag, bad).WeightSource(); err == nil {
+			t.Errorf("weight-source %q produced no error", bad)
+		}
+	}
+}
+
+func TestClaimWeight(t *testing.T) {
+	cred := &Credential{Claims: map[string]interface{}{}}
+	for raw, expected := range map[string]uint64{`100`: 100, `"42"`: 42, `0`: 0, `-5`: 0, `2.5`: 0, `"lots"`: 0, `true`: 0} {
+		claims, err := decodeJSONObject([]byte(`{"shares":` + raw + `}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cred.Claims = claims
+		weight, err := cred.ClaimWeight("shares")
+		if weight != expected || (expected == 0) != (err != nil) {
+			t.Errorf("Claim %s gave a weight of %d and error %v", raw, weight, err)
+		}
+	}
+	if _, err := cred.ClaimWeight("missing"); err != ErrWeightClaimInvalid {
+		t.Errorf("Expected ErrWeightClaimInvalid, got %v", err)
+	}
+}
+
+func TestBallotDenomination(t *testing.T) {
+	ballot, err := NewBallot(goodBallot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if denomination, err := ballot.Denomination(); denomination != 1 || err != nil {
+		t.Errorf("Untagged ballot has denomination %d: %v", denomination, err)
+	}
+
+	ballot.SetDenomination(100)
+	ballot.SetDenomination(10)
+	if denomination, err := ballot.Denomination(); denomination != 10 || err != nil {
+		t.Errorf("Expected denomination 10, got %d: %v", denomination, err)
+	}
+	if len(ballot.TagSet) != 3 || string(ballot.TagSet[0].Key) != "voter" {
+		t.Errorf("Expected the ballot's own tags and a single denomination tag, got %v", ballot.TagSet)
+	}
+	parsed, err := NewBallot([]byte(ballot.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if denomination, _ := parsed.Denomination(); denomination != 10 {
+		t.Errorf("Denomination failed string round-trip, got %d", denomination)
+	}
+
+	ballot.SetDenomination(1)
+	if len(ballot.TagSet) != 2 {
+		t.Errorf("Denomination 1 should be untagged, got %v", ballot.TagSet)
+	}
+	ballot.TagSet = nil
+	ballot.SetDenomination(1)
+	if ballot.TagSet != nil {
+		t.Errorf("Setting denomination 1 on an untagged ballot should leave it untagged, got %v", ballot.TagSet)
+	}
+
+	ballot.TagSet = TagSet{{Key: []byte(BallotDenominationTag), Value: []byte("0")}}
+	if _, err := ballot.Denomination(); err != ErrDenominationInvalid {
+		t.Errorf("Expected ErrDenominationInvalid, got %v", err)
+	}
+}
+
+func TestDenominationKeys(t *testing.T) {
+	keys := newTestDenominationKeys(t, 1, 10, 100)
+
+	parsed, err := NewDenominationKeys([]byte(keys.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, keys) {
+		t.Error("DenominationKeys failed string round-trip")
+	}
+	if !reflect.DeepEqual(keys.Denominations(), []uint64{100, 10, 1}) {
+		t.Errorf("Denominations in the wrong order: %v", keys.Denominations())
+	}
+	if _, err := keys.Key(5); err != ErrDenominationUnknown {
+		t.Errorf("Expected ErrDenominationUnknown, got %v", err)
+	}
+
+	split, err := keys.Split(213)
+	if err != nil || !reflect.DeepEqual(split, []uint64{100, 100, 10, 1, 1, 1}) {
+		t.Errorf("Weight split incorrectly: %v %v", split, err)
+	}
+	if _, err := newTestDenominationKeys(t, 10).Split(15); err == nil {
+		t.Error("Splitting a weight the denominations cannot make produced no error")
+	}
+
+	// A block without a Denomination header is the main key, and each denomination may only be listed once
+	main := strings.Replace(keys.String(), "Denomination: 1\n", "", 1)
+	if parsed, err = NewDenominationKeys([]byte(main)); err != nil || len(parsed) != 3 {
+		t.Errorf("Keys without a Denomination header parsed incorrectly: %v", err)
+	}
+	for _, bad := range []string{"", keys.String() + keys.String(), strings.Replace(keys.String(), "Denomination: 10", "Denomination: ten", 1)} {
+		if _, err := NewDenominationKeys([]byte(bad)); err == nil {
+			t.Errorf("Bad denomination keys produced no error:\n%s", bad)
+		}
+	}
+}
+
+func TestDenominationSignatureRequest(t *testing.T) {
+	voterPriv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	voterPub, err := voterPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	did := "did:elastos:icJ4z2DULrHEzYSvjKNJpKyhqFDxvYV7pN"
+
+	blindBallot, _ := NewBlindBallot([]byte(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("b", 256)))))
+	req := SignatureRequest{
+		ElectionID:   "testelection",
+		RequestID:    DIDRequestID(did),
+		PublicKey:    voterPub.Bytes(),
+		DID:          did,
+		Denomination: 100,
+		BlindBallot:  blindBallot,
+	}
+	req.Signature, err = voterPriv.SignString(req.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := NewSignatureRequest([]byte(req.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*parsed, req) || parsed.Weight() != 100 {
+		t.Error("SignatureRequest with a denomination failed string round-trip")
+	}
+	if err = parsed.VerifySignature(); err != nil {
+		t.Error(err)
+	}
+
+	ballotSignature, _ := NewSignature([]byte(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("s", 256)))))
+	fulfilled := NewFulfilledSignatureRequestFromParts(req, ballotSignature)
+	parsedFulfilled, err := NewFulfilledSignatureRequest([]byte(fulfilled.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*parsedFulfilled, *fulfilled) {
+		t.Error("FulfilledSignatureRequest with a denomination failed string round-trip")
+	}
+
+	req.Denomination = 0
+	if req.Weight() != 1 {
+		t.Errorf("SignatureRequest without a denomination has weight %d", req.Weight())
+	}
+	bad := strings.Replace(fulfilled.String(), "denomination:100", "denomination:0", 1)
+	if _, err = NewFulfilledSignatureRequest([]byte(bad)); err == nil {
+		t.Error("FulfilledSignatureRequest with denomination 0 produced no error")
+	}
+}
+
+func TestDenominationBundle(t *testing.T) {
+	bundle, _ := newTestBundle(t)
+	bundle.DenominationKeys = newTestDenominationKeys(t, 10, 100)
+	bundle.DenominationKeys[1] = bundle.ClerkKey
+
+	parsed, err := NewBundle([]byte(bundle.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed.DenominationKeys, bundle.DenominationKeys) {
+		t.Error("Bundle with denomination keys failed string round-trip")
+	}
+	if parsed.String() != bundle.String() {
+		t.Error("Bundle with denomination keys does not re-encode identically")
+	}
+
+	// Denomination keys must follow the clerk key
+	blocks := strings.SplitAfter(bundle.String(), "-----END PUBLIC KEY-----\n")
+	reordered := blocks[1] + blocks[0] + strings.Join(blocks[2:], "")
+	if _, err = NewBundle([]byte(reordered)); err == nil {
+		t.Error("Bundle with a denomination key before the election produced no error")
+	}
+}
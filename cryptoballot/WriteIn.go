@@ -0,0 +1,260 @@
+package cryptoballot
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"github.com/phayes/errors"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// ElectionCandidatesTag lists the candidates of an election, separated by commas. eg: candidates="Alice, Bob"
+	ElectionCandidatesTag = "candidates"
+
+	// ElectionWriteInsTag allows voters to write in candidates that are not listed. eg: write-ins=true
+	ElectionWriteInsTag = "write-ins"
+
+	// AdjudicationRejected is the ruling given to a write-in that must not be counted
+	AdjudicationRejected = "-"
+
+	// adjudicationSeparator separates a write-in from the candidate it is ruled to be, in rulings and alias files
+	adjudicationSeparator = "=>"
+)
+
+var (
+	ErrAdjudicationInvalid       = errors.New("Invalid adjudication. An adjudication must have an election-id, rulings, a public key and optionally a signature, separated by blank lines")
+	ErrAdjudicationRulingInvalid = errors.New("Invalid adjudication ruling. Rulings must be of the form <write-in> => <candidate>")
+	ErrAdjudicationDuplicate     = errors.New("Invalid adjudication. The same write-in is ruled on more than once")
+	ErrAdjudicationInvalidKey    = errors.New("Invalid adjudication public key")
+	ErrAdjudicationInvalidSig    = errors.New("Invalid adjudication signature")
+	ErrAdjudicationSigNotFound   = errors.New("Could not verify adjudication signature: Signature does not exist")
+	ErrAliasInvalid              = errors.New("Invalid alias. Aliases must be of the form <alias> => <candidate>")
+)
+
+// Contest describes the choices an election offers, as declared by its candidates and write-ins tags.
+// Elections that declare neither are tallied on the raw vote strings, as they always have been.
+type Contest struct {
+	Candidates []string // The listed candidates, as the admin spelled them
+	WriteIns   bool     // Voters may choose candidates that are not listed
+}
+
+// Contest gets the candidates and write-in rules of the election
+func (election *Election) Contest() Contest {
+	tags := election.TagSet.Map()
+	return Contest{
+		Candidates: splitTagList(tags[ElectionCandidatesTag]),
+		WriteIns:   tags[ElectionWriteInsTag] == "true",
+	}
+}
+
+// Declared reports whether the election lists candidates or allows write-ins. Only then are votes normalized.
+func (contest Contest) Declared() bool {
+	return len(contest.Candidates) != 0 || contest.WriteIns
+}
+
+// Candidate finds the listed candidate a choice refers to, comparing normalized forms. See NormalizeChoice
+func (contest Contest) Candidate(choice string) (string, bool) {
+	normalized := NormalizeChoice(choice)
+	for _, candidate := range contest.Candidates {
+		if NormalizeChoice(candidate) == normalized {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// NormalizeChoice reduces a vote option to the form used to compare it with others. The option is put in
+// Unicode NFC form, case folded, and has its whitespace trimmed and collapsed, so that "Dalí", "dalí" and
+// " DALÍ " all compare equal. Accents are kept, so "Dali" and "Dalí" differ; use aliases or an adjudication for those.
+func NormalizeChoice(choice string) string {
+	folded := cases.Fold().String(norm.NFC.String(choice))
+	return norm.NFC.String(strings.Join(strings.Fields(folded), " "))
+}
+
+// AliasMap maps alternative spellings of candidates to the candidate, keyed by normalized spelling.
+// Aliases are configured by whoever tallies the election and are not signed. See Adjudication for signed rulings.
+type AliasMap map[string]string
+
+// NewAliasMap parses an alias file. Each line is of the form "<alias> => <candidate>".
+// Blank lines and lines starting with # are ignored.
+func NewAliasMap(rawAliases []byte) (AliasMap, error) {
+	aliases := AliasMap{}
+	for i, line := range strings.Split(string(rawAliases), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		alias, candidate, err := parseRuling(line)
+		if err != nil {
+			return nil, errors.Wrapf(ErrAliasInvalid, "Line %d", i+1)
+		}
+		aliases[NormalizeChoice(alias)] = candidate
+	}
+	return aliases, nil
+}
+
+// Candidate finds the candidate an alias refers to
+func (aliases AliasMap) Candidate(choice string) (string, bool) {
+	candidate, ok := aliases[NormalizeChoice(choice)]
+	return candidate, ok
+}
+
+// A Ruling decides which candidate a write-in counts for, or that it is rejected. See AdjudicationRejected
+type Ruling struct {
+	WriteIn   string
+	Candidate string
+}
+
+// Implements Stringer
+func (ruling Ruling) String() string {
+	return ruling.WriteIn + " " + adjudicationSeparator + " " + ruling.Candidate
+}
+
+// An Adjudication is the election admin's signed rulings on write-ins that could not be matched automatically.
+// It is of the following format:
+//
+//	<election-id>
+//
+//	<write-in> => <candidate>
+//	<write-in> => <candidate>
+//
+//	<hex-encoded-admin-public-key>
+//
+//	<hex-encoded-signature>
+//
+// Write-ins are compared normalized. See NormalizeChoice
+type Adjudication struct {
+	ElectionID string
+	Rulings    []Ruling
+	PublicKey  []byte // DID public key of the admin
+	Signature  []byte
+}
+
+// NewAdjudication parses an adjudication from its string form
+func NewAdjudication(rawAdjudication []byte) (*Adjudication, error) {
+	parts := bytes.Split(bytes.TrimSpace(rawAdjudication), []byte("\n\n"))
+	if len(parts) != 3 && len(parts) != 4 {
+		return nil, ErrAdjudicationInvalid
+	}
+
+	adjudication := Adjudication{ElectionID: string(parts[0])}
+	if !ValidElectionID.MatchString(adjudication.ElectionID) {
+		return nil, errors.Wrap(ErrElectionIDInvalid, ErrAdjudicationInvalid)
+	}
+
+	rulings, err := NewRulings(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	adjudication.Rulings = rulings
+
+	adjudication.PublicKey, err = hex.DecodeString(string(parts[2]))
+	if err != nil || len(adjudication.PublicKey) == 0 {
+		return nil, ErrAdjudicationInvalidKey
+	}
+
+	if len(parts) == 4 {
+		adjudication.Signature, err = hex.DecodeString(string(parts[3]))
+		if err != nil {
+			return nil, errors.Wrap(err, ErrAdjudicationInvalidSig)
+		}
+	}
+
+	return &adjudication, nil
+}
+
+// NewRulings parses the rulings section of an adjudication, one "<write-in> => <candidate>" per line
+func NewRulings(rawRulings []byte) ([]Ruling, error) {
+	var rulings []Ruling
+	seen := map[string]bool{}
+	for i, line := range strings.Split(strings.TrimSpace(string(rawRulings)), "\n") {
+		writeIn, candidate, err := parseRuling(line)
+		if err != nil {
+			return nil, errors.Wrapf(ErrAdjudicationRulingInvalid, "Line %d", i+1)
+		}
+		if seen[NormalizeChoice(writeIn)] {
+			return nil, errors.Wraps(ErrAdjudicationDuplicate, writeIn)
+		}
+		seen[NormalizeChoice(writeIn)] = true
+		rulings = append(rulings, Ruling{writeIn, candidate})
+	}
+	return rulings, nil
+}
+
+// parseRuling splits a "<choice> => <candidate>" line
+func parseRuling(line string) (string, string, error) {
+	parts := strings.Split(line, adjudicationSeparator)
+	if len(parts) != 2 {
+		return "", "", ErrAdjudicationRulingInvalid
+	}
+	choice, candidate := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	if choice == "" || candidate == "" || len(choice) > MaxVoteBytes || len(candidate) > MaxVoteBytes {
+		return "", "", ErrAdjudicationRulingInvalid
+	}
+	return choice, candidate, nil
+}
+
+// Candidate finds the ruling on a write-in
+func (adjudication *Adjudication) Candidate(choice string) (string, bool) {
+	normalized := NormalizeChoice(choice)
+	for _, ruling := range adjudication.Rulings {
+		if NormalizeChoice(ruling.WriteIn) == normalized {
+			return ruling.Candidate, true
+		}
+	}
+	return "", false
+}
+
+// VerifySignature verifies that the adjudication has been properly signed by the DID key in adjudication.PublicKey.
+// It does not check that the key is the election admin's.
+func (adjudication *Adjudication) VerifySignature() error {
+	if !adjudication.HasSignature() {
+		return ErrAdjudicationSigNotFound
+	}
+	publicKey, err := crypto.DecodePoint(adjudication.PublicKey)
+	if err != nil {
+		return errors.Wrap(err, ErrAdjudicationInvalidKey)
+	}
+	didPublicKey := DIDPublicKey{PublicKey: *publicKey}
+	return didPublicKey.VerifySignature(adjudication.Signature, []byte(adjudication.StringWithoutSignature()))
+}
+
+// VerifyForElection verifies that the adjudication is for the election and is signed by the election's admin
+func (adjudication *Adjudication) VerifyForElection(election *Election) error {
+	if adjudication.ElectionID != election.ElectionID {
+		return errors.Wraps(ErrAdjudicationInvalid, "Adjudication is for election "+adjudication.ElectionID)
+	}
+	if !bytes.Equal(adjudication.PublicKey, election.PublicKey) {
+		return errors.Wraps(ErrAdjudicationInvalidKey, "Adjudication is not signed by the election admin")
+	}
+	return adjudication.VerifySignature()
+}
+
+// HasSignature checks to see if the adjudication has been signed. It does not verify the signature.
+func (adjudication *Adjudication) HasSignature() bool {
+	return adjudication.Signature != nil
+}
+
+// Implements Stringer. The returned string is the same format as expected by NewAdjudication
+func (adjudication Adjudication) String() string {
+	s := adjudication.StringWithoutSignature()
+
+	if adjudication.HasSignature() {
+		s += "\n\n" + hex.EncodeToString(adjudication.Signature)
+	}
+
+	return s
+}
+
+// StringWithoutSignature gets the adjudication without the signature, OK for signing
+func (adjudication Adjudication) StringWithoutSignature() string {
+	rulings := make([]string, len(adjudication.Rulings))
+	for i, ruling := range adjudication.Rulings {
+		rulings[i] = ruling.String()
+	}
+	return adjudication.ElectionID + "\n\n" + strings.Join(rulings, "\n") + "\n\n" + hex.EncodeToString(adjudication.PublicKey)
+}
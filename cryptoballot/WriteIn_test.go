@@ -0,0 +1,218 @@
+package cryptoballot
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeChoice(t *testing.T) {
+	same := []string{"Dalí", "dalí", " DALÍ ", "Dalí", "DALÍ"}
+	for _, choice := range same {
+		if NormalizeChoice(choice) != "dalí" {
+			t.Errorf("Expected %q to normalize to dalí, got %q", choice, NormalizeChoice(choice))
+		}
+	}
+	if NormalizeChoice("Salvador   Dalí") != "salvador dalí" {
+		t.Error("Expected whitespace to be collapsed, got", NormalizeChoice("Salvador   Dalí"))
+	}
+	if NormalizeChoice("Dali") == NormalizeChoice("Dalí") {
+		t.Error("Accents should not be removed")
+	}
+	if NormalizeChoice("STRASSE") != NormalizeChoice("straße") {
+		t.Error("Expected full case folding")
+	}
+}
+
+func TestContest(t *testing.T) {
+	tagSet, err := NewTagSet([]byte("candidates=Salvador Dalí, Frida Kahlo\nwrite-ins=true"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	election := Election{ElectionID: "art", TagSet: tagSet}
+	contest := election.Contest()
+	if !contest.Declared() || !contest.WriteIns || len(contest.Candidates) != 2 {
+		t.Fatal("Unexpected contest", contest)
+	}
+	if candidate, ok := contest.Candidate("frida  KAHLO"); !ok || candidate != "Frida Kahlo" {
+		t.Error("Expected Frida Kahlo, got", candidate)
+	}
+	if _, ok := contest.Candidate("Dali"); ok {
+		t.Error("Dali is not a listed candidate")
+	}
+
+	// Elections without the tags are not normalized
+	if (&Election{ElectionID: "legacy"}).Contest().Declared() {
+		t.Error("Election without candidates or write-ins should not be declared")
+	}
+}
+
+func TestAliasMap(t *testing.T) {
+	aliases, err := NewAliasMap([]byte("# Spellings seen in previous elections\nDali => Salvador Dalí\n\n  s. dali=>Salvador Dalí\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aliases) != 2 {
+		t.Error("Expected 2 aliases, got", len(aliases))
+	}
+	if candidate, ok := aliases.Candidate("DALI"); !ok || candidate != "Salvador Dalí" {
+		t.Error("Expected Salvador Dalí, got", candidate)
+	}
+	if candidate, ok := aliases.Candidate("S. Dali"); !ok || candidate != "Salvador Dalí" {
+		t.Error("Expected Salvador Dalí, got", candidate)
+	}
+
+	bad := []string{"Dali", "Dali =>", "=> Dalí", "a => b => c"}
+	for _, raw := range bad {
+		if _, err := NewAliasMap([]byte(raw)); err == nil {
+			t.Errorf("Expected error for alias %q", raw)
+		}
+	}
+}
+
+func newTestAdjudication(t *testing.T) (*Election, *Adjudication, DIDPrivateKey) {
+	adminPriv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminPub, err := adminPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	election := &Election{
+		ElectionID: "art",
+		Start:      time.Now().Truncate(time.Second),
+		End:        time.Now().Add(time.Hour).Truncate(time.Second),
+		PublicKey:  adminPub.Bytes(),
+	}
+	rulings, err := NewRulings([]byte("Dali => Salvador Dalí\nMickey Mouse => -"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adjudication := &Adjudication{
+		ElectionID: election.ElectionID,
+		Rulings:    rulings,
+		PublicKey:  adminPub.Bytes(),
+	}
+	adjudication.Signature, err = adminPriv.SignString(adjudication.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return election, adjudication, adminPriv
+}
+
+func TestAdjudication(t *testing.T) {
+	election, adjudication, _ := newTestAdjudication(t)
+
+	// Round trip to string and back
+	parsed, err := NewAdjudication([]byte(adjudication.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != adjudication.String() {
+		t.Error("Adjudication round-trip from string and back again failed")
+	}
+	if err := parsed.VerifyForElection(election); err != nil {
+		t.Error(err)
+	}
+	if candidate, ok := parsed.Candidate("dali"); !ok || candidate != "Salvador Dalí" {
+		t.Error("Expected Salvador Dalí, got", candidate)
+	}
+	if candidate, ok := parsed.Candidate("MICKEY mouse"); !ok || candidate != AdjudicationRejected {
+		t.Error("Expected Mickey Mouse to be rejected, got", candidate)
+	}
+	if _, ok := parsed.Candidate("Frida"); ok {
+		t.Error("Frida has no ruling")
+	}
+
+	// Tampering breaks the signature
+	parsed.Rulings[0].Candidate = "Frida Kahlo"
+	if err := parsed.VerifySignature(); err == nil {
+		t.Error("Tampered adjudication should not verify")
+	}
+
+	// Signed by someone other than the admin
+	otherPriv, err := GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, err := otherPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := *election
+	other.PublicKey = otherPub.Bytes()
+	if err := adjudication.VerifyForElection(&other); err == nil {
+		t.Error("Adjudication not signed by the election admin should not verify")
+	}
+
+	// For another election
+	other = *election
+	other.ElectionID = "music"
+	if err := adjudication.VerifyForElection(&other); err == nil {
+		t.Error("Adjudication for another election should not verify")
+	}
+
+	// Unsigned
+	adjudication.Signature = nil
+	if err := adjudication.VerifySignature(); err != ErrAdjudicationSigNotFound {
+		t.Error("Expected ErrAdjudicationSigNotFound, got", err)
+	}
+}
+
+func TestBadAdjudication(t *testing.T) {
+	_, adjudication, _ := newTestAdjudication(t)
+	key := hex.EncodeToString(adjudication.PublicKey)
+
+	bad := []string{
+		"art",
+		"art\n\n" + key,
+		"art\n\nDali Salvador Dalí\n\n" + key,
+		"art\n\nDali => Salvador Dalí\ndali => Frida Kahlo\n\n" + key,
+		"art\n\nDali => Salvador Dalí\n\nnot-hex",
+		"art\n\nDali => Salvador Dalí\n\n" + key + "\n\nnot-hex",
+		"not an election id!\n\nDali => Salvador Dalí\n\n" + key,
+	}
+	for _, raw := range bad {
+		if _, err := NewAdjudication([]byte(raw)); err == nil {
+			t.Errorf("Expected error for adjudication %q", raw)
+		}
+	}
+}
+
+func TestBundleAdjudication(t *testing.T) {
+	bundle, exporterPriv := newTestBundle(t)
+	_, adjudication, _ := newTestAdjudication(t)
+	adjudication.ElectionID = bundle.Election.ElectionID
+	bundle.Adjudication = adjudication
+	var err error
+	bundle.Signature, err = exporterPriv.SignString(bundle.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := NewBundle([]byte(bundle.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != bundle.String() {
+		t.Error("Bundle round-trip from string and back again failed")
+	}
+	if parsed.Adjudication == nil || parsed.Adjudication.String() != adjudication.String() {
+		t.Error("Adjudication changed in round trip")
+	}
+	if err := parsed.VerifySignature(); err != nil {
+		t.Error(err)
+	}
+
+	// The adjudication comes after the ballots and before the Merkle root
+	raw := bundle.String()
+	start := strings.Index(raw, "-----BEGIN ADJUDICATION-----")
+	end := strings.Index(raw, "-----END ADJUDICATION-----\n") + len("-----END ADJUDICATION-----\n")
+	rootEnd := strings.Index(raw, "-----END MERKLE ROOT-----\n") + len("-----END MERKLE ROOT-----\n")
+	swapped := raw[:start] + raw[end:rootEnd] + raw[start:end] + raw[rootEnd:]
+	if _, err := NewBundle([]byte(swapped)); err != ErrBundleBlockOrder {
+		t.Error("Expected ErrBundleBlockOrder, got", err)
+	}
+}
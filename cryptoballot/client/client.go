@@ -0,0 +1,136 @@
+// Package client lets Go programs vote in an election without shelling out to the cryptoballot command.
+//
+// A Voter goes through the same steps as `cryptoballot voter vote`:
+//  1. FetchElection gets the election from the election clerk, and checks the admin's signature on it
+//  2. PrepareBallot blinds a ballot with the election clerk's public key
+//  3. RequestSignature has the election clerk blind-sign the ballot
+//  4. Unblind unblinds the clerk's signature, giving a signed ballot
+//  5. Cast submits the signed ballot to every ballotbox
+//  6. Verify checks that every ballotbox publishes the ballot
+//
+// A voter who would rather not be linked to their ballot by its timing may wait a random time between steps 4 and 5.
+//
+// Every error wraps one of the Err values of this package, so it can be told apart with errors.Is from
+// github.com/phayes/errors. An error reported by a server also wraps the Err value for the server's error code,
+// such as ErrDuplicate, and a server that could not be reached gives ErrUnreachable. The one exception is the
+// *CastError returned by Cast, which holds an error for each ballotbox that refused the ballot. Each of those
+// wraps an Err value in the same way.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/phayes/errors"
+)
+
+var (
+	ErrFetchElection    = errors.New("client: Unable to fetch election")
+	ErrPrepareBallot    = errors.New("client: Unable to prepare ballot")
+	ErrRequestSignature = errors.New("client: Unable to have ballot signed")
+	ErrUnblind          = errors.New("client: Unable to unblind ballot")
+	ErrVerify           = errors.New("client: Unable to verify ballot")
+	ErrNotPublished     = errors.New("client: Ballot is not published")
+	ErrBallotAltered    = errors.New("client: Published ballot does not match the ballot that was cast")
+	ErrWrongResponse    = errors.New("client: Server responded for a different request")
+	ErrResponseTooLarge = errors.New("client: Server response is too large")
+	ErrNoBallotBox      = errors.New("client: No ballotbox given")
+	ErrUnreachable      = errors.New("client: Unable to reach server")
+
+	// Errors reported by a server, by the code in its error response
+	ErrBadRequest   = errors.New("client: Server refused an invalid request")
+	ErrVerification = errors.New("client: Server could not verify a signature")
+	ErrNotFound     = errors.New("client: Server could not find the election or ballot")
+	ErrForbidden    = errors.New("client: Server forbade the request")
+	ErrDuplicate    = errors.New("client: Server already has this ballot, or has already signed for this voter")
+	ErrClosed       = errors.New("client: Election is not open for voting")
+	ErrRateLimited  = errors.New("client: Server is rate limiting requests. Try again later")
+	ErrServer       = errors.New("client: Server failed")
+)
+
+// maxResponseSize bounds the responses read from a server. It leaves room for a fulfilled signature request
+// carrying a verifiable presentation.
+const maxResponseSize = 1024 * 1024
+
+// serverErrors maps the code in a server's error response to the error returned
+var serverErrors = map[string]error{
+	"bad_request":        ErrBadRequest,
+	"too_large":          ErrBadRequest,
+	"method_not_allowed": ErrBadRequest,
+	"verification":       ErrVerification,
+	"not_found":          ErrNotFound,
+	"forbidden":          ErrForbidden,
+	"duplicate":          ErrDuplicate,
+	"closed":             ErrClosed,
+	"rate_limited":       ErrRateLimited,
+	"database":           ErrServer,
+	"internal":           ErrServer,
+}
+
+// errorResponse is the body of a server's error response
+type errorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+// do makes a request and reads the response body. A response with a status other than 200 or 202 is returned as
+// an error, wrapping the Err value for the server's error code. Any other failure wraps ErrUnreachable.
+func (v *Voter) do(ctx context.Context, method string, url string, body []byte, header http.Header) (int, []byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, errors.Wrap(err, ErrUnreachable)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	httpClient := v.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, nil, errors.Wrap(err, ErrUnreachable)
+	}
+	defer func() {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return 0, nil, errors.Wrap(err, ErrUnreachable)
+	}
+	if len(respBody) > maxResponseSize {
+		return 0, nil, ErrResponseTooLarge
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return resp.StatusCode, nil, serverError(resp, respBody)
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+// serverError gets the error for a server's error response
+func serverError(resp *http.Response, body []byte) error {
+	var parsed errorResponse
+	if err := json.Unmarshal(body, &parsed); err != nil || parsed.Code == "" {
+		parsed.Message = string(body)
+	}
+	base, ok := serverErrors[parsed.Code]
+	switch {
+	case ok:
+	case resp.StatusCode == http.StatusNotFound:
+		base = ErrNotFound
+	case resp.StatusCode == http.StatusTooManyRequests:
+		base = ErrRateLimited
+	case resp.StatusCode >= 500:
+		base = ErrServer
+	default:
+		base = ErrBadRequest
+	}
+	return errors.Appendf(base, "%s %s: %s - %s", resp.Request.Method, resp.Request.URL, resp.Status, parsed.Message)
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/phayes/errors"
+)
+
+func TestServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/election/limited":
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, `{"code":"rate_limited","message":"Too many requests"}`, http.StatusTooManyRequests)
+		case "/election/broken":
+			http.Error(w, "proxy error", http.StatusBadGateway)
+		default:
+			http.Error(w, `{"code":"not_found","message":"Could not find election"}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	voterKey, err := cryptoballot.GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	voter := NewVoter(voterKey, server.URL)
+	for electionID, expected := range map[string]error{"limited": ErrRateLimited, "broken": ErrServer, "missing": ErrNotFound} {
+		_, err := voter.FetchElection(context.Background(), electionID)
+		if !errors.Is(err, expected) || !errors.Is(err, ErrFetchElection) {
+			t.Errorf("Expected %v for %s, got %v", expected, electionID, err)
+		}
+	}
+	if _, err := voter.FetchElection(context.Background(), "missing"); !strings.Contains(err.Error(), "Could not find election") {
+		t.Errorf("Expected the server's message in the error, got %v", err)
+	}
+	if err := voter.Cast(context.Background(), &cryptoballot.Ballot{}); err != ErrNoBallotBox {
+		t.Errorf("Expected ErrNoBallotBox, got %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	voterKey, err := cryptoballot.GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prepared := &PreparedBallot{Ballot: &cryptoballot.Ballot{ElectionID: "election"}}
+
+	// Signature requests that cannot be made
+	voter := NewVoter(voterKey, "")
+	voter.DID = "not a did"
+	if _, err = voter.NewSignatureRequest(prepared); !errors.Is(err, cryptoballot.ErrDIDInvalid) || !errors.Is(err, ErrRequestSignature) {
+		t.Errorf("Expected ErrDIDInvalid wrapped in ErrRequestSignature, got %v", err)
+	}
+	voter.DID = ""
+	voter.Credentials = []*cryptoballot.Credential{{}}
+	if _, err = voter.NewSignatureRequest(prepared); !errors.Is(err, cryptoballot.ErrSignatureRequestVP) || !errors.Is(err, ErrRequestSignature) {
+		t.Errorf("Expected ErrSignatureRequestVP wrapped in ErrRequestSignature, got %v", err)
+	}
+
+	// Ballotboxes that refuse the ballot, or cannot be reached
+	refusing := newTestBox()
+	defer refusing.Close()
+	refusing.refuse = true
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	voter = NewVoter(voterKey, "", refusing.URL, unreachable.URL)
+	err = voter.Cast(context.Background(), &cryptoballot.Ballot{ElectionID: "election", BallotID: "ballot"})
+	castErr, ok := err.(*CastError)
+	if !ok || castErr.Cast() {
+		t.Fatalf("Expected a CastError with no ballotbox accepting the ballot, got %v", err)
+	}
+	if !errors.Is(castErr.Refused[refusing.URL], ErrClosed) {
+		t.Errorf("Expected ErrClosed from the refusing ballotbox, got %v", castErr.Refused[refusing.URL])
+	}
+	if !errors.Is(castErr.Refused[unreachable.URL], ErrUnreachable) {
+		t.Errorf("Expected ErrUnreachable from the closed ballotbox, got %v", castErr.Refused[unreachable.URL])
+	}
+}
@@ -0,0 +1,300 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/phayes/errors"
+)
+
+// A Voter votes with their DID key. Ballots are signed by the election clerk at ClerkURL, and cast to the
+// ballotbox at each of BoxURLs, which are usually mirrors of each other.
+type Voter struct {
+	Key         cryptoballot.DIDPrivateKey
+	DID         string                     // Optional DID of the voter. If given, it identifies the voter instead of their key
+	Credentials []*cryptoballot.Credential // Optional credentials to present to the election clerk. Requires DID
+	ClerkURL    string
+	BoxURLs     []string
+	HTTPClient  *http.Client // Defaults to http.DefaultClient
+}
+
+// NewVoter creates a voter who votes with the given DID key
+func NewVoter(key cryptoballot.DIDPrivateKey, clerkURL string, boxURLs ...string) *Voter {
+	return &Voter{Key: key, ClerkURL: strings.TrimSuffix(clerkURL, "/"), BoxURLs: boxURLs}
+}
+
+// A PreparedBallot is a ballot blinded for the election clerk to sign, along with everything needed to unblind
+// the clerk's signature. It must be kept secret until the ballot is cast.
+type PreparedBallot struct {
+	Ballot       *cryptoballot.Ballot // The unsigned ballot
+	Denomination uint64               // Denomination of the ballot in a weighted election. Zero otherwise
+	ClerkKey     cryptoballot.PublicKey
+	BlindBallot  cryptoballot.BlindBallot
+	Unblinder    []byte
+}
+
+// NewBallotID creates a random hex encoded ballot ID
+func NewBallotID() (string, error) {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", errors.Wrap(err, ErrPrepareBallot)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// FetchElection gets an election from the election clerk, and checks that it is signed by its admin
+func (v *Voter) FetchElection(ctx context.Context, electionID string) (*cryptoballot.Election, error) {
+	_, body, err := v.do(ctx, "GET", v.ClerkURL+"/election/"+electionID, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrFetchElection)
+	}
+	election, err := cryptoballot.NewElection(body)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrFetchElection)
+	}
+	if election.ElectionID != electionID {
+		return nil, errors.Wrap(ErrWrongResponse, ErrFetchElection)
+	}
+	if err = election.VerifySignature(); err != nil {
+		return nil, errors.Wrap(err, ErrFetchElection)
+	}
+	return election, nil
+}
+
+// PrepareBallot blinds a copy of the ballot with the election clerk's public key. In a weighted election the
+// denomination of the ballot is given, and the ballot is tagged with it and blinded with the clerk's key for that
+// denomination. Otherwise the denomination is zero.
+func (v *Voter) PrepareBallot(ctx context.Context, ballot *cryptoballot.Ballot, denomination uint64) (*PreparedBallot, error) {
+	if ballot.HasSignature() {
+		return nil, errors.Wrap(cryptoballot.ErrBallotHasSignature, ErrPrepareBallot)
+	}
+	prepared := &PreparedBallot{Denomination: denomination}
+	copied := *ballot
+	prepared.Ballot = &copied
+
+	var err error
+	if denomination == 0 {
+		prepared.ClerkKey, err = v.clerkKey(ctx)
+	} else {
+		prepared.Ballot.SetDenomination(denomination)
+		var keys cryptoballot.DenominationKeys
+		if keys, err = v.denominationKeys(ctx); err == nil {
+			prepared.ClerkKey, err = keys.Key(denomination)
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrPrepareBallot)
+	}
+
+	prepared.BlindBallot, prepared.Unblinder, err = prepared.Ballot.Blind(prepared.ClerkKey)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrPrepareBallot)
+	}
+	return prepared, nil
+}
+
+// RequestSignature sends a signature request for the prepared ballot to the election clerk, signed with the
+// voter's key, and checks the clerk's blind signature. The voter's DID and credentials are included if given.
+func (v *Voter) RequestSignature(ctx context.Context, prepared *PreparedBallot) (*cryptoballot.FulfilledSignatureRequest, error) {
+	sigReq, err := v.NewSignatureRequest(prepared)
+	if err != nil {
+		return nil, err
+	}
+	header, err := v.signatureHeaders("POST /sign")
+	if err != nil {
+		return nil, errors.Wrap(err, ErrRequestSignature)
+	}
+	_, body, err := v.do(ctx, "POST", v.ClerkURL+"/sign", []byte(sigReq.String()), header)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrRequestSignature)
+	}
+
+	fulfilled, err := cryptoballot.NewFulfilledSignatureRequest(body)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrRequestSignature)
+	}
+	if fulfilled.SignatureRequest.String() != sigReq.String() {
+		return nil, errors.Wrap(ErrWrongResponse, ErrRequestSignature)
+	}
+	if err = fulfilled.VerifyBallotSignature(prepared.ClerkKey); err != nil {
+		return nil, errors.Wrap(err, ErrRequestSignature)
+	}
+	return fulfilled, nil
+}
+
+// Unblind unblinds the election clerk's signature, giving the signed ballot to cast
+func (v *Voter) Unblind(prepared *PreparedBallot, fulfilled *cryptoballot.FulfilledSignatureRequest) (*cryptoballot.Ballot, error) {
+	ballot := *prepared.Ballot
+	if err := ballot.Unblind(prepared.ClerkKey, fulfilled.BallotSignature, prepared.Unblinder); err != nil {
+		return nil, errors.Wrap(err, ErrUnblind)
+	}
+	return &ballot, nil
+}
+
+// A CastError reports the ballotboxes that refused a ballot. The ballot has still been cast if any ballotbox
+// accepted it, since mirrors pass the ballots they accept on to each other.
+type CastError struct {
+	Accepted int              // Number of ballotboxes that accepted the ballot
+	Refused  map[string]error // Why each of the other ballotboxes refused it, by URL
+}
+
+func (err *CastError) Error() string {
+	var urls []string
+	for url := range err.Refused {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	s := "client: " + strconv.Itoa(len(urls)) + " of " + strconv.Itoa(len(urls)+err.Accepted) + " ballotboxes refused the ballot"
+	for _, url := range urls {
+		s += ". " + url + ": " + err.Refused[url].Error()
+	}
+	return s
+}
+
+// Cast reports whether any ballotbox accepted the ballot
+func (err *CastError) Cast() bool {
+	return err.Accepted != 0
+}
+
+// Cast submits a signed ballot to every ballotbox. If any refuses it a *CastError is returned. A ballotbox that
+// shuffles ballots before publishing them accepts the ballot, but does not publish it straight away.
+func (v *Voter) Cast(ctx context.Context, ballot *cryptoballot.Ballot) error {
+	if len(v.BoxURLs) == 0 {
+		return ErrNoBallotBox
+	}
+	castErr := &CastError{Refused: map[string]error{}}
+	for _, boxURL := range v.BoxURLs {
+		_, _, err := v.do(ctx, "PUT", ballotURL(boxURL, ballot), []byte(ballot.String()), nil)
+		if err != nil {
+			castErr.Refused[boxURL] = err
+			continue
+		}
+		castErr.Accepted++
+	}
+	if len(castErr.Refused) != 0 {
+		return castErr
+	}
+	return nil
+}
+
+// Verify checks that every ballotbox publishes the ballot exactly as it was cast, with a valid signature from the
+// election clerk. The ballot given may be unsigned, such as a vote file. In a weighted election the signature
+// must be made with the clerk's key for the ballot's denomination.
+func (v *Voter) Verify(ctx context.Context, ballot *cryptoballot.Ballot) error {
+	if len(v.BoxURLs) == 0 {
+		return errors.Wrap(ErrNoBallotBox, ErrVerify)
+	}
+	keys, err := v.denominationKeys(ctx)
+	if err != nil {
+		return errors.Wrap(err, ErrVerify)
+	}
+	for _, boxURL := range v.BoxURLs {
+		_, body, err := v.do(ctx, "GET", ballotURL(boxURL, ballot), nil, nil)
+		if errors.Is(err, ErrNotFound) {
+			return errors.Wrapf(ErrNotPublished, "%s does not publish ballot %s", boxURL, ballot.BallotID)
+		}
+		if err != nil {
+			return errors.Wrap(err, ErrVerify)
+		}
+		published, err := cryptoballot.NewBallot(body)
+		if err != nil {
+			return errors.Wrap(err, ErrVerify)
+		}
+		if published.StringWithoutSignature() != ballot.StringWithoutSignature() ||
+			(ballot.HasSignature() && !bytes.Equal(published.Signature, ballot.Signature)) {
+			return errors.Wrapf(ErrBallotAltered, "%s publishes a different ballot %s", boxURL, ballot.BallotID)
+		}
+		if _, err = published.VerifyWeightedSignature(keys); err != nil {
+			return errors.Wrap(err, ErrVerify)
+		}
+	}
+	return nil
+}
+
+// NewSignatureRequest creates a signature request for the prepared ballot, signed with the voter's key.
+// RequestSignature sends it to the election clerk. Errors wrap ErrRequestSignature.
+func (v *Voter) NewSignatureRequest(prepared *PreparedBallot) (*cryptoballot.SignatureRequest, error) {
+	publicKey, err := v.Key.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		return nil, errors.Wrap(err, ErrRequestSignature)
+	}
+	sigReq := &cryptoballot.SignatureRequest{
+		ElectionID:   prepared.Ballot.ElectionID,
+		RequestID:    publicKey.RequestID(),
+		PublicKey:    publicKey.Bytes(),
+		Denomination: prepared.Denomination,
+		BlindBallot:  prepared.BlindBallot,
+	}
+	if v.DID != "" {
+		if !cryptoballot.ValidDIDString(v.DID) {
+			return nil, errors.Wrap(cryptoballot.ErrDIDInvalid, ErrRequestSignature)
+		}
+		sigReq.DID = v.DID
+		sigReq.RequestID = cryptoballot.DIDRequestID(v.DID)
+	}
+	if len(v.Credentials) != 0 {
+		if v.DID == "" {
+			return nil, errors.Wrap(cryptoballot.ErrSignatureRequestVP, ErrRequestSignature)
+		}
+		presentation, err := cryptoballot.CreatePresentation(v.DID, v.Credentials, sigReq.ElectionID, sigReq.RequestID, v.Key, v.DID)
+		if err != nil {
+			return nil, errors.Wrap(err, ErrRequestSignature)
+		}
+		sigReq.Presentation = []byte(presentation.String())
+	}
+	sigReq.Signature, err = v.Key.SignString(sigReq.StringWithoutSignature())
+	if err != nil {
+		return nil, errors.Wrap(err, ErrRequestSignature)
+	}
+	return sigReq, nil
+}
+
+// signatureHeaders signs a request string, such as "POST /sign", giving the X-Public-Key and X-Signature headers
+func (v *Voter) signatureHeaders(request string) (http.Header, error) {
+	publicKey, err := v.Key.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	signature, err := v.Key.SignString(request)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set("X-Public-Key", hex.EncodeToString(publicKey.Bytes()))
+	header.Set("X-Signature", hex.EncodeToString(signature))
+	return header, nil
+}
+
+// clerkKey gets the election clerk's public key, used to sign ballots in unweighted elections
+func (v *Voter) clerkKey(ctx context.Context) (cryptoballot.PublicKey, error) {
+	_, body, err := v.do(ctx, "GET", v.ClerkURL+"/publickey", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, cryptoballot.ErrPublicKeyInvalidPEM
+	}
+	return cryptoballot.NewPublicKeyFromBlock(block)
+}
+
+// denominationKeys gets the election clerk's public keys by denomination, used to sign ballots in weighted elections
+func (v *Voter) denominationKeys(ctx context.Context) (cryptoballot.DenominationKeys, error) {
+	_, body, err := v.do(ctx, "GET", v.ClerkURL+"/publickeys", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return cryptoballot.NewDenominationKeys(body)
+}
+
+func ballotURL(boxURL string, ballot *cryptoballot.Ballot) string {
+	return strings.TrimSuffix(boxURL, "/") + "/vote/" + ballot.ElectionID + "/" + ballot.BallotID
+}
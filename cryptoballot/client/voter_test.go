@@ -0,0 +1,192 @@
+package client
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/phayes/errors"
+)
+
+// testClerk is a minimal election clerk serving a single election
+func testClerk(t *testing.T, election *cryptoballot.Election, key cryptoballot.PrivateKey) *httptest.Server {
+	publicKey, err := key.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := map[string]bool{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/election/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(election.String()))
+	})
+	mux.HandleFunc("/publickey", func(w http.ResponseWriter, r *http.Request) {
+		pem.Encode(w, &pem.Block{Type: "PUBLIC KEY", Bytes: publicKey.Bytes()})
+	})
+	mux.HandleFunc("/publickeys", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(cryptoballot.DenominationKeys{1: publicKey}.String()))
+	})
+	mux.HandleFunc("/sign", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		sigReq, err := cryptoballot.NewSignatureRequest(body)
+		if err == nil {
+			err = sigReq.VerifySignature()
+		}
+		if err != nil {
+			http.Error(w, `{"code":"verification","message":"`+err.Error()+`"}`, http.StatusBadRequest)
+			return
+		}
+		if signed[string(sigReq.RequestID)] {
+			http.Error(w, `{"code":"duplicate","message":"already received fulfilled signature request"}`, http.StatusBadRequest)
+			return
+		}
+		signed[string(sigReq.RequestID)] = true
+		signature, err := key.BlindSign(sigReq.BlindBallot)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(cryptoballot.NewFulfilledSignatureRequestFromParts(*sigReq, signature).String()))
+	})
+	return httptest.NewServer(mux)
+}
+
+// testBox is a minimal ballotbox. Ballots PUT to it are published unless it is refusing them.
+type testBox struct {
+	*httptest.Server
+	mu      sync.Mutex
+	ballots map[string]string
+	refuse  bool
+}
+
+func newTestBox() *testBox {
+	box := &testBox{ballots: map[string]string{}}
+	box.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		box.mu.Lock()
+		defer box.mu.Unlock()
+		switch {
+		case r.Method == "PUT" && box.refuse:
+			http.Error(w, `{"code":"closed","message":"Election is not open for voting"}`, http.StatusBadRequest)
+		case r.Method == "PUT":
+			body, _ := ioutil.ReadAll(r.Body)
+			box.ballots[r.URL.Path] = string(body)
+		case box.ballots[r.URL.Path] != "":
+			w.Write([]byte(box.ballots[r.URL.Path]))
+		default:
+			http.Error(w, `{"code":"not_found","message":"Ballot not found"}`, http.StatusNotFound)
+		}
+	}))
+	return box
+}
+
+func TestVoter(t *testing.T) {
+	ctx := context.Background()
+	adminKey, err := cryptoballot.GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminPub, err := adminKey.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	election := &cryptoballot.Election{
+		ElectionID: "sdkelection",
+		Start:      time.Now().Add(-time.Minute).Truncate(time.Second),
+		End:        time.Now().Add(time.Hour).Truncate(time.Second),
+		PublicKey:  adminPub.Bytes(),
+	}
+	election.Signature, err = adminKey.SignString(election.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clerkKey, err := cryptoballot.GeneratePrivateKey(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clerk := testClerk(t, election, clerkKey)
+	defer clerk.Close()
+	box, mirror := newTestBox(), newTestBox()
+	defer box.Close()
+	defer mirror.Close()
+
+	voterKey, err := cryptoballot.GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	voter := NewVoter(voterKey, clerk.URL, box.URL, mirror.URL)
+	fetched, err := voter.FetchElection(ctx, "sdkelection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched.String() != election.String() {
+		t.Errorf("Fetched the wrong election:\n%s", fetched)
+	}
+
+	ballotID, err := NewBallotID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ballot := &cryptoballot.Ballot{ElectionID: "sdkelection", BallotID: ballotID, Vote: cryptoballot.Vote{"Alice", "Bob"}}
+	prepared, err := voter.PrepareBallot(ctx, ballot, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fulfilled, err := voter.RequestSignature(ctx, prepared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := voter.Unblind(prepared, fulfilled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ballot.HasSignature() || !signed.HasSignature() {
+		t.Error("Expected a signed copy of the ballot, leaving the ballot unsigned")
+	}
+
+	// A mirror that refuses the ballot is reported, but the ballot is still cast
+	mirror.refuse = true
+	err = voter.Cast(ctx, signed)
+	castErr, ok := err.(*CastError)
+	if !ok || !castErr.Cast() || !errors.Is(castErr.Refused[mirror.URL], ErrClosed) {
+		t.Fatalf("Expected a CastError with the mirror refusing a closed election, got %v", err)
+	}
+
+	// The refusing mirror does not publish the ballot, and a ballot that was not cast is not published anywhere
+	if err = voter.Verify(ctx, ballot); !errors.Is(err, ErrNotPublished) {
+		t.Errorf("Expected ErrNotPublished, got %v", err)
+	}
+	voter.BoxURLs = []string{box.URL}
+	if err = voter.Verify(ctx, ballot); err != nil {
+		t.Error(err)
+	}
+	altered := *ballot
+	altered.Vote = cryptoballot.Vote{"Bob", "Alice"}
+	if err = voter.Verify(ctx, &altered); !errors.Is(err, ErrBallotAltered) {
+		t.Errorf("Expected ErrBallotAltered, got %v", err)
+	}
+
+	// The clerk only signs once for each voter
+	prepared, err = voter.PrepareBallot(ctx, ballot, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = voter.RequestSignature(ctx, prepared); !errors.Is(err, ErrDuplicate) || !errors.Is(err, ErrRequestSignature) {
+		t.Errorf("Expected ErrDuplicate, got %v", err)
+	}
+
+	// A cancelled context stops the request
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err = voter.FetchElection(cancelled, "sdkelection"); !errors.Is(err, ErrFetchElection) {
+		t.Errorf("Expected ErrFetchElection, got %v", err)
+	}
+
+	// A ballot that is already signed cannot be prepared again
+	if _, err = voter.PrepareBallot(ctx, signed, 0); !errors.Is(err, ErrPrepareBallot) {
+		t.Errorf("Expected ErrPrepareBallot, got %v", err)
+	}
+}
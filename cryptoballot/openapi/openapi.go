@@ -0,0 +1,211 @@
+// Package openapi builds the OpenAPI documents that describe the JSON API of the election clerk and ballotbox.
+//
+// Schemas are generated from the Go types that are encoded, by reading their json struct tags, so the documents
+// cannot drift from what the servers actually send. A field's "description" struct tag becomes its description.
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Version is the version of the OpenAPI specification the documents follow
+const Version = "3.0.3"
+
+// MediaTypeJSON is the media type of the JSON API. Text format requests and responses are not described.
+const MediaTypeJSON = "application/json"
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem holds the operations on a path, keyed by lower case HTTP method
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string              `json:"summary"`
+	OperationID string              `json:"operationId"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path, query or header
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is a JSON schema, in the subset used by OpenAPI. An empty schema allows any value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// New creates a document with no paths or schemas
+func New(title, version, description string) *Document {
+	return &Document{
+		OpenAPI:    Version,
+		Info:       Info{Title: title, Description: description, Version: version},
+		Paths:      map[string]PathItem{},
+		Components: Components{Schemas: map[string]*Schema{}},
+	}
+}
+
+// AddSchema generates a schema for the type of v, adds it to the document's components, and returns a reference to it
+func (doc *Document) AddSchema(name string, v interface{}) *Schema {
+	doc.Components.Schemas[name] = SchemaFor(reflect.TypeOf(v))
+	return Ref(name)
+}
+
+// Add adds an operation on a path. The method is an HTTP method, such as "GET".
+func (doc *Document) Add(method, path string, op *Operation) {
+	if doc.Paths[path] == nil {
+		doc.Paths[path] = PathItem{}
+	}
+	doc.Paths[path][strings.ToLower(method)] = op
+}
+
+// Ref references a schema in the document's components
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// ArrayOf is a schema for a list of items
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// JSONContent is the content of a JSON request or response body
+func JSONContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{MediaTypeJSON: {Schema: schema}}
+}
+
+// PathParam is a required path parameter. OpenAPI requires every {name} in a path to be described by one.
+func PathParam(name, description string) Parameter {
+	return Parameter{Name: name, In: "path", Description: description, Required: true, Schema: &Schema{Type: "string"}}
+}
+
+// QueryParam is an optional query parameter
+func QueryParam(name, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+// HeaderParam is a request header
+func HeaderParam(name, description string, required bool) Parameter {
+	return Parameter{Name: name, In: "header", Description: description, Required: required, Schema: &Schema{Type: "string"}}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// SchemaFor generates a schema for values of type t as they are encoded by encoding/json. Struct fields without
+// omitempty are required. Types with their own MarshalJSON method are not followed, so the schema of their JSON form
+// should be generated instead. See cryptoballot.ElectionJSON for example.
+func SchemaFor(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return SchemaFor(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return ArrayOf(SchemaFor(t.Elem()))
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: SchemaFor(t.Elem())}
+	case reflect.Struct:
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		addFields(schema, t)
+		return schema
+	}
+	return &Schema{}
+}
+
+// addFields adds the properties of a struct's fields to an object schema. Embedded structs without a json name
+// have their fields promoted, as encoding/json does.
+func addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, options = tag[:comma], tag[comma:]
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addFields(schema, field.Type)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := SchemaFor(field.Type)
+		if description := field.Tag.Get("description"); description != "" {
+			property.Description = description
+		}
+		schema.Properties[name] = property
+		if !strings.Contains(options, ",omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
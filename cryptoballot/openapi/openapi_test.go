@@ -0,0 +1,85 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+type testEmbedded struct {
+	Note string `json:"note,omitempty" description:"A note"`
+}
+
+type testValue struct {
+	testEmbedded
+	Name    string          `json:"name"`
+	Count   int             `json:"count"`
+	Ratio   float64         `json:"ratio,omitempty"`
+	Tags    []string        `json:"tags"`
+	Extra   map[string]bool `json:"extra,omitempty"`
+	At      time.Time       `json:"at"`
+	Raw     json.RawMessage `json:"raw,omitempty"`
+	Next    *testEmbedded   `json:"next,omitempty"`
+	Ignored string          `json:"-"`
+	private string
+}
+
+func TestSchemaFor(t *testing.T) {
+	schema := SchemaFor(reflect.TypeOf(testValue{}))
+	expected := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"note":  {Type: "string", Description: "A note"},
+			"name":  {Type: "string"},
+			"count": {Type: "integer"},
+			"ratio": {Type: "number"},
+			"tags":  {Type: "array", Items: &Schema{Type: "string"}},
+			"extra": {Type: "object", AdditionalProperties: &Schema{Type: "boolean"}},
+			"at":    {Type: "string", Format: "date-time"},
+			"raw":   {},
+			"next": {Type: "object", Properties: map[string]*Schema{
+				"note": {Type: "string", Description: "A note"},
+			}},
+		},
+		Required: []string{"name", "count", "tags", "at"},
+	}
+	if !reflect.DeepEqual(schema, expected) {
+		got, _ := json.Marshal(schema)
+		want, _ := json.Marshal(expected)
+		t.Errorf("Unexpected schema:\n%s\n%s", got, want)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	doc := New("Test", "1", "")
+	ballot := doc.AddSchema("Ballot", cryptoballot.BallotJSON{})
+	doc.Add("GET", "/vote/{electionId}/{ballotId}", &Operation{
+		Summary:     "Get a ballot",
+		OperationID: "getBallot",
+		Parameters:  []Parameter{PathParam("electionId", ""), PathParam("ballotId", "")},
+		Responses:   map[string]Response{"200": {Description: "The ballot", Content: JSONContent(ballot)}},
+	})
+
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct {
+		OpenAPI    string
+		Paths      map[string]map[string]json.RawMessage
+		Components struct{ Schemas map[string]Schema }
+	}
+	if err = json.Unmarshal(encoded, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.OpenAPI != Version || parsed.Paths["/vote/{electionId}/{ballotId}"]["get"] == nil {
+		t.Errorf("Unexpected document: %s", encoded)
+	}
+	signature := parsed.Components.Schemas["Ballot"].Properties["signature"]
+	if signature == nil || signature.Description == "" {
+		t.Errorf("Expected a described ballot signature, got %s", encoded)
+	}
+}
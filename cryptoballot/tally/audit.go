@@ -0,0 +1,267 @@
+package tally
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+// Report is the outcome of auditing a bundle
+type Report struct {
+	ElectionID        string
+	Signed            bool      // The bundle carries a valid exporter signature
+	SignedByAdmin     bool      // The bundle was signed by the admin who created the election
+	SignatureRequests int       // Number of valid fulfilled signature requests
+	Ballots           int       // Number of valid ballots
+	Weighted          bool      // The election is weighted. See cryptoballot.WeightSource
+	IssuedWeight      uint64    // Total weight of the valid fulfilled signature requests, for weighted elections
+	Weight            uint64    // Total weight of the valid ballots, for weighted elections
+	Adjudicated       bool      // The bundle carries a valid adjudication signed by the election admin
+	WriteIns          []WriteIn // How each option that is not a listed candidate was counted. See Normalizer
+	Decision          *Decision // The result's outcome under the election's quorum and threshold. Nil if it has neither
+	Problems          []string  // Everything that failed verification
+	Result            *Result   // Result of tallying the valid ballots. Nil if they could not be tallied
+}
+
+// Audit verifies everything in a bundle and tallies the valid ballots. It checks that:
+//   - The bundle signature is valid
+//   - The election is signed by it's admin
+//   - Every fulfilled signature request is for this election, is signed by the voter, and was signed by the clerk
+//   - No voter received more than one signature, or in weighted elections, that every signature was made with the key for its denomination
+//   - Every ballot is for this election and carries a valid clerk signature, made with the key for its denomination in weighted elections
+//   - No two ballots share a ballot-id. Such ballots are both invalid, and are not counted
+//   - There are no more ballots than signatures issued, and no more ballot weight than signature weight issued
+//   - The Merkle root matches the ballots
+//   - Any adjudication is for this election and is signed by it's admin
+//
+// If the election lists candidates or allows write-ins, the valid ballots are normalized before they are tallied.
+// See Normalizer. The aliases are optional, and are not used for elections that declare neither.
+//
+// If the election has a quorum or threshold, they are applied to the result. Turnout is counted from the valid
+// signature requests, against the electorate given, or the electorate declared by the election if it is zero. The
+// tally command counts the electorate from the voter roll the same way. See VoterRollElectorate and Decide
+//
+// Problems are collected in the report rather than stopping the audit, so that a single report lists everything wrong with a bundle.
+func Audit(bundle *cryptoballot.Bundle, aliases cryptoballot.AliasMap, electorate uint64) *Report {
+	report := &Report{ElectionID: bundle.Election.ElectionID}
+	problem := func(format string, args ...interface{}) {
+		report.Problems = append(report.Problems, fmt.Sprintf(format, args...))
+	}
+
+	// Bundle and election signatures
+	if bundle.HasSignature() {
+		if err := bundle.VerifySignature(); err != nil {
+			problem("Bundle signature is invalid: %s", err)
+		} else {
+			report.Signed = true
+			report.SignedByAdmin = bytes.Equal(bundle.PublicKey, bundle.Election.PublicKey)
+		}
+	}
+	if err := bundle.Election.VerifySignature(); err != nil {
+		problem("Election signature is invalid: %s", err)
+	}
+
+	// Weighted elections have a clerk key per denomination. Voters may have several signatures, one per denomination.
+	weightSource, err := bundle.Election.WeightSource()
+	if err != nil {
+		problem("Election weight-source is invalid: %s", err)
+	}
+	report.Weighted = weightSource != nil
+	keys := bundle.DenominationKeys
+	if keys == nil {
+		if report.Weighted {
+			problem("Election is weighted but the bundle has no denomination keys")
+		}
+		keys = cryptoballot.DenominationKeys{1: bundle.ClerkKey}
+	}
+
+	// Fulfilled signature requests
+	voters := map[string]bool{}
+	for i, fulfilled := range bundle.SignatureRequests {
+		if fulfilled.ElectionID != bundle.Election.ElectionID {
+			problem("Signature request %d is for election %s", i, fulfilled.ElectionID)
+			continue
+		}
+		if err := fulfilled.VerifySignature(); err != nil {
+			problem("Signature request %d has an invalid voter signature: %s", i, err)
+			continue
+		}
+		if report.Weighted {
+			if err := fulfilled.VerifyWeightedBallotSignature(keys); err != nil {
+				problem("Signature request %d has an invalid clerk signature: %s", i, err)
+				continue
+			}
+			report.IssuedWeight += fulfilled.Weight()
+			report.SignatureRequests++
+			continue
+		}
+		if err := fulfilled.VerifyBallotSignature(bundle.ClerkKey); err != nil {
+			problem("Signature request %d has an invalid clerk signature: %s", i, err)
+			continue
+		}
+		// The request ID identifies the voter, by DID if they have one so that rotating keys does not make a new voter
+		voter := string(fulfilled.RequestID)
+		if voters[voter] {
+			problem("Signature request %d is a second signature for the same voter", i)
+			continue
+		}
+		voters[voter] = true
+		report.SignatureRequests++
+	}
+
+	// Ballots. Count ballot-ids first so that duplicated ballots can be excluded entirely
+	ballotIDs := map[string]int{}
+	for _, ballot := range bundle.Ballots {
+		ballotIDs[ballot.BallotID]++
+	}
+	duplicates := map[string]bool{}
+	valid := []WeightedBallot{}
+	for _, ballot := range bundle.Ballots {
+		if ballot.ElectionID != bundle.Election.ElectionID {
+			problem("Ballot %s is for election %s", ballot.BallotID, ballot.ElectionID)
+			continue
+		}
+		weight := uint64(1)
+		if report.Weighted {
+			weight, err = ballot.VerifyWeightedSignature(keys)
+		} else {
+			err = ballot.VerifyBlindSignature(bundle.ClerkKey)
+		}
+		if err != nil {
+			problem("Ballot %s has an invalid clerk signature: %s", ballot.BallotID, err)
+			continue
+		}
+		if ballotIDs[ballot.BallotID] > 1 {
+			if !duplicates[ballot.BallotID] {
+				problem("Ballot %s appears %d times. None of its copies are counted", ballot.BallotID, ballotIDs[ballot.BallotID])
+				duplicates[ballot.BallotID] = true
+			}
+			continue
+		}
+		valid = append(valid, WeightedBallot{Ballot: ballot, Weight: weight})
+	}
+	report.Ballots = len(valid)
+
+	if report.Ballots > report.SignatureRequests {
+		problem("There are %d valid ballots but only %d valid signature requests", report.Ballots, report.SignatureRequests)
+	}
+	if report.Weighted {
+		for _, ballot := range valid {
+			report.Weight += ballot.Weight
+		}
+		if report.Weight > report.IssuedWeight {
+			problem("The valid ballots have a weight of %d but the valid signature requests only %d", report.Weight, report.IssuedWeight)
+		}
+	}
+
+	// Merkle root
+	if bundle.MerkleRoot != nil && !bytes.Equal(bundle.MerkleRoot, bundle.ComputeMerkleRoot()) {
+		problem("Merkle root does not match the ballots in the bundle")
+	}
+
+	// Write-ins. An adjudication that does not verify is not used
+	contest := bundle.Election.Contest()
+	adjudication := bundle.Adjudication
+	if adjudication != nil {
+		if !contest.Declared() {
+			problem("Bundle has an adjudication but the election does not list candidates or allow write-ins")
+			adjudication = nil
+		} else if err := adjudication.VerifyForElection(&bundle.Election); err != nil {
+			problem("Adjudication is invalid: %s", err)
+			adjudication = nil
+		}
+		report.Adjudicated = adjudication != nil
+	}
+	if contest.Declared() {
+		valid, report.WriteIns = NewNormalizer(&bundle.Election, aliases, adjudication).Normalize(valid)
+	}
+
+	// Tally the valid ballots
+	var result *Result
+	if report.Weighted {
+		result, err = TallyWeighted(valid)
+	} else {
+		ballots := make([]cryptoballot.Ballot, len(valid))
+		for i, ballot := range valid {
+			ballots[i] = ballot.Ballot
+		}
+		result, err = Tally(ballots)
+	}
+	if err != nil {
+		problem("Could not tally ballots: %s", err)
+	} else {
+		report.Result = result
+	}
+
+	// Quorum and threshold
+	rules, err := bundle.Election.DecisionRules()
+	if err != nil {
+		problem("Election quorum or threshold is invalid: %s", err)
+	} else if rules.Declared() && report.Result != nil {
+		turnout := uint64(report.SignatureRequests)
+		if report.Weighted {
+			turnout = report.IssuedWeight
+		}
+		if electorate == 0 {
+			electorate = rules.Electorate
+		}
+		report.Decision, err = Decide(rules, turnout, electorate, valid, report.Result)
+		if err != nil {
+			problem("Could not apply the quorum and threshold: %s", err)
+		}
+	}
+
+	return report
+}
+
+// OK reports whether the audit found no problems
+func (report *Report) OK() bool {
+	return len(report.Problems) == 0
+}
+
+// Implements Stringer
+func (report Report) String() string {
+	s := "election: " + report.ElectionID + "\n"
+	switch {
+	case report.SignedByAdmin:
+		s += "bundle signed by: election admin\n"
+	case report.Signed:
+		s += "bundle signed by: unknown key\n"
+	default:
+		s += "bundle signed by: nobody\n"
+	}
+	s += fmt.Sprintf("signature requests: %d\n", report.SignatureRequests)
+	s += fmt.Sprintf("valid ballots: %d\n", report.Ballots)
+	if report.Weighted {
+		s += fmt.Sprintf("signature weight: %d\n", report.IssuedWeight)
+		s += fmt.Sprintf("ballot weight: %d\n", report.Weight)
+	}
+
+	if len(report.WriteIns) != 0 {
+		if report.Adjudicated {
+			s += "write-ins: adjudicated by election admin\n"
+		} else {
+			s += "write-ins:\n"
+		}
+		for _, writeIn := range report.WriteIns {
+			s += "  " + writeIn.String() + "\n"
+		}
+	}
+
+	if report.OK() {
+		s += "audit: passed\n"
+	} else {
+		s += fmt.Sprintf("audit: failed with %d problems\n", len(report.Problems))
+		s += "  " + strings.Join(report.Problems, "\n  ") + "\n"
+	}
+
+	if report.Result != nil {
+		s += report.Result.String()
+	}
+	if report.Decision != nil {
+		s += report.Decision.String()
+	}
+	return s
+}
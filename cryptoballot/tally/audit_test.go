@@ -0,0 +1,420 @@
+package tally
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cryptoballot/fdh"
+	"github.com/cryptoballot/rsablind"
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+// newTestBundle creates a bundle where each voter has cast a ballot with the given vote, signed by the election admin.
+// Ballots are signed by the clerk directly rather than going through the blinding process.
+func newTestBundle(t *testing.T, votes []cryptoballot.Vote) *cryptoballot.Bundle {
+	return newTestWeightedBundle(t, votes, nil)
+}
+
+// newTestWeightedBundle creates a bundle like newTestBundle. If denominations is not nil the election is weighted by
+// the voter roll, the clerk has a key for each of 1, 10 and 100, and each voter casts one ballot of the given denomination.
+func newTestWeightedBundle(t *testing.T, votes []cryptoballot.Vote, denominations []uint64) *cryptoballot.Bundle {
+	bundle, _ := newTestTaggedBundle(t, votes, denominations, nil)
+	return bundle
+}
+
+// newTestTaggedBundle creates a bundle like newTestWeightedBundle, with the given tags added to the election.
+// The admin's key is returned so that tests can sign more of the bundle.
+func newTestTaggedBundle(t *testing.T, votes []cryptoballot.Vote, denominations []uint64, tags cryptoballot.TagSet) (*cryptoballot.Bundle, cryptoballot.DIDPrivateKey) {
+	adminPriv, err := cryptoballot.GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminPub, err := adminPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clerkKeys := map[uint64]*rsa.PrivateKey{}
+	publicKeys := cryptoballot.DenominationKeys{}
+	for _, denomination := range []uint64{1, 10, 100} {
+		if denomination != 1 && denominations == nil {
+			break
+		}
+		clerkPriv, err := cryptoballot.GeneratePrivateKey(2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKeys[denomination], err = clerkPriv.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		clerkKeys[denomination], err = clerkPriv.GetCryptoKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	clerkPub := publicKeys[1]
+	keylen, err := clerkPub.KeyLength()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	election := cryptoballot.Election{
+		ElectionID: "testelection",
+		Start:      time.Now().Add(-time.Hour).Truncate(time.Second),
+		End:        time.Now().Truncate(time.Second),
+		PublicKey:  adminPub.Bytes(),
+	}
+	if denominations != nil {
+		election.TagSet = cryptoballot.TagSet{{Key: []byte(cryptoballot.ElectionWeightSourceTag), Value: []byte(cryptoballot.WeightSourceVoterRoll)}}
+	}
+	if tags != nil {
+		election.TagSet = append(election.TagSet, tags...)
+	}
+	election.Signature, err = adminPriv.SignString(election.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := &cryptoballot.Bundle{
+		Election: election,
+		ClerkKey: clerkPub,
+	}
+	if denominations != nil {
+		bundle.DenominationKeys = publicKeys
+	}
+
+	for i, vote := range votes {
+		denomination := uint64(1)
+		if denominations != nil {
+			denomination = denominations[i]
+		}
+		clerkCryptoKey := clerkKeys[denomination]
+		// The voter requests a signature
+		voterPriv, err := cryptoballot.GenerateDIDPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		voterPub, err := voterPriv.GetPublicKeyFromPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		blindBallot := make([]byte, keylen/16)
+		_, err = rand.Read(blindBallot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requestID := common.Sha256D(voterPub.Bytes())
+		signatureRequest := cryptoballot.SignatureRequest{
+			ElectionID:  election.ElectionID,
+			RequestID:   requestID[:],
+			PublicKey:   voterPub.Bytes(),
+			BlindBallot: blindBallot,
+		}
+		if denomination > 1 {
+			signatureRequest.Denomination = denomination
+		}
+		signatureRequest.Signature, err = voterPriv.SignString(signatureRequest.StringWithoutSignature())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ballotSignature, err := rsablind.BlindSign(clerkCryptoKey, blindBallot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundle.SignatureRequests = append(bundle.SignatureRequests, *cryptoballot.NewFulfilledSignatureRequestFromParts(signatureRequest, ballotSignature))
+
+		// The voter casts their ballot
+		ballot := cryptoballot.Ballot{
+			ElectionID: election.ElectionID,
+			BallotID:   "ballot" + strconv.Itoa(i),
+			Vote:       vote,
+		}
+		ballot.SetDenomination(denomination)
+		hashed := fdh.Sum(crypto.SHA256, keylen/2, []byte(ballot.StringWithoutSignature()))
+		ballot.Signature, err = rsablind.BlindSign(clerkCryptoKey, hashed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundle.Ballots = append(bundle.Ballots, ballot)
+	}
+
+	bundle.MerkleRoot = bundle.ComputeMerkleRoot()
+	bundle.PublicKey = adminPub.Bytes()
+	bundle.Signature, err = adminPriv.SignString(bundle.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return bundle, adminPriv
+}
+
+var testVotes = []cryptoballot.Vote{
+	{"Alice", "Bob"},
+	{"Alice", "Bob"},
+	{"Bob", "Alice"},
+}
+
+func TestAudit(t *testing.T) {
+	bundle := newTestBundle(t, testVotes)
+
+	// Audit a round-tripped bundle, as a third party would
+	bundle, err := cryptoballot.NewBundle([]byte(bundle.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := Audit(bundle, nil, 0)
+	if !report.OK() {
+		t.Fatal(report.Problems)
+	}
+	if !report.SignedByAdmin {
+		t.Error("Expected bundle to be signed by the election admin")
+	}
+	if report.SignatureRequests != 3 || report.Ballots != 3 {
+		t.Errorf("Expected 3 signature requests and 3 ballots, got %d and %d", report.SignatureRequests, report.Ballots)
+	}
+	if report.Result == nil || report.Result.Winners[0] != "Alice" {
+		t.Error("Expected Alice to win")
+	}
+	if !strings.Contains(report.String(), "audit: passed") {
+		t.Error("Report should say the audit passed")
+	}
+}
+
+func TestAuditProblems(t *testing.T) {
+	bundle := newTestBundle(t, testVotes)
+
+	// Duplicate a ballot, tamper with another, and drop the signature requests
+	bundle.Ballots = append(bundle.Ballots, bundle.Ballots[0])
+	bundle.Ballots[1].Vote = cryptoballot.Vote{"Bob", "Alice"}
+	bundle.SignatureRequests = nil
+
+	report := Audit(bundle, nil, 0)
+	if report.OK() {
+		t.Fatal("Expected audit to fail")
+	}
+
+	// Bundle signature, bad ballot signature, duplicate ballot, too many ballots, and Merkle root
+	if len(report.Problems) != 5 {
+		t.Errorf("Expected 5 problems, got %d: %v", len(report.Problems), report.Problems)
+	}
+	if report.Ballots != 1 {
+		t.Errorf("Expected 1 valid ballot, got %d", report.Ballots)
+	}
+	if report.Signed {
+		t.Error("Tampered bundle should not have a valid signature")
+	}
+}
+
+func TestAuditWeighted(t *testing.T) {
+	// Bob has the fewest voters but the most weight
+	bundle := newTestWeightedBundle(t, testVotes, []uint64{1, 10, 100})
+
+	bundle, err := cryptoballot.NewBundle([]byte(bundle.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := Audit(bundle, nil, 0)
+	if !report.OK() {
+		t.Fatal(report.Problems)
+	}
+	if !report.Weighted || report.Weight != 111 || report.IssuedWeight != 111 {
+		t.Errorf("Expected a weight of 111, got %d ballot and %d signature weight", report.Weight, report.IssuedWeight)
+	}
+	if report.Result == nil || report.Result.Winners[0] != "Bob" {
+		t.Error("Expected Bob to win")
+	}
+
+	// A ballot claiming a denomination it was not signed for is invalid
+	bundle.Ballots[0].SetDenomination(100)
+	report = Audit(bundle, nil, 0)
+	if report.OK() || report.Weight != 110 {
+		t.Errorf("Expected a relabelled ballot to be invalid, got a weight of %d", report.Weight)
+	}
+}
+
+func TestAuditWriteIns(t *testing.T) {
+	tags := cryptoballot.TagSet{
+		{Key: []byte(cryptoballot.ElectionCandidatesTag), Value: []byte("Salvador Dalí, Frida Kahlo")},
+		{Key: []byte(cryptoballot.ElectionWriteInsTag), Value: []byte("true")},
+	}
+	votes := []cryptoballot.Vote{
+		{"Salvador Dalí"},
+		{"salvador  dalí"},
+		{"Dali"},
+		{"S. Dali"},
+		{"Frida Kahlo"},
+		{"Mickey Mouse"},
+		{"Diego Rivera"},
+		{"diego rivera"},
+		{"Diego Rivera"},
+	}
+	bundle, adminPriv := newTestTaggedBundle(t, votes, nil, tags)
+
+	// Without rulings the variants of Dalí split the count, and the write-in Diego Rivera wins
+	report := Audit(bundle, nil, 0)
+	if !report.OK() {
+		t.Fatal(report.Problems)
+	}
+	if report.Result == nil || report.Result.Winners[0] != "Diego Rivera" {
+		t.Fatal("Expected Diego Rivera to win, got", report.Result)
+	}
+	if len(report.WriteIns) != 5 {
+		t.Errorf("Expected 5 write-ins, got %v", report.WriteIns)
+	}
+
+	// The admin rules on the misspelling and a joke, and the auditor knows of an abbreviation
+	rulings, err := cryptoballot.NewRulings([]byte("dali => Salvador Dalí\nMickey Mouse => -"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle.Adjudication = &cryptoballot.Adjudication{
+		ElectionID: bundle.Election.ElectionID,
+		Rulings:    rulings,
+		PublicKey:  bundle.Election.PublicKey,
+	}
+	bundle.Adjudication.Signature, err = adminPriv.SignString(bundle.Adjudication.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle.Signature, err = adminPriv.SignString(bundle.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliases, err := cryptoballot.NewAliasMap([]byte("S. Dali => salvador dalí"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err = cryptoballot.NewBundle([]byte(bundle.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report = Audit(bundle, aliases, 0)
+	if !report.OK() || !report.Adjudicated {
+		t.Fatal("Expected a valid adjudication", report.Problems)
+	}
+	if report.Result == nil || len(report.Result.Winners) != 1 || report.Result.Winners[0] != "Salvador Dalí" {
+		t.Fatal("Expected Salvador Dalí to win, got", report.Result)
+	}
+	expected := map[string]WriteIn{
+		"Dali":         {"Dali", "Salvador Dalí", WriteInAdjudication, 1},
+		"Diego Rivera": {"Diego Rivera", "Diego Rivera", WriteInUnadjudicated, 2},
+		"Mickey Mouse": {"Mickey Mouse", "", WriteInRejected, 1},
+		"S. Dali":      {"S. Dali", "Salvador Dalí", WriteInAlias, 1},
+		"diego rivera": {"diego rivera", "Diego Rivera", WriteInUnadjudicated, 1},
+	}
+	for _, writeIn := range report.WriteIns {
+		if writeIn != expected[writeIn.Raw] {
+			t.Errorf("Expected %v, got %v", expected[writeIn.Raw], writeIn)
+		}
+	}
+	if len(report.WriteIns) != 5 {
+		t.Errorf("Expected 5 write-ins, got %v", report.WriteIns)
+	}
+	if !strings.Contains(report.String(), "write-ins: adjudicated by election admin") {
+		t.Error("Report should say the write-ins were adjudicated")
+	}
+
+	// An adjudication not signed by the admin is a problem, and is not used
+	otherPriv, err := cryptoballot.GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, err := otherPriv.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle.Adjudication.PublicKey = otherPub.Bytes()
+	bundle.Adjudication.Signature, err = otherPriv.SignString(bundle.Adjudication.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+	report = Audit(bundle, nil, 0)
+	if report.OK() || report.Adjudicated {
+		t.Error("Expected an adjudication by someone other than the admin to be a problem")
+	}
+}
+
+func TestAuditDecision(t *testing.T) {
+	for electorate, outcome := range map[string]string{"6": OutcomePassed, "8": OutcomeNoQuorum} {
+		tags := cryptoballot.TagSet{
+			{Key: []byte(cryptoballot.ElectionQuorumTag), Value: []byte("50%")},
+			{Key: []byte(cryptoballot.ElectionThresholdTag), Value: []byte("2/3")},
+			{Key: []byte(cryptoballot.ElectionElectorateTag), Value: []byte(electorate)},
+		}
+		bundle, _ := newTestTaggedBundle(t, testVotes, nil, tags)
+		report := Audit(bundle, nil, 0)
+		if !report.OK() {
+			t.Fatal(report.Problems)
+		}
+		if report.Decision == nil || report.Decision.Outcome != outcome {
+			t.Errorf("Expected %s with an electorate of %s, got %v", outcome, electorate, report.Decision)
+		}
+		if !strings.Contains(report.String(), "outcome: "+outcome) {
+			t.Error("Report should give the outcome")
+		}
+	}
+}
+
+func TestAuditVoterRollElectorate(t *testing.T) {
+	tags := cryptoballot.TagSet{
+		{Key: []byte(cryptoballot.ElectionQuorumTag), Value: []byte("50%")},
+		{Key: []byte(cryptoballot.ElectionThresholdTag), Value: []byte("2/3")},
+	}
+	bundle, _ := newTestTaggedBundle(t, testVotes, nil, tags)
+
+	// Without an electorate tag, a quorum that is a share of the electorate needs the voter roll
+	report := Audit(bundle, nil, 0)
+	if report.OK() || report.Decision != nil {
+		t.Error("Expected a share quorum without an electorate to be a problem")
+	}
+
+	for size, outcome := range map[int]string{6: OutcomePassed, 8: OutcomeNoQuorum} {
+		roll := cryptoballot.VoterRoll{}
+		for i := 0; i < size; i++ {
+			roll["did:elastos:voter"+strconv.Itoa(i)] = 1
+		}
+		electorate, err := VoterRollElectorate(roll, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		report := Audit(bundle, nil, electorate)
+		if !report.OK() {
+			t.Fatal(report.Problems)
+		}
+		if report.Decision == nil || report.Decision.Outcome != outcome {
+			t.Errorf("Expected %s with a voter roll of %d, got %v", outcome, size, report.Decision)
+			continue
+		}
+
+		// The tally command decides the same way
+		ballots := make([]WeightedBallot, len(bundle.Ballots))
+		for i, ballot := range bundle.Ballots {
+			ballots[i] = WeightedBallot{Ballot: ballot, Weight: 1}
+		}
+		result, err := Tally(bundle.Ballots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules, err := bundle.Election.DecisionRules()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decision, err := Decide(rules, Turnout(bundle.SignatureRequests, false), electorate, ballots, result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision.String() != report.Decision.String() {
+			t.Errorf("Expected the audit and the tally to decide the same way:\n%s\n%s", report.Decision, decision)
+		}
+	}
+}
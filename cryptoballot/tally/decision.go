@@ -0,0 +1,117 @@
+package tally
+
+import (
+	"fmt"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/phayes/errors"
+)
+
+// The outcome of an election with a quorum or threshold
+const (
+	OutcomePassed   = "passed"    // Quorum was reached and the winner met the threshold
+	OutcomeFailed   = "failed"    // Quorum was reached but there was no single winner, or the winner did not meet the threshold
+	OutcomeNoQuorum = "no quorum" // Too few voters took part for the result to stand
+)
+
+var (
+	ErrNoElectorate           = errors.New("Cannot check quorum. The quorum is a share of the electorate, but the size of the electorate is not known. Give the election an electorate tag, or provide the voter roll")
+	ErrVoterRollNotElectorate = errors.New("The electorate of an election weighted by credentials is its total weight, which the voter roll does not give. Give the election an electorate tag instead")
+)
+
+// Decision applies an election's quorum and threshold to its result.
+// Counts are of voters in unweighted elections and of weight in weighted elections.
+type Decision struct {
+	Outcome    string
+	Rules      cryptoballot.DecisionRules
+	Turnout    uint64 // Voters issued a signature. See Turnout
+	Electorate uint64 // Eligible voters. Zero if not known
+	Support    uint64 // First choices for the winner
+	Votes      uint64 // Ballots for candidates, plus blank ballots. Abstaining and spoiled ballots are left out
+}
+
+// VoterRollElectorate gets the size of the electorate from the election's voter roll, for elections that do not
+// declare it. In elections weighted by the voter roll it is the total weight of the roll.
+func VoterRollElectorate(roll cryptoballot.VoterRoll, weightSource *cryptoballot.WeightSource) (uint64, error) {
+	if weightSource == nil {
+		return uint64(len(roll)), nil
+	}
+	if !weightSource.FromVoterRoll() {
+		return 0, ErrVoterRollNotElectorate
+	}
+	return roll.TotalWeight(), nil
+}
+
+// Turnout counts the voters that took part in an election from its fulfilled signature requests. Voters are
+// identified by request ID, so that voters issued more than one signature are counted once. In weighted elections
+// turnout is the total weight of the signatures issued instead. The signature requests should already be verified.
+func Turnout(signatureRequests []cryptoballot.FulfilledSignatureRequest, weighted bool) uint64 {
+	var turnout uint64
+	voters := map[string]bool{}
+	for _, fulfilled := range signatureRequests {
+		if weighted {
+			turnout += fulfilled.Weight()
+		} else if !voters[string(fulfilled.RequestID)] {
+			voters[string(fulfilled.RequestID)] = true
+			turnout++
+		}
+	}
+	return turnout
+}
+
+// Decide applies the quorum and threshold to the result of tallying the ballots. The ballots must be the ones that
+// were tallied, after normalizing any write-ins. The electorate is only needed for quorums that are a share of it.
+func Decide(rules cryptoballot.DecisionRules, turnout, electorate uint64, ballots []WeightedBallot, result *Result) (*Decision, error) {
+	decision := &Decision{
+		Rules:      rules,
+		Turnout:    turnout,
+		Electorate: electorate,
+	}
+	if rules.Quorum != nil && rules.Quorum.IsShare() && electorate == 0 {
+		return nil, ErrNoElectorate
+	}
+
+	var winner string
+	if len(result.Winners) == 1 {
+		winner = result.Winners[0]
+	}
+	for _, ballot := range ballots {
+		switch ballot.Vote.Marker() {
+		case cryptoballot.VoteAbstain, cryptoballot.VoteSpoiled:
+			continue
+		case "":
+			if ballot.Vote[0] == winner {
+				decision.Support += ballot.Weight
+			}
+		}
+		decision.Votes += ballot.Weight
+	}
+
+	switch {
+	case rules.Quorum != nil && !rules.Quorum.Met(turnout, electorate):
+		decision.Outcome = OutcomeNoQuorum
+	case winner == "":
+		decision.Outcome = OutcomeFailed
+	case rules.Threshold != nil && !rules.Threshold.Met(decision.Support, decision.Votes):
+		decision.Outcome = OutcomeFailed
+	default:
+		decision.Outcome = OutcomePassed
+	}
+	return decision, nil
+}
+
+// Implements Stringer
+func (decision Decision) String() string {
+	s := "outcome: " + decision.Outcome + "\n"
+	if decision.Rules.Quorum != nil {
+		if decision.Electorate != 0 {
+			s += fmt.Sprintf("turnout: %d of %d (quorum %s)\n", decision.Turnout, decision.Electorate, decision.Rules.Quorum)
+		} else {
+			s += fmt.Sprintf("turnout: %d (quorum %s)\n", decision.Turnout, decision.Rules.Quorum)
+		}
+	}
+	if decision.Rules.Threshold != nil {
+		s += fmt.Sprintf("support: %d of %d (threshold %s)\n", decision.Support, decision.Votes, decision.Rules.Threshold)
+	}
+	return s
+}
@@ -0,0 +1,98 @@
+package tally
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+func TestDecide(t *testing.T) {
+	// A motion with 3 votes for, 1 against, 1 blank and 1 abstention
+	ballots := []WeightedBallot{}
+	for _, vote := range []cryptoballot.Vote{{"Yes"}, {"Yes"}, {"Yes"}, {"No"}, {}, {cryptoballot.VoteAbstain}} {
+		ballots = append(ballots, WeightedBallot{Ballot: cryptoballot.Ballot{Vote: vote}, Weight: 1})
+	}
+	result, err := TallyWeighted(ballots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quorum, _ := cryptoballot.NewThreshold("50%")
+	rules := cryptoballot.DecisionRules{Quorum: quorum}
+
+	// Turnout counts voters, including the one who abstained
+	decision, err := Decide(rules, 6, 12, ballots, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Outcome != OutcomePassed {
+		t.Error("Expected the motion to pass, got", decision.Outcome)
+	}
+	decision, err = Decide(rules, 5, 12, ballots, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Outcome != OutcomeNoQuorum || !strings.Contains(decision.String(), "turnout: 5 of 12 (quorum 50%)") {
+		t.Error("Expected no quorum, got", decision)
+	}
+	if _, err := Decide(rules, 6, 0, ballots, result); err != ErrNoElectorate {
+		t.Error("Expected ErrNoElectorate, got", err)
+	}
+
+	// Blank ballots count against the threshold, abstentions do not. 3 of 5 is 60%
+	rules.Threshold, _ = cryptoballot.NewThreshold("60%")
+	decision, err = Decide(rules, 6, 12, ballots, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Outcome != OutcomePassed || decision.Support != 3 || decision.Votes != 5 {
+		t.Errorf("Expected 3 of 5 to pass, got %s with %d of %d", decision.Outcome, decision.Support, decision.Votes)
+	}
+	rules.Threshold, _ = cryptoballot.NewThreshold("2/3")
+	decision, err = Decide(rules, 6, 12, ballots, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Outcome != OutcomeFailed {
+		t.Error("Expected 3 of 5 to fail a 2/3 threshold, got", decision.Outcome)
+	}
+
+	// An absolute quorum does not need the electorate
+	rules.Quorum, _ = cryptoballot.NewThreshold("6")
+	rules.Threshold = nil
+	decision, err = Decide(rules, 6, 0, ballots, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Outcome != OutcomePassed {
+		t.Error("Expected the motion to pass, got", decision.Outcome)
+	}
+}
+
+func TestTurnout(t *testing.T) {
+	sigReq := func(requestID string, denomination uint64) cryptoballot.FulfilledSignatureRequest {
+		return cryptoballot.FulfilledSignatureRequest{SignatureRequest: cryptoballot.SignatureRequest{RequestID: []byte(requestID), Denomination: denomination}}
+	}
+	signatureRequests := []cryptoballot.FulfilledSignatureRequest{sigReq("a", 0), sigReq("a", 10), sigReq("b", 100)}
+	if turnout := Turnout(signatureRequests, false); turnout != 2 {
+		t.Error("Expected a turnout of 2 voters, got", turnout)
+	}
+	if turnout := Turnout(signatureRequests, true); turnout != 111 {
+		t.Error("Expected a turnout of 111 weight, got", turnout)
+	}
+}
+
+func TestVoterRollElectorate(t *testing.T) {
+	roll := cryptoballot.VoterRoll{"did:elastos:alice": 250, "did:elastos:bob": 1}
+	if electorate, err := VoterRollElectorate(roll, nil); err != nil || electorate != 2 {
+		t.Errorf("Expected an electorate of 2 voters, got %d %v", electorate, err)
+	}
+	if electorate, err := VoterRollElectorate(roll, &cryptoballot.WeightSource{}); err != nil || electorate != 251 {
+		t.Errorf("Expected an electorate of 251 weight, got %d %v", electorate, err)
+	}
+	credentials := &cryptoballot.WeightSource{CredentialType: "MembershipCredential", Claim: "shares"}
+	if _, err := VoterRollElectorate(roll, credentials); err != ErrVoterRollNotElectorate {
+		t.Errorf("Expected ErrVoterRollNotElectorate, got %v", err)
+	}
+}
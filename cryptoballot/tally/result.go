@@ -0,0 +1,76 @@
+package tally
+
+import (
+	"encoding/hex"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/phayes/errors"
+)
+
+var (
+	ErrResultNotTallied = errors.New("Cannot build election result. The ballots could not be tallied")
+	ErrResultMismatch   = errors.New("Election result does not match the result of tallying the ballots")
+)
+
+// NewElectionResult builds the unsigned election result document from an audit of the election's bundle.
+// The software is the name and version of the program that tallied the ballots, eg: cryptoballot/0.1
+func NewElectionResult(bundle *cryptoballot.Bundle, report *Report, software string) (*cryptoballot.ElectionResult, error) {
+	if report.Result == nil {
+		return nil, ErrResultNotTallied
+	}
+	result := report.Result
+	published := &cryptoballot.ElectionResult{
+		ElectionID: bundle.Election.ElectionID,
+		Method:     cryptoballot.ResultMethodSchulze,
+		Software:   software,
+		BallotSet:  hex.EncodeToString(bundle.ComputeMerkleRoot()),
+		Ballots:    result.Ballots,
+		Weight:     result.Weight,
+		Blank:      result.Blank.Ballots,
+		Abstain:    result.Abstain.Ballots,
+		Spoiled:    result.Spoiled.Ballots,
+		Winners:    append([]string{}, result.Winners...),
+		Candidates: []cryptoballot.CandidateResult{},
+		Pairwise:   []cryptoballot.Preference{},
+		PublicKey:  hex.EncodeToString(bundle.Election.PublicKey),
+	}
+	for _, score := range result.Scores {
+		published.Candidates = append(published.Candidates, cryptoballot.CandidateResult{Name: score.Name, Score: score.Score})
+	}
+	for i, candidate := range result.Candidates {
+		for j, against := range result.Candidates {
+			if i != j {
+				published.Pairwise = append(published.Pairwise, cryptoballot.Preference{For: candidate, Against: against, Count: result.Pairwise[i][j]})
+			}
+		}
+	}
+	if decision := report.Decision; decision != nil {
+		published.Decision = &cryptoballot.ResultDecision{
+			Outcome:    decision.Outcome,
+			Turnout:    decision.Turnout,
+			Electorate: decision.Electorate,
+			Support:    decision.Support,
+			Votes:      decision.Votes,
+		}
+	}
+	return published, nil
+}
+
+// VerifyElectionResult independently checks a published election result. It verifies that the result is signed by
+// the election's admin, audits the bundle, and tallies the ballots again. Everything in the result must match,
+// apart from the software that produced it. The aliases and electorate are the ones given to Audit when the result
+// was published, if any. The audit report is returned so that problems with the bundle can be shown alongside a mismatch.
+func VerifyElectionResult(published *cryptoballot.ElectionResult, bundle *cryptoballot.Bundle, aliases cryptoballot.AliasMap, electorate uint64) (*Report, error) {
+	if err := published.VerifyForElection(&bundle.Election); err != nil {
+		return nil, err
+	}
+	report := Audit(bundle, aliases, electorate)
+	expected, err := NewElectionResult(bundle, report, published.Software)
+	if err != nil {
+		return report, err
+	}
+	if expected.StringWithoutSignature() != published.StringWithoutSignature() {
+		return report, ErrResultMismatch
+	}
+	return report, nil
+}
@@ -0,0 +1,72 @@
+package tally
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+func TestElectionResult(t *testing.T) {
+	tags := cryptoballot.TagSet{
+		{Key: []byte(cryptoballot.ElectionThresholdTag), Value: []byte("50%")},
+	}
+	bundle, adminPriv := newTestTaggedBundle(t, testVotes, nil, tags)
+	report := Audit(bundle, nil, 0)
+	if !report.OK() {
+		t.Fatal(report.Problems)
+	}
+
+	published, err := NewElectionResult(bundle, report, "cryptoballot/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published.Ballots != 3 || len(published.Winners) != 1 || published.Winners[0] != "Alice" {
+		t.Errorf("Expected Alice to win with 3 ballots, got %v", published)
+	}
+	if len(published.Pairwise) != 2 || published.Pairwise[0] != (cryptoballot.Preference{For: "Alice", Against: "Bob", Count: 2}) {
+		t.Errorf("Expected 2 ballots preferring Alice to Bob, got %v", published.Pairwise)
+	}
+	if published.Decision == nil || published.Decision.Outcome != OutcomePassed {
+		t.Errorf("Expected the decision to pass, got %v", published.Decision)
+	}
+	signature, err := adminPriv.SignString(published.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+	published.Signature = hex.EncodeToString(signature)
+
+	// A third party verifies the published result from the bundle
+	published, err = cryptoballot.NewElectionResult([]byte(published.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = VerifyElectionResult(published, bundle, nil, 0); err != nil {
+		t.Error(err)
+	}
+
+	// A result that does not match the ballots is rejected, even if signed by the admin
+	published.Winners = []string{"Bob"}
+	signature, err = adminPriv.SignString(published.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+	published.Signature = hex.EncodeToString(signature)
+	if _, err = VerifyElectionResult(published, bundle, nil, 0); err != ErrResultMismatch {
+		t.Errorf("Expected ErrResultMismatch, got %v", err)
+	}
+
+	// A result signed by anyone else is rejected
+	otherPriv, err := cryptoballot.GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err = otherPriv.SignString(published.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+	published.Signature = hex.EncodeToString(signature)
+	if _, err = VerifyElectionResult(published, bundle, nil, 0); err == nil {
+		t.Error("Expected a result with a bad signature to be rejected")
+	}
+}
@@ -0,0 +1,325 @@
+// Package tally counts ballots and audits exported election bundles.
+// Everything in this package works offline, so that any third party holding a bundle can
+// independently reproduce the result of an election.
+package tally
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Sam-Izdat/govote"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/phayes/errors"
+)
+
+var (
+	ErrNoBallots = errors.New("Cannot tally election. There are no ballots")
+	ErrNoResult  = errors.New("Cannot tally election. No election result")
+)
+
+// Result is the outcome of tallying a set of ballots
+type Result struct {
+	Winners []string        // Winners in order. There is more than one winner only if there is a tie. Empty if every ballot was marked
+	Scores  []govote.CScore // Schulze scores for every candidate
+	Ballots int             // Number of ballots counted, including marked ballots
+	Weight  uint64          // Total weight of the ballots counted. Zero for unweighted tallies
+	Blank   Marked          // Ballots that chose no candidate
+	Abstain Marked          // Ballots that abstained
+	Spoiled Marked          // Ballots that were deliberately spoiled, or had no valid choices
+
+	// Pairwise[i][j] is the number, or weight, of ballots preferring Candidates[i] to Candidates[j].
+	// Candidates are sorted. Both are nil for plurality tallies.
+	Candidates []string
+	Pairwise   [][]uint64
+}
+
+// Marked counts the ballots cast with one of the vote markers instead of choosing candidates.
+// Marked ballots count towards turnout, but not for or against any candidate. See cryptoballot.Vote.Marker
+type Marked struct {
+	Ballots int
+	Weight  uint64 // Zero for unweighted tallies
+}
+
+// WeightedBallot is a ballot along with the weight it counts for
+type WeightedBallot struct {
+	cryptoballot.Ballot
+	Weight uint64
+}
+
+// Tally counts the ballots using the schulze (condorcet) method.
+// It does not verify the ballots, see Audit for that.
+func Tally(ballots []cryptoballot.Ballot) (*Result, error) {
+	if len(ballots) == 0 {
+		return nil, ErrNoBallots
+	}
+	result := &Result{Ballots: len(ballots)}
+	unweighted := make([]WeightedBallot, len(ballots))
+	for i, ballot := range ballots {
+		unweighted[i] = WeightedBallot{Ballot: ballot}
+	}
+	counted := result.splitMarked(unweighted)
+	if len(counted) == 0 {
+		return result, nil
+	}
+
+	// Get a list of all candidates. Sort them so the result does not depend on map ordering
+	candidatesmap := map[string]bool{}
+	for _, ballot := range counted {
+		for _, vote := range ballot.Vote {
+			candidatesmap[vote] = true
+		}
+	}
+	candidates := []string{}
+	for candidate := range candidatesmap {
+		candidates = append(candidates, candidate)
+	}
+	sort.Strings(candidates)
+
+	// Create a schulze poll
+	schulze, err := govote.Schulze.New(candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add ballots to the poll
+	for _, ballot := range counted {
+		schulze.AddBallot(ballot.Vote)
+	}
+
+	// Calculate result using schulze (condorcet)
+	winners, scores, err := schulze.Evaluate()
+	if err != nil {
+		return nil, err
+	}
+	if len(winners) == 0 {
+		return nil, ErrNoResult
+	}
+
+	result.Winners, result.Scores = winners, scores
+	for i := range counted {
+		counted[i].Weight = 1
+	}
+	result.Candidates, result.Pairwise = pairwise(counted)
+	return result, nil
+}
+
+// TallyWeighted counts weighted ballots using the schulze (condorcet) method, with each ballot counting as many
+// times as its weight. govote has no notion of weights, so the strongest paths are computed here from the weighted
+// pairwise preferences. A candidate's score is the number of other candidates it beats.
+// Candidates left off a ballot are ranked equally, below every candidate on it.
+func TallyWeighted(ballots []WeightedBallot) (*Result, error) {
+	if len(ballots) == 0 {
+		return nil, ErrNoBallots
+	}
+	result := &Result{Ballots: len(ballots)}
+	for _, ballot := range ballots {
+		result.Weight += ballot.Weight
+	}
+	ballots = result.splitMarked(ballots)
+	if len(ballots) == 0 {
+		return result, nil
+	}
+	candidates, d := pairwise(ballots)
+	n := len(candidates)
+	p := make([][]uint64, n)
+	for i := range p {
+		p[i] = make([]uint64, n)
+	}
+
+	// p[i][j] is the strength of the strongest path from candidate i to candidate j
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i != j && d[i][j] > d[j][i] {
+				p[i][j] = d[i][j]
+			}
+		}
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i == j {
+				continue
+			}
+			for k := 0; k < n; k++ {
+				if k == i || k == j {
+					continue
+				}
+				if through := minUint64(p[j][i], p[i][k]); through > p[j][k] {
+					p[j][k] = through
+				}
+			}
+		}
+	}
+
+	scores := make([]govote.CScore, n)
+	winners := []string{}
+	for i, candidate := range candidates {
+		scores[i].Name = candidate
+		winner := true
+		for j := range candidates {
+			if p[i][j] > p[j][i] {
+				scores[i].Score++
+			}
+			if p[j][i] > p[i][j] {
+				winner = false
+			}
+		}
+		if winner {
+			winners = append(winners, candidate)
+		}
+	}
+	if len(winners) == 0 {
+		return nil, ErrNoResult
+	}
+
+	result.Winners, result.Scores = winners, scores
+	result.Candidates, result.Pairwise = candidates, d
+	return result, nil
+}
+
+// TallyPlurality counts the weight of each ballot towards its first choice. The candidates with the most weight win.
+// Use a weight of 1 for every ballot in unweighted elections.
+func TallyPlurality(ballots []WeightedBallot) (*Result, error) {
+	if len(ballots) == 0 {
+		return nil, ErrNoBallots
+	}
+	result := &Result{Ballots: len(ballots)}
+	for _, ballot := range ballots {
+		result.Weight += ballot.Weight
+	}
+	ballots = result.splitMarked(ballots)
+	if len(ballots) == 0 {
+		return result, nil
+	}
+	candidates, index := weightedCandidates(ballots)
+	totals := make([]uint64, len(candidates))
+	var best uint64
+	for _, ballot := range ballots {
+		i := index[ballot.Vote[0]]
+		totals[i] += ballot.Weight
+		if totals[i] > best {
+			best = totals[i]
+		}
+	}
+	if best == 0 {
+		return nil, ErrNoResult
+	}
+
+	scores := make([]govote.CScore, len(candidates))
+	winners := []string{}
+	for i, candidate := range candidates {
+		scores[i] = govote.CScore{Name: candidate, Score: int(totals[i])}
+		if totals[i] == best {
+			winners = append(winners, candidate)
+		}
+	}
+	result.Winners, result.Scores = winners, scores
+	return result, nil
+}
+
+// splitMarked counts the ballots cast with a vote marker in the result, and returns the ballots that choose candidates
+func (result *Result) splitMarked(ballots []WeightedBallot) []WeightedBallot {
+	counted := []WeightedBallot{}
+	for _, ballot := range ballots {
+		var marked *Marked
+		switch ballot.Vote.Marker() {
+		case cryptoballot.VoteBlank:
+			marked = &result.Blank
+		case cryptoballot.VoteAbstain:
+			marked = &result.Abstain
+		case cryptoballot.VoteSpoiled:
+			marked = &result.Spoiled
+		default:
+			counted = append(counted, ballot)
+			continue
+		}
+		marked.Ballots++
+		marked.Weight += ballot.Weight
+	}
+	return counted
+}
+
+// pairwise gets every candidate on the ballots in sorted order, and the weight of ballots preferring each candidate
+// to each other candidate. Candidates left off a ballot are ranked equally, below every candidate on it.
+func pairwise(ballots []WeightedBallot) ([]string, [][]uint64) {
+	candidates, index := weightedCandidates(ballots)
+	n := len(candidates)
+
+	// d[i][j] is the weight of ballots preferring candidate i to candidate j
+	d := make([][]uint64, n)
+	for i := range d {
+		d[i] = make([]uint64, n)
+	}
+	for _, ballot := range ballots {
+		ranked := make([]bool, n)
+		for _, vote := range ballot.Vote {
+			i := index[vote]
+			if ranked[i] {
+				continue
+			}
+			ranked[i] = true
+			for j := range candidates {
+				if !ranked[j] {
+					d[i][j] += ballot.Weight
+				}
+			}
+		}
+	}
+	return candidates, d
+}
+
+// weightedCandidates gets every candidate on the ballots in sorted order, and the index of each
+func weightedCandidates(ballots []WeightedBallot) ([]string, map[string]int) {
+	index := map[string]int{}
+	candidates := []string{}
+	for _, ballot := range ballots {
+		for _, vote := range ballot.Vote {
+			if _, ok := index[vote]; !ok {
+				index[vote] = 0
+				candidates = append(candidates, vote)
+			}
+		}
+	}
+	sort.Strings(candidates)
+	for i, candidate := range candidates {
+		index[candidate] = i
+	}
+	return candidates, index
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Implements Stringer
+func (result Result) String() string {
+	winners := strings.Join(result.Winners, ", ")
+	if winners == "" {
+		winners = "none"
+	}
+	s := "winner: " + winners + "\n"
+	s += fmt.Sprintf("ballots: %d\n", result.Ballots)
+	if result.Weight != 0 {
+		s += fmt.Sprintf("weight: %d\n", result.Weight)
+	}
+	s += result.Blank.line("blank") + result.Abstain.line("abstain") + result.Spoiled.line("spoiled")
+	for _, score := range result.Scores {
+		s += fmt.Sprintf("  %s: %d\n", score.Name, score.Score)
+	}
+	return s
+}
+
+// line gets the line for the marked ballots in Result.String. It is empty if there are none.
+func (marked Marked) line(marker string) string {
+	switch {
+	case marked.Ballots == 0:
+		return ""
+	case marked.Weight != 0:
+		return fmt.Sprintf("%s: %d (weight %d)\n", marker, marked.Ballots, marked.Weight)
+	default:
+		return fmt.Sprintf("%s: %d\n", marker, marked.Ballots)
+	}
+}
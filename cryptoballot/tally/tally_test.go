@@ -0,0 +1,144 @@
+package tally
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+func TestTally(t *testing.T) {
+	ballots := []cryptoballot.Ballot{
+		{ElectionID: "election1", BallotID: "a", Vote: cryptoballot.Vote{"Alice", "Bob"}},
+		{ElectionID: "election1", BallotID: "b", Vote: cryptoballot.Vote{"Alice", "Bob"}},
+		{ElectionID: "election1", BallotID: "c", Vote: cryptoballot.Vote{"Bob", "Alice"}},
+	}
+
+	result, err := Tally(ballots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Winners) != 1 || result.Winners[0] != "Alice" {
+		t.Errorf("Expected Alice to win, got %v", result.Winners)
+	}
+	if result.Ballots != 3 {
+		t.Errorf("Expected 3 ballots counted, got %d", result.Ballots)
+	}
+
+	if _, err := Tally(nil); err != ErrNoBallots {
+		t.Error("Expected ErrNoBallots, got", err)
+	}
+}
+
+func TestTallyWeighted(t *testing.T) {
+	ballots := []WeightedBallot{
+		{Ballot: cryptoballot.Ballot{BallotID: "a", Vote: cryptoballot.Vote{"Alice", "Bob", "Carol"}}, Weight: 1},
+		{Ballot: cryptoballot.Ballot{BallotID: "b", Vote: cryptoballot.Vote{"Alice", "Carol", "Bob"}}, Weight: 1},
+		{Ballot: cryptoballot.Ballot{BallotID: "c", Vote: cryptoballot.Vote{"Bob", "Carol"}}, Weight: 1},
+	}
+
+	// With every weight 1 it agrees with Tally
+	result, err := TallyWeighted(ballots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Winners) != 1 || result.Winners[0] != "Alice" || result.Weight != 3 {
+		t.Errorf("Expected Alice to win with a weight of 3, got %v with %d", result.Winners, result.Weight)
+	}
+
+	// Enough weight on one ballot flips the result
+	ballots[2].Weight = 5
+	result, err = TallyWeighted(ballots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Winners) != 1 || result.Winners[0] != "Bob" || result.Weight != 7 {
+		t.Errorf("Expected Bob to win with a weight of 7, got %v with %d", result.Winners, result.Weight)
+	}
+
+	if _, err := TallyWeighted(nil); err != ErrNoBallots {
+		t.Error("Expected ErrNoBallots, got", err)
+	}
+}
+
+func TestTallyPlurality(t *testing.T) {
+	ballots := []WeightedBallot{
+		{Ballot: cryptoballot.Ballot{BallotID: "a", Vote: cryptoballot.Vote{"Alice"}}, Weight: 3},
+		{Ballot: cryptoballot.Ballot{BallotID: "b", Vote: cryptoballot.Vote{"Bob", "Alice"}}, Weight: 2},
+		{Ballot: cryptoballot.Ballot{BallotID: "c", Vote: cryptoballot.Vote{"Bob"}}, Weight: 1},
+	}
+	result, err := TallyPlurality(ballots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Winners) != 2 || result.Winners[0] != "Alice" || result.Winners[1] != "Bob" {
+		t.Errorf("Expected Alice and Bob to tie, got %v", result.Winners)
+	}
+
+	ballots[2].Weight = 2
+	result, err = TallyPlurality(ballots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Winners) != 1 || result.Winners[0] != "Bob" || result.Weight != 7 {
+		t.Errorf("Expected Bob to win with a weight of 7, got %v with %d", result.Winners, result.Weight)
+	}
+}
+
+func TestTallyMarked(t *testing.T) {
+	ballots := []WeightedBallot{
+		{Ballot: cryptoballot.Ballot{BallotID: "a", Vote: cryptoballot.Vote{"Alice", "Bob"}}, Weight: 1},
+		{Ballot: cryptoballot.Ballot{BallotID: "b", Vote: cryptoballot.Vote{"Bob"}}, Weight: 2},
+		{Ballot: cryptoballot.Ballot{BallotID: "c", Vote: cryptoballot.Vote{}}, Weight: 10},
+		{Ballot: cryptoballot.Ballot{BallotID: "d", Vote: cryptoballot.Vote{cryptoballot.VoteBlank}}, Weight: 10},
+		{Ballot: cryptoballot.Ballot{BallotID: "e", Vote: cryptoballot.Vote{cryptoballot.VoteAbstain}}, Weight: 100},
+		{Ballot: cryptoballot.Ballot{BallotID: "f", Vote: cryptoballot.Vote{cryptoballot.VoteSpoiled}}, Weight: 1000},
+	}
+
+	// Marked ballots count towards the ballots cast, but are never candidates
+	result, err := TallyWeighted(ballots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Winners) != 1 || result.Winners[0] != "Bob" {
+		t.Errorf("Expected Bob to win, got %v", result.Winners)
+	}
+	if len(result.Scores) != 2 {
+		t.Errorf("Expected only Alice and Bob to be scored, got %v", result.Scores)
+	}
+	if result.Ballots != 6 || result.Weight != 1123 {
+		t.Errorf("Expected 6 ballots with a weight of 1123, got %d and %d", result.Ballots, result.Weight)
+	}
+	if result.Blank != (Marked{2, 20}) || result.Abstain != (Marked{1, 100}) || result.Spoiled != (Marked{1, 1000}) {
+		t.Errorf("Unexpected marked ballots: %v, %v, %v", result.Blank, result.Abstain, result.Spoiled)
+	}
+
+	plurality, err := TallyPlurality(ballots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plurality.Blank != result.Blank || len(plurality.Winners) != 1 || plurality.Winners[0] != "Bob" {
+		t.Errorf("Expected plurality to agree, got %v", plurality)
+	}
+
+	unweighted := make([]cryptoballot.Ballot, len(ballots))
+	for i, ballot := range ballots {
+		unweighted[i] = ballot.Ballot
+	}
+	result, err = Tally(unweighted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Ballots != 6 || result.Blank != (Marked{2, 0}) || result.Spoiled != (Marked{1, 0}) {
+		t.Errorf("Unexpected unweighted result: %v", result)
+	}
+
+	// An election where nobody chose a candidate has no winner
+	result, err = Tally(unweighted[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Winners) != 0 || !strings.Contains(result.String(), "winner: none\n") {
+		t.Error("Expected no winner, got", result)
+	}
+}
@@ -0,0 +1,180 @@
+package tally
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"golang.org/x/text/unicode/norm"
+)
+
+// How a write-in was matched to the candidate it counts for
+const (
+	WriteInAlias         = "alias"         // Matched by the alias map given to the tally
+	WriteInAdjudication  = "adjudication"  // Ruled on by the election admin
+	WriteInUnadjudicated = "unadjudicated" // Counted as a candidate in its own right, grouped with other spellings that normalize the same
+	WriteInRejected      = "rejected"      // Not counted, either by ruling or because the election does not allow write-ins
+)
+
+// WriteIn records how one spelling of a vote option that is not a listed candidate was counted
+type WriteIn struct {
+	Raw       string // The option as it appears on ballots
+	Candidate string // The candidate it counts for. Empty if rejected
+	Source    string // How it was matched. See WriteInAlias and friends
+	Ballots   int    // Number of ballots it appears on
+}
+
+// Implements Stringer
+func (writeIn WriteIn) String() string {
+	if writeIn.Source == WriteInRejected {
+		return fmt.Sprintf("%q rejected (%d ballots)", writeIn.Raw, writeIn.Ballots)
+	}
+	return fmt.Sprintf("%q => %q by %s (%d ballots)", writeIn.Raw, writeIn.Candidate, writeIn.Source, writeIn.Ballots)
+}
+
+// A Normalizer maps the options on ballots to the candidates they count for before tallying.
+// Options are matched, in order, against the election's listed candidates, the admin's adjudication and the
+// alias map, comparing normalized forms (see cryptoballot.NormalizeChoice). The signed adjudication takes
+// precedence over aliases, which are only configured by whoever runs the tally. Unmatched options count as
+// write-ins if the election allows them, and are rejected otherwise. Alias and adjudication targets that are not
+// listed candidates are themselves write-ins.
+type Normalizer struct {
+	Contest      cryptoballot.Contest
+	Aliases      cryptoballot.AliasMap      // Optional
+	Adjudication *cryptoballot.Adjudication // Optional. Must already be verified
+}
+
+// NewNormalizer creates a normalizer for an election
+func NewNormalizer(election *cryptoballot.Election, aliases cryptoballot.AliasMap, adjudication *cryptoballot.Adjudication) *Normalizer {
+	return &Normalizer{
+		Contest:      election.Contest(),
+		Aliases:      aliases,
+		Adjudication: adjudication,
+	}
+}
+
+// Normalize rewrites the votes on the ballots to the candidates they count for. Rejected options are removed, and
+// options that count for a candidate already ranked higher on the same ballot are dropped. Ballots left without any
+// choices are spoiled, and ballots cast with a vote marker are left as they are. The ballots passed in are not modified. Every option that is not a listed candidate is reported as a write-in, sorted by spelling.
+func (n *Normalizer) Normalize(ballots []WeightedBallot) ([]WeightedBallot, []WriteIn) {
+	// First resolve every distinct option, so that write-ins can be grouped across ballots
+	writeIns := map[string]*WriteIn{}
+	for _, ballot := range ballots {
+		if ballot.Vote.Marker() != "" {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, option := range ballot.Vote {
+			if _, ok := n.Contest.Candidate(option); ok || seen[option] {
+				continue
+			}
+			seen[option] = true
+			if _, ok := writeIns[option]; !ok {
+				writeIns[option] = n.resolve(option)
+			}
+			writeIns[option].Ballots++
+		}
+	}
+
+	// Write-ins that are not listed candidates are displayed with a single spelling
+	spellings := map[string]spelling{}
+	for _, writeIn := range writeIns {
+		if writeIn.Source == WriteInRejected {
+			continue
+		}
+		if candidate, ok := n.Contest.Candidate(writeIn.Candidate); ok {
+			writeIn.Candidate = candidate
+			continue
+		}
+		addSpelling(spellings, writeIn.Candidate, writeIn.Ballots)
+	}
+	for _, writeIn := range writeIns {
+		if found, ok := spellings[cryptoballot.NormalizeChoice(writeIn.Candidate)]; ok && writeIn.Source != WriteInRejected {
+			writeIn.Candidate = found.display()
+		}
+	}
+
+	normalized := make([]WeightedBallot, len(ballots))
+	for i, ballot := range ballots {
+		normalized[i] = ballot
+		if ballot.Vote.Marker() != "" {
+			continue
+		}
+		normalized[i].Vote = cryptoballot.Vote{}
+		ranked := map[string]bool{}
+		for _, option := range ballot.Vote {
+			candidate, ok := n.Contest.Candidate(option)
+			if !ok {
+				if writeIns[option].Source == WriteInRejected {
+					continue
+				}
+				candidate = writeIns[option].Candidate
+			}
+			if !ranked[candidate] {
+				ranked[candidate] = true
+				normalized[i].Vote = append(normalized[i].Vote, candidate)
+			}
+		}
+		if len(normalized[i].Vote) == 0 {
+			normalized[i].Vote = cryptoballot.Vote{cryptoballot.VoteSpoiled}
+		}
+	}
+
+	report := make([]WriteIn, 0, len(writeIns))
+	for _, writeIn := range writeIns {
+		report = append(report, *writeIn)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Raw < report[j].Raw })
+	return normalized, report
+}
+
+// resolve works out which candidate an option that is not a listed candidate counts for
+func (n *Normalizer) resolve(option string) *WriteIn {
+	writeIn := &WriteIn{Raw: option, Candidate: option, Source: WriteInUnadjudicated}
+	if candidate, ok := n.adjudicated(option); ok {
+		writeIn.Candidate, writeIn.Source = candidate, WriteInAdjudication
+	} else if candidate, ok := n.Aliases.Candidate(option); ok {
+		writeIn.Candidate, writeIn.Source = candidate, WriteInAlias
+	}
+
+	if writeIn.Candidate == cryptoballot.AdjudicationRejected || cryptoballot.NormalizeChoice(writeIn.Candidate) == "" {
+		writeIn.Candidate, writeIn.Source = "", WriteInRejected
+	} else if _, listed := n.Contest.Candidate(writeIn.Candidate); !listed && !n.Contest.WriteIns {
+		writeIn.Candidate, writeIn.Source = "", WriteInRejected
+	}
+	return writeIn
+}
+
+// adjudicated finds the admin's ruling on an option, if there is an adjudication
+func (n *Normalizer) adjudicated(option string) (string, bool) {
+	if n.Adjudication == nil {
+		return "", false
+	}
+	return n.Adjudication.Candidate(option)
+}
+
+// spelling counts the ballots each spelling of a write-in appears on
+type spelling map[string]int
+
+// addSpelling records the ballots a spelling of a write-in appears on. Spellings are put in NFC form with their
+// whitespace collapsed first.
+func addSpelling(spellings map[string]spelling, raw string, ballots int) {
+	key := cryptoballot.NormalizeChoice(raw)
+	if spellings[key] == nil {
+		spellings[key] = spelling{}
+	}
+	spellings[key][norm.NFC.String(strings.Join(strings.Fields(raw), " "))] += ballots
+}
+
+// display gets the most common spelling of a write-in. Ties go to the lowest spelling, so that the result does
+// not depend on the order of the ballots.
+func (s spelling) display() string {
+	var best string
+	for candidate, count := range s {
+		if best == "" || count > s[best] || (count == s[best] && candidate < best) {
+			best = candidate
+		}
+	}
+	return best
+}
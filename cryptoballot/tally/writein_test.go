@@ -0,0 +1,71 @@
+package tally
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+func TestNormalize(t *testing.T) {
+	ballots := []WeightedBallot{
+		{Ballot: cryptoballot.Ballot{Vote: cryptoballot.Vote{"frida KAHLO", "Frida Kahlo", "Diego Rivera"}}, Weight: 1},
+		{Ballot: cryptoballot.Ballot{Vote: cryptoballot.Vote{"diego  rivera", "", "Salvador Dalí"}}, Weight: 10},
+		{Ballot: cryptoballot.Ballot{Vote: cryptoballot.Vote{"Diego Rivera", "diego rivera"}}, Weight: 1},
+		{Ballot: cryptoballot.Ballot{Vote: cryptoballot.Vote{cryptoballot.VoteAbstain}}, Weight: 1},
+	}
+	normalizer := &Normalizer{Contest: cryptoballot.Contest{Candidates: []string{"Frida Kahlo", "Salvador Dalí"}, WriteIns: true}}
+
+	normalized, writeIns := normalizer.Normalize(ballots)
+	expected := []cryptoballot.Vote{
+		{"Frida Kahlo", "Diego Rivera"},
+		{"Diego Rivera", "Salvador Dalí"},
+		{"Diego Rivera"},
+		{cryptoballot.VoteAbstain},
+	}
+	for i, ballot := range normalized {
+		if !reflect.DeepEqual(ballot.Vote, expected[i]) {
+			t.Errorf("Expected ballot %d to be %v, got %v", i, expected[i], ballot.Vote)
+		}
+		if ballot.Weight != ballots[i].Weight {
+			t.Errorf("Ballot %d changed weight", i)
+		}
+	}
+	if ballots[0].Vote[0] != "frida KAHLO" {
+		t.Error("Normalize should not modify the ballots passed in")
+	}
+
+	// The empty option is rejected, and every spelling of Diego Rivera is grouped under one name
+	if len(writeIns) != 4 {
+		t.Fatalf("Expected 4 write-ins, got %v", writeIns)
+	}
+	if writeIns[0].Raw != "" || writeIns[0].Source != WriteInRejected {
+		t.Error("Expected the empty option to be rejected, got", writeIns[0])
+	}
+	for _, writeIn := range writeIns[1:] {
+		if writeIn.Candidate != "Diego Rivera" || writeIn.Source != WriteInUnadjudicated {
+			t.Error("Expected an unadjudicated write-in for Diego Rivera, got", writeIn)
+		}
+	}
+	if writeIns[1].Raw != "Diego Rivera" || writeIns[1].Ballots != 2 {
+		t.Error("Expected Diego Rivera to be on 2 ballots, got", writeIns[1])
+	}
+
+	// Without write-ins only listed candidates, and aliases for them, are counted
+	normalizer.Contest.WriteIns = false
+	normalizer.Aliases = cryptoballot.AliasMap{"diego rivera": "Frida Kahlo"}
+	normalized, writeIns = normalizer.Normalize(ballots)
+	if !reflect.DeepEqual(normalized[1].Vote, cryptoballot.Vote{"Frida Kahlo", "Salvador Dalí"}) {
+		t.Error("Expected the alias to be used, got", normalized[1].Vote)
+	}
+	normalizer.Aliases = nil
+	normalized, writeIns = normalizer.Normalize(ballots)
+	if normalized[2].Vote.Marker() != cryptoballot.VoteSpoiled {
+		t.Error("Expected a ballot with only write-ins to be spoiled, got", normalized[2].Vote)
+	}
+	for _, writeIn := range writeIns {
+		if writeIn.Source != WriteInRejected {
+			t.Error("Expected write-in to be rejected, got", writeIn)
+		}
+	}
+}
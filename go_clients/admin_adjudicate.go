@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/urfave/cli"
+)
+
+// actionAdminAdjudicate signs the admin's rulings on write-ins, so that they can be included in the election's bundle.
+// The rulings file holds one "<write-in> => <candidate>" per line. See cryptoballot.Adjudication
+func actionAdminAdjudicate(c *cli.Context) error {
+	electionid, filename := c.Args().Get(0), c.Args().Get(1)
+	if electionid == "" || filename == "" {
+		log.Fatal("Please specify an election-id and a rulings file")
+	}
+
+	if len(DidPrivateKey) != 32 {
+		log.Fatal("Please specify the election admin's DID key from the keystore with --did (eg: `--did=mykey`)")
+	}
+
+	var election *cryptoballot.Election
+	if c.String("bundle") != "" {
+		election = &loadBundle(c.String("bundle")).Election
+	} else {
+		var err error
+		election, err = BallotClerkClient.GetElection(electionid)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if election.ElectionID != electionid {
+		log.Fatal("The bundle is for election " + election.ElectionID)
+	}
+	if !election.Contest().Declared() {
+		log.Fatal("Election " + electionid + " does not list candidates or allow write-ins")
+	}
+	if !bytes.Equal(election.PublicKey, DidPublicKey.Bytes()) {
+		log.Fatal("Write-ins must be adjudicated by the election admin, and the --did key is not theirs")
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rulings, err := cryptoballot.NewRulings(content)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	adjudication := cryptoballot.Adjudication{
+		ElectionID: electionid,
+		Rulings:    rulings,
+		PublicKey:  DidPublicKey.Bytes(),
+	}
+	adjudication.Signature, err = DidPrivateKey.SignString(adjudication.StringWithoutSignature())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if c.String("out") == "" {
+		fmt.Println(adjudication.String())
+		return nil
+	}
+	err = ioutil.WriteFile(c.String("out"), []byte(adjudication.String()+"\n"), 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return nil
+}
+
+// loadAdjudication reads and parses an adjudication file given with --adjudication. It is nil if none was given.
+func loadAdjudication(c *cli.Context) *cryptoballot.Adjudication {
+	if c.String("adjudication") == "" {
+		return nil
+	}
+	content, err := ioutil.ReadFile(c.String("adjudication"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	adjudication, err := cryptoballot.NewAdjudication(content)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return adjudication
+}
+
+// loadAliases reads and parses an alias file given with --aliases. It is nil if none was given.
+func loadAliases(c *cli.Context) cryptoballot.AliasMap {
+	if c.String("aliases") == "" {
+		return nil
+	}
+	content, err := ioutil.ReadFile(c.String("aliases"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	aliases, err := cryptoballot.NewAliasMap(content)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return aliases
+}
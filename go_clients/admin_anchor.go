@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/go_clients/util"
+	"github.com/urfave/cli"
+)
+
+// actionAdminAnchor commits the state of an election to the anchor given with --anchor, and appends the receipt to
+// the file given with --receipts. The commitment is final once --settle has passed since the election ended, giving
+// the ballotbox time to publish its last mixed batch and its mirrors time to pull it. With --interval it keeps
+// making interim commitments until it can make the final one.
+func actionAdminAnchor(c *cli.Context) error {
+	electionid := c.Args().First()
+	if electionid == "" {
+		log.Fatal("Please specify an election-id to anchor")
+	}
+	if c.String("receipts") == "" {
+		log.Fatal("Please specify a file to keep the anchor receipts in with --receipts")
+	}
+	anchor := loadAnchor(c)
+
+	election, err := BallotClerkClient.GetElection(electionid)
+	if err != nil {
+		log.Fatal(err)
+	}
+	clerkKey, err := BallotClerkClient.GetPublicKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	settled := election.End.Add(c.Duration("settle"))
+	for {
+		// The time is taken first, so that no ballot published after it can be left out of a final commitment
+		now := time.Now()
+		final := !now.Before(settled)
+		entries, err := fetchLog(clerkKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var ballots []cryptoballot.Ballot
+		it := BallotBoxClient.IterateBallots(electionid, util.DefaultBallotPageSize)
+		for it.Next() {
+			ballots = append(ballots, *it.Ballot())
+		}
+		it.Close()
+		if err = it.Err(); err != nil {
+			log.Fatal(err)
+		}
+
+		commitment, err := cryptoballot.NewCommitmentForElection(election, entries, ballots, now, final)
+		if err != nil {
+			log.Fatal(err)
+		}
+		receipt, err := anchor.Commit(commitment)
+		if err != nil {
+			log.Fatal(err)
+		}
+		f, err := os.OpenFile(c.String("receipts"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		_, err = fmt.Fprintln(f, receipt)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if final {
+			fmt.Printf("anchored %d ballots and log entry %d: %s\n", commitment.Ballots, commitment.LogSequence, receipt)
+		} else {
+			fmt.Printf("anchored log entry %d (interim): %s\n", commitment.LogSequence, receipt)
+		}
+
+		if final || c.Duration("interval") == 0 {
+			return nil
+		}
+		wait := c.Duration("interval")
+		if untilSettled := time.Until(settled); untilSettled > 0 && untilSettled < wait {
+			wait = untilSettled
+		}
+		time.Sleep(wait)
+	}
+}
+
+// verifyAnchored checks the bundle against every commitment in the receipts file given with --receipts, and prints
+// the outcome. The ballotclerk's audit log is fetched to check the anchored log entries. It reports whether every
+// commitment matched.
+func verifyAnchored(c *cli.Context, bundle *cryptoballot.Bundle) bool {
+	anchor := loadAnchor(c)
+	receipts := loadReceipts(c.String("receipts"))
+
+	ok := true
+	entries, err := fetchLog(bundle.ClerkKey)
+	if err != nil {
+		fmt.Println("anchor: audit log is invalid: " + err.Error())
+		ok = false
+	}
+	for _, receipt := range receipts {
+		commitment, err := anchor.Lookup(receipt)
+		if err == nil {
+			err = commitment.Verify(bundle, entries)
+		}
+		if err != nil {
+			fmt.Printf("anchor: %s failed: %s\n", receipt, err)
+			ok = false
+			continue
+		}
+		if commitment.Final {
+			fmt.Printf("anchor: %s verified (%d ballots at %s)\n", receipt, commitment.Ballots, commitment.Time.Format(time.RFC1123Z))
+		} else {
+			fmt.Printf("anchor: %s verified the election and audit log at %s (interim, ballots not checked)\n", receipt, commitment.Time.Format(time.RFC1123Z))
+		}
+	}
+	return ok
+}
+
+// loadAnchor gets the anchor given with --anchor. It is either file:<path> for a FileAnchor, or the URL of an
+// Elastos sidechain node, which requires the anchoring account with --anchor-account
+func loadAnchor(c *cli.Context) cryptoballot.Anchor {
+	spec := c.String("anchor")
+	switch {
+	case spec == "":
+		log.Fatal("Please specify an anchor with --anchor (eg: `--anchor=file:anchor.txt` or `--anchor=https://api.elastos.io/esc`)")
+	case strings.HasPrefix(spec, "file:"):
+		return &cryptoballot.FileAnchor{Path: strings.TrimPrefix(spec, "file:")}
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		if c.String("anchor-account") == "" {
+			log.Fatal("Please specify the anchoring account with --anchor-account")
+		}
+		anchor, err := cryptoballot.NewElastosAnchor(spec, c.String("anchor-account"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		return anchor
+	default:
+		log.Fatal("Unknown anchor " + spec + ". Must be file:<path> or the URL of an Elastos sidechain node")
+	}
+	return nil
+}
+
+// loadReceipts reads a file of anchor receipts, one per line
+func loadReceipts(filename string) []string {
+	f, err := os.Open(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	var receipts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if receipt := strings.TrimSpace(scanner.Text()); receipt != "" {
+			receipts = append(receipts, receipt)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	return receipts
+}
@@ -20,7 +20,7 @@ func actionAdminCreate(c *cli.Context) error {
 	}
 
 	if len(DidPrivateKey) != 32 {
-		log.Fatal("Please specify a did private key with --didKey (eg: `--didKey=CC6FA0F0E191AD47A430FE04411C079F07D5C1EE47C3AA55F0E0204C8FE36D17`)")
+		log.Fatal("Please specify a DID key from the keystore with --did (eg: `--did=mykey`). See `cryptoballot key generate`")
 	}
 
 	content, err := ioutil.ReadFile(filename)
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/urfave/cli"
+)
+
+// actionAdminExport exports an ended election as a signed bundle, which can be audited and tallied offline.
+// The admin's adjudication of write-ins is included if given with --adjudication.
+func actionAdminExport(c *cli.Context) error {
+	electionid := c.Args().First()
+	if electionid == "" {
+		log.Fatal("Please specify an election-id to export")
+	}
+
+	if len(DidPrivateKey) != 32 {
+		log.Fatal("Please specify a DID key from the keystore with --did to sign the bundle (eg: `--did=mykey`)")
+	}
+
+	bundle := fetchBundle(electionid)
+	bundle.Adjudication = loadAdjudication(c)
+	if bundle.Adjudication != nil {
+		if err := bundle.Adjudication.VerifyForElection(&bundle.Election); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Sign the bundle
+	var err error
+	bundle.PublicKey = DidPublicKey.Bytes()
+	bundle.Signature, err = DidPrivateKey.SignString(bundle.StringWithoutSignature())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if c.String("out") == "" {
+		fmt.Print(bundle.String())
+		return nil
+	}
+	err = ioutil.WriteFile(c.String("out"), []byte(bundle.String()), 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return nil
+}
+
+// fetchBundle gets everything needed to audit an election from the ballotclerk and ballotbox.
+// The returned bundle is not signed.
+func fetchBundle(electionid string) *cryptoballot.Bundle {
+	election, err := BallotClerkClient.GetElection(electionid)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	clerkPublicKey, err := BallotClerkClient.GetPublicKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fulfilled, err := BallotClerkClient.GetAllSignatureRequests(electionid)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	bundle := &cryptoballot.Bundle{
+		Election: *election,
+		ClerkKey: clerkPublicKey,
+	}
+
+	// Weighted elections need the clerk's key for every denomination
+	weightSource, err := election.WeightSource()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if weightSource != nil {
+		bundle.DenominationKeys, err = BallotClerkClient.GetDenominationKeys()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, sigReq := range fulfilled {
+		bundle.SignatureRequests = append(bundle.SignatureRequests, *sigReq)
+	}
+
+	it := BallotBoxClient.IterateBallots(electionid, 0)
+	defer it.Close()
+	for it.Next() {
+		bundle.Ballots = append(bundle.Ballots, *it.Ballot())
+	}
+	if err = it.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	bundle.MerkleRoot = bundle.ComputeMerkleRoot()
+	return bundle
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/urfave/cli"
+)
+
+// logPageSize is the number of audit log entries fetched at a time
+const logPageSize = 1000
+
+// actionVerifyLog fetches the ballotclerk's whole audit log and checks that no entry has been inserted, deleted,
+// altered or reordered. Given an election-id, it also checks that the signature requests the ballotclerk publishes
+// for the election are exactly those recorded in the log.
+func actionVerifyLog(c *cli.Context) error {
+	clerkKey, err := BallotClerkClient.GetPublicKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	entries, err := fetchLog(clerkKey)
+	if err != nil {
+		return cli.NewExitError("log: "+err.Error(), 1)
+	}
+	fmt.Printf("log: verified %d entries\n", len(entries))
+
+	electionid := c.Args().First()
+	if electionid == "" {
+		return nil
+	}
+	fulfilled, err := BallotClerkClient.GetAllSignatureRequests(electionid)
+	if err != nil {
+		log.Fatal(err)
+	}
+	signatureRequests := make([]cryptoballot.FulfilledSignatureRequest, len(fulfilled))
+	for i, sigReq := range fulfilled {
+		signatureRequests[i] = *sigReq
+	}
+	if err = cryptoballot.CheckLoggedSignatureRequests(entries, electionid, signatureRequests); err != nil {
+		return cli.NewExitError("signature requests: "+err.Error(), 1)
+	}
+	fmt.Printf("signature requests: %d match the log\n", len(signatureRequests))
+
+	return nil
+}
+
+// fetchLog gets the ballotclerk's whole audit log, verifying it a page at a time with each page following on from
+// the last entry of the one before. Errors fetching the log are fatal. Verification errors are returned.
+func fetchLog(clerkKey cryptoballot.PublicKey) ([]*cryptoballot.LogEntry, error) {
+	var (
+		entries []*cryptoballot.LogEntry
+		prev    *cryptoballot.LogEntry
+	)
+	for {
+		page, err := BallotClerkClient.GetLog(uint64(len(entries)), logPageSize)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = cryptoballot.VerifyAuditLog(page, clerkKey, prev); err != nil {
+			return nil, err
+		}
+		entries = append(entries, page...)
+		if len(page) < logPageSize {
+			return entries, nil
+		}
+		prev = page[len(page)-1]
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot/tally"
+	"github.com/urfave/cli"
+)
+
+// actionAdminPublishResult audits an ended election, signs its result and publishes it to the ballotclerk.
+// Like audit, it works from a bundle given with --bundle, or fetches the election from the servers.
+// Nothing is published if the audit finds any problems.
+func actionAdminPublishResult(c *cli.Context) error {
+	if len(DidPrivateKey) != 32 {
+		log.Fatal("Please specify a DID key from the keystore with --did to sign the result (eg: `--did=mykey`)")
+	}
+
+	bundle := resultBundle(c)
+	report := tally.Audit(bundle, loadAliases(c), electorateFromVoterRoll(c, &bundle.Election))
+	if !report.OK() {
+		fmt.Print(report.String())
+		return cli.NewExitError("Not publishing a result for an election that failed its audit", 1)
+	}
+
+	result, err := tally.NewElectionResult(bundle, report, "cryptoballot/"+Version)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if result.PublicKey != DidPublicKey.Hex() {
+		log.Fatal("Results must be signed with the key of the admin who created election " + result.ElectionID)
+	}
+	signature, err := DidPrivateKey.SignString(result.StringWithoutSignature())
+	if err != nil {
+		log.Fatal(err)
+	}
+	result.Signature = hex.EncodeToString(signature)
+
+	err = BallotClerkClient.PutResult(result, DidPrivateKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(result.String())
+
+	return nil
+}
+
+// actionVerifyResult fetches the published result of an election and checks it by auditing and tallying the
+// election again. The aliases given with --aliases, and the voter roll given with --voter-roll, must be the ones the
+// admin published the result with.
+func actionVerifyResult(c *cli.Context) error {
+	bundle := resultBundle(c)
+	result, err := BallotClerkClient.GetResult(bundle.Election.ElectionID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := tally.VerifyElectionResult(result, bundle, loadAliases(c), electorateFromVoterRoll(c, &bundle.Election))
+	if report != nil {
+		fmt.Print(report.String())
+	}
+	if err != nil {
+		return cli.NewExitError("result: "+err.Error(), 1)
+	}
+	fmt.Println("result: verified, published by election admin using " + result.Software)
+
+	return nil
+}
+
+// resultBundle gets the bundle to build or verify a result from, the same way as actionAudit
+func resultBundle(c *cli.Context) *cryptoballot.Bundle {
+	if c.String("bundle") != "" {
+		if c.String("adjudication") != "" {
+			log.Fatal("Bundles carry their own adjudication. Export the election again with --adjudication to change it")
+		}
+		return loadBundle(c.String("bundle"))
+	}
+	electionid := c.Args().First()
+	if electionid == "" {
+		log.Fatal("Please specify an election-id, or a bundle with --bundle")
+	}
+	bundle := fetchBundle(electionid)
+	bundle.Adjudication = loadAdjudication(c)
+	return bundle
+}
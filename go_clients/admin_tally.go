@@ -2,75 +2,229 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
-	"strings"
 
-	"github.com/Sam-Izdat/govote"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot/tally"
 	"github.com/urfave/cli"
 )
 
-func actionAdminTally(c *cli.Context) error {
-	electionid := c.Args().First()
+// actionTally tallies an election. With --bundle it works entirely offline from an exported bundle,
+// otherwise it fetches the ballots from the ballotbox. Weighted elections are tallied by ballot weight.
+// Elections that list candidates or allow write-ins are normalized first, using the adjudication from --adjudication
+// or the bundle and the aliases from --aliases. Elections with a quorum or threshold also report whether they passed.
+func actionTally(c *cli.Context) error {
+	var (
+		election          *cryptoballot.Election
+		clerkPublicKey    cryptoballot.PublicKey
+		clerkKeys         cryptoballot.DenominationKeys
+		allBallots        []cryptoballot.Ballot
+		adjudication      *cryptoballot.Adjudication
+		signatureRequests []cryptoballot.FulfilledSignatureRequest
+	)
 
-	// Get public key from ballotclerk server
-	clerkPublicKey, err := BallotClerkClient.GetPublicKey()
-	if err != nil {
-		log.Fatal(err)
+	if c.String("bundle") != "" {
+		bundle := loadBundle(c.String("bundle"))
+		election = &bundle.Election
+		clerkPublicKey = bundle.ClerkKey
+		clerkKeys = bundle.DenominationKeys
+		allBallots = bundle.Ballots
+		adjudication = bundle.Adjudication
+		signatureRequests = bundle.SignatureRequests
+	} else {
+		electionid := c.Args().First()
+		if electionid == "" {
+			log.Fatal("Please specify an election-id, or a bundle with --bundle")
+		}
+
+		var err error
+		election, err = BallotClerkClient.GetElection(electionid)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// Get public key from ballotclerk server
+		clerkPublicKey, err = BallotClerkClient.GetPublicKey()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if weightSource, _ := election.WeightSource(); weightSource != nil {
+			clerkKeys, err = BallotClerkClient.GetDenominationKeys()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		// Get all the ballots
+		it := BallotBoxClient.IterateBallots(electionid, 0)
+		for it.Next() {
+			allBallots = append(allBallots, *it.Ballot())
+		}
+		it.Close()
+		if err = it.Err(); err != nil {
+			log.Fatal(err)
+		}
+
+		// Turnout is counted from the signature requests, which are published once the election has ended
+		if rules, _ := election.DecisionRules(); rules.Declared() {
+			fulfilled, err := BallotClerkClient.GetAllSignatureRequests(electionid)
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, sigReq := range fulfilled {
+				signatureRequests = append(signatureRequests, *sigReq)
+			}
+		}
 	}
 
-	// Get all the ballots
-	allBallots, err := BallotBoxClient.GetAllBallots(electionid)
+	weightSource, err := election.WeightSource()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// Verify the signature on all ballots
-	for _, ballot := range allBallots {
-		err = ballot.VerifyBlindSignature(clerkPublicKey)
+	// Verify the signature on all ballots. In weighted elections this also gets each ballot's weight
+	weighted := make([]tally.WeightedBallot, len(allBallots))
+	for i, ballot := range allBallots {
+		weight := uint64(1)
+		if weightSource != nil {
+			weight, err = ballot.VerifyWeightedSignature(clerkKeys)
+		} else {
+			err = ballot.VerifyBlindSignature(clerkPublicKey)
+		}
 		if err != nil {
 			log.Fatal(err)
 		}
+		weighted[i] = tally.WeightedBallot{Ballot: ballot, Weight: weight}
 	}
 
 	// TODO: Verify that no two ballots have the same ID
 
 	// TODO: Verify all fulfilledSignatureRequests (if has sufficient permission)
 
-	// Get a list of all candidates
-	candidatesmap := map[string]bool{}
-	for _, ballot := range allBallots {
-		for _, vote := range ballot.Vote {
-			candidatesmap[vote] = true
-		}
+	// Map write-ins to candidates
+	if given := loadAdjudication(c); given != nil {
+		adjudication = given
 	}
-	candidates := []string{}
-	for candidate := range candidatesmap {
-		candidates = append(candidates, candidate)
+	aliases := loadAliases(c)
+	if election.Contest().Declared() {
+		if adjudication != nil {
+			if err = adjudication.VerifyForElection(election); err != nil {
+				log.Fatal(err)
+			}
+		}
+		var writeIns []tally.WriteIn
+		weighted, writeIns = tally.NewNormalizer(election, aliases, adjudication).Normalize(weighted)
+		for _, writeIn := range writeIns {
+			fmt.Println("write-in: " + writeIn.String())
+		}
+	} else if adjudication != nil || aliases != nil {
+		log.Fatal("Election " + election.ElectionID + " does not list candidates or allow write-ins")
 	}
 
-	// Create a schulze poll
-	schulze, err := govote.Schulze.New(candidates)
+	// Calculate result using schulze (condorcet)
+	var result *tally.Result
+	if weightSource != nil {
+		result, err = tally.TallyWeighted(weighted)
+	} else {
+		ballots := make([]cryptoballot.Ballot, len(weighted))
+		for i, ballot := range weighted {
+			ballots[i] = ballot.Ballot
+		}
+		result, err = tally.Tally(ballots)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// Add ballots to the poll
-	for _, ballot := range allBallots {
-		schulze.AddBallot(ballot.Vote)
+	// Print the result
+	fmt.Print(result.String())
+
+	// Apply the quorum and threshold
+	rules, err := election.DecisionRules()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if rules.Declared() {
+		electorate := electorateFromVoterRoll(c, election)
+		if electorate == 0 {
+			electorate = rules.Electorate
+		}
+		decision, err := tally.Decide(rules, tally.Turnout(signatureRequests, weightSource != nil), electorate, weighted, result)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(decision.String())
 	}
 
-	// Calculate result using schulze (condorcet)
-	result, _, err := schulze.Evaluate()
+	return nil
+}
+
+// electorateFromVoterRoll gets the size of the electorate from the voter roll given with --voter-roll. It is zero
+// without one, so that the election's electorate tag is used. See tally.VoterRollElectorate
+func electorateFromVoterRoll(c *cli.Context, election *cryptoballot.Election) uint64 {
+	if c.String("voter-roll") == "" {
+		return 0
+	}
+	weightSource, err := election.WeightSource()
 	if err != nil {
 		log.Fatal(err)
 	}
+	content, err := ioutil.ReadFile(c.String("voter-roll"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	roll, err := cryptoballot.NewVoterRoll(content)
+	if err != nil {
+		log.Fatal(err)
+	}
+	electorate, err := tally.VoterRollElectorate(roll, weightSource)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return electorate
+}
 
-	if len(result) == 0 {
-		log.Fatal("No election result")
+// actionAudit verifies an election and tallies the valid ballots. With --bundle it works entirely offline
+// from an exported bundle, otherwise it fetches everything from the ballotclerk and ballotbox, along with the
+// adjudication given by --adjudication. Bundles carry their own adjudication. Anchored commitments are checked with
+// --receipts, and the ballotbox is compared with the mirrors given with --mirror.
+func actionAudit(c *cli.Context) error {
+	var bundle *cryptoballot.Bundle
+	if c.String("bundle") != "" {
+		if c.String("adjudication") != "" {
+			log.Fatal("Bundles carry their own adjudication. Export the election again with --adjudication to change it")
+		}
+		bundle = loadBundle(c.String("bundle"))
+	} else {
+		electionid := c.Args().First()
+		if electionid == "" {
+			log.Fatal("Please specify an election-id, or a bundle with --bundle")
+		}
+		bundle = fetchBundle(electionid)
+		bundle.Adjudication = loadAdjudication(c)
 	}
 
-	// Print the winner
-	fmt.Println("winner: ", strings.Join(result, ", "))
+	report := tally.Audit(bundle, loadAliases(c), electorateFromVoterRoll(c, &bundle.Election))
+	fmt.Print(report.String())
+	anchored := c.String("receipts") == "" || verifyAnchored(c, bundle)
+	mirrored := len(BallotBoxMirrors) == 0 || verifyMirrors(bundle.Election.ElectionID)
+	if !report.OK() || !anchored || !mirrored {
+		return cli.NewExitError("", 1)
+	}
 
 	return nil
 }
+
+// loadBundle reads and parses a bundle file
+func loadBundle(filename string) *cryptoballot.Bundle {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bundle, err := cryptoballot.NewBundle(content)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return bundle
+}
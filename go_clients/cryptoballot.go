@@ -4,17 +4,18 @@ import (
 	"encoding/hex"
 	"fmt"
 	"github.com/cryptoballot/entropychecker"
-	"github.com/elastos/Elastos.Service.DIDVote/go_clients/util"
 	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/go_clients/util"
 	"github.com/phayes/decryptpem"
 	"github.com/urfave/cli"
 	"log"
 	"os"
 	"runtime"
+	"time"
 )
 
 // Version specifies the version of this binary
-var Version = "0.1"
+var Version = "0.2"
 
 // BallotClerkClient is used to connect to ballotclerk server
 var BallotClerkClient *util.BallotclerkClient
@@ -22,6 +23,9 @@ var BallotClerkClient *util.BallotclerkClient
 // BallotBoxClient is used to connect to ballotbox server
 var BallotBoxClient *util.BallotBoxClient
 
+// BallotBoxMirrors are used to connect to the other ballotbox mirrors given with --mirror. See mirrors.go
+var BallotBoxMirrors []*util.BallotBoxClient
+
 // PrivateKey for all operations that require a private key
 var PrivateKey cryptoballot.PrivateKey
 
@@ -34,6 +38,49 @@ var DidPrivateKey cryptoballot.DIDPrivateKey
 // DID PublicKey derived from DiDPrivateKey
 var DidPublicKey cryptoballot.DIDPublicKey
 
+// bundleFlag lets tally and audit work offline from an exported bundle. See admin_export.go
+var bundleFlag = cli.StringFlag{
+	Name:  "bundle",
+	Usage: "work offline from an exported election bundle instead of contacting the servers",
+}
+
+// adjudicationFlag and aliasesFlag map write-ins to candidates. See admin_adjudicate.go
+var adjudicationFlag = cli.StringFlag{
+	Name:  "adjudication",
+	Usage: "file holding the election admin's signed rulings on write-ins. See cryptoballot admin adjudicate",
+}
+
+var aliasesFlag = cli.StringFlag{
+	Name:  "aliases",
+	Usage: "file mapping alternative spellings to candidates, one <alias> => <candidate> per line",
+}
+
+// voterRollFlag gives the size of the electorate for quorums. See admin_tally.go
+var voterRollFlag = cli.StringFlag{
+	Name:  "voter-roll",
+	Usage: "voter roll file to count the electorate from when checking quorum, instead of the election's electorate tag",
+}
+
+// anchorFlag, anchorAccountFlag and receiptsFlag locate commitments anchored to a ledger. See admin_anchor.go
+var anchorFlag = cli.StringFlag{
+	Name:  "anchor",
+	Usage: "where commitments are anchored: file:<path>, or the URL of an Elastos sidechain node",
+}
+var anchorAccountFlag = cli.StringFlag{
+	Name:  "anchor-account",
+	Usage: "hex address of the sidechain account that anchors the election",
+}
+var receiptsFlag = cli.StringFlag{
+	Name:  "receipts",
+	Usage: "file of anchor receipts, one per line",
+}
+
+// noNewLineFlag is used by the tools commands. See tools.go
+var noNewLineFlag = cli.BoolFlag{
+	Name:  "n",
+	Usage: "do not output the trailing newline",
+}
+
 func main() {
 	app := cli.NewApp()
 	app.Name = "cryptoballot"
@@ -48,6 +95,10 @@ func main() {
 			Name:  "ballotbox",
 			Value: "http://localhost:8001",
 		},
+		cli.StringSliceFlag{
+			Name:  "mirror",
+			Usage: "URL of another ballotbox mirror. Ballots are cast to every mirror, and audit compares them. May be repeated",
+		},
 		cli.StringFlag{
 			Name:  "key",
 			Value: "",
@@ -55,6 +106,17 @@ func main() {
 		cli.StringFlag{
 			Name:  "didKey",
 			Value: "",
+			Usage: "hex encoded DID private key. Deprecated since it is visible in shell history and process lists. Use --did instead",
+		},
+		cli.StringFlag{
+			Name:  "did",
+			Value: "",
+			Usage: "name of the DID key to use from the keystore. See cryptoballot key --help",
+		},
+		cli.StringFlag{
+			Name:  "keystore",
+			Value: defaultKeystorePath(),
+			Usage: "keystore file holding encrypted DID keys",
 		},
 	}
 
@@ -74,10 +136,91 @@ func main() {
 					Name:      "tally",
 					Usage:     "Verify and tally election results",
 					ArgsUsage: "[election-id]",
-					Action:    actionAdminTally,
+					Action:    actionTally,
+					Flags:     []cli.Flag{bundleFlag, adjudicationFlag, aliasesFlag, voterRollFlag},
+				},
+				{
+					Name:      "adjudicate",
+					Usage:     "sign rulings on which candidate each write-in counts for, as the election admin",
+					ArgsUsage: "[election-id] [rulingsfile]",
+					Action:    actionAdminAdjudicate,
+					Flags: []cli.Flag{
+						bundleFlag,
+						cli.StringFlag{
+							Name:  "out",
+							Usage: "file to write the adjudication to. Defaults to stdout",
+						},
+					},
+				},
+				{
+					Name:      "publish-result",
+					Usage:     "Audit an ended election, then sign and publish its result to the ballotclerk",
+					ArgsUsage: "[election-id]",
+					Action:    actionAdminPublishResult,
+					Flags:     []cli.Flag{bundleFlag, adjudicationFlag, aliasesFlag, voterRollFlag},
+				},
+				{
+					Name:      "anchor",
+					Usage:     "Commit the election, the ballotclerk's audit log and the ballots to an external ledger",
+					ArgsUsage: "[election-id]",
+					Action:    actionAdminAnchor,
+					Flags: []cli.Flag{
+						anchorFlag,
+						anchorAccountFlag,
+						receiptsFlag,
+						cli.DurationFlag{
+							Name:  "interval",
+							Usage: "keep committing this often (eg: 1h) until the final commitment has been made",
+						},
+						cli.DurationFlag{
+							Name:  "settle",
+							Value: 10 * time.Minute,
+							Usage: "how long after the election ends to make the final commitment. Must be longer than the ballotbox's mixing interval and its mirrors' pull interval",
+						},
+					},
+				},
+				{
+					Name:      "export",
+					Usage:     "export an ended election as a signed bundle that can be audited offline",
+					ArgsUsage: "[election-id]",
+					Action:    actionAdminExport,
+					Flags: []cli.Flag{
+						adjudicationFlag,
+						cli.StringFlag{
+							Name:  "out",
+							Usage: "file to write the bundle to. Defaults to stdout",
+						},
+					},
 				},
 			},
 		},
+		{
+			Name:      "tally",
+			Usage:     "Verify and tally election results",
+			ArgsUsage: "[election-id]",
+			Action:    actionTally,
+			Flags:     []cli.Flag{bundleFlag, adjudicationFlag, aliasesFlag, voterRollFlag},
+		},
+		{
+			Name:      "audit",
+			Usage:     "Verify every signature request and ballot in an election, then tally the valid ballots",
+			ArgsUsage: "[election-id]",
+			Action:    actionAudit,
+			Flags:     []cli.Flag{bundleFlag, adjudicationFlag, aliasesFlag, voterRollFlag, anchorFlag, anchorAccountFlag, receiptsFlag},
+		},
+		{
+			Name:      "verify-result",
+			Usage:     "Check the published result of an election by auditing and tallying it again",
+			ArgsUsage: "[election-id]",
+			Action:    actionVerifyResult,
+			Flags:     []cli.Flag{bundleFlag, adjudicationFlag, aliasesFlag, voterRollFlag},
+		},
+		{
+			Name:      "verify-log",
+			Usage:     "Check the ballotclerk's audit log, and that an election's signature requests match it",
+			ArgsUsage: "[election-id]",
+			Action:    actionVerifyLog,
+		},
 		{
 			Name:  "voter",
 			Usage: "vote in an election",
@@ -87,14 +230,190 @@ func main() {
 					Usage:     "vote in an election",
 					Action:    actionVoterVote,
 					ArgsUsage: "[votefile]",
+					Flags: []cli.Flag{
+						cli.DurationFlag{
+							Name:  "delay",
+							Usage: "wait a random time up to this long (eg: 6h) between getting the ballot signed and submitting it",
+						},
+						cli.StringFlag{
+							Name:  "voter-did",
+							Usage: "the voter's DID. Required if the election has a voter roll",
+						},
+						cli.StringSliceFlag{
+							Name:  "credential",
+							Usage: "file holding a verifiable credential to present to the election clerk. May be repeated. Requires --voter-did",
+						},
+						cli.Uint64Flag{
+							Name:  "weight",
+							Usage: "your weight in a weighted election. The ballot is split into one ballot per denomination of the election clerk's keys, each cast after its own --delay",
+						},
+						cli.StringFlag{
+							Name:  "pending",
+							Usage: "file to keep signed ballots in until they are submitted. Defaults to <votefile>.pending",
+						},
+					},
 				},
 				{
 					Name:      "verify",
-					Usage:     "Verify that the voters vote has been counted",
+					Usage:     "Verify that the voters vote has been published by the ballotbox and every --mirror",
 					ArgsUsage: "[votefile]",
-					Action: func(c *cli.Context) error {
-						fmt.Println("verify: ", c.Args().First())
-						return nil
+					Action:    actionVoterVerify,
+				},
+			},
+		},
+		{
+			Name:  "key",
+			Usage: "manage DID keys in an encrypted keystore",
+			Subcommands: []cli.Command{
+				{
+					Name:      "generate",
+					Usage:     "generate a new DID key",
+					ArgsUsage: "[name]",
+					Action:    actionKeyGenerate,
+				},
+				{
+					Name:      "import",
+					Usage:     "import a hex or PEM encoded DID private key from a file, or from stdin with -",
+					ArgsUsage: "[name] [keyfile]",
+					Action:    actionKeyImport,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "format",
+							Usage: "hex or pem. Detected from the file if not given",
+						},
+					},
+				},
+				{
+					Name:      "export",
+					Usage:     "export a DID key as hex or PEM",
+					ArgsUsage: "[name]",
+					Action:    actionKeyExport,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "format",
+							Value: "hex",
+							Usage: "hex or pem",
+						},
+						cli.BoolFlag{
+							Name:  "public",
+							Usage: "only export the public key",
+						},
+						cli.StringFlag{
+							Name:  "out",
+							Usage: "file to write the key to. Defaults to stdout",
+						},
+					},
+				},
+				{
+					Name:      "show",
+					Usage:     "show the public key and signature request ID of a DID key",
+					ArgsUsage: "[name]",
+					Action:    actionKeyShow,
+				},
+				{
+					Name:   "list",
+					Usage:  "list all keys in the keystore",
+					Action: actionKeyList,
+				},
+				{
+					Name:      "remove",
+					Usage:     "remove a DID key from the keystore",
+					ArgsUsage: "[name]",
+					Action:    actionKeyRemove,
+				},
+			},
+		},
+		{
+			Name:  "tools",
+			Usage: "low level tools for working with keys, signatures and signature requests",
+			Subcommands: []cli.Command{
+				{
+					Name:      "getid",
+					Usage:     "print the ID of a public key. Uses the --did or --key key if no file is given",
+					ArgsUsage: "[public-key-file]",
+					Action:    actionToolsGetID,
+					Flags:     []cli.Flag{noNewLineFlag},
+				},
+				{
+					Name:   "public-key",
+					Usage:  "print the public key of the --did or --key key",
+					Action: actionToolsPublicKey,
+					Flags:  []cli.Flag{noNewLineFlag},
+				},
+				{
+					Name:      "sign",
+					Usage:     "print the signature of a file, or of stdin. Use --naive --sha256 with the clerk's --key to simulate the clerk signing a ballot",
+					ArgsUsage: "[file]",
+					Action:    actionToolsSign,
+					Flags: []cli.Flag{
+						noNewLineFlag,
+						cli.BoolFlag{
+							Name:  "d",
+							Usage: "trim the linux newline character (0A) from the end of the input",
+						},
+						cli.BoolFlag{
+							Name:  "naive",
+							Usage: "naively sign the message without any padding or hashing. RSA keys only",
+						},
+						cli.BoolFlag{
+							Name:  "sha256",
+							Usage: "apply a SHA256 hash before naive signing. Only used in combination with --naive",
+						},
+					},
+				},
+				{
+					Name:      "signature-request",
+					Usage:     "blind a ballot and print a signature request for it, signed with the --did key. The unblinder is printed to stderr. Ballots with a denomination tag are requested at that denomination",
+					ArgsUsage: "[ballotfile]",
+					Action:    actionToolsSignatureRequest,
+					Flags: []cli.Flag{
+						noNewLineFlag,
+						cli.StringFlag{
+							Name:  "voter-did",
+							Usage: "the voter's DID. Required if the election has a voter roll",
+						},
+						cli.StringSliceFlag{
+							Name:  "credential",
+							Usage: "file holding a verifiable credential to present to the election clerk. May be repeated. Requires --voter-did",
+						},
+						cli.StringFlag{
+							Name:  "clerk-key",
+							Usage: "file holding the election clerk's public key for the ballot's denomination. Fetched from the election clerk if not given",
+						},
+					},
+				},
+				{
+					Name:      "issue-credential",
+					Usage:     "issue a verifiable credential to a DID, signed with the --did key. Claims are given as name=value arguments",
+					ArgsUsage: "[name=value...]",
+					Action:    actionToolsIssueCredential,
+					Flags: []cli.Flag{
+						noNewLineFlag,
+						cli.StringFlag{
+							Name:  "issuer",
+							Usage: "the issuer's DID. The --did key must be one of its keys",
+						},
+						cli.StringFlag{
+							Name:  "key-id",
+							Usage: "the ID of the --did key in the issuer's DID document",
+							Value: "#primary",
+						},
+						cli.StringFlag{
+							Name:  "subject",
+							Usage: "the DID of the voter the credential is issued to",
+						},
+						cli.StringSliceFlag{
+							Name:  "type",
+							Usage: "the type of credential, for example MemberCredential. May be repeated",
+						},
+						cli.StringFlag{
+							Name:  "id",
+							Usage: "the credential's ID, used to revoke it. Defaults to a random urn:uuid",
+						},
+						cli.DurationFlag{
+							Name:  "valid-for",
+							Usage: "how long the credential is valid for (eg: 8760h). The credential does not expire if not given",
+						},
 					},
 				},
 			},
@@ -125,6 +444,9 @@ func main() {
 
 		// Connect to A4D Extract
 		BallotBoxClient = util.NewBallotBoxClient(c.String("ballotbox"))
+		for _, mirror := range c.StringSlice("mirror") {
+			BallotBoxMirrors = append(BallotBoxMirrors, util.NewBallotBoxClient(mirror))
+		}
 
 		// Privat Key
 		if c.String("key") != "" {
@@ -145,15 +467,20 @@ func main() {
 				log.Fatal(err)
 			}
 		}
-		// DID private key
+		// DID private key, either by name from the keystore or given directly in hex
 		var err error
-		DidPrivateKey,err = hex.DecodeString(c.String("didKey"))
-		if err != nil {
-			log.Fatal("Invalid didKey :" + err.Error())
+		if c.String("did") != "" {
+			DidPrivateKey = loadDIDKeyByName(c.String("keystore"), c.String("did"))
+		} else if c.String("didKey") != "" {
+			log.Println("Warning: --didKey is deprecated. Import the key with `cryptoballot key import` and use --did instead")
+			DidPrivateKey, err = hex.DecodeString(c.String("didKey"))
+			if err != nil {
+				log.Fatal("Invalid didKey :" + err.Error())
+			}
 		}
 		if len(DidPrivateKey) == 32 {
 			var err error
-			DidPublicKey , err = DidPrivateKey.GetPublicKeyFromPrivateKey()
+			DidPublicKey, err = DidPrivateKey.GetPublicKeyFromPrivateKey()
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// passphraseEnv may hold the keystore passphrase for non-interactive use. Otherwise the passphrase is prompted for.
+const passphraseEnv = "CRYPTOBALLOT_PASSPHRASE"
+
+// defaultKeystorePath gets the keystore used when --keystore is not given
+func defaultKeystorePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "keystore.pem"
+	}
+	return filepath.Join(home, ".cryptoballot", "keystore.pem")
+}
+
+// loadKeystore reads the keystore. A missing keystore is treated as empty.
+func loadKeystore(path string) *cryptoballot.Keystore {
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatal(err)
+	}
+	ks, err := cryptoballot.NewKeystore(content)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return ks
+}
+
+// saveKeystore writes the keystore so that only the current user can read it
+func saveKeystore(path string, ks *cryptoballot.Keystore) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, []byte(ks.String()), 0600)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readPassphrase gets the keystore passphrase from the environment, or prompts for it.
+// When confirm is set the passphrase must be entered twice.
+func readPassphrase(prompt string, confirm bool) []byte {
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return []byte(passphrase)
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		log.Fatal("Cannot prompt for a passphrase. Please set " + passphraseEnv)
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(passphrase) == 0 {
+		log.Fatal("The passphrase may not be empty")
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Repeat passphrase: ")
+		again, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !bytes.Equal(passphrase, again) {
+			log.Fatal("Passphrases do not match")
+		}
+	}
+	return passphrase
+}
+
+// loadDIDKeyByName decrypts a key from the keystore. This is how --did is resolved
+func loadDIDKeyByName(keystorePath string, name string) cryptoballot.DIDPrivateKey {
+	key, err := loadKeystore(keystorePath).Get(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	privateKey, err := key.Decrypt(readPassphrase("Passphrase for "+name+": ", false))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return privateKey
+}
+
+// addKey adds a key to the keystore and prints its public details
+func addKey(c *cli.Context, name string, privateKey cryptoballot.DIDPrivateKey) {
+	ks := loadKeystore(c.GlobalString("keystore"))
+	if _, err := ks.Get(name); err == nil {
+		log.Fatal(cryptoballot.ErrKeystoreKeyExists)
+	}
+	key, err := ks.Add(name, privateKey, readPassphrase("New passphrase for "+name+": ", true))
+	if err != nil {
+		log.Fatal(err)
+	}
+	saveKeystore(c.GlobalString("keystore"), ks)
+	printKey(key)
+}
+
+func printKey(key *cryptoballot.KeystoreKey) {
+	fmt.Println("Name:       " + key.Name)
+	fmt.Println("Public Key: " + key.PublicKey.Hex())
+	fmt.Println("Request ID: " + hex.EncodeToString(key.PublicKey.RequestID()))
+}
+
+func actionKeyGenerate(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		log.Fatal("Please specify a name for the new key")
+	}
+
+	privateKey, err := cryptoballot.GenerateDIDPrivateKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+	addKey(c, name, privateKey)
+	return nil
+}
+
+// actionKeyImport imports a hex or PEM encoded key from a file, or from stdin if the file is "-".
+// Keys are never taken from the command line itself, since they would end up in shell history.
+func actionKeyImport(c *cli.Context) error {
+	name, filename := c.Args().Get(0), c.Args().Get(1)
+	if name == "" || filename == "" {
+		log.Fatal("Please specify a name for the key and a file to import it from (or - for stdin)")
+	}
+
+	var (
+		content []byte
+		err     error
+	)
+	if filename == "-" {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	content = bytes.TrimSpace(content)
+
+	format := c.String("format")
+	if format == "" {
+		format = "hex"
+		if bytes.HasPrefix(content, []byte("-----BEGIN")) {
+			format = "pem"
+		}
+	}
+
+	var privateKey cryptoballot.DIDPrivateKey
+	switch format {
+	case "hex":
+		privateKey, err = cryptoballot.NewDIDPrivateKeyFromHex(string(content))
+	case "pem":
+		privateKey, err = cryptoballot.NewDIDPrivateKeyFromPEM(content)
+	default:
+		log.Fatal("Invalid format. Must be either hex or pem")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	addKey(c, name, privateKey)
+	return nil
+}
+
+// actionKeyExport prints a key as hex or PEM. Only the private key requires the passphrase
+func actionKeyExport(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		log.Fatal("Please specify the name of the key to export")
+	}
+	key, err := loadKeystore(c.GlobalString("keystore")).Get(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var out []byte
+	switch {
+	case c.Bool("public") && c.String("format") == "pem":
+		out, err = key.PublicKey.PEM()
+	case c.Bool("public"):
+		out = []byte(key.PublicKey.Hex() + "\n")
+	default:
+		privateKey, err := key.Decrypt(readPassphrase("Passphrase for "+name+": ", false))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if c.String("format") == "pem" {
+			out, err = privateKey.PEM()
+			if err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			out = []byte(privateKey.Hex() + "\n")
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if c.String("out") == "" {
+		os.Stdout.Write(out)
+		return nil
+	}
+	err = ioutil.WriteFile(c.String("out"), out, 0600)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return nil
+}
+
+func actionKeyShow(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		log.Fatal("Please specify the name of the key to show")
+	}
+	key, err := loadKeystore(c.GlobalString("keystore")).Get(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printKey(key)
+	return nil
+}
+
+func actionKeyList(c *cli.Context) error {
+	for _, key := range loadKeystore(c.GlobalString("keystore")).Keys() {
+		fmt.Println(key.Name + "\t" + key.PublicKey.Hex())
+	}
+	return nil
+}
+
+func actionKeyRemove(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		log.Fatal("Please specify the name of the key to remove")
+	}
+	ks := loadKeystore(c.GlobalString("keystore"))
+	err := ks.Remove(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	saveKeystore(c.GlobalString("keystore"), ks)
+	return nil
+}
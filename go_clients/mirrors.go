@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot/client"
+	"github.com/elastos/Elastos.Service.DIDVote/go_clients/util"
+)
+
+// ballotBoxes gets the ballotbox and every mirror given with --mirror
+func ballotBoxes() []*util.BallotBoxClient {
+	return append([]*util.BallotBoxClient{BallotBoxClient}, BallotBoxMirrors...)
+}
+
+// castBallot PUTs the ballot to the ballotbox and every mirror given with --mirror. It only fails if none of them
+// accepted the ballot, since mirrors pass the ballots they accept on to each other.
+func castBallot(voter *client.Voter, ballot *cryptoballot.Ballot) error {
+	err := voter.Cast(context.Background(), ballot)
+	if castErr, ok := err.(*client.CastError); ok && castErr.Cast() {
+		for url, refusal := range castErr.Refused {
+			log.Printf("%s did not accept ballot %s: %s", url, ballot.BallotID, refusal)
+		}
+		return nil
+	}
+	return err
+}
+
+// verifyMirrors compares the ballots published for an election by the ballotbox and every mirror given with
+// --mirror, and prints the outcome. Each mirror's ballots must match its signed tree head, and every ballot must be
+// published by every mirror. It reports whether the mirrors agree.
+func verifyMirrors(electionID string) bool {
+	ok := true
+	mirrors := map[string][]cryptoballot.Ballot{}
+	for _, box := range ballotBoxes() {
+		head, err := box.GetTreeHead(electionID)
+		if err != nil {
+			fmt.Printf("mirror: %s failed: %s\n", box.BaseURL, err)
+			ok = false
+			continue
+		}
+
+		var ballots []cryptoballot.Ballot
+		it := box.IterateBallots(electionID, util.DefaultBallotPageSize)
+		for it.Next() {
+			ballots = append(ballots, *it.Ballot())
+		}
+		it.Close()
+		if err = it.Err(); err != nil {
+			fmt.Printf("mirror: %s failed: %s\n", box.BaseURL, err)
+			ok = false
+			continue
+		}
+		mirrors[box.BaseURL] = ballots
+
+		// Ballots published between fetching the tree head and the ballots are reported as a mismatch
+		if err = head.VerifySignature(); err == nil {
+			err = head.VerifyBallots(ballots)
+		}
+		if err != nil {
+			fmt.Printf("mirror: %s tree head failed: %s\n", box.BaseURL, err)
+			ok = false
+			continue
+		}
+		fmt.Printf("mirror: %s publishes %d ballots, signed by %x\n", box.BaseURL, len(ballots), head.PublicKey)
+	}
+
+	for _, discrepancy := range cryptoballot.CompareMirrors(mirrors) {
+		fmt.Printf("mirror: ballot %s is missing from %s\n", discrepancy.BallotID, strings.Join(discrepancy.MissingFrom, ", "))
+		ok = false
+	}
+	return ok
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot/client"
+	"github.com/urfave/cli"
+)
+
+// The tools commands replace the old standalone utils. They use the global --key (RSA) or --did (DID) key,
+// and produce exactly what the servers accept: DID keys are hex encoded and signature requests are identified
+// by the double SHA256 of the DID public key.
+
+// toolsUseDID works out which key the tools command should use. Exactly one of --key or --did must be given.
+func toolsUseDID() bool {
+	hasRSA, hasDID := !PrivateKey.IsEmpty(), len(DidPrivateKey) != 0
+	switch {
+	case hasRSA && hasDID:
+		log.Fatal("Please specify either --key or --did, not both")
+	case !hasRSA && !hasDID:
+		log.Fatal("Please specify a key with --did (or an RSA key with --key)")
+	}
+	return hasDID
+}
+
+// readInput reads a file, or stdin if no file or "-" is given
+func readInput(filename string) []byte {
+	var (
+		content []byte
+		err     error
+	)
+	if filename == "" || filename == "-" {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	return content
+}
+
+// printResult prints the output of a tools command, without the trailing newline if -n was given
+func printResult(c *cli.Context, result string) {
+	if c.Bool("n") {
+		fmt.Print(result)
+	} else {
+		fmt.Println(result)
+	}
+}
+
+// actionToolsGetID prints the ID of a public key.
+// For a DID key this is the signature request ID. For an RSA key it is the hex encoded SHA256 of the base64 encoded key.
+func actionToolsGetID(c *cli.Context) error {
+	filename := c.Args().First()
+	if filename == "" {
+		if toolsUseDID() {
+			printResult(c, hex.EncodeToString(DidPublicKey.RequestID()))
+		} else {
+			printResult(c, string(PublicKey.GetSHA256()))
+		}
+		return nil
+	}
+
+	content := bytes.TrimSpace(readInput(filename))
+	if !bytes.HasPrefix(content, []byte("-----BEGIN")) {
+		raw, err := hex.DecodeString(string(content))
+		if err != nil {
+			log.Fatal(cryptoballot.ErrDIDPublicKeyInvalid)
+		}
+		didPub, err := cryptoballot.NewDIDPublicKey(raw)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printResult(c, hex.EncodeToString(didPub.RequestID()))
+		return nil
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		log.Fatal("Could not find PUBLIC KEY block in " + filename)
+	}
+	cryptoKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if rsaKey, ok := cryptoKey.(*rsa.PublicKey); ok {
+		publicKey, err := cryptoballot.NewPublicKeyFromCryptoKey(rsaKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printResult(c, string(publicKey.GetSHA256()))
+		return nil
+	}
+	didPub, err := cryptoballot.NewDIDPublicKeyFromPEM(content)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printResult(c, hex.EncodeToString(didPub.RequestID()))
+	return nil
+}
+
+// actionToolsPublicKey prints the public key of --did as hex, or of --key as base64
+func actionToolsPublicKey(c *cli.Context) error {
+	if toolsUseDID() {
+		printResult(c, DidPublicKey.Hex())
+	} else {
+		printResult(c, PublicKey.String())
+	}
+	return nil
+}
+
+// actionToolsSign prints the signature of a file, or of stdin.
+// DID signatures are hex encoded, as in the X-Signature header. RSA signatures are base64 encoded.
+func actionToolsSign(c *cli.Context) error {
+	useDID := toolsUseDID()
+	if c.Bool("naive") && useDID {
+		log.Fatal("The --naive option can only be used with an RSA key")
+	}
+	if c.Bool("sha256") && !c.Bool("naive") {
+		log.Fatal("You passed the --sha256 option without the --naive option. This is not allowed. By default normal non-naive signing automatically applies a SHA256 hash and as part of the RSA signing process.")
+	}
+
+	target := readInput(c.Args().First())
+
+	// Check for the common error of there being a trailing newline (0A) character.
+	if len(target) != 0 && target[len(target)-1] == 0x0A {
+		if c.Bool("d") {
+			target = target[:len(target)-1]
+		} else {
+			log.Println("Warning: Your input contains a trailing newline character (0A). You may want to run this again with the -d flag.")
+		}
+	}
+
+	if useDID {
+		signature, err := DidPrivateKey.SignString(string(target))
+		if err != nil {
+			log.Fatal(err)
+		}
+		printResult(c, hex.EncodeToString(signature))
+		return nil
+	}
+
+	var (
+		signature cryptoballot.Signature
+		err       error
+	)
+	if c.Bool("naive") {
+		if c.Bool("sha256") {
+			hash := sha256.Sum256(target)
+			target = hash[:]
+		}
+		signature, err = PrivateKey.SignRawBytes(target)
+	} else {
+		signature, err = PrivateKey.SignBytes(target)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	printResult(c, signature.String())
+	return nil
+}
+
+// actionToolsSignatureRequest blinds a ballot and prints a SignatureRequest for it, signed with the --did key.
+// The unblinder is printed to stderr, since it is needed to unblind the clerk's signature.
+func actionToolsSignatureRequest(c *cli.Context) error {
+	if len(DidPrivateKey) == 0 {
+		log.Fatal("Please specify the voter's DID key with --did. Signature requests can only be signed with a DID key")
+	}
+
+	rawBallot := readInput(c.Args().First())
+	ballot, err := cryptoballot.NewBallot(rawBallot)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Ballots in weighted elections are signed with the clerk's key for their denomination
+	denomination, err := ballot.Denomination()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Use the clerk key from a file if given, otherwise get it from the election clerk
+	var clerkPublicKey cryptoballot.PublicKey
+	if c.String("clerk-key") != "" {
+		content := bytes.TrimSpace(readInput(c.String("clerk-key")))
+		if block, _ := pem.Decode(content); block != nil {
+			clerkPublicKey, err = cryptoballot.NewPublicKeyFromBlock(block)
+		} else {
+			clerkPublicKey, err = cryptoballot.NewPublicKey(content)
+		}
+	} else if denomination != 1 {
+		var keys cryptoballot.DenominationKeys
+		if keys, err = BallotClerkClient.GetDenominationKeys(); err == nil {
+			clerkPublicKey, err = keys.Key(denomination)
+		}
+	} else {
+		clerkPublicKey, err = BallotClerkClient.GetPublicKey()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if denomination == 1 {
+		denomination = 0
+	}
+
+	prepared := &client.PreparedBallot{Ballot: ballot, Denomination: denomination, ClerkKey: clerkPublicKey}
+	prepared.BlindBallot, prepared.Unblinder, err = ballot.Blind(clerkPublicKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintln(os.Stderr, hex.EncodeToString(prepared.Unblinder))
+
+	signatureRequest, err := newVoter(c).NewSignatureRequest(prepared)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printResult(c, signatureRequest.String())
+	return nil
+}
+
+// actionToolsIssueCredential prints a verifiable credential issued to --subject, signed with the --did key.
+// Claims that look like numbers are issued as JSON numbers, so they can be used as vote weights.
+func actionToolsIssueCredential(c *cli.Context) error {
+	if len(DidPrivateKey) == 0 {
+		log.Fatal("Please specify the issuer's DID key with --did")
+	}
+	issuer, subject := c.String("issuer"), c.String("subject")
+	if !cryptoballot.ValidDIDString(issuer) || !cryptoballot.ValidDIDString(subject) {
+		log.Fatal("Please give the issuer and subject DIDs with --issuer and --subject")
+	}
+	if len(c.StringSlice("type")) == 0 {
+		log.Fatal("Please give the type of credential with --type")
+	}
+
+	claims := map[string]interface{}{}
+	for _, arg := range c.Args() {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[0] == "id" {
+			log.Fatal("Invalid claim " + arg + ". Claims must be given as name=value")
+		}
+		if _, err := strconv.ParseFloat(parts[1], 64); err == nil {
+			claims[parts[0]] = json.Number(parts[1])
+		} else {
+			claims[parts[0]] = parts[1]
+		}
+	}
+
+	id := c.String("id")
+	if id == "" {
+		uuid := make([]byte, 16)
+		if _, err := rand.Read(uuid); err != nil {
+			log.Fatal(err)
+		}
+		uuid[6], uuid[8] = uuid[6]&0x0f|0x40, uuid[8]&0x3f|0x80
+		id = fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+	}
+	issued := time.Now()
+	var expires time.Time
+	if c.Duration("valid-for") != 0 {
+		expires = issued.Add(c.Duration("valid-for"))
+	}
+
+	credential, err := cryptoballot.NewUnsignedCredential(id, issuer, subject, c.StringSlice("type"), claims, issued, expires)
+	if err != nil {
+		log.Fatal(err)
+	}
+	keyID := c.String("key-id")
+	if strings.HasPrefix(keyID, "#") {
+		keyID = issuer + keyID
+	}
+	if err = credential.Sign(DidPrivateKey, keyID); err != nil {
+		log.Fatal(err)
+	}
+	printResult(c, credential.String())
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"bytes"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
@@ -14,8 +16,15 @@ import (
 var (
 	ErrPutBallot = errors.New("ballotbox: Unable to PUT ballot")
 	ErrGetBallot = errors.New("ballotbox: Unable to GET ballot")
+
+	ErrGetTreeHead = errors.New("ballotbox: Unable to GET tree head")
+
+	ErrBallotStreamTruncated = errors.New("ballotbox: Ballot stream ended before all ballots were received")
 )
 
+// DefaultBallotPageSize is the number of ballots fetched per request when iterating over ballots
+const DefaultBallotPageSize = 500
+
 // Client provides access to the ballotclerk REST service
 type BallotBoxClient struct {
 	BaseURL    string
@@ -41,8 +50,8 @@ func (c *BallotBoxClient) PutBallot(ballot *cryptoballot.Ballot) error {
 		return errors.Wrap(err, ErrPutBallot)
 	}
 
-	// Handle errors
-	if resp.StatusCode != 200 {
+	// Handle errors. A ballotbox that mixes ballots responds with 202 Accepted, since the ballot will only be published later
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
 		details, _ := ioutil.ReadAll(resp.Body)
 		return errors.Appendf(ErrPutBallot, "ballotbox: %s - %s", resp.Status, details)
 	}
@@ -51,6 +60,54 @@ func (c *BallotBoxClient) PutBallot(ballot *cryptoballot.Ballot) error {
 	return nil
 }
 
+// PutBallots casts a batch of ballots for an election. It returns an error for each ballot, nil if it was accepted,
+// along with an error if the batch as a whole was refused.
+func (c *BallotBoxClient) PutBallots(electionID string, ballots []*cryptoballot.Ballot) ([]error, error) {
+	body := make([]string, len(ballots))
+	for i, ballot := range ballots {
+		body[i] = ballot.String()
+	}
+	resp, err := c.HTTPClient.Post(c.BaseURL+"/vote/"+electionID, "text/plain", strings.NewReader(strings.Join(body, "\n\n\n")))
+	defer ResponseDrainAndClose(resp)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrPutBallot)
+	}
+	if resp.StatusCode != http.StatusOK {
+		details, _ := ioutil.ReadAll(resp.Body)
+		return nil, errors.Appendf(ErrPutBallot, "ballotbox: %s - %s", resp.Status, details)
+	}
+
+	// Each line gives the ballot-id, the status the ballot was given, and the reason it was refused if it was
+	results := make([]error, 0, len(ballots))
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), " ", 3)
+		if len(parts) < 2 {
+			return nil, errors.Appendf(ErrPutBallot, "ballotbox: Invalid result %q", scanner.Text())
+		}
+		status, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, errors.Wrap(err, ErrPutBallot)
+		}
+		if status == http.StatusOK || status == http.StatusAccepted {
+			results = append(results, nil)
+			continue
+		}
+		message := ""
+		if len(parts) == 3 {
+			message = parts[2]
+		}
+		results = append(results, errors.Appendf(ErrPutBallot, "ballotbox: %d %s - %s", status, http.StatusText(status), message))
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, ErrPutBallot)
+	}
+	if len(results) != len(ballots) {
+		return nil, errors.Appendf(ErrPutBallot, "ballotbox: Expected %d results, received %d", len(ballots), len(results))
+	}
+	return results, nil
+}
+
 // GetBallot gets a single ballot from the ballotbox
 func (c *BallotBoxClient) GetBallot(electionID string, ballotID string) (*cryptoballot.Ballot, error) {
 	url := c.BaseURL + "/vote/" + electionID + "/" + ballotID
@@ -78,35 +135,163 @@ func (c *BallotBoxClient) GetBallot(electionID string, ballotID string) (*crypto
 	return election, nil
 }
 
-// GetAllBallots gets all ballots for an election
-func (c *BallotBoxClient) GetAllBallots(electionID string) ([]*cryptoballot.Ballot, error) {
-	url := c.BaseURL + "/vote/" + electionID
-	resp, err := c.HTTPClient.Get(url)
+// GetTreeHead gets the ballotbox's signed tree head over the ballots it has published for an election.
+// The signature is not verified.
+func (c *BallotBoxClient) GetTreeHead(electionID string) (*cryptoballot.TreeHead, error) {
+	resp, err := c.HTTPClient.Get(c.BaseURL + "/treehead/" + electionID)
 	defer ResponseDrainAndClose(resp)
 	if err != nil {
-		return nil, errors.Wrap(err, ErrGetBallot)
+		return nil, errors.Wrap(err, ErrGetTreeHead)
 	}
 
 	if resp.StatusCode != 200 {
 		details, _ := ioutil.ReadAll(resp.Body)
-		return nil, errors.Appendf(ErrGetBallot, "ballotbox: %s - %s", resp.Status, details)
+		return nil, errors.Appendf(ErrGetTreeHead, "ballotbox: %s - %s", resp.Status, details)
 	}
 
-	// There could be a large number of ballots, parse them as they come in.
-	scanner := bufio.NewScanner(resp.Body)
-	scanner.Split(scanBallots)
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGetTreeHead)
+	}
+
+	head, err := cryptoballot.NewTreeHead(body)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGetTreeHead)
+	}
 
+	return head, nil
+}
+
+// GetAllBallots gets all ballots for an election
+func (c *BallotBoxClient) GetAllBallots(electionID string) ([]*cryptoballot.Ballot, error) {
 	ballots := []*cryptoballot.Ballot{}
-	for scanner.Scan() {
-		bytes := scanner.Bytes()
-		ballot, err := cryptoballot.NewBallot(bytes)
-		if err != nil {
-			return nil, err
+	it := c.IterateBallots(electionID, DefaultBallotPageSize)
+	defer it.Close()
+	for it.Next() {
+		ballots = append(ballots, it.Ballot())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return ballots, nil
+}
+
+// BallotIterator streams the ballots for an election in ballot-id order, fetching one page at a time.
+// Ballots are parsed as they arrive, so only a single ballot is held in memory at once.
+//
+//	it := client.IterateBallots(electionID, util.DefaultBallotPageSize)
+//	defer it.Close()
+//	for it.Next() {
+//		ballot := it.Ballot()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type BallotIterator struct {
+	client     *BallotBoxClient
+	electionID string
+	pageSize   int
+	after      string
+	resp       *http.Response
+	scanner    *bufio.Scanner
+	ballot     *cryptoballot.Ballot
+	done       bool
+	err        error
+}
+
+// IterateBallots creates an iterator over all ballots for an election.
+// A pageSize of zero fetches every ballot in a single request.
+func (c *BallotBoxClient) IterateBallots(electionID string, pageSize int) *BallotIterator {
+	return &BallotIterator{client: c, electionID: electionID, pageSize: pageSize}
+}
+
+// Next advances to the next ballot. It returns false when there are no more ballots or an error occurred.
+func (it *BallotIterator) Next() bool {
+	for !it.done && it.err == nil {
+		if it.scanner == nil {
+			it.err = it.fetch()
+			continue
+		}
+
+		if it.scanner.Scan() {
+			ballot, err := cryptoballot.NewBallot(it.scanner.Bytes())
+			if err != nil {
+				it.err = errors.Wrap(err, ErrGetBallot)
+				return false
+			}
+			it.ballot = ballot
+			return true
+		}
+		if err := it.scanner.Err(); err != nil {
+			it.err = errors.Wrap(err, ErrGetBallot)
+			return false
+		}
+
+		// The page has been read in full, so the trailers are now available
+		status := it.resp.Trailer.Get("X-Stream-Status")
+		next := it.resp.Trailer.Get("X-Next-After")
+		it.Close()
+		if status != "complete" {
+			it.err = ErrBallotStreamTruncated
+		} else if next == "" {
+			it.done = true
+		} else {
+			it.after = next
 		}
-		ballots = append(ballots, ballot)
 	}
+	return false
+}
 
-	return ballots, nil
+// Ballot returns the current ballot
+func (it *BallotIterator) Ballot() *cryptoballot.Ballot {
+	return it.ballot
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *BallotIterator) Err() error {
+	return it.err
+}
+
+// Close releases the current page. It should be deferred.
+func (it *BallotIterator) Close() error {
+	if it.resp != nil {
+		ResponseDrainAndClose(it.resp)
+		it.resp = nil
+	}
+	it.scanner = nil
+	return nil
+}
+
+// fetch requests the next page of ballots
+func (it *BallotIterator) fetch() error {
+	query := url.Values{}
+	if it.pageSize != 0 {
+		query.Set("limit", strconv.Itoa(it.pageSize))
+	}
+	if it.after != "" {
+		query.Set("after", it.after)
+	}
+	u := it.client.BaseURL + "/vote/" + it.electionID
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+
+	resp, err := it.client.HTTPClient.Get(u)
+	if err != nil {
+		ResponseDrainAndClose(resp)
+		return errors.Wrap(err, ErrGetBallot)
+	}
+	if resp.StatusCode != 200 {
+		details, _ := ioutil.ReadAll(resp.Body)
+		ResponseDrainAndClose(resp)
+		return errors.Appendf(ErrGetBallot, "ballotbox: %s - %s", resp.Status, details)
+	}
+
+	it.resp = resp
+	it.scanner = bufio.NewScanner(resp.Body)
+	it.scanner.Buffer(make([]byte, 4096), cryptoballot.MaxBallotSize+3)
+	it.scanner.Split(scanBallots)
+	return nil
 }
 
 // ResponseDrainAndClose drains a response of it's body and closes it
@@ -1,11 +1,14 @@
 package util
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/pem"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/phayes/errors"
@@ -15,10 +18,15 @@ import (
 
 var (
 	ErrGetPublicKey         = errors.New("ballotclerk: Unable to GET public signing key")
+	ErrGetDenominationKeys  = errors.New("ballotclerk: Unable to GET public signing keys by denomination")
 	ErrMisingPEMBLock       = errors.New("ballotclerk: Missing PEM Block")
 	ErrPutElection          = errors.New("ballotclerk: Unable to PUT election")
 	ErrGetElection          = errors.New("ballotclerk: Unable to GET election")
 	ErrPostSignatureRequest = errors.New("ballotclerk: Unable to POST signature request")
+	ErrGetSignatureRequests = errors.New("ballotclerk: Unable to GET fulfilled signature requests")
+	ErrPutResult            = errors.New("ballotclerk: Unable to PUT election result")
+	ErrGetResult            = errors.New("ballotclerk: Unable to GET election result")
+	ErrGetLog               = errors.New("ballotclerk: Unable to GET audit log")
 )
 
 // Client provides access to the ballotclerk REST service
@@ -64,6 +72,33 @@ func (c *BallotclerkClient) GetPublicKey() (cryptoballot.PublicKey, error) {
 	return pubKey, nil
 }
 
+// GetDenominationKeys gets the ballot clerk's public signing keys for each denomination, used in weighted elections
+func (c *BallotclerkClient) GetDenominationKeys() (cryptoballot.DenominationKeys, error) {
+	url := c.BaseURL + "/publickeys"
+	resp, err := c.HTTPClient.Get(url)
+	defer ResponseDrainAndClose(resp)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGetDenominationKeys)
+	}
+
+	if resp.StatusCode != 200 {
+		details, _ := ioutil.ReadAll(resp.Body)
+		return nil, errors.Appendf(ErrGetDenominationKeys, "ballotclerk: %v - %v", resp.Status, details)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGetDenominationKeys)
+	}
+
+	keys, err := cryptoballot.NewDenominationKeys(body)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGetDenominationKeys)
+	}
+
+	return keys, nil
+}
+
 // PutElection creates a new election
 func (c *BallotclerkClient) PutElection(election *cryptoballot.Election, privKey cryptoballot.DIDPrivateKey) error {
 	// Prepare to PUT the election to the Election Clerk server
@@ -176,6 +211,144 @@ func (c *BallotclerkClient) PostSignatureRequest(signatureRequest *cryptoballot.
 	return fulfilledReq, nil
 }
 
+// GetAllSignatureRequests gets all the fulfilled signature requests for an election.
+// The ballotclerk only publishes these once the election has ended.
+func (c *BallotclerkClient) GetAllSignatureRequests(electionID string) ([]*cryptoballot.FulfilledSignatureRequest, error) {
+	url := c.BaseURL + "/sigs/" + electionID
+	resp, err := c.HTTPClient.Get(url)
+	defer ResponseDrainAndClose(resp)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGetSignatureRequests)
+	}
+
+	if resp.StatusCode != 200 {
+		details, _ := ioutil.ReadAll(resp.Body)
+		return nil, errors.Appendf(ErrGetSignatureRequests, "ballotclerk: %v - %v", resp.Status, details)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGetSignatureRequests)
+	}
+
+	fulfilled := []*cryptoballot.FulfilledSignatureRequest{}
+	if len(body) == 0 {
+		return fulfilled, nil
+	}
+	for _, raw := range bytes.Split(body, []byte("\n\n\n")) {
+		sigReq, err := cryptoballot.NewFulfilledSignatureRequest(raw)
+		if err != nil {
+			return nil, errors.Wrap(err, ErrGetSignatureRequests)
+		}
+		fulfilled = append(fulfilled, sigReq)
+	}
+
+	return fulfilled, nil
+}
+
+// PutResult publishes the signed result of an election. Only the election's admin may publish it, once the election has ended.
+func (c *BallotclerkClient) PutResult(result *cryptoballot.ElectionResult, privKey cryptoballot.DIDPrivateKey) error {
+	path := "/election/" + result.ElectionID + "/result"
+	req, err := http.NewRequest("PUT", c.BaseURL+path, strings.NewReader(result.String()))
+	if err != nil {
+		return errors.Wrap(err, ErrPutResult)
+	}
+	reqSig, err := privKey.SignString("PUT " + path)
+	if err != nil {
+		return errors.Wrap(err, ErrPutResult)
+	}
+	pubKey, err := privKey.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		return errors.Wrap(err, ErrPutResult)
+	}
+
+	// Add authentication headers
+	req.Header.Add("X-Public-Key", hex.EncodeToString(pubKey.Bytes()))
+	req.Header.Add("X-Signature", hex.EncodeToString(reqSig))
+
+	// Do the request
+	resp, err := c.HTTPClient.Do(req)
+	defer ResponseDrainAndClose(resp)
+	if err != nil {
+		return errors.Wrap(err, ErrPutResult)
+	}
+
+	// Handle errors
+	if resp.StatusCode != 200 {
+		details, _ := ioutil.ReadAll(resp.Body)
+		return errors.Appendf(ErrPutResult, "ballotclerk: %v - %v", resp.Status, details)
+	}
+
+	// Success
+	return nil
+}
+
+// GetResult gets the published result of an election. The result's signature is not verified.
+func (c *BallotclerkClient) GetResult(electionID string) (*cryptoballot.ElectionResult, error) {
+	url := c.BaseURL + "/election/" + electionID + "/result"
+	resp, err := c.HTTPClient.Get(url)
+	defer ResponseDrainAndClose(resp)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGetResult)
+	}
+
+	if resp.StatusCode != 200 {
+		details, _ := ioutil.ReadAll(resp.Body)
+		return nil, errors.Appendf(ErrGetResult, "ballotclerk: %v - %v", resp.Status, details)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGetResult)
+	}
+
+	result, err := cryptoballot.NewElectionResult(body)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGetResult)
+	}
+
+	return result, nil
+}
+
+// GetLog gets a segment of the ballotclerk's audit log, starting at the entry with sequence number from.
+// A limit of zero gets the rest of the log. The entries are not verified. See cryptoballot.VerifyAuditLog
+func (c *BallotclerkClient) GetLog(from uint64, limit int) ([]*cryptoballot.LogEntry, error) {
+	query := url.Values{}
+	query.Set("from", strconv.FormatUint(from, 10))
+	if limit != 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	resp, err := c.HTTPClient.Get(c.BaseURL + "/log?" + query.Encode())
+	defer ResponseDrainAndClose(resp)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGetLog)
+	}
+
+	if resp.StatusCode != 200 {
+		details, _ := ioutil.ReadAll(resp.Body)
+		return nil, errors.Appendf(ErrGetLog, "ballotclerk: %v - %v", resp.Status, details)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGetLog)
+	}
+
+	entries := []*cryptoballot.LogEntry{}
+	if len(body) == 0 {
+		return entries, nil
+	}
+	for _, raw := range bytes.Split(body, []byte("\n\n\n")) {
+		entry, err := cryptoballot.NewLogEntry(raw)
+		if err != nil {
+			return nil, errors.Wrap(err, ErrGetLog)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
 // ResponseDrainAndClose drains a response of it's body and closes it
 // It should be used in a defer statement when doing an HTTP request
 func ResponseDrainAndClose(resp *http.Response) {
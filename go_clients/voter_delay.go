@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/phayes/errors"
+)
+
+// A voter who casts their ballot immediately after receiving a signature from the election clerk
+// can be linked to their ballot by anyone who can see both the clerk's and the ballotbox's logs.
+// To break this link the voter may wait a random amount of time between getting their ballot signed
+// and submitting it. While waiting, the signed ballot is kept in a pending file so that the
+// submission survives restarts without having to request a second signature.
+//
+// In a weighted election each denomination's ballot gets its own random delay, and they all wait in the
+// pending file. Ballots are removed from the file as they are cast, so a run that fails part way through
+// can be resumed without casting any ballot twice.
+//
+// The pending file holds one or more pending ballots, separated by a triple line break, each in the format:
+//
+//   <submit-at (RFC 3339)>
+//
+//   <signed-ballot>
+
+var (
+	ErrPendingInvalid = errors.New("Invalid pending ballot file")
+	ErrPendingTime    = errors.New("Cannot parse submission time in pending ballot file")
+	ErrDelayNegative  = errors.New("Delay must not be negative")
+)
+
+// randomDelay picks a uniformly random delay between zero and max
+func randomDelay(max time.Duration) (time.Duration, error) {
+	if max < 0 {
+		return 0, ErrDelayNegative
+	}
+	if max == 0 {
+		return 0, nil
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(n.Int64()), nil
+}
+
+// pendingBallot is a signed ballot waiting to be submitted
+type pendingBallot struct {
+	SubmitAt time.Time
+	Ballot   *cryptoballot.Ballot
+}
+
+// savePendingBallots writes signed ballots and the times they should be submitted to the pending file, or removes
+// the file if there are none left. The file is written to a temporary location first so that an interrupted write
+// never leaves a corrupt file behind.
+func savePendingBallots(path string, pending []pendingBallot) error {
+	if len(pending) == 0 {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	entries := make([]string, len(pending))
+	for i, p := range pending {
+		entries[i] = p.SubmitAt.UTC().Format(time.RFC3339) + "\n\n" + p.Ballot.String()
+	}
+	tmp := path + ".tmp"
+	err := ioutil.WriteFile(tmp, []byte(strings.Join(entries, "\n\n\n")), 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadPendingBallots reads a pending file. If the file does not exist no ballots are returned.
+func loadPendingBallots(path string) ([]pendingBallot, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var pending []pendingBallot
+	for _, entry := range bytes.Split(content, []byte("\n\n\n")) {
+		parts := bytes.SplitN(entry, []byte("\n\n"), 2)
+		if len(parts) != 2 {
+			return nil, ErrPendingInvalid
+		}
+
+		submitAt, err := time.Parse(time.RFC3339, string(parts[0]))
+		if err != nil {
+			return nil, errors.Wrap(err, ErrPendingTime)
+		}
+
+		ballot, err := cryptoballot.NewBallot(parts[1])
+		if err != nil {
+			return nil, errors.Wrap(err, ErrPendingInvalid)
+		}
+		pending = append(pending, pendingBallot{SubmitAt: submitAt, Ballot: ballot})
+	}
+	return pending, nil
+}
@@ -1 +1,37 @@
 package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/urfave/cli"
+)
+
+// actionVoterVerify checks that the ballotbox, and every mirror given with --mirror, publishes the voter's ballot
+// exactly as it was cast, with a valid signature from the election clerk
+func actionVoterVerify(c *cli.Context) error {
+	filename := c.Args().First()
+	if filename == "" {
+		log.Fatal("Please specify the ballot file that was voted")
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ballot, err := cryptoballot.NewBallot(content)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = newVoter(c).Verify(context.Background(), ballot)
+	if err != nil {
+		fmt.Println("verify: " + err.Error())
+		return cli.NewExitError("", 1)
+	}
+	fmt.Println("verify: ballot " + ballot.BallotID + " is published and counted")
+	return nil
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
-	"github.com/elastos/Elastos.ELA.Utility/common"
+	"context"
 	"io/ioutil"
 	"log"
+	"sort"
+	"time"
 
 	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot/client"
 	"github.com/urfave/cli"
 )
 
@@ -16,14 +19,34 @@ func actionVoterVote(c *cli.Context) error {
 		log.Fatal("Please specify an balliot file to PUT to the ballotbox server")
 	}
 
+	pendingFile := c.String("pending")
+	if pendingFile == "" {
+		pendingFile = filename + ".pending"
+	}
+
+	// If a previous run left signed ballots waiting to be submitted, resume them
+	pending, err := loadPendingBallots(pendingFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(pending) != 0 {
+		log.Println("Resuming pending ballot submission from " + pendingFile)
+		submitPendingBallots(newVoter(c), pendingFile, pending)
+		return nil
+	}
+
 	if PrivateKey == nil {
 		log.Fatal("Please specify a private key pem file with --key (eg: `--key=path/to/mykey.pem`)")
 	}
 
 	if len(DidPrivateKey) != 32 {
-		log.Fatal("Please specify a did private key with --didKey (eg: `--didKey=CC6FA0F0E191AD47A430FE04411C079F07D5C1EE47C3AA55F0E0204C8FE36D17`)")
+		log.Fatal("Please specify a DID key from the keystore with --did (eg: `--did=mykey`). See `cryptoballot key generate`")
 	}
 
+	delay, err := randomDelay(c.Duration("delay"))
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	content, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -35,49 +58,159 @@ func actionVoterVote(c *cli.Context) error {
 		log.Fatal(err)
 	}
 
-	// Get public key from ballotclerk server
-	clerkPublicKey, err := BallotClerkClient.GetPublicKey()
+	voter := newVoter(c)
+	ctx := context.Background()
+	if weight := c.Uint64("weight"); weight != 0 {
+		voteWeighted(ctx, voter, ballot, weight, c.Duration("delay"), pendingFile)
+		return nil
+	}
+
+	// Blind the ballot with the clerk's public key and have the clerk sign it
+	prepared, err := voter.PrepareBallot(ctx, ballot, 0)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	// Blind the ballot
-	blindBallot, unblinder, err := ballot.Blind(clerkPublicKey)
+	fulfilled, err := voter.RequestSignature(ctx, prepared)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// Unblind the ballot using the FulfilledSignatureRequest
+	ballot, err = voter.Unblind(prepared, fulfilled)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Create a signature request
-	reqId := common.Sha256D(DidPublicKey.Bytes())
-	signatureRequest := &cryptoballot.SignatureRequest{
-		ElectionID:  ballot.ElectionID,
-		RequestID:   reqId[:],
-		PublicKey:   DidPublicKey.Bytes(),
-		BlindBallot: blindBallot,
+	// If we are not delaying, PUT the ballot right away
+	if delay == 0 {
+		err = castBallot(voter, ballot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return nil
 	}
-	// replace it with DID privatekey
-	signatureRequest.Signature, err = DidPrivateKey.SignString(signatureRequest.String())
+
+	// Otherwise save the signed ballot so that the submission survives a restart, then wait
+	pending = []pendingBallot{{SubmitAt: time.Now().Add(delay), Ballot: ballot}}
+	err = savePendingBallots(pendingFile, pending)
 	if err != nil {
 		log.Fatal(err)
 	}
-	// Do the signature request
-	fulfilled, err := BallotClerkClient.PostSignatureRequest(signatureRequest, DidPrivateKey)
+	submitPendingBallots(voter, pendingFile, pending)
+
+	return nil
+}
+
+// voteWeighted casts a ballot with the given weight in a weighted election. The weight is split into the
+// denominations of the election clerk's keys, and a copy of the ballot with a random ballot-id is signed for each,
+// so that each ballot looks the same as every other ballot of its denomination. Each signed ballot is kept in the
+// pending file as soon as it is signed, and is cast after its own random delay of up to maxDelay.
+func voteWeighted(ctx context.Context, voter *client.Voter, ballot *cryptoballot.Ballot, weight uint64, maxDelay time.Duration, pendingFile string) {
+	keys, err := BallotClerkClient.GetDenominationKeys()
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	// Unblind the ballot using the FulfilledSignatureRequest
-	err = ballot.Unblind(clerkPublicKey, fulfilled.BallotSignature, unblinder)
+	denominations, err := keys.Split(weight)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// PUT the ballot
-	err = BallotBoxClient.PutBallot(ballot)
+	var pending []pendingBallot
+	for _, denomination := range denominations {
+		signed, err := signDenomination(ctx, voter, ballot, denomination)
+		if err != nil {
+			if len(pending) != 0 {
+				log.Printf("%d of %d ballots were signed before the error, and are kept in %s. Running again casts them", len(pending), len(denominations), pendingFile)
+			}
+			log.Fatal(err)
+		}
+		delay, err := randomDelay(maxDelay)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pending = append(pending, pendingBallot{SubmitAt: time.Now().Add(delay), Ballot: signed})
+		if err = savePendingBallots(pendingFile, pending); err != nil {
+			log.Fatal(err)
+		}
+	}
+	submitPendingBallots(voter, pendingFile, pending)
+}
+
+// signDenomination has a copy of the ballot with a random ballot-id signed with the clerk's key for the denomination
+func signDenomination(ctx context.Context, voter *client.Voter, ballot *cryptoballot.Ballot, denomination uint64) (*cryptoballot.Ballot, error) {
+	part := *ballot
+	var err error
+	part.BallotID, err = client.NewBallotID()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	prepared, err := voter.PrepareBallot(ctx, &part, denomination)
+	if err != nil {
+		return nil, err
+	}
+	fulfilled, err := voter.RequestSignature(ctx, prepared)
+	if err != nil {
+		return nil, err
 	}
+	return voter.Unblind(prepared, fulfilled)
+}
 
-	return nil
+// submitPendingBallots PUTs each pending ballot once its submission time comes, in order, and removes it from the
+// pending file once it is cast. The file is removed once every ballot is cast.
+func submitPendingBallots(voter *client.Voter, pendingFile string, pending []pendingBallot) {
+	sort.Slice(pending, func(i, j int) bool { return pending[i].SubmitAt.Before(pending[j].SubmitAt) })
+	for len(pending) != 0 {
+		next := pending[0]
+		if wait := time.Until(next.SubmitAt); wait > 0 {
+			log.Println("Ballot will be submitted at " + next.SubmitAt.Format(time.RFC3339))
+			time.Sleep(wait)
+		}
+
+		err := castBallot(voter, next.Ballot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if weight, err := next.Ballot.Denomination(); err == nil && weight != 1 {
+			log.Printf("Cast ballot %s with a weight of %d", next.Ballot.BallotID, weight)
+		}
+
+		pending = pending[1:]
+		err = savePendingBallots(pendingFile, pending)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// newVoter creates a voter with the --did key, who casts ballots to the ballotbox and every mirror given with
+// --mirror. If the voter gives their DID with --voter-did, it identifies them instead of their public key, so they
+// may rotate their keys. Any credential files given with --credential are presented to the election clerk, which
+// requires the voter's DID.
+func newVoter(c *cli.Context) *client.Voter {
+	var boxURLs []string
+	for _, box := range ballotBoxes() {
+		boxURLs = append(boxURLs, box.BaseURL)
+	}
+	voter := client.NewVoter(DidPrivateKey, BallotClerkClient.BaseURL, boxURLs...)
+
+	voter.DID = c.String("voter-did")
+	if voter.DID != "" && !cryptoballot.ValidDIDString(voter.DID) {
+		log.Fatal(cryptoballot.ErrDIDInvalid)
+	}
+	credentialFiles := c.StringSlice("credential")
+	if len(credentialFiles) != 0 && voter.DID == "" {
+		log.Fatal("Please give your DID with --voter-did to present credentials")
+	}
+	for _, filename := range credentialFiles {
+		rawCredential, err := ioutil.ReadFile(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		credential, err := cryptoballot.NewCredential(rawCredential)
+		if err != nil {
+			log.Fatal(err)
+		}
+		voter.Credentials = append(voter.Credentials, credential)
+	}
+	return voter
 }
@@ -0,0 +1,43 @@
+/**
+ * Copyright (c) 2017-2019 The Elastos Developers
+ * <p>
+ * Distributed under the MIT software license, see the accompanying file
+ * LICENSE or https://opensource.org/licenses/mit-license.php
+ */
+package org.elastos.client;
+
+import org.elastos.crypto.Ballot;
+
+/**
+ * Client for a ballotbox, which publishes signed ballots
+ */
+public class BallotBoxClient {
+
+    private final String baseURL;
+
+    public BallotBoxClient(String baseURL) {
+        this.baseURL = baseURL.replaceAll("/+$", "");
+    }
+
+    public String getBaseURL() {
+        return baseURL;
+    }
+
+    /**
+     * PUT a signed ballot. A ballotbox that mixes ballots accepts it with 202, and publishes it later.
+     */
+    public void putBallot(Ballot ballot) throws Exception {
+        Http.request("PUT", ballotURL(ballot.getElectionId(), ballot.getBallotId()), ballot.string(), null);
+    }
+
+    /**
+     * Get a published ballot. Its signature is not verified.
+     */
+    public Ballot getBallot(String electionId, String ballotId) throws Exception {
+        return new Ballot(Http.request("GET", ballotURL(electionId, ballotId), null, null));
+    }
+
+    private String ballotURL(String electionId, String ballotId) {
+        return baseURL + "/vote/" + electionId + "/" + ballotId;
+    }
+}
@@ -0,0 +1,86 @@
+/**
+ * Copyright (c) 2017-2019 The Elastos Developers
+ * <p>
+ * Distributed under the MIT software license, see the accompanying file
+ * LICENSE or https://opensource.org/licenses/mit-license.php
+ */
+package org.elastos.client;
+
+import org.apache.commons.codec.binary.Hex;
+import org.bouncycastle.util.io.pem.PemHeader;
+import org.bouncycastle.util.io.pem.PemObject;
+import org.bouncycastle.util.io.pem.PemReader;
+import org.elastos.crypto.DIDPrivateKey;
+import org.elastos.crypto.FulfilledSignatureRequest;
+import org.elastos.crypto.PublicKey;
+import org.elastos.crypto.SignatureRequest;
+
+import java.io.StringReader;
+import java.util.HashMap;
+import java.util.Map;
+
+/**
+ * Client for the election clerk, which blind signs voters' ballots
+ */
+public class BallotClerkClient {
+
+    public static final Exception ErrDenominationKeysInvalid = new Exception("Cannot parse denomination keys. Invalid format");
+
+    private final String baseURL;
+
+    public BallotClerkClient(String baseURL) {
+        this.baseURL = baseURL.replaceAll("/+$", "");
+    }
+
+    public String getBaseURL() {
+        return baseURL;
+    }
+
+    /**
+     * Get the clerk's key for signing ballots
+     */
+    public PublicKey getPublicKey() throws Exception {
+        return new PublicKey(Http.request("GET", baseURL + "/publickey", null, null).getBytes("UTF-8"));
+    }
+
+    /**
+     * Get the clerk's keys for signing ballots in weighted elections, by denomination. They are a series of PUBLIC
+     * KEY PEM blocks, each with a Denomination header. A block without the header is denomination 1.
+     */
+    public Map<Long, PublicKey> getDenominationKeys() throws Exception {
+        String body = Http.request("GET", baseURL + "/publickeys", null, null);
+        Map<Long, PublicKey> keys = new HashMap<>();
+        try (PemReader reader = new PemReader(new StringReader(body))) {
+            PemObject block;
+            while ((block = reader.readPemObject()) != null) {
+                if (!"PUBLIC KEY".equals(block.getType())) {
+                    throw new Exception(ErrDenominationKeysInvalid.getMessage() + ": found unexpected " + block.getType() + " block");
+                }
+                long denomination = 1;
+                for (Object header : block.getHeaders()) {
+                    if ("Denomination".equals(((PemHeader) header).getName())) {
+                        denomination = Long.parseUnsignedLong(((PemHeader) header).getValue());
+                    }
+                }
+                if (denomination == 0 || keys.put(denomination, PublicKey.fromDER(block.getContent())) != null) {
+                    throw new Exception(ErrDenominationKeysInvalid.getMessage());
+                }
+            }
+        }
+        if (keys.isEmpty()) {
+            throw new Exception(ErrDenominationKeysInvalid.getMessage());
+        }
+        return keys;
+    }
+
+    /**
+     * POST a signed Signature Request, getting back the clerk's signature of the blind ballot. The request is
+     * authenticated with X-Public-Key and X-Signature headers made with the voter's DID key.
+     */
+    public FulfilledSignatureRequest postSignatureRequest(SignatureRequest signatureRequest, DIDPrivateKey key) throws Exception {
+        Map<String, String> headers = new HashMap<>();
+        headers.put("X-Public-Key", key.publicKey().hex());
+        headers.put("X-Signature", Hex.encodeHexString(key.sign("POST /sign")));
+        return new FulfilledSignatureRequest(Http.request("POST", baseURL + "/sign", signatureRequest.string(), headers));
+    }
+}
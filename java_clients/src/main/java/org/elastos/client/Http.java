@@ -0,0 +1,88 @@
+/**
+ * Copyright (c) 2017-2019 The Elastos Developers
+ * <p>
+ * Distributed under the MIT software license, see the accompanying file
+ * LICENSE or https://opensource.org/licenses/mit-license.php
+ */
+package org.elastos.client;
+
+import java.io.ByteArrayOutputStream;
+import java.io.InputStream;
+import java.io.OutputStream;
+import java.net.HttpURLConnection;
+import java.net.URL;
+import java.nio.charset.StandardCharsets;
+import java.util.Map;
+import java.util.regex.Matcher;
+import java.util.regex.Pattern;
+
+/**
+ * The HTTP requests shared by the clients. Bodies are in the text formats.
+ */
+class Http {
+
+    static final int MaxResponseSize = 1024 * 1024;
+
+    static int ConnectTimeout = 10000; // Milliseconds
+    static int ReadTimeout    = 30000; // Milliseconds
+
+    private static final Pattern errorCode    = Pattern.compile("\"code\"\\s*:\\s*\"([^\"]*)\"");
+    private static final Pattern errorMessage = Pattern.compile("\"message\"\\s*:\\s*\"((?:[^\"\\\\]|\\\\.)*)\"");
+
+    /**
+     * Make a request and get the response body. 200 and 202 are successful, and any other status is a ServerException.
+     */
+    static String request(String method, String url, String body, Map<String, String> headers) throws Exception {
+        HttpURLConnection conn = (HttpURLConnection) new URL(url).openConnection();
+        conn.setRequestMethod(method);
+        conn.setConnectTimeout(ConnectTimeout);
+        conn.setReadTimeout(ReadTimeout);
+        if (headers != null) {
+            for (Map.Entry<String, String> header : headers.entrySet()) {
+                conn.setRequestProperty(header.getKey(), header.getValue());
+            }
+        }
+        try {
+            if (body != null) {
+                conn.setDoOutput(true);
+                conn.setRequestProperty("Content-Type", "text/plain; charset=utf-8");
+                try (OutputStream out = conn.getOutputStream()) {
+                    out.write(body.getBytes(StandardCharsets.UTF_8));
+                }
+            }
+            int status = conn.getResponseCode();
+            if (status == HttpURLConnection.HTTP_OK || status == HttpURLConnection.HTTP_ACCEPTED) {
+                return read(conn.getInputStream());
+            }
+            String details = read(conn.getErrorStream());
+            throw new ServerException(method, url, status, find(errorCode, details), find(errorMessage, details));
+        } finally {
+            conn.disconnect();
+        }
+    }
+
+    private static String read(InputStream in) throws Exception {
+        if (in == null) {
+            return "";
+        }
+        try {
+            ByteArrayOutputStream out = new ByteArrayOutputStream();
+            byte[] buf = new byte[4096];
+            int n;
+            while ((n = in.read(buf)) != -1) {
+                if (out.size() + n > MaxResponseSize) {
+                    throw new Exception("Response is larger than " + MaxResponseSize + " bytes");
+                }
+                out.write(buf, 0, n);
+            }
+            return new String(out.toByteArray(), StandardCharsets.UTF_8);
+        } finally {
+            in.close();
+        }
+    }
+
+    private static String find(Pattern pattern, String s) {
+        Matcher m = pattern.matcher(s);
+        return m.find() ? m.group(1) : "";
+    }
+}
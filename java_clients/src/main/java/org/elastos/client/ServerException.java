@@ -0,0 +1,31 @@
+/**
+ * Copyright (c) 2017-2019 The Elastos Developers
+ * <p>
+ * Distributed under the MIT software license, see the accompanying file
+ * LICENSE or https://opensource.org/licenses/mit-license.php
+ */
+package org.elastos.client;
+
+/**
+ * An error response from the election clerk or a ballotbox. The code is the same as the Go client's server errors,
+ * for example "duplicate" when the clerk has already signed for the voter, or "closed" when the election has ended.
+ */
+public class ServerException extends Exception {
+
+    private final int status;
+    private final String code;
+
+    public ServerException(String method, String url, int status, String code, String message) {
+        super(method + " " + url + ": " + status + " " + code + " - " + message);
+        this.status = status;
+        this.code = code;
+    }
+
+    public int getStatus() {
+        return status;
+    }
+
+    public String getCode() {
+        return code;
+    }
+}
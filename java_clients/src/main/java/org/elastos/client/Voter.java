@@ -0,0 +1,166 @@
+/**
+ * Copyright (c) 2017-2019 The Elastos Developers
+ * <p>
+ * Distributed under the MIT software license, see the accompanying file
+ * LICENSE or https://opensource.org/licenses/mit-license.php
+ */
+package org.elastos.client;
+
+import org.elastos.crypto.Ballot;
+import org.elastos.crypto.DIDPrivateKey;
+import org.elastos.crypto.FulfilledSignatureRequest;
+import org.elastos.crypto.PublicKey;
+import org.elastos.crypto.RSABlind;
+import org.elastos.crypto.SignatureRequest;
+
+import java.util.ArrayList;
+import java.util.Arrays;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+
+/**
+ * A voter, who has their ballot blind signed by the election clerk and casts it to one or more ballotboxes. This is
+ * the same flow as the Go client package and `cryptoballot voter vote`:
+ * <pre>
+ * Voter voter = new Voter(key, "https://clerk.example.org", "https://ballotbox.example.org");
+ * Voter.PreparedBallot prepared = voter.prepareBallot(ballot, 0);
+ * FulfilledSignatureRequest fulfilled = voter.requestSignature(prepared);
+ * Ballot signed = voter.unblind(prepared, fulfilled);
+ * voter.cast(signed);
+ * </pre>
+ */
+public class Voter {
+
+    public static final Exception ErrWrongResponse = new Exception("The election clerk returned a different Signature Request");
+    public static final Exception ErrBadSignature  = new Exception("The election clerk's signature does not verify");
+    public static final Exception ErrNoBallotBox   = new Exception("No ballotbox to cast the ballot to");
+
+    private final DIDPrivateKey key;
+    private final BallotClerkClient clerk;
+    private final List<BallotBoxClient> boxes = new ArrayList<>();
+    private String did = "";          // The voter's DID. If given it identifies the voter instead of their key
+    private String presentation = ""; // A verifiable presentation of the voter's credentials, as compact JSON. Requires the DID
+
+    /**
+     * A ballot blinded with the clerk's key, ready to be signed
+     */
+    public static class PreparedBallot {
+        public final Ballot ballot;
+        public final long denomination; // Zero outside weighted elections
+        public final PublicKey clerkKey;
+        public final RSABlind.Blinded blinded;
+
+        PreparedBallot(Ballot ballot, long denomination, PublicKey clerkKey, RSABlind.Blinded blinded) {
+            this.ballot = ballot;
+            this.denomination = denomination;
+            this.clerkKey = clerkKey;
+            this.blinded = blinded;
+        }
+    }
+
+    public Voter(DIDPrivateKey key, String clerkURL, String... boxURLs) {
+        this.key = key;
+        this.clerk = new BallotClerkClient(clerkURL);
+        for (String boxURL : boxURLs) {
+            boxes.add(new BallotBoxClient(boxURL));
+        }
+    }
+
+    public void setDid(String did) {
+        this.did = did == null ? "" : did;
+    }
+
+    public void setPresentation(String presentation) {
+        this.presentation = presentation == null ? "" : presentation;
+    }
+
+    /**
+     * Blind the ballot with the clerk's key. In a weighted election give the ballot's denomination, which tags the
+     * ballot and picks the clerk's key for that denomination. Otherwise give zero.
+     */
+    public PreparedBallot prepareBallot(Ballot ballot, long denomination) throws Exception {
+        PublicKey clerkKey;
+        if (denomination == 0) {
+            clerkKey = clerk.getPublicKey();
+        } else {
+            ballot.setDenomination(denomination);
+            clerkKey = clerk.getDenominationKeys().get(denomination);
+            if (clerkKey == null) {
+                throw new Exception("The election clerk has no key for denomination " + Long.toUnsignedString(denomination));
+            }
+        }
+        return new PreparedBallot(ballot, denomination, clerkKey, ballot.blind(clerkKey));
+    }
+
+    /**
+     * Make a Signature Request for the prepared ballot, signed with the voter's key
+     */
+    public SignatureRequest newSignatureRequest(PreparedBallot prepared) throws Exception {
+        SignatureRequest sigReq = new SignatureRequest(prepared.ballot.getElectionId(), key.publicKey(), did, presentation,
+                prepared.denomination, prepared.blinded.blinded);
+        sigReq.sign(key);
+        return sigReq;
+    }
+
+    /**
+     * Have the clerk sign the prepared ballot. The clerk's response is checked against the request, and its
+     * signature against the clerk's key.
+     */
+    public FulfilledSignatureRequest requestSignature(PreparedBallot prepared) throws Exception {
+        SignatureRequest sigReq = newSignatureRequest(prepared);
+        FulfilledSignatureRequest fulfilled = clerk.postSignatureRequest(sigReq, key);
+        if (!fulfilled.getSignatureRequest().stringWithoutSignature().equals(sigReq.stringWithoutSignature())) {
+            throw new Exception(ErrWrongResponse.getMessage());
+        }
+        if (!fulfilled.verifyBallotSignature(prepared.clerkKey)) {
+            throw new Exception(ErrBadSignature.getMessage());
+        }
+        return fulfilled;
+    }
+
+    /**
+     * Unblind the clerk's signature, giving the signed ballot
+     */
+    public Ballot unblind(PreparedBallot prepared, FulfilledSignatureRequest fulfilled) throws Exception {
+        prepared.ballot.unblind(prepared.clerkKey, fulfilled.getBallotSignature(), prepared.blinded.unblinder);
+        return prepared.ballot;
+    }
+
+    /**
+     * Cast the signed ballot to every ballotbox. It is enough for one of them to accept it, since mirrors pass the
+     * ballots they accept on to each other. The ballotboxes that refused it are returned with their errors.
+     */
+    public Map<String, Exception> cast(Ballot ballot) throws Exception {
+        if (boxes.isEmpty()) {
+            throw new Exception(ErrNoBallotBox.getMessage());
+        }
+        Map<String, Exception> refused = new LinkedHashMap<>();
+        for (BallotBoxClient box : boxes) {
+            try {
+                box.putBallot(ballot);
+            } catch (Exception ex) {
+                refused.put(box.getBaseURL(), ex);
+            }
+        }
+        if (refused.size() == boxes.size()) {
+            throw refused.values().iterator().next();
+        }
+        return refused;
+    }
+
+    /**
+     * Check that every ballotbox publishes the ballot exactly as it was cast, signed with the clerk's key
+     */
+    public boolean verify(Ballot ballot, PublicKey clerkKey) throws Exception {
+        for (BallotBoxClient box : boxes) {
+            Ballot published = box.getBallot(ballot.getElectionId(), ballot.getBallotId());
+            if (!published.stringWithoutSignature().equals(ballot.stringWithoutSignature()) ||
+                    (ballot.hasSignature() && !Arrays.equals(published.getSignature(), ballot.getSignature())) ||
+                    !published.verifyBlindSignature(clerkKey)) {
+                return false;
+            }
+        }
+        return !boxes.isEmpty();
+    }
+}
@@ -6,13 +6,320 @@
  */
 package org.elastos.crypto;
 
+import java.nio.charset.StandardCharsets;
+import java.util.ArrayList;
+import java.util.Arrays;
+import java.util.Base64;
+import java.util.List;
+import java.util.regex.Pattern;
+
 /**
  * clark
  * <p>
  * 3/5/19
+ * <p>
+ * A ballot, in the same text format as the Go library. Each section is separated by a double line break:
+ * election ID, ballot ID, the vote with one choice per line, optional tags with one key=value per line, and the
+ * election clerk's base64 encoded signature.
  */
 public class Ballot {
 
+    public static final int MaxElectionIDSize = 32;
+    public static final int MaxBallotIDSize   = 128;
+    public static final int MaxVoteOptions    = 64;
+    public static final int MaxVoteBytes      = 256;
+    public static final int MaxTagKeySize     = 64;
+    public static final int MaxTagValueSize   = 256;
+    public static final int MaxBallotSize     = MaxElectionIDSize + MaxBallotIDSize + (MaxVoteOptions * MaxVoteBytes + MaxVoteOptions) +
+            (64 * (MaxTagKeySize + MaxTagValueSize + 1)) + 1368 + (4 * 2 + 64 + 64);
+
+    public static final String DenominationTag = "denomination"; // Ballot tag giving the ballot's weight. Ballots without it have a weight of 1
+    public static final String VoteBlank       = "@blank";
+    public static final String VoteAbstain     = "@abstain";
+    public static final String VoteSpoiled     = "@spoiled";
+
+    public static final Pattern ValidElectionID = Pattern.compile("^[0-9a-z_]+$");
+    public static final Pattern ValidBallotID   = Pattern.compile("^[0-9a-zA-Z\\-.\\[\\]_~:/?#@!$&'()*+,;=]+$");
+
+    public static final Exception ErrBallotTooBig        = new Exception("This ballot is too big. Maximum ballot size is " + MaxBallotSize + " bytes");
+    public static final Exception ErrBallotInvalid       = new Exception("Invalid ballot format");
+    public static final Exception ErrElectionIDInvalid   = new Exception("ElectionID contains illigal characters. Only lowercase alpha-numeric characters allowed");
+    public static final Exception ErrBallotIDInvalid     = new Exception("Ballot ID contains illigal characters. Valid characters are as per RFC 3986, sec 2");
+    public static final Exception ErrBallotInvalidVote   = new Exception("Cannot parse Cote in ballot");
+    public static final Exception ErrBallotInvalidTagSet = new Exception("Cannot parse TagSet in ballot");
+    public static final Exception ErrBallotInvalidSig    = new Exception("Cannot parse ballot Signature");
+    public static final Exception ErrBallotHasSignature  = new Exception("The ballot already has a signature");
+    public static final Exception ErrBallotCannotUnblind = new Exception("Could not unblind ballot");
+    public static final Exception ErrDenominationInvalid = new Exception("Invalid denomination. Must be a positive whole number");
+
+    /**
+     * A key=value tag
+     */
+    public static class Tag {
+        public final String key;
+        public final String value;
+
+        public Tag(String key, String value) {
+            this.key = key;
+            this.value = value;
+        }
+
+        public String string() {
+            return key + "=" + value;
+        }
+    }
+
+    private String electionId;
+    private String ballotId;
+    private List<String> vote;
+    private List<Tag> tags;     // Null if the ballot has no tags
+    private byte[] signature;   // Null if the ballot is not signed
+
+    public Ballot(String electionId, String ballotId, List<String> vote) {
+        this.electionId = electionId;
+        this.ballotId = ballotId;
+        this.vote = new ArrayList<>(vote);
+    }
+
+    /**
+     * Parse a ballot from its text form, as it is PUT to the ballotbox
+     * @param rawBallot
+     * @throws Exception
+     */
+    public Ballot(String rawBallot) throws Exception {
+        if (utf8Length(rawBallot) > MaxBallotSize) {
+            throw new Exception(ErrBallotTooBig.getMessage());
+        }
+        String[] parts = rawBallot.split("\n\n", -1);
+
+        int tagsSec;
+        int signSec;
+        switch (parts.length) {
+            case 3:
+                tagsSec = 0;
+                signSec = 0;
+                break;
+            case 4:
+                // If it contains a line break it's a tagset. Otherwise a signature is longer than the longest tag
+                if (parts[3].contains("\n") || utf8Length(parts[3]) <= MaxTagKeySize + MaxTagValueSize + 1) {
+                    tagsSec = 3;
+                    signSec = 0;
+                } else {
+                    tagsSec = 0;
+                    signSec = 3;
+                }
+                break;
+            case 5:
+                tagsSec = 3;
+                signSec = 4;
+                break;
+            default:
+                throw new Exception(ErrBallotInvalid.getMessage());
+        }
+
+        this.electionId = parts[0];
+        if (utf8Length(electionId) > MaxElectionIDSize || !ValidElectionID.matcher(electionId).matches()) {
+            throw new Exception(ErrElectionIDInvalid.getMessage());
+        }
+        this.ballotId = parts[1];
+        if (utf8Length(ballotId) > MaxBallotIDSize || !ValidBallotID.matcher(ballotId).matches()) {
+            throw new Exception(ErrBallotIDInvalid.getMessage());
+        }
+        this.vote = parseVote(parts[2]);
+        if (tagsSec != 0) {
+            this.tags = parseTags(parts[tagsSec]);
+        }
+        if (signSec != 0) {
+            this.signature = parseSignature(parts[signSec]);
+        }
+    }
+
+    private static List<String> parseVote(String rawVote) throws Exception {
+        List<String> vote = new ArrayList<>();
+        if (rawVote.isEmpty()) {
+            return vote;
+        }
+        vote.addAll(Arrays.asList(rawVote.split("\n", -1)));
+        if (vote.size() > MaxVoteOptions) {
+            throw new Exception(ErrBallotInvalidVote.getMessage() + ": too many options");
+        }
+        for (String option : vote) {
+            if (option.isEmpty() || utf8Length(option) > MaxVoteBytes) {
+                throw new Exception(ErrBallotInvalidVote.getMessage() + ": invalid option");
+            }
+            // Options starting with @ are reserved for markers, which must be the only line of a vote
+            if (option.startsWith("@")) {
+                boolean marker = option.equals(VoteBlank) || option.equals(VoteAbstain) || option.equals(VoteSpoiled);
+                if (!marker || vote.size() != 1) {
+                    throw new Exception(ErrBallotInvalidVote.getMessage() + ": invalid marker " + option);
+                }
+            }
+        }
+        return vote;
+    }
+
+    private static List<Tag> parseTags(String rawTags) throws Exception {
+        List<Tag> tags = new ArrayList<>();
+        for (String rawTag : rawTags.split("\n", -1)) {
+            String[] kv = rawTag.split("=", 2);
+            if (kv.length != 2 || kv[0].isEmpty() || kv[1].isEmpty() ||
+                    utf8Length(kv[0]) > MaxTagKeySize || utf8Length(kv[1]) > MaxTagValueSize) {
+                throw new Exception(ErrBallotInvalidTagSet.getMessage());
+            }
+            tags.add(new Tag(kv[0], kv[1]));
+        }
+        return tags;
+    }
+
+    private static byte[] parseSignature(String rawSignature) throws Exception {
+        byte[] signature;
+        try {
+            signature = Base64.getDecoder().decode(rawSignature);
+        } catch (IllegalArgumentException ex) {
+            throw new Exception(ErrBallotInvalidSig.getMessage(), ex);
+        }
+        if (signature.length < 128) {
+            throw new Exception(ErrBallotInvalidSig.getMessage() + ": signature too short");
+        }
+        return signature;
+    }
+
+    private static int utf8Length(String s) {
+        return s.getBytes(StandardCharsets.UTF_8).length;
+    }
+
+    public String getElectionId() {
+        return electionId;
+    }
+
+    public String getBallotId() {
+        return ballotId;
+    }
+
+    public List<String> getVote() {
+        return vote;
+    }
+
+    public List<Tag> getTags() {
+        return tags;
+    }
+
+    public void setTags(List<Tag> tags) {
+        this.tags = tags == null || tags.isEmpty() ? null : new ArrayList<>(tags);
+    }
+
+    public byte[] getSignature() {
+        return signature;
+    }
+
+    public boolean hasSignature() {
+        return signature != null;
+    }
+
+    /**
+     * Get the ballot's denomination in a weighted election, from its denomination tag. It is 1 without the tag.
+     */
+    public long denomination() throws Exception {
+        if (tags != null) {
+            for (Tag tag : tags) {
+                if (tag.key.equals(DenominationTag)) {
+                    return parseDenomination(tag.value);
+                }
+            }
+        }
+        return 1;
+    }
+
+    /**
+     * Tag the ballot with its denomination. Denomination 1 is left untagged.
+     */
+    public void setDenomination(long denomination) {
+        List<Tag> kept = new ArrayList<>();
+        if (tags != null) {
+            for (Tag tag : tags) {
+                if (!tag.key.equals(DenominationTag)) {
+                    kept.add(tag);
+                }
+            }
+        }
+        if (Long.compareUnsigned(denomination, 1) > 0) {
+            kept.add(new Tag(DenominationTag, Long.toUnsignedString(denomination)));
+        }
+        setTags(kept);
+    }
+
+    static long parseDenomination(String value) throws Exception {
+        long denomination;
+        try {
+            denomination = Long.parseUnsignedLong(value);
+        } catch (NumberFormatException ex) {
+            throw new Exception(ErrDenominationInvalid.getMessage(), ex);
+        }
+        if (denomination == 0) {
+            throw new Exception(ErrDenominationInvalid.getMessage());
+        }
+        return denomination;
+    }
+
+    /**
+     * Blind the ballot with the election clerk's key, ready to be sent to the clerk in a Signature Request. The
+     * ballot is full-domain-hashed to half the size of the key first.
+     */
+    public RSABlind.Blinded blind(PublicKey clerkKey) throws Exception {
+        if (hasSignature()) {
+            throw new Exception(ErrBallotHasSignature.getMessage());
+        }
+        return RSABlind.blind(clerkKey.getCryptoKey(), hash(clerkKey));
+    }
+
+    /**
+     * Unblind the clerk's signature of the blinded ballot, and add it to the ballot once it is checked
+     */
+    public void unblind(PublicKey clerkKey, byte[] blindSignature, byte[] unblinder) throws Exception {
+        if (hasSignature()) {
+            throw new Exception(ErrBallotHasSignature.getMessage());
+        }
+        byte[] unblinded = RSABlind.unblind(clerkKey.getCryptoKey(), blindSignature, unblinder);
+        if (!RSABlind.verifyBlindSignature(clerkKey.getCryptoKey(), hash(clerkKey), unblinded)) {
+            throw new Exception(ErrBallotCannotUnblind.getMessage());
+        }
+        this.signature = unblinded;
+    }
+
+    /**
+     * Verify that the ballot is signed with the clerk's key
+     */
+    public boolean verifyBlindSignature(PublicKey clerkKey) throws Exception {
+        return hasSignature() && RSABlind.verifyBlindSignature(clerkKey.getCryptoKey(), hash(clerkKey), signature);
+    }
+
+    private byte[] hash(PublicKey clerkKey) throws Exception {
+        return FDH.sum(stringWithoutSignature().getBytes(StandardCharsets.UTF_8), clerkKey.keyLength() / 2);
+    }
 
+    /**
+     * Get the ballot in the text format, as it is PUT to the ballotbox
+     */
+    public String string() {
+        String s = stringWithoutSignature();
+        if (hasSignature()) {
+            s += "\n\n" + Base64.getEncoder().encodeToString(signature);
+        }
+        return s;
+    }
 
+    /**
+     * Get the ballot without the signature. This is what the clerk signs
+     */
+    public String stringWithoutSignature() {
+        String s = electionId + "\n\n" + ballotId + "\n\n" + String.join("\n", vote);
+        if (tags != null) {
+            List<String> rawTags = new ArrayList<>();
+            for (Tag tag : tags) {
+                rawTags.add(tag.string());
+            }
+            s += "\n\n" + String.join("\n", rawTags);
+        }
+        return s;
+    }
 }
@@ -0,0 +1,99 @@
+/**
+ * Copyright (c) 2017-2019 The Elastos Developers
+ * <p>
+ * Distributed under the MIT software license, see the accompanying file
+ * LICENSE or https://opensource.org/licenses/mit-license.php
+ */
+package org.elastos.crypto;
+
+import org.apache.commons.codec.binary.Hex;
+import org.bouncycastle.crypto.digests.SHA256Digest;
+import org.bouncycastle.crypto.params.ECPrivateKeyParameters;
+import org.bouncycastle.crypto.signers.ECDSASigner;
+import org.bouncycastle.crypto.signers.HMacDSAKCalculator;
+
+import java.math.BigInteger;
+import java.security.SecureRandom;
+
+/**
+ * A secp256r1 (P-256) private key, as used by Elastos DIDs. Voters sign their Signature Requests and the
+ * X-Signature header with it.
+ * <p>
+ * Signatures are the 64 byte r || s of an ECDSA signature of the SHA256 of the message, the same as the Go library.
+ * They are deterministic (RFC 6979), which the Go library accepts the same as its own randomised signatures.
+ */
+public class DIDPrivateKey {
+
+    public static final int DIDPrivateKeySize = 32; // Size of a DID private key in bytes
+
+    public static final Exception ErrDIDKeyInvalid    = new Exception("Invalid DID private key. Must be a 32 byte secp256r1 private key");
+    public static final Exception ErrDIDKeyInvalidHex = new Exception("Invalid DID private key. Could not decode hex");
+
+    private byte[] priv;
+
+    public DIDPrivateKey(byte[] key) throws Exception {
+        if (key.length != DIDPrivateKeySize) {
+            throw new Exception(ErrDIDKeyInvalid.getMessage());
+        }
+        BigInteger d = new BigInteger(1, key);
+        if (d.signum() == 0 || d.compareTo(DIDPublicKey.CURVE.getN()) >= 0) {
+            throw new Exception(ErrDIDKeyInvalid.getMessage());
+        }
+        this.priv = key.clone();
+    }
+
+    public static DIDPrivateKey fromHex(String hexKey) throws Exception {
+        byte[] key;
+        try {
+            key = Hex.decodeHex(hexKey.toCharArray());
+        } catch (Exception ex) {
+            throw new Exception(ErrDIDKeyInvalidHex.getMessage(), ex);
+        }
+        return new DIDPrivateKey(key);
+    }
+
+    /**
+     * Generate a new random DID private key
+     */
+    public static DIDPrivateKey generate() throws Exception {
+        SecureRandom random = new SecureRandom();
+        BigInteger n = DIDPublicKey.CURVE.getN();
+        BigInteger d;
+        do {
+            d = new BigInteger(n.bitLength(), random);
+        } while (d.signum() == 0 || d.compareTo(n) >= 0);
+        byte[] key = new byte[DIDPrivateKeySize];
+        byte[] b = Kit.unsignedBytes(d);
+        System.arraycopy(b, 0, key, DIDPrivateKeySize - b.length, b.length);
+        return new DIDPrivateKey(key);
+    }
+
+    public byte[] bytes() {
+        return this.priv.clone();
+    }
+
+    public String hex() {
+        return Hex.encodeHexString(this.priv);
+    }
+
+    public DIDPublicKey publicKey() {
+        return new DIDPublicKey(DIDPublicKey.CURVE.getG().multiply(new BigInteger(1, this.priv)).normalize());
+    }
+
+    public byte[] sign(String data) throws Exception {
+        return signBytes(data.getBytes("UTF-8"));
+    }
+
+    public byte[] signBytes(byte[] data) throws Exception {
+        ECDSASigner signer = new ECDSASigner(new HMacDSAKCalculator(new SHA256Digest()));
+        signer.init(true, new ECPrivateKeyParameters(new BigInteger(1, this.priv), DIDPublicKey.CURVE));
+        BigInteger[] rs = signer.generateSignature(Kit.sha256(data));
+
+        byte[] signature = new byte[DIDPublicKey.SignatureLength];
+        byte[] r = Kit.unsignedBytes(rs[0]);
+        byte[] s = Kit.unsignedBytes(rs[1]);
+        System.arraycopy(r, 0, signature, DIDPublicKey.SignatureLength / 2 - r.length, r.length);
+        System.arraycopy(s, 0, signature, DIDPublicKey.SignatureLength - s.length, s.length);
+        return signature;
+    }
+}
@@ -0,0 +1,95 @@
+/**
+ * Copyright (c) 2017-2019 The Elastos Developers
+ * <p>
+ * Distributed under the MIT software license, see the accompanying file
+ * LICENSE or https://opensource.org/licenses/mit-license.php
+ */
+package org.elastos.crypto;
+
+import org.apache.commons.codec.binary.Hex;
+import org.bouncycastle.asn1.sec.SECNamedCurves;
+import org.bouncycastle.asn1.x9.X9ECParameters;
+import org.bouncycastle.crypto.params.ECDomainParameters;
+import org.bouncycastle.crypto.params.ECPublicKeyParameters;
+import org.bouncycastle.crypto.signers.ECDSASigner;
+import org.bouncycastle.math.ec.ECPoint;
+
+import java.math.BigInteger;
+import java.util.Arrays;
+
+/**
+ * A secp256r1 (P-256) public key, as used by Elastos DIDs. It is sent compressed and hex encoded, in Signature
+ * Requests and in the X-Public-Key header.
+ */
+public class DIDPublicKey {
+
+    public static final int CompressedLength = 33;
+    public static final int SignatureLength  = 64;
+
+    public static final Exception ErrDIDPublicKeyInvalid = new Exception("Invalid DID public key");
+
+    static final ECDomainParameters CURVE;
+
+    static {
+        X9ECParameters params = SECNamedCurves.getByName("secp256r1");
+        CURVE = new ECDomainParameters(params.getCurve(), params.getG(), params.getN(), params.getH());
+    }
+
+    private ECPoint point;
+
+    DIDPublicKey(ECPoint point) {
+        this.point = point;
+    }
+
+    /**
+     * Parse a compressed DID public key
+     */
+    public DIDPublicKey(byte[] compressed) throws Exception {
+        if (compressed.length != CompressedLength || (compressed[0] != 0x02 && compressed[0] != 0x03)) {
+            throw new Exception(ErrDIDPublicKeyInvalid.getMessage());
+        }
+        try {
+            this.point = CURVE.getCurve().decodePoint(compressed).normalize();
+        } catch (IllegalArgumentException ex) {
+            throw new Exception(ErrDIDPublicKeyInvalid.getMessage(), ex);
+        }
+    }
+
+    public static DIDPublicKey fromHex(String hexKey) throws Exception {
+        return new DIDPublicKey(Hex.decodeHex(hexKey.toCharArray()));
+    }
+
+    /**
+     * Get the compressed public key
+     */
+    public byte[] bytes() {
+        return this.point.getEncoded(true);
+    }
+
+    public String hex() {
+        return Hex.encodeHexString(bytes());
+    }
+
+    /**
+     * Get the ID used for a voter's Signature Requests when they do not give their DID. It is the double SHA256 of
+     * the compressed public key
+     */
+    public byte[] requestID() throws Exception {
+        return Kit.sha256D(bytes());
+    }
+
+    public boolean verifySignature(byte[] signature, String data) throws Exception {
+        return verifySignatureBytes(signature, data.getBytes("UTF-8"));
+    }
+
+    public boolean verifySignatureBytes(byte[] signature, byte[] data) throws Exception {
+        if (signature.length != SignatureLength) {
+            return false;
+        }
+        BigInteger r = new BigInteger(1, Arrays.copyOfRange(signature, 0, SignatureLength / 2));
+        BigInteger s = new BigInteger(1, Arrays.copyOfRange(signature, SignatureLength / 2, SignatureLength));
+        ECDSASigner signer = new ECDSASigner();
+        signer.init(false, new ECPublicKeyParameters(this.point, CURVE));
+        return signer.verifySignature(Kit.sha256(data), r, s);
+    }
+}
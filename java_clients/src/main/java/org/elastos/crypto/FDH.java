@@ -0,0 +1,36 @@
+/**
+ * Copyright (c) 2017-2019 The Elastos Developers
+ * <p>
+ * Distributed under the MIT software license, see the accompanying file
+ * LICENSE or https://opensource.org/licenses/mit-license.php
+ */
+package org.elastos.crypto;
+
+import java.io.ByteArrayOutputStream;
+import java.nio.ByteBuffer;
+import java.security.MessageDigest;
+import java.util.Arrays;
+
+/**
+ * SHA256 full-domain-hash, compatible with github.com/cryptoballot/fdh. Ballots are hashed to half the size of the
+ * election clerk's key before they are blinded.
+ * <p>
+ * The hash is SHA256(message || 0) || SHA256(message || 1) || ..., with each counter a 4 byte big-endian number,
+ * cut to the requested length.
+ */
+public class FDH {
+
+    public static byte[] sum(byte[] message, int bitLength) throws Exception {
+        if (bitLength <= 0 || bitLength % 8 != 0) {
+            throw new IllegalArgumentException("fdh: the hash length must be a positive multiple of 8 bits");
+        }
+        ByteArrayOutputStream out = new ByteArrayOutputStream();
+        for (int i = 0; out.size() * 8 < bitLength; i++) {
+            MessageDigest md = MessageDigest.getInstance("SHA-256");
+            md.update(message);
+            md.update(ByteBuffer.allocate(4).putInt(i).array());
+            out.write(md.digest());
+        }
+        return Arrays.copyOf(out.toByteArray(), bitLength / 8);
+    }
+}
@@ -0,0 +1,59 @@
+/**
+ * Copyright (c) 2017-2019 The Elastos Developers
+ * <p>
+ * Distributed under the MIT software license, see the accompanying file
+ * LICENSE or https://opensource.org/licenses/mit-license.php
+ */
+package org.elastos.crypto;
+
+import java.util.Base64;
+
+/**
+ * A Signature Request the election clerk has signed. It is the signed Signature Request, a double line break, and
+ * the clerk's base64 encoded blind signature of the blind ballot.
+ */
+public class FulfilledSignatureRequest {
+
+    public static final Exception ErrFulfilledSignatureRequestInvalid = new Exception("Cannot read Fulfilled Signature Request. Invalid format");
+
+    private SignatureRequest signatureRequest;
+    private byte[] ballotSignature;
+
+    public FulfilledSignatureRequest(String rawFulfilled) throws Exception {
+        int last = rawFulfilled.lastIndexOf("\n\n");
+        if (last < 0) {
+            throw new Exception(ErrFulfilledSignatureRequestInvalid.getMessage());
+        }
+        this.signatureRequest = new SignatureRequest(rawFulfilled.substring(0, last));
+        if (!signatureRequest.hasSignature()) {
+            throw new Exception(ErrFulfilledSignatureRequestInvalid.getMessage());
+        }
+        try {
+            this.ballotSignature = Base64.getDecoder().decode(rawFulfilled.substring(last + 2));
+        } catch (IllegalArgumentException ex) {
+            throw new Exception(Ballot.ErrBallotInvalidSig.getMessage(), ex);
+        }
+        if (ballotSignature.length < 128) {
+            throw new Exception(Ballot.ErrBallotInvalidSig.getMessage() + ": signature too short");
+        }
+    }
+
+    public SignatureRequest getSignatureRequest() {
+        return signatureRequest;
+    }
+
+    public byte[] getBallotSignature() {
+        return ballotSignature;
+    }
+
+    /**
+     * Verify that the ballot signature is the clerk's blind signature of the blind ballot
+     */
+    public boolean verifyBallotSignature(PublicKey clerkKey) throws Exception {
+        return RSABlind.verifyBlindSignature(clerkKey.getCryptoKey(), signatureRequest.getBlindBallot(), ballotSignature);
+    }
+
+    public String string() {
+        return signatureRequest.string() + "\n\n" + Base64.getEncoder().encodeToString(ballotSignature);
+    }
+}
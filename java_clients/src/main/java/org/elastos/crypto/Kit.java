@@ -10,6 +10,9 @@ import org.bouncycastle.openssl.PEMParser;
 
 import java.io.InputStream;
 import java.io.InputStreamReader;
+import java.math.BigInteger;
+import java.security.MessageDigest;
+import java.util.Arrays;
 
 /**
  * clark
@@ -32,4 +35,27 @@ public class Kit {
             throw new RuntimeException("Cannot read PEM object from input data", ex);
         }
     }
+
+    public static byte[] sha256(byte[] data) throws Exception {
+        return MessageDigest.getInstance("SHA-256").digest(data);
+    }
+
+    /**
+     * Double SHA256, as used for the request IDs of Signature Requests
+     */
+    public static byte[] sha256D(byte[] data) throws Exception {
+        return sha256(sha256(data));
+    }
+
+    /**
+     * Get the big-endian bytes of a non-negative number without a sign byte, the same as Go's big.Int.Bytes
+     */
+    public static byte[] unsignedBytes(BigInteger n) {
+        byte[] b = n.toByteArray();
+        int start = 0;
+        while (start < b.length && b[start] == 0) {
+            start++;
+        }
+        return Arrays.copyOfRange(b, start, b.length);
+    }
 }
@@ -24,6 +24,7 @@ import java.security.interfaces.RSAPrivateKey;
 import java.security.interfaces.RSAPublicKey;
 import java.security.spec.PKCS8EncodedKeySpec;
 import java.security.spec.X509EncodedKeySpec;
+import java.util.Base64;
 
 /**
  * clark
@@ -72,6 +73,35 @@ public class PublicKey {
     }
 
 
+    /**
+     * get public key from the base64 encoded DER, as it is given in ballots and the other text formats
+     * @param base64
+     * @throws Exception
+     */
+    public static PublicKey fromBase64(String base64) throws Exception {
+        byte[] der;
+        try {
+            der = Base64.getDecoder().decode(base64.trim());
+        } catch (IllegalArgumentException ex) {
+            throw new Exception(ErrPublicKeyBase64.getMessage(), ex);
+        }
+        return fromDER(der);
+    }
+
+    /**
+     * get public key from DER encoded bytes
+     * @param der
+     * @throws Exception
+     */
+    public static PublicKey fromDER(byte[] der) throws Exception {
+        KeyFactory kf = KeyFactory.getInstance("RSA");
+        return new PublicKey((RSAPublicKey) kf.generatePublic(new X509EncodedKeySpec(der)));
+    }
+
+    public String base64() {
+        return Base64.getEncoder().encodeToString(this.pub);
+    }
+
     public int keyLength()  throws Exception{
         RSAPublicKey rsaPub = this.getCryptoKey();
         return rsaPub.getModulus().bitLength();
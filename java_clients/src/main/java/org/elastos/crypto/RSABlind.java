@@ -0,0 +1,81 @@
+/**
+ * Copyright (c) 2017-2019 The Elastos Developers
+ * <p>
+ * Distributed under the MIT software license, see the accompanying file
+ * LICENSE or https://opensource.org/licenses/mit-license.php
+ */
+package org.elastos.crypto;
+
+import java.math.BigInteger;
+import java.security.SecureRandom;
+import java.security.interfaces.RSAPublicKey;
+
+/**
+ * RSA blinding, compatible with github.com/cryptoballot/rsablind. A voter blinds the full-domain-hash of their ballot
+ * so that the election clerk can sign it without seeing it, then unblinds the clerk's signature.
+ * <p>
+ * Numbers are given as big-endian bytes without padding, as rsablind gives them.
+ */
+public class RSABlind {
+
+    public static final Exception ErrMessageTooLong = new Exception("Message too long for the RSA key size");
+
+    /**
+     * A blinded message, along with the unblinder needed to unblind its signature
+     */
+    public static class Blinded {
+        public final byte[] blinded;
+        public final byte[] unblinder;
+
+        Blinded(byte[] blinded, byte[] unblinder) {
+            this.blinded = blinded;
+            this.unblinder = unblinder;
+        }
+    }
+
+    /**
+     * Blind a hashed message with a random blinding factor
+     */
+    public static Blinded blind(RSAPublicKey key, byte[] hashed) throws Exception {
+        SecureRandom random = new SecureRandom();
+        BigInteger n = key.getModulus();
+        while (true) {
+            BigInteger r = new BigInteger(n.bitLength(), random);
+            if (r.signum() != 0 && r.compareTo(n) < 0 && r.gcd(n).equals(BigInteger.ONE)) {
+                return blind(key, hashed, r);
+            }
+        }
+    }
+
+    /**
+     * Blind a hashed message with the given blinding factor r. The blinded message is m * r^e mod n, and the
+     * unblinder is the inverse of r.
+     */
+    public static Blinded blind(RSAPublicKey key, byte[] hashed, BigInteger r) throws Exception {
+        BigInteger n = key.getModulus();
+        if (hashed.length * 8 > n.bitLength()) {
+            throw new Exception(ErrMessageTooLong.getMessage());
+        }
+        BigInteger m = new BigInteger(1, hashed);
+        BigInteger blinded = m.multiply(r.modPow(key.getPublicExponent(), n)).mod(n);
+        return new Blinded(Kit.unsignedBytes(blinded), Kit.unsignedBytes(r.modInverse(n)));
+    }
+
+    /**
+     * Unblind a signature of a blinded message, giving the signature of the message
+     */
+    public static byte[] unblind(RSAPublicKey key, byte[] blindSignature, byte[] unblinder) {
+        BigInteger n = key.getModulus();
+        BigInteger s = new BigInteger(1, blindSignature).multiply(new BigInteger(1, unblinder)).mod(n);
+        return Kit.unsignedBytes(s);
+    }
+
+    /**
+     * Verify an unpadded RSA signature of a hashed message. This verifies both the clerk's signature of a blinded
+     * ballot and the unblinded signature of a ballot's full-domain-hash.
+     */
+    public static boolean verifyBlindSignature(RSAPublicKey key, byte[] hashed, byte[] signature) {
+        BigInteger m = new BigInteger(1, signature).modPow(key.getPublicExponent(), key.getModulus());
+        return m.equals(new BigInteger(1, hashed));
+    }
+}
@@ -0,0 +1,223 @@
+/**
+ * Copyright (c) 2017-2019 The Elastos Developers
+ * <p>
+ * Distributed under the MIT software license, see the accompanying file
+ * LICENSE or https://opensource.org/licenses/mit-license.php
+ */
+package org.elastos.crypto;
+
+import org.apache.commons.codec.binary.Hex;
+
+import java.nio.charset.StandardCharsets;
+import java.util.ArrayList;
+import java.util.Arrays;
+import java.util.Base64;
+import java.util.List;
+import java.util.regex.Matcher;
+import java.util.regex.Pattern;
+
+/**
+ * A voter's request for the election clerk to sign their blinded ballot, in the same text format as the Go library.
+ * Each section is separated by a double line break: election ID, hex request ID, the voter's hex DID public key,
+ * then optionally their DID, a verifiable presentation as compact JSON and "denomination:N", then the base64 blind
+ * ballot and the voter's hex signature of everything before it.
+ * <p>
+ * The request ID is the double SHA256 of the voter's DID if they give it, otherwise of their public key.
+ */
+public class SignatureRequest {
+
+    public static final int MaxSignatureRequestSize = 64 * 1024;
+
+    public static final Pattern ValidDID = Pattern.compile("^did:elastos:[1-9A-HJ-NP-Za-km-z]+$");
+
+    public static final Exception ErrSignatureRequestInvalid    = new Exception("Cannot read Signature Request. Invalid format");
+    public static final Exception ErrSignatureRequestTooBig     = new Exception("This Signature Request is too big. Maximum size is " + MaxSignatureRequestSize + " bytes");
+    public static final Exception ErrSignatureRequestPublicKey  = new Exception("Cannot read Signature Request. Invalid Public Key");
+    public static final Exception ErrSignatureRequestID         = new Exception("Invalid SignatureRequest ID. A SignatureRequest ID must be the double SHA256 of the voter's DID, or of their public key if no DID is given.");
+    public static final Exception ErrSignatureRequestDID        = new Exception("Invalid Signature Request. Invalid voter DID");
+    public static final Exception ErrSignatureRequestVP         = new Exception("Invalid Signature Request. A verifiable presentation must be made by the voter's DID");
+    public static final Exception ErrSignatureRequestBallotHash = new Exception("Invalid Signature Request. Ballot hash must be hex encoded.");
+    public static final Exception ErrSignatureRequestSigInvalid = new Exception("Invalid Signature Request. Could not parse voter signature");
+
+    private static final String denominationPrefix = "denomination:";
+    private static final Pattern presentationHolder = Pattern.compile("\"holder\"\\s*:\\s*\"([^\"]*)\"");
+
+    private String electionId;
+    private byte[] requestId;
+    private byte[] publicKey;
+    private String did = "";          // Empty if the voter does not give their DID
+    private String presentation = ""; // Empty if the voter presents no credentials
+    private long denomination;        // Zero if not given
+    private byte[] blindBallot;
+    private byte[] signature;         // Null until signed
+
+    /**
+     * Create an unsigned Signature Request for a blinded ballot
+     * @param electionId
+     * @param publicKey the voter's DID public key
+     * @param did the voter's DID, or empty
+     * @param presentation a verifiable presentation of the voter's credentials as compact JSON, or empty. Requires a DID
+     * @param denomination the ballot's denomination in a weighted election, or zero
+     * @param blindBallot
+     * @throws Exception
+     */
+    public SignatureRequest(String electionId, DIDPublicKey publicKey, String did, String presentation, long denomination, byte[] blindBallot) throws Exception {
+        this.electionId = electionId;
+        this.publicKey = publicKey.bytes();
+        this.did = did == null ? "" : did;
+        this.presentation = presentation == null ? "" : presentation;
+        this.denomination = denomination;
+        this.blindBallot = blindBallot;
+        if (!this.did.isEmpty() && !ValidDID.matcher(this.did).matches()) {
+            throw new Exception(ErrSignatureRequestDID.getMessage());
+        }
+        if (!this.presentation.isEmpty() && this.did.isEmpty()) {
+            throw new Exception(ErrSignatureRequestVP.getMessage());
+        }
+        this.requestId = this.did.isEmpty() ? publicKey.requestID() : Kit.sha256D(this.did.getBytes(StandardCharsets.UTF_8));
+    }
+
+    /**
+     * Parse a Signature Request from its text form. The voter's signature is not verified.
+     * @param rawSignatureRequest
+     * @throws Exception
+     */
+    public SignatureRequest(String rawSignatureRequest) throws Exception {
+        if (rawSignatureRequest.getBytes(StandardCharsets.UTF_8).length > MaxSignatureRequestSize) {
+            throw new Exception(ErrSignatureRequestTooBig.getMessage());
+        }
+        List<String> parts = new ArrayList<>(Arrays.asList(rawSignatureRequest.split("\n\n", -1)));
+
+        // The DID, presentation and denomination are optional, and come in that order before the blind ballot
+        if (parts.size() > 4 && parts.get(3).startsWith("did:")) {
+            this.did = parts.remove(3);
+            if (!ValidDID.matcher(this.did).matches()) {
+                throw new Exception(ErrSignatureRequestDID.getMessage());
+            }
+        }
+        if (parts.size() > 4 && parts.get(3).startsWith("{")) {
+            if (this.did.isEmpty()) {
+                throw new Exception(ErrSignatureRequestVP.getMessage());
+            }
+            // The presentation must be held by the voter's DID. It is otherwise checked by the election clerk
+            Matcher holder = presentationHolder.matcher(parts.get(3));
+            if (!holder.find() || !holder.group(1).equals(this.did)) {
+                throw new Exception(ErrSignatureRequestVP.getMessage());
+            }
+            this.presentation = parts.remove(3);
+        }
+        if (parts.size() > 4 && parts.get(3).startsWith(denominationPrefix)) {
+            this.denomination = Ballot.parseDenomination(parts.remove(3).substring(denominationPrefix.length()));
+        }
+        if (parts.size() != 4 && parts.size() != 5) {
+            throw new Exception(ErrSignatureRequestInvalid.getMessage());
+        }
+
+        this.electionId = parts.get(0);
+        try {
+            this.publicKey = Hex.decodeHex(parts.get(2).toCharArray());
+        } catch (Exception ex) {
+            throw new Exception(ErrSignatureRequestPublicKey.getMessage(), ex);
+        }
+        try {
+            this.requestId = Hex.decodeHex(parts.get(1).toCharArray());
+        } catch (Exception ex) {
+            throw new Exception(ErrSignatureRequestID.getMessage(), ex);
+        }
+        byte[] expectedId = did.isEmpty() ? Kit.sha256D(publicKey) : Kit.sha256D(did.getBytes(StandardCharsets.UTF_8));
+        if (!Arrays.equals(requestId, expectedId)) {
+            throw new Exception(ErrSignatureRequestID.getMessage());
+        }
+        try {
+            this.blindBallot = Base64.getDecoder().decode(parts.get(3));
+        } catch (IllegalArgumentException ex) {
+            throw new Exception(ErrSignatureRequestBallotHash.getMessage(), ex);
+        }
+        if (parts.size() == 5) {
+            try {
+                this.signature = Hex.decodeHex(parts.get(4).toCharArray());
+            } catch (Exception ex) {
+                throw new Exception(ErrSignatureRequestSigInvalid.getMessage(), ex);
+            }
+        }
+    }
+
+    public String getElectionId() {
+        return electionId;
+    }
+
+    public byte[] getRequestId() {
+        return requestId;
+    }
+
+    public byte[] getPublicKey() {
+        return publicKey;
+    }
+
+    public String getDid() {
+        return did;
+    }
+
+    public String getPresentation() {
+        return presentation;
+    }
+
+    public long getDenomination() {
+        return denomination;
+    }
+
+    public byte[] getBlindBallot() {
+        return blindBallot;
+    }
+
+    public byte[] getSignature() {
+        return signature;
+    }
+
+    public boolean hasSignature() {
+        return signature != null;
+    }
+
+    /**
+     * Sign the Signature Request with the voter's DID key
+     */
+    public void sign(DIDPrivateKey key) throws Exception {
+        this.signature = key.sign(stringWithoutSignature());
+    }
+
+    /**
+     * Verify the voter's signature with the public key in the Signature Request
+     */
+    public boolean verifySignature() throws Exception {
+        return hasSignature() && new DIDPublicKey(publicKey).verifySignature(signature, stringWithoutSignature());
+    }
+
+    /**
+     * Get the Signature Request in the text format, as it is POSTed to the election clerk
+     */
+    public String string() {
+        String s = stringWithoutSignature();
+        if (hasSignature()) {
+            s += "\n\n" + Hex.encodeHexString(signature);
+        }
+        return s;
+    }
+
+    /**
+     * Get the Signature Request without the voter's signature. This is what the voter signs
+     */
+    public String stringWithoutSignature() {
+        String s = electionId + "\n\n" + Hex.encodeHexString(requestId) + "\n\n" + Hex.encodeHexString(publicKey);
+        if (!did.isEmpty()) {
+            s += "\n\n" + did;
+        }
+        if (!presentation.isEmpty()) {
+            s += "\n\n" + presentation;
+        }
+        if (denomination != 0) {
+            s += "\n\n" + denominationPrefix + Long.toUnsignedString(denomination);
+        }
+        s += "\n\n" + Base64.getEncoder().encodeToString(blindBallot);
+        return s;
+    }
+}
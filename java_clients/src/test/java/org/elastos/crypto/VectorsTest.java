@@ -0,0 +1,293 @@
+/**
+ * Copyright (c) 2017-2019 The Elastos Developers
+ * <p>
+ * Distributed under the MIT software license, see the accompanying file
+ * LICENSE or https://opensource.org/licenses/mit-license.php
+ */
+package org.elastos.crypto;
+
+import com.google.gson.Gson;
+import org.apache.commons.codec.binary.Hex;
+import org.junit.Assert;
+import org.junit.BeforeClass;
+import org.junit.Test;
+
+import java.io.InputStreamReader;
+import java.io.Reader;
+import java.math.BigInteger;
+import java.nio.charset.StandardCharsets;
+import java.nio.file.Files;
+import java.nio.file.Paths;
+import java.security.interfaces.RSAPublicKey;
+import java.util.ArrayList;
+import java.util.Arrays;
+import java.util.List;
+
+/**
+ * Checks the library against the vectors made by the Go library, in testing/vectors/client.json and
+ * conformance.json. See testing/vectors for how to make them again.
+ */
+public class VectorsTest {
+
+    static class ClientVectors {
+        String clerkKey;
+        List<DIDKeyVector> didKeys;
+        List<DIDRequestIDVector> didRequestIds;
+        List<FDHVector> fdh;
+        List<BlindingVector> blinding;
+        List<BallotVector> ballots;
+        List<SignatureRequestVector> signatureRequests;
+        List<FulfilledSignatureRequestVector> fulfilledSignatureRequests;
+    }
+
+    static class DIDKeyVector {
+        String privateKey, publicKey, requestId, message, signature;
+    }
+
+    static class DIDRequestIDVector {
+        String did, requestId;
+    }
+
+    static class FDHVector {
+        String message, hash;
+        int bitLength;
+    }
+
+    static class BlindingVector {
+        String ballot, hash, blinded, unblinder, blindSignature, signature, signedBallot;
+    }
+
+    static class BallotVector {
+        String text, electionId, ballotId;
+        List<String> vote, tags;
+        long denomination;
+        boolean signed;
+    }
+
+    static class SignatureRequestVector {
+        String text, electionId, requestId, publicKey, did, presentation, blindBallot;
+        long denomination;
+    }
+
+    static class FulfilledSignatureRequestVector {
+        String text, ballotSignature;
+    }
+
+    static class ConformanceVectors {
+        List<DIDSignatureVector> didSignatures;
+        List<MalformedVector> malformed;
+    }
+
+    static class DIDSignatureVector {
+        String privateKey, publicKey, message, signature;
+        boolean valid;
+    }
+
+    static class MalformedVector {
+        String kind, description, input;
+        List<String> errors;
+    }
+
+    private static ClientVectors vectors;
+    private static ConformanceVectors conformance;
+    private static PublicKey clerkKey;
+
+    @BeforeClass
+    public static void load() throws Exception {
+        vectors = load("client.json", ClientVectors.class);
+        conformance = load("conformance.json", ConformanceVectors.class);
+        clerkKey = PublicKey.fromBase64(vectors.clerkKey);
+    }
+
+    private static <T> T load(String filename, Class<T> type) throws Exception {
+        try (Reader reader = new InputStreamReader(Files.newInputStream(Paths.get("../testing/vectors", filename)), StandardCharsets.UTF_8)) {
+            return new Gson().fromJson(reader, type);
+        }
+    }
+
+    private static byte[] hex(String s) throws Exception {
+        return Hex.decodeHex(s.toCharArray());
+    }
+
+    @Test
+    public void TestDIDKeys() throws Exception {
+        for (DIDKeyVector vector : vectors.didKeys) {
+            DIDPrivateKey key = DIDPrivateKey.fromHex(vector.privateKey);
+            DIDPublicKey pub = key.publicKey();
+            Assert.assertEquals(vector.publicKey, pub.hex());
+            Assert.assertEquals(vector.requestId, Hex.encodeHexString(pub.requestID()));
+            Assert.assertTrue("Go signature does not verify", DIDPublicKey.fromHex(vector.publicKey).verifySignature(hex(vector.signature), vector.message));
+            Assert.assertFalse("Signature verifies a different message", pub.verifySignature(hex(vector.signature), vector.message + "x"));
+
+            // Both libraries sign deterministically, so the signatures are the same
+            Assert.assertEquals(vector.signature, Hex.encodeHexString(key.sign(vector.message)));
+        }
+        for (DIDRequestIDVector vector : vectors.didRequestIds) {
+            Assert.assertEquals(vector.requestId, Hex.encodeHexString(Kit.sha256D(vector.did.getBytes(StandardCharsets.UTF_8))));
+        }
+    }
+
+    @Test
+    public void TestFDH() throws Exception {
+        for (FDHVector vector : vectors.fdh) {
+            Assert.assertEquals("FDH of \"" + vector.message + "\" to " + vector.bitLength + " bits", vector.hash,
+                    Hex.encodeHexString(FDH.sum(vector.message.getBytes(StandardCharsets.UTF_8), vector.bitLength)));
+        }
+    }
+
+    @Test
+    public void TestBlinding() throws Exception {
+        RSAPublicKey key = clerkKey.getCryptoKey();
+        for (BlindingVector vector : vectors.blinding) {
+            Ballot ballot = new Ballot(vector.ballot);
+            Assert.assertEquals(vector.hash, Hex.encodeHexString(FDH.sum(ballot.stringWithoutSignature().getBytes(StandardCharsets.UTF_8), clerkKey.keyLength() / 2)));
+
+            // Blinding with the same factor gives the same blinded ballot. The unblinder is the inverse of the factor
+            BigInteger r = new BigInteger(1, hex(vector.unblinder)).modInverse(key.getModulus());
+            RSABlind.Blinded blinded = RSABlind.blind(key, hex(vector.hash), r);
+            Assert.assertEquals(vector.blinded, Hex.encodeHexString(blinded.blinded));
+            Assert.assertEquals(vector.unblinder, Hex.encodeHexString(blinded.unblinder));
+
+            Assert.assertTrue(RSABlind.verifyBlindSignature(key, hex(vector.blinded), hex(vector.blindSignature)));
+            Assert.assertEquals(vector.signature, Hex.encodeHexString(RSABlind.unblind(key, hex(vector.blindSignature), hex(vector.unblinder))));
+
+            ballot.unblind(clerkKey, hex(vector.blindSignature), hex(vector.unblinder));
+            Assert.assertEquals(vector.signedBallot, ballot.string());
+            Assert.assertTrue(ballot.verifyBlindSignature(clerkKey));
+
+            // A random blinding factor unblinds to the same signature
+            RSABlind.Blinded random = new Ballot(vector.ballot).blind(clerkKey);
+            Assert.assertNotEquals(vector.blinded, Hex.encodeHexString(random.blinded));
+        }
+    }
+
+    @Test
+    public void TestBallots() throws Exception {
+        for (BallotVector vector : vectors.ballots) {
+            Ballot ballot = new Ballot(vector.text);
+            Assert.assertEquals(vector.text, ballot.string());
+            Assert.assertEquals(vector.electionId, ballot.getElectionId());
+            Assert.assertEquals(vector.ballotId, ballot.getBallotId());
+            Assert.assertEquals(vector.vote, ballot.getVote());
+            List<String> tags = null;
+            if (ballot.getTags() != null) {
+                tags = new ArrayList<>();
+                for (Ballot.Tag tag : ballot.getTags()) {
+                    tags.add(tag.string());
+                }
+            }
+            Assert.assertEquals(vector.tags, tags);
+            Assert.assertEquals(vector.denomination, ballot.denomination());
+            Assert.assertEquals(vector.signed, ballot.hasSignature());
+            Assert.assertEquals(vector.signed, ballot.verifyBlindSignature(clerkKey));
+        }
+
+        for (String bad : Arrays.asList("vectorelection\n\nballot1", "Bad Election\n\nballot1\n\nKrampus", "vectorelection\n\nballot 1\n\nKrampus",
+                "vectorelection\n\nballot1\n\nKrampus\n\n\n\nSanta", "vectorelection\n\nballot1\n\n@abstain\nKrampus", "vectorelection\n\nballot1\n\nKrampus\n\nnotatag")) {
+            try {
+                new Ballot(bad);
+                Assert.fail("Invalid ballot was read: " + bad);
+            } catch (Exception expected) {
+            }
+        }
+    }
+
+    @Test
+    public void TestSignatureRequests() throws Exception {
+        for (SignatureRequestVector vector : vectors.signatureRequests) {
+            SignatureRequest sigReq = new SignatureRequest(vector.text);
+            Assert.assertEquals(vector.text, sigReq.string());
+            Assert.assertEquals(vector.electionId, sigReq.getElectionId());
+            Assert.assertEquals(vector.requestId, Hex.encodeHexString(sigReq.getRequestId()));
+            Assert.assertEquals(vector.publicKey, Hex.encodeHexString(sigReq.getPublicKey()));
+            Assert.assertEquals(vector.did, sigReq.getDid());
+            Assert.assertEquals(vector.presentation, sigReq.getPresentation());
+            Assert.assertEquals(vector.denomination, sigReq.getDenomination());
+            Assert.assertEquals(vector.blindBallot, Hex.encodeHexString(sigReq.getBlindBallot()));
+            Assert.assertTrue("Go signature does not verify", sigReq.verifySignature());
+
+            // The same request made and signed in Java is the same, signature and all
+            DIDPrivateKey key = null;
+            for (DIDKeyVector didKey : vectors.didKeys) {
+                if (didKey.publicKey.equals(vector.publicKey)) {
+                    key = DIDPrivateKey.fromHex(didKey.privateKey);
+                }
+            }
+            Assert.assertNotNull(key);
+            SignatureRequest made = new SignatureRequest(vector.electionId, key.publicKey(), vector.did, vector.presentation, vector.denomination, hex(vector.blindBallot));
+            Assert.assertEquals(sigReq.stringWithoutSignature(), made.stringWithoutSignature());
+            made.sign(key);
+            Assert.assertEquals(vector.text, made.string());
+        }
+    }
+
+    @Test
+    public void TestFulfilledSignatureRequests() throws Exception {
+        for (FulfilledSignatureRequestVector vector : vectors.fulfilledSignatureRequests) {
+            FulfilledSignatureRequest fulfilled = new FulfilledSignatureRequest(vector.text);
+            Assert.assertEquals(vector.text, fulfilled.string());
+            Assert.assertEquals(vector.ballotSignature, Hex.encodeHexString(fulfilled.getBallotSignature()));
+            Assert.assertTrue(fulfilled.verifyBallotSignature(clerkKey));
+        }
+    }
+
+    @Test
+    public void TestDIDSignatures() throws Exception {
+        for (DIDSignatureVector vector : conformance.didSignatures) {
+            DIDPublicKey pub = DIDPublicKey.fromHex(vector.publicKey);
+            Assert.assertEquals("Signature " + vector.signature + " of \"" + vector.message + "\"", vector.valid,
+                    pub.verifySignature(hex(vector.signature), vector.message));
+            if (vector.privateKey != null && !vector.privateKey.isEmpty()) {
+                Assert.assertEquals(vector.signature, Hex.encodeHexString(DIDPrivateKey.fromHex(vector.privateKey).sign(vector.message)));
+            }
+        }
+    }
+
+    /**
+     * Every malformed ballot and Signature Request is refused. Where the Go error has a counterpart of the same name
+     * here, it is the one thrown. There are no elections in the Java library, so malformed elections are skipped.
+     */
+    @Test
+    public void TestMalformed() throws Exception {
+        for (MalformedVector vector : conformance.malformed) {
+            Exception thrown = null;
+            try {
+                switch (vector.kind) {
+                    case "election":
+                        continue;
+                    case "ballot":
+                        new Ballot(vector.input);
+                        break;
+                    case "signatureRequest":
+                        new SignatureRequest(vector.input);
+                        break;
+                    case "fulfilledSignatureRequest":
+                        new FulfilledSignatureRequest(vector.input);
+                        break;
+                    default:
+                        Assert.fail("Unknown kind " + vector.kind);
+                }
+            } catch (Exception ex) {
+                thrown = ex;
+            }
+            Assert.assertNotNull(vector.kind + " " + vector.description + " was read", thrown);
+            for (String name : vector.errors) {
+                Exception expected = namedError(name);
+                if (expected != null) {
+                    Assert.assertTrue(vector.kind + " " + vector.description + ": expected " + name + ", got " + thrown.getMessage(),
+                            thrown.getMessage().startsWith(expected.getMessage()));
+                }
+            }
+        }
+    }
+
+    private static Exception namedError(String name) throws Exception {
+        for (Class<?> type : Arrays.asList(Ballot.class, SignatureRequest.class, FulfilledSignatureRequest.class)) {
+            try {
+                return (Exception) type.getField(name).get(null);
+            } catch (NoSuchFieldException ignored) {
+            }
+        }
+        return null;
+    }
+}
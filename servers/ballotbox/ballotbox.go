@@ -2,166 +2,56 @@ package main
 
 import (
 	"database/sql"
-	"encoding/hex"
-	"errors"
-	"fmt"
-	"github.com/elastos/Elastos.ELA.Utility/crypto"
-	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
-	"log"
-	"net/http"
 	"strconv"
-	"strings"
-)
-
-const (
-	ballotsQuery = `CREATE TABLE ballots_<election-id> (
-					  ballot_id varchar(128) NOT NULL, -- TODO: change to 64 on move to SHA256
-					  tags text,
-					  ballot text NOT NULL
-					)ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
-	`
-
-	ballotsQueryIndex = `CREATE INDEX ballot_id_idx_<election-id> ON ballots_<election-id> (ballot_id);`
 
+	"github.com/elastos/Elastos.Service.DIDVote/servers/ballotbox/box"
 )
 
 var (
-	db             *sql.DB
-	ballotClerkKey PublicKey // Used to verify signatures on ballots
-	admins         UserSet   // Admin requests must be signed by an admin. We publish the public keys of all admin users
-	conf           config
+	db     *sql.DB
+	conf   config
+	logger = box.Logger
 )
 
 type config struct {
 	configFilePath string
 	database       struct {
-		driver 			   string
+		driver             string
 		sslmode            string
 		maxIdleConnections int
-		connMaxLifetime	   int
-	}
-	port             int                 // Listen port -- generally it should be 443
-	readmePath       string              // Path to the readme file
-	readme           []byte              // Static content for serving to the root readme (at "/")
-	electionclerkURL string              // URL for electionclerk
-	adminUsers       UserSet             // Admin users. Pulled from electionclerk server on bootstrap
-	clerkKey         PublicKey           // Election Clerk public key. Pulled from electionclerk server on bootstrap
-	elections        map[string]Election // List of valid elections. Pulled from electionclerk server on bootstrap, updated by data pushed from electionclerk.
-}
-
-type parseError struct {
-	Err  string
-	Code int
-}
-
-func (err parseError) Error() string {
-	return err.Err
+		connMaxLifetime    int
+	}
+	log struct {
+		level  string // Log level. One of debug, info, warn, error
+		format string // Log format. Either text or json
+	}
+	port             int        // Listen port -- generally it should be 443
+	readmePath       string     // Path to the readme file
+	readme           []byte     // Static content for serving to the root readme (at "/")
+	mirrorKeyPath    string     // Path to the PEM encoded DID private key that signs tree heads
+	electionclerkURL string     // URL for electionclerk
+	box              box.Config // Admin users, clerk keys and elections pulled from electionclerk, mixing and mirror options, rate limits and timeouts. See box.Config
 }
 
 func main() {
 	bootstrap()
 
-	// Bootstrap is complete, let's serve some REST
-
-	http.HandleFunc("/vote/", voteHandler) // Casting votes and viewing votes. See vote-handler.go
-
-	log.Println("Listning on port " + strconv.Itoa(conf.port))
-
-	err := http.ListenAndServe(":"+strconv.Itoa(conf.port), nil)
-
-	if err != nil {
-		log.Fatal("Error starting http server: ", err)
-	}
-}
+	// Bootstrap is complete, let's serve some REST. See the box package for the handlers
+	server := box.NewServer(conf.box, box.NewMySQLStore(db))
+	server.StartSweeper()
 
-// When a voter or an admin makes a priviledged request that requires verification
-// of their public-key, they are required to include the following HTTP headers:
-// 1. X-Public-Key: The user's base64 encoded public key.
-// 2. X-Signature: Signature for this request. The user should sign the HTTP request string which includes
-//    the method and the path (for example PUT /vote/1234/939fhdsjkksdkl0903f). This signature should be
-//    base64 encoded
-// This function verifies that these headers are constructed properly and that the signature
-// cryptographically signs the request. This function does not check the cryptographic veracity of the body.
-func verifySignatureHeaders(r *http.Request) error {
-	rawpk := r.Header.Get("X-Public-Key")
-	if rawpk == "" {
-		return errors.New("Missing X-Public-Key header. ")
+	if conf.box.Mixing.Enabled {
+		server.StartMixer()
 	}
-	rawsig := r.Header.Get("X-Signature")
-	if rawsig == "" {
-		return errors.New("Missing X-Signature header. ")
+	if len(conf.box.Mirror.Peers) != 0 {
+		server.StartGossip()
 	}
-	sig, err := hex.DecodeString(rawsig)
 
-	if err != nil {
-		return errors.New("Error parsing X-Signature header. " + err.Error())
-	}
+	logger.WithField("port", conf.port).Info("Listening")
 
-	pub , err := hex.DecodeString(rawpk)
-	if err != nil {
-		return errors.New("invalid did public key")
-	}
-	publicKey , err := crypto.DecodePoint(pub)
-	if err != nil {
-		return err
-	}
-	didPublicKey := DIDPublicKey{*publicKey}
-	// Verify the signature against the request string. For example PUT /vote/1234/939fhdsjkksdkl0903f...
-	err = didPublicKey.VerifySignature(sig, []byte(r.Method+" "+r.RequestURI))
-	if err != nil {
-		return errors.New("Cryptographic verification of X-Signature header failed. " + err.Error())
-	}
-	return nil
-}
+	err := server.HTTPServer(":" + strconv.Itoa(conf.port)).ListenAndServe()
 
-// Sync Elections to database tables
-// This will create database tables for any election that doesn't already have one
-// If a database table is found starting with `ballots_` that doesn't correspond to a
-// an Election an error will occur.
-func syncElectionToDB(elections map[string]Election) error {
-	// Build a list of elections found in the database
-	electionsInDB := make(map[string]bool)
-	rows, err := db.Query("SELECT table_name FROM information_schema.tables WHERE table_name LIKE 'ballots_%'")
 	if err != nil {
-		return err
-	}
-	defer rows.Close()
-	for rows.Next() {
-		var tablename string
-		err := rows.Scan(&tablename)
-		if err != nil {
-			return err
-		}
-		electionID := strings.TrimPrefix(tablename, "ballots_")
-		electionsInDB[electionID] = true
-	}
-
-	// Compare elections in the database to elections passes in
-	// Create any missing tables
-	for electionID, _ := range elections {
-		if electionsInDB[electionID] {
-			// Election matches - mark as false to denote that it has been processed and is OK
-			electionsInDB[electionID] = false
-		} else {
-			// Create missing table
-			_, err = db.Exec(strings.Replace(ballotsQuery, "<election-id>", electionID, -1))
-			if err != nil {
-				return err
-			}
-			_, err = db.Exec(strings.Replace(ballotsQueryIndex, "<election-id>", electionID, -1))
-			if err != nil {
-				return err
-			}
-		}
+		logger.WithError(err).Fatal("Error starting http server")
 	}
-
-	// Check for extrenous elections in the database. If any remaining items in the map are TRUE we have an extraneous table
-	for tablename, extra := range electionsInDB {
-		if extra {
-			return fmt.Errorf("Found extraneous %s table in database. This table does not correspond to any existing election.", tablename)
-		}
-	}
-
-	// Success
-	return nil
 }
@@ -1,25 +1,22 @@
 package main
 
 import (
-	"bytes"
-	"crypto/rsa"
-	"crypto/x509"
 	"database/sql"
-	"encoding/pem"
 	"errors"
 	"flag"
 	"io/ioutil"
-	"log"
-	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/cryptoballot/entropychecker"
 	"github.com/dlintw/goconf"
-	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/ballotbox/box"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/ratelimit"
 	_ "github.com/go-sql-driver/mysql"
 )
 
@@ -30,7 +27,7 @@ func bootstrap() {
 	if runtime.GOOS == "linux" {
 		err := entropychecker.WaitForEntropy()
 		if err != nil {
-			log.Fatal(err)
+			logger.Fatal(err)
 		}
 	}
 
@@ -42,18 +39,23 @@ func bootstrap() {
 
 	c, err := NewConfigFromFile(*configPathOpt)
 	if err != nil {
-		log.Fatal("Error parsing config file. ", err)
+		logger.WithError(err).Fatal("Error parsing config file")
 	}
 	conf = *c
 
+	err = box.ConfigureLogger(conf.log.level, conf.log.format)
+	if err != nil {
+		logger.WithError(err).Fatal("Error configuring logger")
+	}
+
 	// Connect to the database and set-up
 	db, err = sql.Open("mysql", conf.databaseConnectionString())
 	if err != nil {
-		log.Fatal("Database connection error: ", err)
+		logger.WithError(err).Fatal("Database connection error")
 	}
 	err = db.Ping()
 	if err != nil {
-		log.Fatal("Database connection error: ", err)
+		logger.WithError(err).Fatal("Database connection error")
 	}
 	// Set the maximum number of idle connections in the connection pool. `-1` means default (2 idle connections in the pool)
 	if conf.database.maxIdleConnections != -1 {
@@ -62,14 +64,18 @@ func bootstrap() {
 	// ConnMaxLifetime unit is second
 	db.SetConnMaxLifetime(time.Duration(conf.database.connMaxLifetime * 1000 * 1000 * 1000))
 
-	// Sync elections to database tables
-	err = syncElectionToDB(conf.elections)
+	// Sync elections to database tables, now and whenever elections are pulled from the election clerk
+	store := box.NewMySQLStore(db)
+	err = store.Sync(conf.box.Elections.Map(), conf.box.Mixing.Enabled)
 	if err != nil {
-		log.Fatal("Error syncing elections to database: ", err)
+		logger.WithError(err).Fatal("Error syncing elections to database")
+	}
+	conf.box.Elections.Sync = func(elections map[string]cryptoballot.Election) error {
+		return store.Sync(elections, conf.box.Mixing.Enabled)
 	}
 }
 
-//@@TEST: loading known good config from file
+// @@TEST: loading known good config from file
 func NewConfigFromFile(filepath string) (*config, error) {
 	conf := config{
 		configFilePath: filepath,
@@ -125,6 +131,140 @@ func NewConfigFromFile(filepath string) (*config, error) {
 		conf.database.connMaxLifetime = 14440
 	}
 
+	// Parse logging options. Default to info level with text output.
+	conf.log.level = "info"
+	if c.HasOption("log", "level") {
+		conf.log.level, err = c.GetString("log", "level")
+		if err != nil {
+			return nil, err
+		}
+	}
+	conf.log.format = "text"
+	if c.HasOption("log", "format") {
+		conf.log.format, err = c.GetString("log", "format")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Parse mixing options. Mixing is off by default.
+	if c.HasOption("mixing", "enabled") {
+		conf.box.Mixing.Enabled, err = c.GetBool("mixing", "enabled")
+		if err != nil {
+			return nil, err
+		}
+	}
+	conf.box.Mixing.BatchSize = 20
+	if c.HasOption("mixing", "batch-size") {
+		conf.box.Mixing.BatchSize, err = c.GetInt("mixing", "batch-size")
+		if err != nil {
+			return nil, err
+		}
+	}
+	interval := 60
+	if c.HasOption("mixing", "interval") {
+		interval, err = c.GetInt("mixing", "interval")
+		if err != nil {
+			return nil, err
+		}
+	}
+	conf.box.Mixing.Interval = time.Duration(interval) * time.Second
+	if conf.box.Mixing.BatchSize < 1 || interval < 1 {
+		return nil, errors.New("mixing batch-size and interval must both be at least 1")
+	}
+
+	// Parse mirror options. A ballotbox has no peers and signs no tree heads by default.
+	if c.HasOption("mirror", "peers") {
+		peers, err := c.GetString("mirror", "peers")
+		if err != nil {
+			return nil, err
+		}
+		for _, peer := range strings.Split(peers, ",") {
+			if peer = strings.TrimSpace(peer); peer != "" {
+				if _, err = url.Parse(peer); err != nil {
+					return nil, err
+				}
+				conf.box.Mirror.Peers = append(conf.box.Mirror.Peers, peer)
+			}
+		}
+	}
+	if c.HasOption("mirror", "key") {
+		conf.mirrorKeyPath, err = c.GetString("mirror", "key")
+		if err != nil {
+			return nil, err
+		}
+	}
+	gossipInterval := 30
+	if c.HasOption("mirror", "interval") {
+		gossipInterval, err = c.GetInt("mirror", "interval")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if gossipInterval < 1 {
+		return nil, errors.New("mirror interval must be at least 1")
+	}
+	conf.box.Mirror.Interval = time.Duration(gossipInterval) * time.Second
+
+	// Parse batch options. Options that are not given are left to box.NewServer's defaults. See box/batch.go
+	batchOptions := []struct {
+		option string
+		value  *int
+	}{
+		{"max-ballots", &conf.box.Batch.MaxBallots},
+		{"workers", &conf.box.Batch.Workers},
+		{"tx-size", &conf.box.Batch.TxSize},
+	}
+	for _, o := range batchOptions {
+		if c.HasOption("batch", o.option) {
+			*o.value, err = c.GetInt("batch", o.option)
+			if err != nil {
+				return nil, err
+			}
+			if *o.value < 1 {
+				return nil, errors.New("batch " + o.option + " must be at least 1")
+			}
+		}
+	}
+
+	// Parse rate limits and timeouts. See box/ratelimit.go
+	conf.box.RateLimits.IP, err = ratelimit.ParseConfig(c, "ip", ratelimit.Limit{Rate: 10, Burst: 20})
+	if err != nil {
+		return nil, err
+	}
+	conf.box.RateLimits.Key, err = ratelimit.ParseConfig(c, "key", ratelimit.Limit{Rate: 1, Burst: 5})
+	if err != nil {
+		return nil, err
+	}
+	if c.HasOption("ratelimit", "trust-proxy") {
+		conf.box.RateLimits.TrustProxy, err = c.GetBool("ratelimit", "trust-proxy")
+		if err != nil {
+			return nil, err
+		}
+	}
+	timeouts := []struct {
+		option   string
+		timeout  *time.Duration
+		fallback int
+	}{
+		{"read-header-timeout", &conf.box.Timeouts.ReadHeader, 10},
+		{"read-timeout", &conf.box.Timeouts.Read, 30},
+		{"write-timeout", &conf.box.Timeouts.Write, 300},
+		{"idle-timeout", &conf.box.Timeouts.Idle, 120},
+	}
+	for _, t := range timeouts {
+		seconds := t.fallback
+		if c.HasOption("http", t.option) {
+			seconds, err = c.GetInt("http", t.option)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if seconds < 0 {
+			return nil, errors.New("http " + t.option + " must not be negative")
+		}
+		*t.timeout = time.Duration(seconds) * time.Second
+	}
 
 	// Parse election-clerk URL
 	conf.electionclerkURL, err = c.GetString("", "electionclerk-url")
@@ -136,6 +276,20 @@ func NewConfigFromFile(filepath string) (*config, error) {
 		return nil, err
 	}
 
+	// Parse how often elections are pulled from the election-clerk. 0 only pulls them on startup
+	electionTTL := 60
+	if c.HasOption("", "election-ttl") {
+		electionTTL, err = c.GetInt("", "election-ttl")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if electionTTL < 0 {
+		return nil, errors.New("election-ttl must not be negative")
+	}
+	conf.box.Elections = box.NewElectionRegistry()
+	conf.box.Elections.TTL = time.Duration(electionTTL) * time.Second
+
 	// Ingest the readme
 	conf.readmePath, err = c.GetString("", "readme")
 	if err != nil {
@@ -149,7 +303,7 @@ func NewConfigFromFile(filepath string) (*config, error) {
 	}
 
 	// Update From BallotClerk
-	err = UpdateConfigFromBallotClerk(&conf)
+	err = conf.box.UpdateFromClerk(conf.electionclerkURL)
 	if err != nil {
 		return nil, err
 	}
@@ -166,76 +320,21 @@ func configProcessFiles(conf *config) error {
 		return err
 	}
 
-	return nil
-}
-
-func UpdateConfigFromBallotClerk(conf *config) error {
-	// Get the ballot-clerk public key
-	body, err := httpGetAll(conf.electionclerkURL + "/publickey")
-	if err != nil {
-		return err
-	}
-	PEMBlock, _ := pem.Decode(body)
-	if PEMBlock.Type != "PUBLIC KEY" {
-		return errors.New("Could not parse Election Clerk Public Key")
-	}
-	cryptoKey, err := x509.ParsePKIXPublicKey(PEMBlock.Bytes)
-	if err != nil {
-		return err
-	}
-	conf.clerkKey, err = NewPublicKeyFromCryptoKey(cryptoKey.(*rsa.PublicKey))
-	if err != nil {
-		return err
-	}
-
-	// Get the admin users
-	body, err = httpGetAll(conf.electionclerkURL + "/admins")
-	if err != nil {
-		return err
-	}
-	conf.adminUsers, err = NewUserSet(body)
-	if err != nil {
-		return err
-	}
-
-	// Get the list of elections
-	body, err = httpGetAll(conf.electionclerkURL + "/election")
-	if err != nil {
-		return err
-	}
-	if len(body) != 0 {
-		rawElections := bytes.Split(body, []byte("\n\n\n"))
-		conf.elections = make(map[string]Election, len(rawElections))
-
-		for _, rawElection := range rawElections {
-			election, err := NewElection(rawElection)
-			if err != nil {
-				return err
-			}
-			conf.elections[election.ElectionID] = *election
+	// Load the key that signs tree heads
+	if conf.mirrorKeyPath != "" {
+		pemKey, err := ioutil.ReadFile(conf.mirrorKeyPath)
+		if err != nil {
+			return err
+		}
+		conf.box.Mirror.Key, err = cryptoballot.NewDIDPrivateKeyFromPEM(pemKey)
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-// Given a URL, do the request and get the body as a byte slice
-func httpGetAll(url string) ([]byte, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return nil, errors.New("Received " + resp.Status + " from " + url)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
-}
-
 func (conf *config) databaseConnectionString() (connection string) {
 
 	return conf.database.driver
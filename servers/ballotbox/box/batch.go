@@ -0,0 +1,239 @@
+package box
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/httplog"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/ratelimit"
+	"github.com/sirupsen/logrus"
+)
+
+// Batch submission
+//
+// Voters tend to cast their ballots all at once as a big election closes, so ballots may also be cast in batches by
+// POSTing them to /vote/<election-id>, in the text format separated by "\n\n\n", or as a JSON array. A batch goes
+// through a pipeline: a pool of Config.Batch.Workers goroutines parses the ballots and verifies the clerk's
+// signatures, while the ballots they pass are saved Config.Batch.TxSize at a time, each lot in one transaction.
+// Duplicate ballot-ids are refused by the store's unique index rather than looked up before saving.
+//
+// The response gives a result for each ballot, in the order they were sent, with the status that casting the
+// ballot on its own with PUT would have been given. A ballot failing does not stop the others being saved.
+
+const (
+	defaultBatchMaxBallots = 1000 // Most ballots that may be cast in one batch
+	defaultBatchTxSize     = 100  // Most ballots saved in one transaction
+)
+
+// batchResult is the outcome of casting one ballot in a batch
+type batchResult struct {
+	BallotID string `json:"ballotId,omitempty" description:"Empty if the ballot could not be read"`
+	Status   int    `json:"status" description:"The status the ballot would have been given if it had been cast on its own with PUT"`
+	Code     string `json:"code,omitempty" description:"Error class if the ballot was refused"`
+	Message  string `json:"message,omitempty"`
+}
+
+// String gets the result as a line of the text response: the ballot-id, or "-" if the ballot could not be read,
+// the status, and the message if the ballot was refused
+func (result batchResult) String() string {
+	ballotID := result.BallotID
+	if ballotID == "" {
+		ballotID = "-"
+	}
+	line := ballotID + " " + strconv.Itoa(result.Status)
+	if result.Message != "" {
+		line += " " + result.Message
+	}
+	return line
+}
+
+// handlePOSTVoteBatch casts a batch of ballots for an election
+func (s *Server) handlePOSTVoteBatch(w http.ResponseWriter, r *http.Request, electionID string) {
+	m := newHandlerMetric("handlePOSTVoteBatch")
+	defer m.observe()
+
+	election, ok := s.conf.Elections.Get(electionID)
+	if !ok {
+		writeError(w, r, m, http.StatusNotFound, errClassNotFound, "Election not found")
+		return
+	}
+	m.setElection(electionID)
+
+	if !s.electionIsOpen(&election) {
+		writeError(w, r, m, http.StatusBadRequest, errClassClosed, "Election is not open for voting")
+		return
+	}
+
+	body, err := ratelimit.ReadBody(w, r, s.conf.Batch.MaxBallots*(MaxBallotSize+3))
+	if err != nil {
+		writeBodyError(w, r, m, err)
+		return
+	}
+	rawBallots, err := splitBatch(body, httplog.IsJSON(r))
+	if err != nil {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, "Error reading ballots. "+err.Error())
+		return
+	}
+	if len(rawBallots) == 0 {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, "No ballots were given")
+		return
+	}
+	if len(rawBallots) > s.conf.Batch.MaxBallots {
+		writeError(w, r, m, http.StatusRequestEntityTooLarge, errClassTooLarge, "At most "+strconv.Itoa(s.conf.Batch.MaxBallots)+" ballots may be cast at once")
+		return
+	}
+
+	results := s.ingest(requestLogger(r), &election, rawBallots, httplog.IsJSON(r))
+	if httplog.WantsJSON(r) {
+		writeJSON(w, r, m, results)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, result := range results {
+		w.Write([]byte(result.String() + "\n"))
+	}
+}
+
+// splitBatch splits the body of a batch into ballots. JSON ballots are converted to the text format by the workers
+func splitBatch(body []byte, asJSON bool) ([][]byte, error) {
+	if asJSON {
+		var elements []json.RawMessage
+		if err := json.Unmarshal(body, &elements); err != nil {
+			return nil, err
+		}
+		rawBallots := make([][]byte, len(elements))
+		for i, element := range elements {
+			rawBallots[i] = element
+		}
+		return rawBallots, nil
+	}
+
+	var rawBallots [][]byte
+	for _, rawBallot := range bytes.Split(body, []byte("\n\n\n")) {
+		if len(bytes.TrimSpace(rawBallot)) != 0 {
+			rawBallots = append(rawBallots, rawBallot)
+		}
+	}
+	return rawBallots, nil
+}
+
+// ingest runs a batch of ballots through the pipeline, and gets the result for each
+func (s *Server) ingest(log *logrus.Entry, election *Election, rawBallots [][]byte, asJSON bool) []batchResult {
+	results := make([]batchResult, len(rawBallots))
+	ballots := make([]*Ballot, len(rawBallots))
+
+	// The workers pass on the index of each ballot that is valid. They do not wait for a lot to be saved
+	jobs := make(chan int)
+	verified := make(chan int, len(rawBallots))
+	var wg sync.WaitGroup
+	for i := 0; i < s.conf.Batch.Workers && i < len(rawBallots); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				ballots[j], results[j] = s.verifyBatchBallot(election, rawBallots[j], asJSON)
+				if ballots[j] != nil {
+					verified <- j
+				}
+			}
+		}()
+	}
+	go func() {
+		for i := range rawBallots {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(verified)
+	}()
+
+	lot := make([]int, 0, s.conf.Batch.TxSize)
+	for i := range verified {
+		lot = append(lot, i)
+		if len(lot) == s.conf.Batch.TxSize {
+			s.saveLot(log, ballots, results, lot)
+			lot = lot[:0]
+		}
+	}
+	if len(lot) != 0 {
+		s.saveLot(log, ballots, results, lot)
+	}
+
+	for _, result := range results {
+		code := result.Code
+		if code == "" {
+			code = errClassNone
+		}
+		batchBallots.WithLabelValues(election.ElectionID, code).Inc()
+	}
+	return results
+}
+
+// verifyBatchBallot parses a ballot from a batch and verifies its signature. The ballot is nil if it is refused,
+// and the result says why.
+func (s *Server) verifyBatchBallot(election *Election, rawBallot []byte, asJSON bool) (*Ballot, batchResult) {
+	if asJSON {
+		var decoded Ballot
+		if err := json.Unmarshal(rawBallot, &decoded); err != nil {
+			return nil, batchResult{Status: http.StatusBadRequest, Code: errClassBadRequest, Message: "Error reading ballot. " + err.Error()}
+		}
+		rawBallot = []byte(decoded.String())
+	}
+	ballot, err := NewBallot(rawBallot)
+	if err != nil {
+		return nil, batchResult{Status: http.StatusBadRequest, Code: errClassBadRequest, Message: "Error reading ballot. " + err.Error()}
+	}
+	result := batchResult{BallotID: ballot.BallotID}
+	if ballot.ElectionID != election.ElectionID {
+		result.Status, result.Code, result.Message = http.StatusBadRequest, errClassBadRequest, "Ballot is for another election"
+		return nil, result
+	}
+	if err = s.verifyBallotSignature(election, ballot); err != nil {
+		verificationFailures.WithLabelValues(election.ElectionID, "batch_ballot_signature").Inc()
+		result.Status, result.Code, result.Message = http.StatusBadRequest, errClassVerification, "Error verifying ballot signature. "+err.Error()
+		return nil, result
+	}
+	return ballot, result
+}
+
+// saveLot saves the ballots at the given indexes in one transaction, and records their results. When mixing, they
+// are held back to be published later as part of a shuffled batch.
+func (s *Server) saveLot(log *logrus.Entry, ballots []*Ballot, results []batchResult, lot []int) {
+	toSave := make([]*Ballot, len(lot))
+	for i, j := range lot {
+		toSave[i] = ballots[j]
+	}
+
+	var (
+		saved  []bool
+		err    error
+		status = http.StatusOK
+	)
+	if s.conf.Mixing.Enabled {
+		saved, err = s.store.SavePendingBallots(toSave)
+		status = http.StatusAccepted
+	} else {
+		saved, err = s.store.SaveBallots(toSave)
+	}
+	if err != nil {
+		log.WithError(err).WithField("code", errClassDatabase).Error("internal error while saving a batch of ballots")
+	}
+
+	for i, j := range lot {
+		switch {
+		case err != nil:
+			results[j].Status, results[j].Code, results[j].Message = http.StatusInternalServerError, errClassDatabase, "Internal server error"
+		case !saved[i]:
+			results[j].Status, results[j].Code, results[j].Message = http.StatusForbidden, errClassDuplicate, "Ballot with this ID already exists"
+		default:
+			results[j].Status = status
+			if !s.conf.Mixing.Enabled {
+				s.trees.add(toSave[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Package box implements the ballotbox's REST service.
+//
+// The ballotbox accepts ballots that have been signed by the election clerk and publishes them. Several ballotboxes
+// may mirror each other's ballots. See mirror.go. A Server is built from a Config and a Store, so it may be run
+// against MySQL by the ballotbox binary, or against an in-memory store in tests.
+package box
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/ratelimit"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Config holds everything the ballotbox needs to serve requests
+type Config struct {
+	AdminUsers       UserSet           // Admin users. Pulled from electionclerk server on bootstrap
+	ClerkKey         PublicKey         // Election Clerk public key. Used to verify signatures on ballots
+	DenominationKeys DenominationKeys  // Election Clerk public keys by denomination. Used to verify signatures on ballots in weighted elections
+	Elections        *ElectionRegistry // Valid elections. Pulled from electionclerk server on bootstrap, and again once older than Elections.TTL. See elections.go
+	Mixing           struct {
+		Enabled   bool          // Hold ballots back and publish them in shuffled batches. See mixing.go
+		BatchSize int           // Minimum number of pending ballots before a batch is published
+		Interval  time.Duration // Time between checks for a publishable batch
+	}
+	Mirror struct {
+		Peers    []string      // URLs of the other ballotbox mirrors to pull ballots from. See mirror.go
+		Key      DIDPrivateKey // Signs this mirror's tree heads. Tree heads are not served if nil
+		Interval time.Duration // Time between pulls from the peers
+	}
+	Batch struct {
+		MaxBallots int // Most ballots that may be cast in one batch. See batch.go
+		Workers    int // Goroutines verifying the ballots of a batch. Defaults to the number of CPUs
+		TxSize     int // Most ballots of a batch saved in one transaction
+	}
+	Clock    func() time.Time // Returns the current time. Defaults to time.Now
+	Timeouts Timeouts         // Timeouts for slow clients. See HTTPServer
+
+	// RateLimits throttle requests per client IP address, and per key that signs a request. See ratelimit.go
+	RateLimits struct {
+		IP         RateLimit
+		Key        RateLimit
+		TrustProxy bool // Take the client IP address from X-Forwarded-For, as set by a trusted reverse proxy
+	}
+}
+
+// Server is the ballotbox REST service
+type Server struct {
+	conf  Config
+	store Store
+
+	ipLimiter  *ratelimit.Limiter // See ratelimit.go
+	keyLimiter *ratelimit.Limiter
+	trees      *ballotTrees // See mirror.go
+}
+
+type parseError struct {
+	Err  string
+	Code int
+}
+
+func (err parseError) Error() string {
+	return err.Err
+}
+
+// NewServer creates a ballotbox that keeps its ballots in the given store
+func NewServer(conf Config, store Store) *Server {
+	if conf.Clock == nil {
+		conf.Clock = time.Now
+	}
+	if conf.Elections == nil {
+		conf.Elections = NewElectionRegistry()
+	}
+	if conf.Elections.Clock == nil {
+		conf.Elections.Clock = conf.Clock
+	}
+	if conf.Batch.MaxBallots == 0 {
+		conf.Batch.MaxBallots = defaultBatchMaxBallots
+	}
+	if conf.Batch.Workers == 0 {
+		conf.Batch.Workers = runtime.NumCPU()
+	}
+	if conf.Batch.TxSize == 0 {
+		conf.Batch.TxSize = defaultBatchTxSize
+	}
+	return &Server{
+		conf:       conf,
+		store:      store,
+		ipLimiter:  ratelimit.New(conf.RateLimits.IP, conf.Clock),
+		keyLimiter: ratelimit.New(conf.RateLimits.Key, conf.Clock),
+		trees:      &ballotTrees{trees: make(map[string]*ballotTree)},
+	}
+}
+
+// Handler gets an http.Handler that serves all of the ballotbox's routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/vote/", withLogging("vote", s.withRateLimit(s.voteHandler)))              // Casting votes and viewing votes. See vote-handler.go
+	mux.Handle("/treehead/", withLogging("treehead", s.withRateLimit(s.treeHeadHandler)))  // Signed tree heads of published ballots. See mirror.go
+	mux.Handle("/openapi.json", withLogging("openapi", s.withRateLimit(s.openAPIHandler))) // Describes the JSON API. See openapi.go
+	mux.Handle("/metrics", promhttp.Handler())                                             // Prometheus metrics. See metrics.go
+	return mux
+}
+
+// UpdateFromClerk pulls the election clerk's public keys, the admin users, and the list of elections from the election clerk.
+// The elections are pulled again later if conf.Elections has a TTL.
+func (conf *Config) UpdateFromClerk(electionclerkURL string) error {
+	// Get the ballot-clerk public key
+	body, err := httpGetAll(electionclerkURL + "/publickey")
+	if err != nil {
+		return err
+	}
+	PEMBlock, _ := pem.Decode(body)
+	if PEMBlock == nil || PEMBlock.Type != "PUBLIC KEY" {
+		return errors.New("Could not parse Election Clerk Public Key")
+	}
+	cryptoKey, err := x509.ParsePKIXPublicKey(PEMBlock.Bytes)
+	if err != nil {
+		return err
+	}
+	conf.ClerkKey, err = NewPublicKeyFromCryptoKey(cryptoKey.(*rsa.PublicKey))
+	if err != nil {
+		return err
+	}
+
+	// Get the ballot-clerk public keys for weighted elections
+	body, err = httpGetAll(electionclerkURL + "/publickeys")
+	if err != nil {
+		return err
+	}
+	conf.DenominationKeys, err = NewDenominationKeys(body)
+	if err != nil {
+		return err
+	}
+
+	// Get the admin users
+	body, err = httpGetAll(electionclerkURL + "/admins")
+	if err != nil {
+		return err
+	}
+	conf.AdminUsers, err = NewUserSet(body)
+	if err != nil {
+		return err
+	}
+
+	// Get the list of elections
+	if conf.Elections == nil {
+		conf.Elections = NewElectionRegistry()
+	}
+	if conf.Elections.Clock == nil {
+		conf.Elections.Clock = conf.Clock
+	}
+	conf.Elections.ClerkURL = electionclerkURL
+	return conf.Elections.Refresh()
+}
+
+// Given a URL, do the request and get the body as a byte slice
+func httpGetAll(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errors.New("Received " + resp.Status + " from " + url)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// When a voter or an admin makes a priviledged request that requires verification
+// of their public-key, they are required to include the following HTTP headers:
+//  1. X-Public-Key: The user's base64 encoded public key.
+//  2. X-Signature: Signature for this request. The user should sign the HTTP request string which includes
+//     the method and the path (for example PUT /vote/1234/939fhdsjkksdkl0903f). This signature should be
+//     base64 encoded
+//
+// This function verifies that these headers are constructed properly and that the signature
+// cryptographically signs the request. This function does not check the cryptographic veracity of the body.
+func verifySignatureHeaders(r *http.Request) error {
+	rawpk := r.Header.Get("X-Public-Key")
+	if rawpk == "" {
+		return errors.New("Missing X-Public-Key header. ")
+	}
+	rawsig := r.Header.Get("X-Signature")
+	if rawsig == "" {
+		return errors.New("Missing X-Signature header. ")
+	}
+	sig, err := hex.DecodeString(rawsig)
+
+	if err != nil {
+		return errors.New("Error parsing X-Signature header. " + err.Error())
+	}
+
+	pub, err := hex.DecodeString(rawpk)
+	if err != nil {
+		return errors.New("invalid did public key")
+	}
+	publicKey, err := crypto.DecodePoint(pub)
+	if err != nil {
+		return err
+	}
+	didPublicKey := DIDPublicKey{PublicKey: *publicKey}
+	// Verify the signature against the request string. For example PUT /vote/1234/939fhdsjkksdkl0903f...
+	err = didPublicKey.VerifySignature(sig, []byte(r.Method+" "+r.RequestURI))
+	if err != nil {
+		return errors.New("Cryptographic verification of X-Signature header failed. " + err.Error())
+	}
+	return nil
+}
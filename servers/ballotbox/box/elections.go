@@ -0,0 +1,244 @@
+package box
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+// Elections
+//
+// The ballotbox only accepts ballots for elections it has pulled from the election clerk. Every handler looks
+// elections up concurrently, while elections created at the clerk are pulled in the background once the elections
+// are older than the TTL, or when a ballot arrives for an election the ballotbox does not know yet. The elections
+// are kept in a map that is never modified once it is shared. A pull builds a new map and swaps it in, so lookups
+// only hold the read lock for the swap.
+//
+// Only one pull goes to the clerk at a time. Lookups of an unknown election wait for the pull in flight, or start
+// one, but not more than once per missInterval, so that requests for elections that do not exist cannot flood the
+// clerk. Lookups of a known election never wait: if the elections are stale they start a pull and carry on.
+//
+// A pull is conditional on the ETag of the previous one, so the clerk answers 304 Not Modified, and the
+// elections are not parsed again, unless an election has been created since.
+
+// missInterval is the least time between pulls started by lookups of unknown elections
+const missInterval = time.Second
+
+// electionClient fetches elections from the election clerk. Lookups may wait on a pull, so it must not hang.
+var electionClient = &http.Client{Timeout: 10 * time.Second}
+
+// ElectionRegistry holds the elections the ballotbox accepts ballots for. It is safe for concurrent use.
+// The zero value holds no elections, and is not refreshed until ClerkURL and TTL are set.
+type ElectionRegistry struct {
+	ClerkURL string           // Election clerk the elections are pulled from. Set by Config.UpdateFromClerk
+	TTL      time.Duration    // How long the elections are used before they are pulled again. Zero never pulls them again
+	Clock    func() time.Time // Returns the current time. Set from Config.Clock by Config.UpdateFromClerk or NewServer
+
+	// Sync prepares the store for the elections before they are used, such as MySQLStore.Sync. It is given every
+	// election, not only new ones. A refresh fails without changing the elections if it returns an error.
+	Sync func(elections map[string]Election) error
+
+	mu        sync.RWMutex
+	elections map[string]Election // Replaced as a whole, never modified
+	etag      string              // ETag of the clerk's response the elections were read from
+	fetched   time.Time           // When the last pull from the clerk started, successful or not
+	pulling   *pull               // The pull in flight, if any
+}
+
+// pull is a pull from the clerk. Everything that needs the elections pulled while it is in flight waits on it.
+type pull struct {
+	done chan struct{} // Closed once the pull has finished
+	err  error
+}
+
+// NewElectionRegistry creates a registry holding the given elections, which is not refreshed from the clerk until
+// ClerkURL and TTL are set
+func NewElectionRegistry(elections ...Election) *ElectionRegistry {
+	registry := &ElectionRegistry{elections: make(map[string]Election, len(elections))}
+	for _, election := range elections {
+		registry.elections[election.ElectionID] = election
+	}
+	return registry
+}
+
+// Get looks up an election. An unknown election waits for the elections to be pulled from the clerk, unless they
+// were pulled within missInterval. If the pull fails the election is not found.
+func (registry *ElectionRegistry) Get(electionID string) (Election, bool) {
+	registry.mu.Lock()
+	election, ok := registry.elections[electionID]
+	var p *pull
+	switch {
+	case ok:
+		registry.pullIfStale()
+	case registry.pulling != nil:
+		p = registry.pulling
+	case registry.pulls() && registry.now().Sub(registry.fetched) >= missInterval:
+		p = registry.startPull()
+	}
+	registry.mu.Unlock()
+	if p == nil {
+		return election, ok
+	}
+
+	<-p.done
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	election, ok = registry.elections[electionID]
+	return election, ok
+}
+
+// All gets every election, ordered by election ID. Like Get, it starts a pull in the background if they are stale.
+func (registry *ElectionRegistry) All() []Election {
+	registry.mu.Lock()
+	registry.pullIfStale()
+	elections := registry.elections
+	registry.mu.Unlock()
+
+	all := make([]Election, 0, len(elections))
+	for _, election := range elections {
+		all = append(all, election)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ElectionID < all[j].ElectionID })
+	return all
+}
+
+// Map gets every election, keyed by election ID. The map is a copy, so the caller may modify it.
+func (registry *ElectionRegistry) Map() map[string]Election {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	elections := make(map[string]Election, len(registry.elections))
+	for electionID, election := range registry.elections {
+		elections[electionID] = election
+	}
+	return elections
+}
+
+// Refresh pulls the elections from the clerk now, whatever their age, and waits for the pull to finish. A pull
+// already in flight may have started before an election was created, so it is waited out first. Elections are never
+// removed, since the clerk does not remove them, and an election already held is never changed.
+func (registry *ElectionRegistry) Refresh() error {
+	registry.mu.RLock()
+	p := registry.pulling
+	registry.mu.RUnlock()
+	if p != nil {
+		<-p.done
+	}
+
+	registry.mu.Lock()
+	p = registry.pulling
+	if p == nil {
+		p = registry.startPull()
+	}
+	registry.mu.Unlock()
+	<-p.done
+	return p.err
+}
+
+// pullIfStale starts a pull in the background if the elections are older than the TTL. The caller must hold the
+// write lock. Errors are logged, and the elections already held are kept.
+func (registry *ElectionRegistry) pullIfStale() {
+	if registry.pulls() && registry.pulling == nil && registry.now().Sub(registry.fetched) >= registry.TTL {
+		registry.startPull()
+	}
+}
+
+// pulls checks if the elections are ever pulled again
+func (registry *ElectionRegistry) pulls() bool {
+	return registry.ClerkURL != "" && registry.TTL > 0
+}
+
+// startPull starts pulling the elections from the clerk. The caller must hold the write lock, and there must not be
+// a pull in flight. The attempt is recorded first, so that after a failure the clerk is not asked again until the
+// TTL has passed.
+func (registry *ElectionRegistry) startPull() *pull {
+	p := &pull{done: make(chan struct{})}
+	registry.pulling = p
+	registry.fetched = registry.now()
+	etag := registry.etag
+	go func() {
+		p.err = registry.refresh(etag)
+		if p.err != nil {
+			Logger.WithError(p.err).Error("Error refreshing elections from the election clerk")
+		}
+		registry.mu.Lock()
+		registry.pulling = nil
+		registry.mu.Unlock()
+		close(p.done)
+	}()
+	return p
+}
+
+// refresh pulls the elections from the clerk, conditional on the ETag of the previous pull. It is only run by
+// startPull, so that there is never more than one at a time.
+func (registry *ElectionRegistry) refresh(etag string) error {
+	body, newETag, err := fetchElections(registry.ClerkURL, etag)
+	if err != nil || body == nil {
+		return err
+	}
+
+	elections := registry.Map()
+	if len(body) != 0 {
+		for _, rawElection := range bytes.Split(body, []byte("\n\n\n")) {
+			election, err := NewElection(rawElection)
+			if err != nil {
+				return err
+			}
+			if _, ok := elections[election.ElectionID]; !ok {
+				elections[election.ElectionID] = *election
+			}
+		}
+	}
+	if registry.Sync != nil {
+		if err = registry.Sync(elections); err != nil {
+			return err
+		}
+	}
+
+	registry.mu.Lock()
+	registry.elections = elections
+	registry.etag = newETag
+	registry.mu.Unlock()
+	return nil
+}
+
+func (registry *ElectionRegistry) now() time.Time {
+	if registry.Clock == nil {
+		return time.Now()
+	}
+	return registry.Clock()
+}
+
+// fetchElections gets every election from the clerk, separated by "\n\n\n". If the clerk's ETag matches the one
+// given, the elections have not changed and the body is nil.
+func fetchElections(electionclerkURL string, etag string) (body []byte, newETag string, err error) {
+	req, err := http.NewRequest("GET", electionclerkURL+"/election", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := electionClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, etag, nil
+	case http.StatusOK:
+	default:
+		return nil, "", errors.New("Received " + resp.Status + " from " + electionclerkURL + "/election")
+	}
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, resp.Header.Get("ETag"), nil
+}
@@ -0,0 +1,270 @@
+package box
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+// testClerk serves GET /election the way the election clerk does, with an ETag that changes whenever an election is
+// added. See holdResponses.
+type testClerk struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	elections   []string
+	requests    int
+	notModified int
+	started     chan struct{}
+	hold        chan struct{}
+}
+
+func newTestClerk(electionIDs ...string) *testClerk {
+	clerk := &testClerk{}
+	for _, electionID := range electionIDs {
+		clerk.add(electionID)
+	}
+	clerk.Server = httptest.NewServer(http.HandlerFunc(clerk.serve))
+	return clerk
+}
+
+func (clerk *testClerk) add(electionID string) {
+	start := time.Unix(1500000000, 0)
+	election := Election{ElectionID: electionID, Start: start, End: start.Add(time.Hour), PublicKey: []byte{1, 2, 3}}
+	clerk.mu.Lock()
+	clerk.elections = append(clerk.elections, election.String())
+	clerk.mu.Unlock()
+}
+
+func (clerk *testClerk) serve(w http.ResponseWriter, r *http.Request) {
+	clerk.mu.Lock()
+	clerk.requests++
+	etag := `"` + strconv.Itoa(len(clerk.elections)) + `"`
+	body := strings.Join(clerk.elections, "\n\n\n")
+	started, hold := clerk.started, clerk.hold
+	notModified := r.Header.Get("If-None-Match") == etag
+	if notModified {
+		clerk.notModified++
+	}
+	clerk.mu.Unlock()
+
+	if started != nil {
+		started <- struct{}{}
+	}
+	if hold != nil {
+		<-hold
+	}
+	if notModified {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Write([]byte(body))
+}
+
+// holdResponses makes every response wait until the returned channel is closed, sending each request to started as
+// it arrives
+func (clerk *testClerk) holdResponses() (started chan struct{}, hold chan struct{}) {
+	clerk.mu.Lock()
+	defer clerk.mu.Unlock()
+	clerk.started = make(chan struct{}, 1)
+	clerk.hold = make(chan struct{})
+	return clerk.started, clerk.hold
+}
+
+// counts gets the number of requests the clerk has received, and how many of them were answered 304 Not Modified
+func (clerk *testClerk) counts() (requests int, notModified int) {
+	clerk.mu.Lock()
+	defer clerk.mu.Unlock()
+	return clerk.requests, clerk.notModified
+}
+
+// testClock is a clock that only moves when told to
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func newTestRegistry(clerk *testClerk, clock *testClock) *ElectionRegistry {
+	registry := NewElectionRegistry()
+	registry.ClerkURL = clerk.URL
+	registry.TTL = time.Hour
+	registry.Clock = clock.Now
+	return registry
+}
+
+func TestElectionRegistryMiss(t *testing.T) {
+	clerk := newTestClerk("one")
+	defer clerk.Close()
+	registry := newTestRegistry(clerk, &testClock{now: time.Unix(1500000000, 0)})
+
+	// An unknown election waits for a pull
+	if election, ok := registry.Get("one"); !ok || election.ElectionID != "one" {
+		t.Fatalf("Expected election one to be pulled from the clerk, got %v %v", election.ElectionID, ok)
+	}
+	if requests, _ := clerk.counts(); requests != 1 {
+		t.Errorf("Expected 1 request to the clerk, got %d", requests)
+	}
+
+	// A known election does not
+	registry.Get("one")
+	if requests, _ := clerk.counts(); requests != 1 {
+		t.Errorf("Expected no more requests to the clerk for a known election, got %d", requests)
+	}
+}
+
+func TestElectionRegistryConcurrentMisses(t *testing.T) {
+	clerk := newTestClerk("one")
+	defer clerk.Close()
+	started, hold := clerk.holdResponses()
+	registry := newTestRegistry(clerk, &testClock{now: time.Unix(1500000000, 0)})
+
+	const lookups = 20
+	found := make(chan bool, lookups)
+	get := func() {
+		_, ok := registry.Get("one")
+		found <- ok
+	}
+
+	// Every lookup that starts while the first pull is held waits on that pull instead of starting its own
+	go get()
+	<-started
+	for i := 1; i < lookups; i++ {
+		go get()
+	}
+	close(hold)
+	for i := 0; i < lookups; i++ {
+		if !<-found {
+			t.Error("Expected every lookup to find the election")
+		}
+	}
+	if requests, _ := clerk.counts(); requests != 1 {
+		t.Errorf("Expected concurrent lookups to share 1 request to the clerk, got %d", requests)
+	}
+}
+
+func TestElectionRegistryMissInterval(t *testing.T) {
+	clerk := newTestClerk("one")
+	defer clerk.Close()
+	clock := &testClock{now: time.Unix(1500000000, 0)}
+	registry := newTestRegistry(clerk, clock)
+
+	if _, ok := registry.Get("missing"); ok {
+		t.Fatal("Expected a missing election not to be found")
+	}
+
+	// Lookups of missing elections within missInterval of the last pull do not go to the clerk
+	clock.Add(missInterval / 2)
+	if _, ok := registry.Get("missing"); ok {
+		t.Fatal("Expected a missing election not to be found")
+	}
+	if requests, _ := clerk.counts(); requests != 1 {
+		t.Errorf("Expected 1 request to the clerk within missInterval, got %d", requests)
+	}
+
+	clock.Add(missInterval / 2)
+	registry.Get("missing")
+	if requests, _ := clerk.counts(); requests != 2 {
+		t.Errorf("Expected a second request to the clerk after missInterval, got %d", requests)
+	}
+}
+
+func TestElectionRegistryStale(t *testing.T) {
+	clerk := newTestClerk("one")
+	defer clerk.Close()
+	clock := &testClock{now: time.Unix(1500000000, 0)}
+	registry := newTestRegistry(clerk, clock)
+	if err := registry.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	clerk.add("two")
+
+	// Fresh elections are not pulled again
+	registry.Get("one")
+	registry.All()
+	if requests, _ := clerk.counts(); requests != 1 {
+		t.Errorf("Expected no requests to the clerk before the TTL, got %d", requests)
+	}
+
+	// Stale elections are pulled in the background, without holding up the lookup of a known election
+	started, hold := clerk.holdResponses()
+	clock.Add(registry.TTL)
+	if _, ok := registry.Get("one"); !ok {
+		t.Fatal("Expected election one to be found while the elections are pulled")
+	}
+	<-started
+	if _, ok := registry.Map()["two"]; ok {
+		t.Error("Expected election two not to be held before the pull finishes")
+	}
+	close(hold)
+
+	// Election two is found once the pull finishes, waiting on it if it is still in flight
+	if _, ok := registry.Get("two"); !ok {
+		t.Error("Expected election two to be pulled in the background")
+	}
+	if requests, _ := clerk.counts(); requests != 2 {
+		t.Errorf("Expected 2 requests to the clerk, got %d", requests)
+	}
+}
+
+func TestElectionRegistryNotModified(t *testing.T) {
+	clerk := newTestClerk("one")
+	defer clerk.Close()
+	registry := newTestRegistry(clerk, &testClock{now: time.Unix(1500000000, 0)})
+	var syncs int
+	registry.Sync = func(elections map[string]Election) error {
+		syncs++
+		return nil
+	}
+
+	if err := registry.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second pull is conditional on the ETag of the first, so nothing is parsed or synced again
+	if err := registry.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if _, notModified := clerk.counts(); notModified != 1 {
+		t.Errorf("Expected the second pull to be answered 304 Not Modified, got %d", notModified)
+	}
+	if syncs != 1 {
+		t.Errorf("Expected the store to be synced once, got %d", syncs)
+	}
+	if _, ok := registry.Get("one"); !ok {
+		t.Error("Expected election one to be kept after a 304")
+	}
+
+	// A new election changes the ETag
+	clerk.add("two")
+	if err := registry.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if _, notModified := clerk.counts(); notModified != 1 {
+		t.Errorf("Expected the third pull not to be answered 304 Not Modified, got %d", notModified)
+	}
+	if elections := registry.All(); len(elections) != 2 || elections[1].ElectionID != "two" {
+		t.Errorf("Expected elections one and two, got %v", elections)
+	}
+	if syncs != 2 {
+		t.Errorf("Expected the store to be synced twice, got %d", syncs)
+	}
+}
@@ -0,0 +1,179 @@
+package box
+
+import (
+	"sort"
+	"sync"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+// MemoryStore keeps everything in memory. It is intended for testing.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	published map[string]map[string][]byte // Published ballot text, keyed by election ID then ballot ID
+	pending   map[string]map[string][]byte // Pending ballot text, keyed by election ID then ballot ID
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		published: make(map[string]map[string][]byte),
+		pending:   make(map[string]map[string][]byte),
+	}
+}
+
+func (s *MemoryStore) GetBallot(electionID string, ballotID string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ballot, ok := s.published[electionID][ballotID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return ballot, nil
+}
+
+func (s *MemoryStore) BallotExists(electionID string, ballotID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.published[electionID][ballotID]
+	return ok, nil
+}
+
+func (s *MemoryStore) PendingBallotExists(electionID string, ballotID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.pending[electionID][ballotID]
+	return ok, nil
+}
+
+func (s *MemoryStore) SaveBallot(ballot *Ballot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.published[ballot.ElectionID][ballot.BallotID]; ok {
+		return ErrDuplicate
+	}
+	save(s.published, ballot.ElectionID, ballot.BallotID, []byte(ballot.String()))
+	return nil
+}
+
+func (s *MemoryStore) SavePendingBallot(ballot *Ballot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pending[ballot.ElectionID][ballot.BallotID]; ok {
+		return ErrDuplicate
+	}
+	save(s.pending, ballot.ElectionID, ballot.BallotID, []byte(ballot.String()))
+	return nil
+}
+
+func (s *MemoryStore) SaveBallots(ballots []*Ballot) ([]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := make([]bool, len(ballots))
+	for i, ballot := range ballots {
+		if _, ok := s.published[ballot.ElectionID][ballot.BallotID]; ok {
+			continue
+		}
+		save(s.published, ballot.ElectionID, ballot.BallotID, []byte(ballot.String()))
+		saved[i] = true
+	}
+	return saved, nil
+}
+
+func (s *MemoryStore) SavePendingBallots(ballots []*Ballot) ([]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := make([]bool, len(ballots))
+	for i, ballot := range ballots {
+		if _, ok := s.published[ballot.ElectionID][ballot.BallotID]; ok {
+			continue
+		}
+		if _, ok := s.pending[ballot.ElectionID][ballot.BallotID]; ok {
+			continue
+		}
+		save(s.pending, ballot.ElectionID, ballot.BallotID, []byte(ballot.String()))
+		saved[i] = true
+	}
+	return saved, nil
+}
+
+func save(ballots map[string]map[string][]byte, electionID string, ballotID string, ballot []byte) {
+	if ballots[electionID] == nil {
+		ballots[electionID] = make(map[string][]byte)
+	}
+	ballots[electionID][ballotID] = ballot
+}
+
+func (s *MemoryStore) CountBallots(electionID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.published[electionID]), nil
+}
+
+func (s *MemoryStore) ListBallots(electionID string, after string, limit int) (BallotCursor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ballotIDs []string
+	for ballotID := range s.published[electionID] {
+		if ballotID > after {
+			ballotIDs = append(ballotIDs, ballotID)
+		}
+	}
+	sort.Strings(ballotIDs)
+	if limit != 0 && len(ballotIDs) > limit {
+		ballotIDs = ballotIDs[:limit]
+	}
+
+	cursor := &memoryCursor{i: -1}
+	for _, ballotID := range ballotIDs {
+		cursor.ballotIDs = append(cursor.ballotIDs, ballotID)
+		cursor.ballots = append(cursor.ballots, s.published[electionID][ballotID])
+	}
+	return cursor, nil
+}
+
+func (s *MemoryStore) PublishPending(electionID string, minimum int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Published ballots are not kept in the order they were published, so there is nothing to shuffle
+	n := len(s.pending[electionID])
+	if n == 0 || n < minimum {
+		return 0, nil
+	}
+	// As in MySQLStore, a ballot published by a peer mirror while it was pending is dropped
+	published := 0
+	for ballotID, ballot := range s.pending[electionID] {
+		if _, ok := s.published[electionID][ballotID]; !ok {
+			save(s.published, electionID, ballotID, ballot)
+			published++
+		}
+	}
+	delete(s.pending, electionID)
+	return published, nil
+}
+
+// memoryCursor is a BallotCursor over a snapshot of ballots
+type memoryCursor struct {
+	ballotIDs []string
+	ballots   [][]byte
+	i         int
+}
+
+func (c *memoryCursor) Next() bool {
+	c.i++
+	return c.i < len(c.ballotIDs)
+}
+
+func (c *memoryCursor) Ballot() (string, []byte, error) {
+	return c.ballotIDs[c.i], c.ballots[c.i], nil
+}
+
+func (c *memoryCursor) Err() error {
+	return nil
+}
+
+func (c *memoryCursor) Close() error {
+	return nil
+}
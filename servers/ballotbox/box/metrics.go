@@ -0,0 +1,139 @@
+package box
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics are served at /metrics in the prometheus text format.
+//
+// Label values are limited to handler names, election IDs and error classes. Ballot IDs,
+// public keys and remote addresses must never be used as label values, since publishing them
+// alongside request counts and timings would help an observer de-anonymize voters.
+
+// Error classes used to label failed requests
+const (
+	errClassNone         = "none"
+	errClassBadRequest   = "bad_request"
+	errClassNotFound     = "not_found"
+	errClassMethod       = "method_not_allowed"
+	errClassClosed       = "election_closed"
+	errClassVerification = "verification"
+	errClassDuplicate    = "duplicate"
+	errClassRateLimited  = "rate_limited"
+	errClassTooLarge     = "too_large"
+	errClassDatabase     = "database"
+	errClassInternal     = "internal"
+)
+
+// electionUnknown is used as the election label before the election has been confirmed to exist.
+// This prevents clients from creating an unbounded number of label values by requesting random election IDs.
+const electionUnknown = "unknown"
+
+var (
+	requestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ballotbox",
+			Name:      "requests_total",
+			Help:      "Number of requests handled, partitioned by handler, election and error class.",
+		},
+		[]string{"handler", "election", "error"},
+	)
+
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "ballotbox",
+			Name:      "request_duration_seconds",
+			Help:      "Time taken to handle a request, partitioned by handler and election.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"handler", "election"},
+	)
+
+	verificationFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ballotbox",
+			Name:      "verification_failures_total",
+			Help:      "Number of cryptographic verification failures, partitioned by election and what was being verified.",
+		},
+		[]string{"election", "kind"},
+	)
+
+	gossipBallots = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ballotbox",
+			Name:      "gossip_ballots_total",
+			Help:      "Number of ballots pulled from peer mirrors and published, partitioned by election.",
+		},
+		[]string{"election"},
+	)
+
+	batchBallots = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ballotbox",
+			Name:      "batch_ballots_total",
+			Help:      "Number of ballots cast in batches, partitioned by election and error class.",
+		},
+		[]string{"election", "error"},
+	)
+
+	dbQueryDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "ballotbox",
+			Name:      "db_query_duration_seconds",
+			Help:      "Time taken by database queries, partitioned by query.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"query"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal, requestDuration, verificationFailures, gossipBallots, batchBallots, dbQueryDuration)
+}
+
+// handlerMetric tracks a single request as it passes through a handler.
+// Create one at the top of a handler and defer a call to observe().
+type handlerMetric struct {
+	handler  string
+	election string
+	errClass string
+	start    time.Time
+}
+
+func newHandlerMetric(handler string) *handlerMetric {
+	return &handlerMetric{
+		handler:  handler,
+		election: electionUnknown,
+		errClass: errClassNone,
+		start:    time.Now(),
+	}
+}
+
+// setElection labels the request with an election. Only call this once the election is known to exist.
+func (m *handlerMetric) setElection(electionID string) {
+	m.election = electionID
+}
+
+// fail marks the request as failed with the given error class
+func (m *handlerMetric) fail(errClass string) {
+	m.errClass = errClass
+}
+
+// verificationFailed marks the request as failed cryptographic verification of the given kind
+func (m *handlerMetric) verificationFailed(kind string) {
+	m.errClass = errClassVerification
+	verificationFailures.WithLabelValues(m.election, kind).Inc()
+}
+
+// observe records the request. It should be deferred.
+func (m *handlerMetric) observe() {
+	requestsTotal.WithLabelValues(m.handler, m.election, m.errClass).Inc()
+	requestDuration.WithLabelValues(m.handler, m.election).Observe(time.Since(m.start).Seconds())
+}
+
+// observeQuery records the time taken by a database query that started at the given time
+func observeQuery(query string, start time.Time) {
+	dbQueryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,314 @@
+package box
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/sirupsen/logrus"
+)
+
+// Mirroring
+//
+// A single ballotbox could censor a voter by refusing or quietly dropping their ballot. To guard against this,
+// several independently run ballotboxes may mirror each other. Voters submit their ballot to more than one mirror,
+// and every mirror periodically pulls the published ballots of its peers, verifies the clerk's signature on each,
+// and publishes any it is missing. A ballot accepted by any honest mirror therefore reaches every honest mirror.
+//
+// Each mirror also signs a tree head over the ballots it has published, served at GET /treehead/<election-id>.
+// Auditors compare the ballots of every mirror, and check each against its tree head, so that a mirror that
+// withholds a ballot or drops one it has already published is caught. See cryptoballot.CompareMirrors. The leaf
+// hashes of each election's tree are kept in memory and added to as ballots are published, so that serving a tree
+// head does not load every ballot.
+
+// gossipPageSize is the number of ballots pulled from a peer per request
+const gossipPageSize = maxBallotPageSize
+
+// StartGossip periodically pulls ballots from every peer
+func (s *Server) StartGossip() {
+	go func() {
+		ticker := time.NewTicker(s.conf.Mirror.Interval)
+		for range ticker.C {
+			s.Gossip()
+		}
+	}()
+}
+
+// Gossip pulls the published ballots of every peer for every election, and publishes any that are missing here
+func (s *Server) Gossip() {
+	for _, peer := range s.conf.Mirror.Peers {
+		for _, election := range s.conf.Elections.All() {
+			electionID := election.ElectionID
+			n, err := s.pullBallots(peer, &election)
+			if n != 0 {
+				gossipBallots.WithLabelValues(electionID).Add(float64(n))
+				Logger.WithFields(logrus.Fields{"election": electionID, "peer": peer, "count": n}).Info("Published ballots from peer")
+			}
+			if err != nil {
+				Logger.WithError(err).WithFields(logrus.Fields{"election": electionID, "peer": peer}).Error("Error pulling ballots from peer")
+			}
+		}
+	}
+}
+
+// pullBallots pages through the peer's published ballots for an election, and publishes any that are missing here.
+// It returns the number of ballots published.
+func (s *Server) pullBallots(peer string, election *Election) (int, error) {
+	var (
+		after     string
+		published int
+	)
+	for {
+		ballots, nextAfter, err := fetchBallotPage(peer, election.ElectionID, after)
+		if err != nil {
+			return published, err
+		}
+		for _, ballot := range ballots {
+			saved, err := s.saveGossipedBallot(election, ballot)
+			if err != nil {
+				return published, err
+			}
+			if saved {
+				published++
+			}
+		}
+		if nextAfter == "" {
+			return published, nil
+		}
+		after = nextAfter
+	}
+}
+
+// saveGossipedBallot publishes a ballot pulled from a peer, unless it is already published or pending here.
+// Ballots are accepted after the election has ended, since a peer may have accepted them just before it did.
+func (s *Server) saveGossipedBallot(election *Election, ballot *Ballot) (bool, error) {
+	if ballot.ElectionID != election.ElectionID {
+		return false, errors.New("Peer returned a ballot for election " + ballot.ElectionID)
+	}
+	exists, err := s.store.BallotExists(election.ElectionID, ballot.BallotID)
+	if err != nil || exists {
+		return false, err
+	}
+	exists, err = s.store.PendingBallotExists(election.ElectionID, ballot.BallotID)
+	if err != nil || exists {
+		return false, err
+	}
+	if err = s.verifyBallotSignature(election, ballot); err != nil {
+		verificationFailures.WithLabelValues(election.ElectionID, "gossip_ballot_signature").Inc()
+		Logger.WithError(err).WithField("election", election.ElectionID).Warn("Peer published a ballot with an invalid signature")
+		return false, nil
+	}
+	// A voter may have cast the same ballot here since it was checked
+	err = s.store.SaveBallot(ballot)
+	if err == ErrDuplicate {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	s.trees.add(ballot)
+	return true, nil
+}
+
+// fetchBallotPage gets a page of a peer's published ballots, starting after the given ballot-id. It also returns
+// the `after` value for the next page, which is empty if this is the last page.
+func fetchBallotPage(peer string, electionID string, after string) ([]*Ballot, string, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(gossipPageSize))
+	if after != "" {
+		query.Set("after", after)
+	}
+	resp, err := http.Get(strings.TrimSuffix(peer, "/") + "/vote/" + electionID + "?" + query.Encode())
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", errors.New("Received " + resp.Status + " from " + peer)
+	}
+
+	var ballots []*Ballot
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 4096), MaxBallotSize+3)
+	scanner.Split(scanBallots)
+	for scanner.Scan() {
+		ballot, err := NewBallot(scanner.Bytes())
+		if err != nil {
+			return nil, "", err
+		}
+		ballots = append(ballots, ballot)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, "", err
+	}
+
+	// The outcome of the stream is only known from the trailers, once the body has been read
+	if resp.Trailer.Get("X-Stream-Status") != "complete" {
+		return nil, "", errors.New("Ballot stream from " + peer + " did not complete")
+	}
+	return ballots, resp.Trailer.Get("X-Next-After"), nil
+}
+
+// scanBallots splits a stream of ballots on the "\n\n\n" between them. For use in a bufio.Scanner
+func scanBallots(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.Index(data, []byte("\n\n\n")); i >= 0 {
+		return i + 3, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// Main tree head handler. GET /treehead/<election-id> gets a tree head over the published ballots, signed with
+// this mirror's DID key.
+func (s *Server) treeHeadHandler(w http.ResponseWriter, r *http.Request) {
+	m := newHandlerMetric("treeHeadHandler")
+	defer m.observe()
+
+	if r.Method != "GET" {
+		writeError(w, r, m, http.StatusMethodNotAllowed, errClassMethod, "Method not allowed")
+		return
+	}
+	if s.conf.Mirror.Key == nil {
+		writeError(w, r, m, http.StatusNotFound, errClassNotFound, "This ballotbox does not sign tree heads")
+		return
+	}
+	electionID := strings.TrimPrefix(r.URL.Path, "/treehead/")
+	if _, ok := s.conf.Elections.Get(electionID); !ok {
+		writeError(w, r, m, http.StatusNotFound, errClassNotFound, "Election not found")
+		return
+	}
+	m.setElection(electionID)
+
+	count, root, err := s.trees.get(electionID).head(s.store, electionID)
+	if err != nil {
+		writeInternalError(w, r, m, errClassDatabase, err)
+		return
+	}
+	head := &TreeHead{ElectionID: electionID, Time: s.conf.Clock(), Ballots: count, Root: root}
+	publicKey, err := s.conf.Mirror.Key.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		writeInternalError(w, r, m, errClassInternal, err)
+		return
+	}
+	head.PublicKey = publicKey.Bytes()
+	head.Signature, err = s.conf.Mirror.Key.SignString(head.StringWithoutSignature())
+	if err != nil {
+		writeInternalError(w, r, m, errClassInternal, err)
+		return
+	}
+	w.Write([]byte(head.String()))
+}
+
+// ballotTrees holds the Merkle tree of each election's published ballots, as served in tree heads
+type ballotTrees struct {
+	mu    sync.Mutex
+	trees map[string]*ballotTree
+}
+
+// get gets the tree for an election. It is empty until it is first loaded from the store
+func (trees *ballotTrees) get(electionID string) *ballotTree {
+	trees.mu.Lock()
+	defer trees.mu.Unlock()
+	tree, ok := trees.trees[electionID]
+	if !ok {
+		tree = &ballotTree{}
+		trees.trees[electionID] = tree
+	}
+	return tree
+}
+
+// add adds a ballot to its election's tree once it has been published
+func (trees *ballotTrees) add(ballot *Ballot) {
+	trees.get(ballot.ElectionID).add(ballot)
+}
+
+// ballotTree holds the leaf hashes of an election's published ballots in ballot-id order, and the root computed
+// from them. Only ballots that have been published are added, and published ballots are never removed, so the tree
+// matches the store whenever it holds as many ballots as the store does. Ballots published some other way, such as
+// by a mixing batch or another ballotbox sharing the database, are picked up by reloading the tree from the store.
+type ballotTree struct {
+	mu        sync.Mutex
+	ballotIDs []string
+	hashes    [][]byte
+	root      []byte // Nil if a ballot has been added since the root was computed
+}
+
+// add adds a published ballot to the tree. A ballot already in the tree is ignored
+func (tree *ballotTree) add(ballot *Ballot) {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	i := sort.SearchStrings(tree.ballotIDs, ballot.BallotID)
+	if i < len(tree.ballotIDs) && tree.ballotIDs[i] == ballot.BallotID {
+		return
+	}
+	tree.ballotIDs = append(tree.ballotIDs, "")
+	copy(tree.ballotIDs[i+1:], tree.ballotIDs[i:])
+	tree.ballotIDs[i] = ballot.BallotID
+	tree.hashes = append(tree.hashes, nil)
+	copy(tree.hashes[i+1:], tree.hashes[i:])
+	tree.hashes[i] = MerkleLeafHash([]byte(ballot.String()))
+	tree.root = nil
+}
+
+// head gets the number of ballots in the tree and its root, first reloading the tree if the store holds ballots
+// that it does not
+func (tree *ballotTree) head(store Store, electionID string) (int, []byte, error) {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	count, err := store.CountBallots(electionID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if count != len(tree.ballotIDs) {
+		if err = tree.load(store, electionID); err != nil {
+			return 0, nil, err
+		}
+	}
+	if tree.root == nil {
+		tree.root = MerkleRootOfHashes(tree.hashes)
+	}
+	return len(tree.ballotIDs), tree.root, nil
+}
+
+// load replaces the tree with the ballots in the store. The lock must be held
+func (tree *ballotTree) load(store Store, electionID string) error {
+	rows, err := store.ListBallots(electionID, "", 0)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var (
+		ballotIDs []string
+		hashes    [][]byte
+	)
+	for rows.Next() {
+		_, ballotString, err := rows.Ballot()
+		if err != nil {
+			return err
+		}
+		ballot, err := NewBallot(ballotString)
+		if err != nil {
+			return err
+		}
+		ballotIDs = append(ballotIDs, ballot.BallotID)
+		hashes = append(hashes, MerkleLeafHash([]byte(ballot.String())))
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	tree.ballotIDs, tree.hashes, tree.root = ballotIDs, hashes, nil
+	return nil
+}
@@ -0,0 +1,62 @@
+package box
+
+import (
+	"crypto/rand"
+	"math/big"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Mixing
+//
+// Without mixing, a ballot becomes visible at GET /vote/<election-id> as soon as it is cast. Anyone who
+// can see when a voter's signature request was fulfilled by the election clerk could then link that voter
+// to their ballot by looking at which ballot appeared next.
+//
+// When mixing is enabled, accepted ballots are held in a pending table. Once enough ballots have
+// accumulated they are shuffled and published together, so that a ballot can only be narrowed down
+// to its batch. When an election ends any remaining pending ballots are published regardless of the batch size.
+// Neither table records when a ballot was received, and published ballots are always listed in ballot-id order.
+
+// StartMixer periodically publishes pending ballots for every election
+func (s *Server) StartMixer() {
+	go func() {
+		ticker := time.NewTicker(s.conf.Mixing.Interval)
+		for range ticker.C {
+			s.Mix()
+		}
+	}()
+}
+
+// Mix publishes the pending ballots for every election that has a full batch, or that has ended
+func (s *Server) Mix() {
+	for _, election := range s.conf.Elections.All() {
+		electionID := election.ElectionID
+		minimum := s.conf.Mixing.BatchSize
+		if s.conf.Clock().After(election.End) {
+			minimum = 1
+		}
+		n, err := s.store.PublishPending(electionID, minimum)
+		if err != nil {
+			Logger.WithError(err).WithField("election", electionID).Error("Error publishing pending ballots")
+			continue
+		}
+		if n != 0 {
+			Logger.WithFields(logrus.Fields{"election": electionID, "count": n}).Info("Published pending ballots")
+		}
+	}
+}
+
+// shuffle randomly permutes n elements using a Fisher-Yates shuffle driven by crypto/rand.
+// math/rand is not used since its output could be predicted by an observer.
+func shuffle(n int, swap func(i, j int)) error {
+	for i := n - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return err
+		}
+		swap(i, int(j.Int64()))
+	}
+	return nil
+}
@@ -0,0 +1,424 @@
+package box
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlDuplicateEntry is the error number MySQL gives when an insert breaks a unique index
+const mysqlDuplicateEntry = 1062
+
+// ballot_id uses a binary collation so that ballot IDs are compared case-sensitively, both when checking
+// for duplicates and when paging through ballots in ballot-id order. It is unique in both tables, so that duplicate
+// ballots are refused by the database rather than by checking for them first.
+const (
+	ballotsQuery = `CREATE TABLE ballots_<election-id> (
+					  ballot_id varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL, -- TODO: change to 64 on move to SHA256
+					  tags text,
+					  ballot text NOT NULL
+					)ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`
+
+	ballotsQueryIndex = `CREATE UNIQUE INDEX ballot_id_idx_<election-id> ON ballots_<election-id> (ballot_id);`
+
+	// Tables made before ballot_id was case-sensitive are converted to the binary collation
+	ballotsQueryCollation = `ALTER TABLE <table> CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;`
+
+	// Tables made before ballot_id was unique have a plain index, which is replaced
+	ballotsQueryUnique = `ALTER TABLE ballots_<election-id> DROP INDEX ballot_id_idx_<election-id>, ADD UNIQUE INDEX ballot_id_idx_<election-id> (ballot_id);`
+
+	pendingQuery = `CREATE TABLE IF NOT EXISTS pending_<election-id> (
+					  ballot_id varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,
+					  tags text,
+					  ballot text NOT NULL,
+					  PRIMARY KEY (ballot_id)
+					)ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`
+)
+
+// MySQLStore keeps the published ballots for each election in a `ballots_<election-id>` table,
+// and pending ballots in a `pending_<election-id>` table.
+type MySQLStore struct {
+	db *sql.DB
+}
+
+// NewMySQLStore creates a store using the given database connection
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+// Sync Elections to database tables
+// This will create database tables for any election that doesn't already have one
+// If a database table is found starting with `ballots_` that doesn't correspond to a
+// an Election an error will occur. Pending tables are also created if mixing is enabled.
+func (s *MySQLStore) Sync(elections map[string]Election, mixing bool) error {
+	// Build a list of elections found in the database
+	electionsInDB := make(map[string]bool)
+	rows, err := s.db.Query("SELECT table_name FROM information_schema.tables WHERE table_name LIKE 'ballots_%'")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tablename string
+		err := rows.Scan(&tablename)
+		if err != nil {
+			return err
+		}
+		electionID := strings.TrimPrefix(tablename, "ballots_")
+		electionsInDB[electionID] = true
+	}
+
+	// Compare elections in the database to elections passes in
+	// Create any missing tables
+	for electionID, _ := range elections {
+		if electionsInDB[electionID] {
+			// Election matches - mark as false to denote that it has been processed and is OK
+			electionsInDB[electionID] = false
+			if err = s.uniqueBallotIDs(electionID); err != nil {
+				return err
+			}
+		} else {
+			// Create missing table
+			_, err = s.db.Exec(strings.Replace(ballotsQuery, "<election-id>", electionID, -1))
+			if err != nil {
+				return err
+			}
+			_, err = s.db.Exec(strings.Replace(ballotsQueryIndex, "<election-id>", electionID, -1))
+			if err != nil {
+				return err
+			}
+		}
+		if mixing {
+			_, err = s.db.Exec(strings.Replace(pendingQuery, "<election-id>", electionID, -1))
+			if err != nil {
+				return err
+			}
+			if err = s.binaryBallotIDs("pending_" + electionID); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Check for extrenous elections in the database. If any remaining items in the map are TRUE we have an extraneous table
+	for tablename, extra := range electionsInDB {
+		if extra {
+			return fmt.Errorf("Found extraneous %s table in database. This table does not correspond to any existing election.", tablename)
+		}
+	}
+
+	// Success
+	return nil
+}
+
+// uniqueBallotIDs replaces the plain ballot_id index of a ballots table made by an earlier version. The table is
+// converted to the binary collation first, so that ballot IDs differing only in case are not taken as duplicates.
+func (s *MySQLStore) uniqueBallotIDs(electionID string) error {
+	if err := s.binaryBallotIDs("ballots_" + electionID); err != nil {
+		return err
+	}
+	var nonUnique int
+	err := s.db.QueryRow("SELECT non_unique FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?",
+		"ballots_"+electionID, "ballot_id_idx_"+electionID).Scan(&nonUnique)
+	if err == sql.ErrNoRows {
+		_, err = s.db.Exec(strings.Replace(ballotsQueryIndex, "<election-id>", electionID, -1))
+		return err
+	}
+	if err != nil || nonUnique == 0 {
+		return err
+	}
+	_, err = s.db.Exec(strings.Replace(ballotsQueryUnique, "<election-id>", electionID, -1))
+	return err
+}
+
+// binaryBallotIDs converts a table made by an earlier version to the binary collation, unless ballot_id already has it
+func (s *MySQLStore) binaryBallotIDs(table string) error {
+	var collation sql.NullString
+	err := s.db.QueryRow("SELECT collation_name FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = 'ballot_id'",
+		table).Scan(&collation)
+	if err != nil || collation.String == "utf8mb4_bin" {
+		return err
+	}
+	_, err = s.db.Exec(strings.Replace(ballotsQueryCollation, "<table>", table, -1))
+	return err
+}
+
+func (s *MySQLStore) GetBallot(electionID string, ballotID string) ([]byte, error) {
+	var ballotString []byte
+	start := time.Now()
+	err := s.db.QueryRow("SELECT ballot FROM ballots_"+electionID+" WHERE ballot_id = ?", ballotID).Scan(&ballotString)
+	observeQuery("select_ballot", start)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return ballotString, err
+}
+
+func (s *MySQLStore) BallotExists(electionID string, ballotID string) (bool, error) {
+	return s.exists("ballots_", "select_ballot_exists", electionID, ballotID)
+}
+
+func (s *MySQLStore) PendingBallotExists(electionID string, ballotID string) (bool, error) {
+	return s.exists("pending_", "select_pending_exists", electionID, ballotID)
+}
+
+// exists checks the table with the given prefix, either "ballots_" or "pending_", for a ballot
+func (s *MySQLStore) exists(table string, query string, electionID string, ballotID string) (bool, error) {
+	var exists int
+	start := time.Now()
+	err := s.db.QueryRow("SELECT 1 FROM "+table+electionID+" WHERE ballot_id = ?", ballotID).Scan(&exists)
+	observeQuery(query, start)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *MySQLStore) SaveBallot(ballot *Ballot) error {
+	return s.save("ballots_", ballot)
+}
+
+func (s *MySQLStore) SavePendingBallot(ballot *Ballot) error {
+	return s.save("pending_", ballot)
+}
+
+// save saves a ballot to the table with the given prefix, either "ballots_" or "pending_"
+func (s *MySQLStore) save(table string, ballot *Ballot) error {
+	start := time.Now()
+	_, err := s.db.Exec("INSERT INTO "+table+ballot.ElectionID+" (ballot_id, ballot, tags) VALUES (?, ?, ?)", ballot.BallotID, ballot.String(), ballotTags(ballot))
+	observeQuery("insert_"+strings.TrimSuffix(table, "_"), start)
+	if isDuplicateEntry(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *MySQLStore) SaveBallots(ballots []*Ballot) ([]bool, error) {
+	return s.saveAll("ballots_", ballots)
+}
+
+func (s *MySQLStore) SavePendingBallots(ballots []*Ballot) ([]bool, error) {
+	return s.saveAll("pending_", ballots)
+}
+
+// saveAll saves ballots to the table with the given prefix in one transaction, so that there is a single commit
+// for the lot. When an insert breaks the unique index MySQL only rolls back that statement, so the duplicate is
+// skipped and the transaction carries on. Pending ballots are also checked against the published ballots, which
+// have their own table.
+func (s *MySQLStore) saveAll(table string, ballots []*Ballot) ([]bool, error) {
+	saved := make([]bool, len(ballots))
+	if len(ballots) == 0 {
+		return saved, nil
+	}
+	electionID := ballots[0].ElectionID
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	published := make(map[string]bool)
+	if table == "pending_" {
+		placeholders := make([]string, len(ballots))
+		ballotIDs := make([]interface{}, len(ballots))
+		for i, ballot := range ballots {
+			placeholders[i] = "?"
+			ballotIDs[i] = ballot.BallotID
+		}
+		start := time.Now()
+		rows, err := tx.Query("SELECT ballot_id FROM ballots_"+electionID+" WHERE ballot_id IN ("+strings.Join(placeholders, ",")+")", ballotIDs...)
+		observeQuery("select_ballots_exist", start)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var ballotID string
+			if err = rows.Scan(&ballotID); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			published[ballotID] = true
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO " + table + electionID + " (ballot_id, ballot, tags) VALUES (?, ?, ?)")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	start := time.Now()
+	for i, ballot := range ballots {
+		if published[ballot.BallotID] {
+			continue
+		}
+		_, err = stmt.Exec(ballot.BallotID, ballot.String(), ballotTags(ballot))
+		if isDuplicateEntry(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		saved[i] = true
+	}
+	err = tx.Commit()
+	observeQuery("insert_batch_"+strings.TrimSuffix(table, "_"), start)
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// ballotTags gets the ballot's tags as "key=value" lines, as they are kept in the tags column
+func ballotTags(ballot *Ballot) string {
+	buf := new(bytes.Buffer)
+	for key, value := range ballot.TagSet.Map() {
+		buf.WriteString(key)
+		buf.WriteString("=")
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+	}
+	if buf.Len() == 0 {
+		return ""
+	}
+	return string(buf.Bytes()[:(buf.Len() - 1)])
+}
+
+// isDuplicateEntry checks if an insert failed because the ballot-id is already in the table
+func isDuplicateEntry(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	return ok && mysqlErr.Number == mysqlDuplicateEntry
+}
+
+func (s *MySQLStore) CountBallots(electionID string) (int, error) {
+	var count int
+	start := time.Now()
+	err := s.db.QueryRow("SELECT COUNT(*) FROM ballots_" + electionID).Scan(&count)
+	observeQuery("count_ballots", start)
+	return count, err
+}
+
+func (s *MySQLStore) ListBallots(electionID string, after string, limit int) (BallotCursor, error) {
+	query := "SELECT ballot_id, ballot FROM ballots_" + electionID + " WHERE ballot_id > ? ORDER BY ballot_id"
+	args := []interface{}{after}
+	if limit != 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	start := time.Now()
+	rows, err := s.db.Query(query, args...)
+	observeQuery("select_ballots", start)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlCursor{rows: rows}, nil
+}
+
+// sqlCursor is a BallotCursor over the rows of a `SELECT ballot_id, ballot` query
+type sqlCursor struct {
+	rows *sql.Rows
+}
+
+func (c *sqlCursor) Next() bool {
+	return c.rows.Next()
+}
+
+func (c *sqlCursor) Ballot() (string, []byte, error) {
+	var (
+		ballotID     string
+		ballotString sql.RawBytes
+	)
+	err := c.rows.Scan(&ballotID, &ballotString)
+	return ballotID, ballotString, err
+}
+
+func (c *sqlCursor) Err() error {
+	return c.rows.Err()
+}
+
+func (c *sqlCursor) Close() error {
+	return c.rows.Close()
+}
+
+type pendingBallot struct {
+	ballotID string
+	tags     string
+	ballot   string
+}
+
+func (s *MySQLStore) PublishPending(electionID string, minimum int) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	start := time.Now()
+	rows, err := tx.Query("SELECT ballot_id, tags, ballot FROM pending_" + electionID + " FOR UPDATE")
+	observeQuery("select_pending", start)
+	if err != nil {
+		return 0, err
+	}
+	var pending []pendingBallot
+	for rows.Next() {
+		var p pendingBallot
+		err = rows.Scan(&p.ballotID, &p.tags, &p.ballot)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, p)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(pending) == 0 || len(pending) < minimum {
+		return 0, nil
+	}
+
+	err = shuffle(len(pending), func(i, j int) {
+		pending[i], pending[j] = pending[j], pending[i]
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// A ballot published by a peer mirror while it was pending here is dropped
+	published := 0
+	start = time.Now()
+	for _, p := range pending {
+		_, err = tx.Exec("INSERT INTO ballots_"+electionID+" (ballot_id, ballot, tags) VALUES (?, ?, ?)", p.ballotID, p.ballot, p.tags)
+		if err != nil && !isDuplicateEntry(err) {
+			return 0, err
+		}
+		if err == nil {
+			published++
+		}
+		_, err = tx.Exec("DELETE FROM pending_"+electionID+" WHERE ballot_id = ?", p.ballotID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	observeQuery("publish_pending", start)
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return published, nil
+}
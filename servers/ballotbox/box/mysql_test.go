@@ -0,0 +1,132 @@
+package box
+
+import (
+	"database/sql"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+// The MySQL store is only tested against a real database. Set BALLOTBOX_TEST_MYSQL to the DSN of an empty database
+// that the test may create and drop tables in, such as the one started by example/docker-compose.yml:
+//
+//	BALLOTBOX_TEST_MYSQL='root@tcp(localhost:3306)/ballot' go test ./servers/ballotbox/box
+//
+// newTestMySQLStore creates the tables for a new election, and gives a function that drops them.
+func newTestMySQLStore(t *testing.T) (*MySQLStore, string, func()) {
+	dsn := os.Getenv("BALLOTBOX_TEST_MYSQL")
+	if dsn == "" {
+		t.Skip("BALLOTBOX_TEST_MYSQL is not set")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	electionID := "mysqltest" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	store := NewMySQLStore(db)
+	cleanup := func() {
+		db.Exec("DROP TABLE IF EXISTS ballots_" + electionID + ", pending_" + electionID)
+		db.Close()
+	}
+	if err = store.Sync(map[string]Election{electionID: {ElectionID: electionID}}, true); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return store, electionID, cleanup
+}
+
+func newTestMySQLBallot(t *testing.T, electionID string, ballotID string) *Ballot {
+	ballot, err := NewBallot([]byte(electionID + "\n\n" + ballotID + "\n\nSanta Clause\nTooth Fairy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ballot
+}
+
+func TestMySQLSaveBallots(t *testing.T) {
+	store, electionID, cleanup := newTestMySQLStore(t)
+	defer cleanup()
+	ballot := func(ballotID string) *Ballot {
+		return newTestMySQLBallot(t, electionID, ballotID)
+	}
+
+	// A duplicate inside a batch is refused without refusing the rest. Ballot IDs are case-sensitive
+	saved, err := store.SaveBallots([]*Ballot{ballot("one"), ballot("two"), ballot("one"), ballot("ONE")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []bool{true, true, false, true}; !equalSaved(saved, expected) {
+		t.Errorf("Expected %v saved, got %v", expected, saved)
+	}
+
+	// Ballots already saved by an earlier batch are refused too
+	saved, err = store.SaveBallots([]*Ballot{ballot("two"), ballot("three")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []bool{false, true}; !equalSaved(saved, expected) {
+		t.Errorf("Expected %v saved, got %v", expected, saved)
+	}
+	if err = store.SaveBallot(ballot("three")); err != ErrDuplicate {
+		t.Errorf("Expected ErrDuplicate, got %v", err)
+	}
+	if count, err := store.CountBallots(electionID); err != nil || count != 4 {
+		t.Errorf("Expected 4 ballots, got %d %v", count, err)
+	}
+
+	// A batch is saved in one transaction, so a failure leaves none of its ballots behind
+	tooLong := ballot("toolong")
+	tooLong.BallotID = strings.Repeat("x", 200)
+	if _, err = store.SaveBallots([]*Ballot{ballot("four"), tooLong}); err == nil {
+		t.Fatal("Expected an error saving a ballot ID too long for the table")
+	}
+	if exists, err := store.BallotExists(electionID, "four"); err != nil || exists {
+		t.Errorf("Expected the batch to be rolled back, got %v %v", exists, err)
+	}
+}
+
+func TestMySQLSavePendingBallots(t *testing.T) {
+	store, electionID, cleanup := newTestMySQLStore(t)
+	defer cleanup()
+	ballot := func(ballotID string) *Ballot {
+		return newTestMySQLBallot(t, electionID, ballotID)
+	}
+
+	if err := store.SaveBallot(ballot("published")); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := store.SavePendingBallots([]*Ballot{ballot("published"), ballot("pending"), ballot("pending")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []bool{false, true, false}; !equalSaved(saved, expected) {
+		t.Errorf("Expected %v saved, got %v", expected, saved)
+	}
+
+	if n, err := store.PublishPending(electionID, 1); err != nil || n != 1 {
+		t.Errorf("Expected 1 ballot published, got %d %v", n, err)
+	}
+	if count, err := store.CountBallots(electionID); err != nil || count != 2 {
+		t.Errorf("Expected 2 ballots, got %d %v", count, err)
+	}
+}
+
+func equalSaved(saved []bool, expected []bool) bool {
+	if len(saved) != len(expected) {
+		return false
+	}
+	for i := range saved {
+		if saved[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package box
+
+import (
+	"encoding/json"
+	"net/http"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot/openapi"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/httplog"
+)
+
+// JSON API
+//
+// Ballots are accepted as JSON when the request's Content-Type is application/json, and served as JSON when its
+// Accept header includes application/json. Otherwise the text format is used, as before. JSON ballots are converted
+// to the text format as soon as they are read, so they are checked, stored and gossiped exactly as text ballots are.
+// See cryptoballot.BallotJSON
+//
+// The JSON API is described by the OpenAPI document served at GET /openapi.json.
+
+// apiVersion is the version of the JSON API given in the OpenAPI document
+const apiVersion = "1"
+
+// writeJSON writes a JSON response, or an internal error if v cannot be encoded
+func writeJSON(w http.ResponseWriter, r *http.Request, m *handlerMetric, v interface{}) {
+	if err := httplog.WriteJSON(w, v); err != nil {
+		writeInternalError(w, r, m, errClassInternal, err)
+	}
+}
+
+// ballotJSON converts a stored ballot to JSON
+func ballotJSON(ballotString []byte) ([]byte, error) {
+	ballot, err := NewBallot(ballotString)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(ballot)
+}
+
+// Serve the OpenAPI document describing the JSON API at "/openapi.json"
+func (s *Server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	m := newHandlerMetric("openAPIHandler")
+	defer m.observe()
+
+	if r.Method != "GET" {
+		writeError(w, r, m, http.StatusMethodNotAllowed, errClassMethod, "Method not allowed. Only GET is allowed here.")
+		return
+	}
+	writeJSON(w, r, m, openAPIDocument())
+}
+
+// openAPIDocument describes the ballotbox's JSON API
+func openAPIDocument() *openapi.Document {
+	doc := openapi.New("Ballot Box", apiVersion,
+		"Accepts and publishes ballots signed by the election clerk. Every endpoint also accepts and serves the text "+
+			"format, which is the one that is signed. In JSON, binary fields are hex encoded.")
+
+	var (
+		ballot   = doc.AddSchema("Ballot", BallotJSON{})
+		apiError = doc.AddSchema("Error", httplog.ErrorResponse{})
+		result   = doc.AddSchema("BatchResult", batchResult{})
+
+		electionID = openapi.PathParam("electionId", "Lowercase alphanumeric election ID")
+		ballotID   = openapi.PathParam("ballotId", "Ballot ID chosen by the voter")
+	)
+	failed := func(description string) openapi.Response {
+		return openapi.Response{Description: description, Content: openapi.JSONContent(apiError)}
+	}
+
+	doc.Add("GET", "/vote/{electionId}", &openapi.Operation{
+		Summary: "List the published ballots of an election, ordered by ballot ID. The outcome of the stream is " +
+			"reported in the X-Stream-Status, X-Ballot-Count and X-Next-After trailers",
+		OperationID: "listBallots",
+		Parameters: []openapi.Parameter{
+			electionID,
+			openapi.QueryParam("limit", "Maximum number of ballots to return", &openapi.Schema{Type: "integer"}),
+			openapi.QueryParam("after", "Ballot ID of the last ballot on the previous page", &openapi.Schema{Type: "string"}),
+		},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "A page of ballots", Content: openapi.JSONContent(openapi.ArrayOf(ballot))},
+			"304": {Description: "The ballots have not changed since the ETag given in If-None-Match"},
+			"400": failed("The limit or after parameter is invalid"),
+			"404": failed("The election does not exist"),
+		},
+	})
+	doc.Add("POST", "/vote/{electionId}", &openapi.Operation{
+		Summary: "Cast a batch of ballots. A result is given for each ballot, in the order they were sent, and a " +
+			"ballot being refused does not stop the others being cast",
+		OperationID: "postBallots",
+		Parameters:  []openapi.Parameter{electionID},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSONContent(openapi.ArrayOf(ballot))},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The result for each ballot", Content: openapi.JSONContent(openapi.ArrayOf(result))},
+			"400": failed("The batch could not be read, or the election is not open"),
+			"404": failed("The election does not exist"),
+			"413": failed("The batch has too many ballots"),
+		},
+	})
+	doc.Add("GET", "/vote/{electionId}/{ballotId}", &openapi.Operation{
+		Summary:     "Get a published ballot",
+		OperationID: "getBallot",
+		Parameters:  []openapi.Parameter{electionID, ballotID},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The ballot", Content: openapi.JSONContent(ballot)},
+			"404": failed("The election or ballot does not exist"),
+		},
+	})
+	doc.Add("PUT", "/vote/{electionId}/{ballotId}", &openapi.Operation{
+		Summary:     "Cast a ballot",
+		OperationID: "putBallot",
+		Parameters:  []openapi.Parameter{electionID, ballotID},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSONContent(ballot)},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The ballot was published"},
+			"202": {Description: "The ballot was accepted, and will be published in a shuffled batch"},
+			"400": failed("The ballot or its signature is invalid, or the election is not open"),
+			"403": failed("A ballot with this ID already exists"),
+			"404": failed("The election does not exist"),
+		},
+	})
+	return doc
+}
@@ -0,0 +1,69 @@
+package box
+
+import (
+	"errors"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+var (
+	ErrNotFound  = errors.New("box: not found")
+	ErrDuplicate = errors.New("box: ballot-id already exists")
+)
+
+// Store is where the ballotbox keeps ballots. Published ballots are visible to everyone, while pending ballots are
+// held back until they are published in a shuffled batch. See mixing.go
+//
+// See MySQLStore for the store used in production, and MemoryStore for testing.
+type Store interface {
+	// GetBallot gets the text of a published ballot. ErrNotFound is returned if there is no such ballot.
+	GetBallot(electionID string, ballotID string) ([]byte, error)
+
+	// BallotExists checks if a ballot has been published
+	BallotExists(electionID string, ballotID string) (bool, error)
+
+	// PendingBallotExists checks if a ballot is waiting to be published
+	PendingBallotExists(electionID string, ballotID string) (bool, error)
+
+	// SaveBallot publishes a ballot. ErrDuplicate is returned if a ballot with the same ballot-id has been published.
+	SaveBallot(ballot *Ballot) error
+
+	// SavePendingBallot holds back a ballot so that it is published later as part of a batch.
+	// ErrDuplicate is returned if a ballot with the same ballot-id is pending.
+	SavePendingBallot(ballot *Ballot) error
+
+	// SaveBallots publishes ballots for a single election in one transaction. A ballot whose ballot-id has already
+	// been published, or is used by an earlier ballot in the list, is skipped, and saved[i] is false for it.
+	// Any other error saves none of the ballots.
+	SaveBallots(ballots []*Ballot) (saved []bool, err error)
+
+	// SavePendingBallots holds back ballots for a single election in one transaction, skipping them as SaveBallots
+	// does. A ballot is also skipped if its ballot-id is pending.
+	SavePendingBallots(ballots []*Ballot) (saved []bool, err error)
+
+	// CountBallots gets the number of published ballots for an election
+	CountBallots(electionID string) (int, error)
+
+	// ListBallots gets the published ballots with a ballot-id greater than after, in ballot-id order.
+	// A limit of zero means no limit.
+	ListBallots(electionID string, after string, limit int) (BallotCursor, error)
+
+	// PublishPending publishes all pending ballots for an election in a random order, provided there are at least
+	// minimum of them. It returns the number of ballots published.
+	PublishPending(electionID string, minimum int) (int, error)
+}
+
+// BallotCursor iterates over a list of ballots
+type BallotCursor interface {
+	// Next advances to the next ballot. It returns false when there are no more ballots or an error occurred.
+	Next() bool
+
+	// Ballot gets the current ballot-id and ballot text. The text is only valid until the next call to Next.
+	Ballot() (ballotID string, ballot []byte, err error)
+
+	// Err gets any error that occurred while iterating
+	Err() error
+
+	// Close frees the cursor
+	Close() error
+}
@@ -0,0 +1,387 @@
+package box
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/httplog"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/ratelimit"
+)
+
+const (
+	maxBallotPageSize   = 1000 // Maximum number of ballots that may be requested with `limit`
+	ballotFlushInterval = 100  // Flush the response after this many ballots while streaming
+)
+
+// Main vote handler. A user may GET a single vote, a list of all votes, or PUT (cast) their vote
+func (s *Server) voteHandler(w http.ResponseWriter, r *http.Request) {
+	electionID, ballotID, err := parseVoteRequest(r)
+	if err != nil {
+		m := newHandlerMetric("voteHandler")
+		defer m.observe()
+		code := errClassBadRequest
+		if err.(parseError).Code == http.StatusNotFound {
+			code = errClassNotFound
+		}
+		writeError(w, r, m, err.(parseError).Code, code, err.Error())
+		return
+	}
+
+	// Signed requests are throttled by key once parseVoteRequest has verified the signature, so that a forged
+	// request cannot use up the bucket of someone else's key. Only requests for a single ballot are verified
+	if key := r.Header.Get("X-Public-Key"); key != "" && ballotID != "" && r.Header.Get("X-Signature") != "" {
+		if ok, retryAfter := s.keyLimiter.Allow(key); !ok {
+			m := newHandlerMetric("voteHandler")
+			defer m.observe()
+			writeRateLimited(w, r, m, retryAfter)
+			return
+		}
+	}
+
+	// If there is no ballotID and we are GETing, just return the full-list of votes for the electionID.
+	// POSTing casts a batch of ballots. See batch.go
+	if ballotID == "" {
+		if r.Method == "GET" {
+			s.handleGETVoteBatch(w, r, electionID)
+			return
+		} else if r.Method == "POST" {
+			s.handlePOSTVoteBatch(w, r, electionID)
+			return
+		} else {
+			m := newHandlerMetric("voteHandler")
+			defer m.observe()
+			writeError(w, r, m, http.StatusMethodNotAllowed, errClassMethod, "Method not allowed")
+			return
+		}
+	}
+
+	// We are dealing with an individual vote
+	switch r.Method {
+	case "GET":
+		s.handleGETVote(w, r, electionID, ballotID)
+	case "PUT":
+		s.handlePUTVote(w, r, electionID, ballotID)
+	case "HEAD":
+		s.handleHEADVote(w, r, electionID, ballotID)
+	default:
+		m := newHandlerMetric("voteHandler")
+		defer m.observe()
+		writeError(w, r, m, http.StatusMethodNotAllowed, errClassMethod, "Method not allowed")
+	}
+}
+
+func parseVoteRequest(r *http.Request) (electionID string, ballotID string, err error) {
+	// Parse URL and route. The path is used rather than the RequestURI so that query parameters are ignored
+	urlparts := strings.Split(r.URL.Path, "/")
+
+	// Check for the correct number of request parts
+	if len(urlparts) < 3 || len(urlparts) > 4 {
+		err = parseError{"Invalid number of url parts. 404 Not Found.", http.StatusNotFound}
+		return
+	}
+
+	// Get the electionID
+	electionID = urlparts[2]
+	if len(electionID) > MaxElectionIDSize || !ValidElectionID.MatchString(electionID) {
+		err = parseError{"Invalid Election ID. 404 Not Found.", http.StatusNotFound}
+		return
+	}
+
+	// If we are only length 3, that's it, we are asking for a full report / ballot roll for an election
+	if len(urlparts) == 3 || urlparts[3] == "" {
+		return
+	}
+
+	// Get the ballotID
+	ballotID = urlparts[3]
+	if len(ballotID) > MaxBallotIDSize || !ValidBallotID.MatchString(ballotID) {
+		err = parseError{"Invalid Ballot ID. 404 Not Found.", http.StatusNotFound}
+	}
+
+	// If the user has provided a signature of the request in the headers, verify it
+	if r.Header.Get("X-Signature") != "" {
+		// Verify the signature headers, do a cryptographic check to make sure the header and Method / URL request is signed
+		if suberr := verifySignatureHeaders(r); suberr != nil {
+			err = parseError{suberr.Error(), http.StatusBadRequest}
+			return
+		}
+	}
+
+	// All checks pass
+	return
+}
+
+func (s *Server) handleGETVote(w http.ResponseWriter, r *http.Request, electionID string, ballotID string) {
+	m := newHandlerMetric("handleGETVote")
+	defer m.observe()
+
+	// Check to make sure the Election exists
+	_, ok := s.conf.Elections.Get(electionID)
+	if !ok {
+		writeError(w, r, m, http.StatusNotFound, errClassNotFound, "Election not found")
+		return
+	}
+	m.setElection(electionID)
+
+	ballotString, err := s.store.GetBallot(electionID, ballotID)
+	if err != nil {
+		if err == ErrNotFound {
+			writeError(w, r, m, http.StatusNotFound, errClassNotFound, "Ballot not found")
+			return
+		} else {
+			writeInternalError(w, r, m, errClassDatabase, err)
+			return
+		}
+	}
+	if httplog.WantsJSON(r) {
+		ballot, err := NewBallot(ballotString)
+		if err != nil {
+			writeInternalError(w, r, m, errClassInternal, err)
+			return
+		}
+		writeJSON(w, r, m, ballot)
+		return
+	}
+	w.Write(ballotString)
+}
+
+func (s *Server) handlePUTVote(w http.ResponseWriter, r *http.Request, electionID string, ballotID string) {
+	m := newHandlerMetric("handlePUTVote")
+	defer m.observe()
+
+	// Check to make sure the Election exists. Elections created at the clerk since the last refresh are pulled once the TTL has passed
+	election, ok := s.conf.Elections.Get(electionID)
+	if !ok {
+		writeError(w, r, m, http.StatusNotFound, errClassNotFound, "Election not found")
+		return
+	}
+	m.setElection(electionID)
+
+	if !s.electionIsOpen(&election) {
+		writeError(w, r, m, http.StatusBadRequest, errClassClosed, "Election is not open for voting")
+		return
+	}
+
+	body, err := ratelimit.ReadBody(w, r, MaxBallotSize)
+	if err != nil {
+		writeBodyError(w, r, m, err)
+		return
+	}
+
+	body, err = httplog.TextBody(r, body, &Ballot{})
+	if err != nil {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, "Error reading ballot. "+err.Error())
+		return
+	}
+	ballot, err := NewBallot(body)
+	if err != nil {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, "Error reading ballot. "+err.Error())
+		return
+	}
+
+	if err = s.verifyBallotSignature(&election, ballot); err != nil {
+		m.verificationFailed("ballot_signature")
+		writeError(w, r, m, http.StatusBadRequest, errClassVerification, "Error verifying ballot signature. "+err.Error())
+		return
+	}
+
+	// When mixing, hold the ballot back so that it is published later as part of a shuffled batch. The pending table
+	// only refuses ballot-ids that are pending, so the published ballots are checked first
+	if s.conf.Mixing.Enabled {
+		exists, err := s.store.BallotExists(electionID, ballot.BallotID)
+		if err != nil {
+			writeInternalError(w, r, m, errClassDatabase, err)
+			return
+		}
+		if exists {
+			writeError(w, r, m, http.StatusForbidden, errClassDuplicate, "Ballot with this ID already exists")
+			return
+		}
+
+		err = s.store.SavePendingBallot(ballot)
+		if err == ErrDuplicate {
+			writeError(w, r, m, http.StatusForbidden, errClassDuplicate, "Ballot with this ID already exists")
+			return
+		}
+		if err != nil {
+			writeInternalError(w, r, m, errClassDatabase, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// The store refuses a ballot-id that is already published
+	err = s.store.SaveBallot(ballot)
+	if err == ErrDuplicate {
+		writeError(w, r, m, http.StatusForbidden, errClassDuplicate, "Ballot with this ID already exists")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, m, errClassDatabase, err)
+		return
+	}
+	s.trees.add(ballot)
+}
+
+func (s *Server) handleHEADVote(w http.ResponseWriter, r *http.Request, electionID string, ballotID string) {
+	w.Write([]byte("Not implemented yet!"))
+}
+
+// handleGETVoteBatch streams the ballots for an election, ordered by ballot-id and separated by "\n\n\n", or as
+// a JSON array if the client accepts JSON.
+// A page of ballots may be requested with the `limit` and `after` query parameters, where `after` is
+// the ballot-id of the last ballot on the previous page. Since the status code has already been sent by the
+// time most errors could occur, the outcome is reported in the following HTTP trailers:
+//   - X-Stream-Status: "complete" if every requested ballot was sent, otherwise "error"
+//   - X-Ballot-Count: The number of ballots sent
+//   - X-Next-After: The `after` value for the next page. Empty if this is the last page.
+func (s *Server) handleGETVoteBatch(w http.ResponseWriter, r *http.Request, electionID string) {
+	m := newHandlerMetric("handleGETVoteBatch")
+	defer m.observe()
+
+	// First check to make sure the election exists
+	_, ok := s.conf.Elections.Get(electionID)
+	if !ok {
+		writeError(w, r, m, http.StatusNotFound, errClassNotFound, "Election not found")
+		return
+	}
+	m.setElection(electionID)
+
+	limit, after, err := parsePageParams(r)
+	if err != nil {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, err.Error())
+		return
+	}
+
+	// Ballots are never modified or deleted once published, so the number of ballots identifies the content
+	count, err := s.store.CountBallots(electionID)
+	if err != nil {
+		writeInternalError(w, r, m, errClassDatabase, err)
+		return
+	}
+	asJSON := httplog.WantsJSON(r)
+	etag := ballotsETag(electionID, count, limit, after, asJSON)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Accept")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	// Fetch one more ballot than requested to find out if there is another page
+	fetch := limit
+	if limit != 0 {
+		fetch = limit + 1
+	}
+	rows, err := s.store.ListBallots(electionID, after, fetch)
+	if err != nil {
+		writeInternalError(w, r, m, errClassDatabase, err)
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Trailer", "X-Stream-Status, X-Ballot-Count, X-Next-After")
+	separator := []byte("\n\n\n")
+	if asJSON {
+		separator = []byte(",")
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("["))
+		defer w.Write([]byte("]"))
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+	}
+	flusher, _ := w.(http.Flusher)
+
+	var (
+		ballotID     string
+		ballotString []byte
+		nextAfter    string
+		i            int
+	)
+	for rows.Next() {
+		if limit != 0 && i == limit {
+			nextAfter = ballotID
+			break
+		}
+		ballotID, ballotString, err = rows.Ballot()
+		if err != nil {
+			break
+		}
+		if asJSON {
+			if ballotString, err = ballotJSON(ballotString); err != nil {
+				break
+			}
+		}
+		if i != 0 {
+			w.Write(separator)
+		}
+		w.Write(ballotString)
+		i++
+		if flusher != nil && i%ballotFlushInterval == 0 {
+			flusher.Flush()
+		}
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+
+	w.Header().Set("X-Ballot-Count", strconv.Itoa(i))
+	w.Header().Set("X-Next-After", nextAfter)
+	if err != nil {
+		m.fail(errClassDatabase)
+		requestLogger(r).WithError(err).WithField("code", errClassDatabase).Error("internal error while streaming ballots")
+		w.Header().Set("X-Stream-Status", "error")
+		return
+	}
+	w.Header().Set("X-Stream-Status", "complete")
+}
+
+// parsePageParams parses the `limit` and `after` query parameters. A limit of zero means no limit.
+func parsePageParams(r *http.Request) (limit int, after string, err error) {
+	query := r.URL.Query()
+	if rawLimit := query.Get("limit"); rawLimit != "" {
+		limit, err = strconv.Atoi(rawLimit)
+		if err != nil || limit < 1 || limit > maxBallotPageSize {
+			return 0, "", errors.New("Invalid limit. Must be between 1 and " + strconv.Itoa(maxBallotPageSize))
+		}
+	}
+	after = query.Get("after")
+	if after != "" && (len(after) > MaxBallotIDSize || !ValidBallotID.MatchString(after)) {
+		return 0, "", errors.New("Invalid after. Must be a ballot ID")
+	}
+	return limit, after, nil
+}
+
+// ballotsETag builds the ETag for a page of ballots, in the text format or as JSON
+func ballotsETag(electionID string, count int, limit int, after string, asJSON bool) string {
+	key := electionID + "\n" + strconv.Itoa(count) + "\n" + strconv.Itoa(limit) + "\n" + after
+	if asJSON {
+		key += "\njson"
+	}
+	h := sha256.Sum256([]byte(key))
+	return `"` + hex.EncodeToString(h[:16]) + `"`
+}
+
+// verifyBallotSignature verifies the clerk's signature on a ballot. In weighted elections it must be made with the
+// clerk's key for the ballot's denomination
+func (s *Server) verifyBallotSignature(election *Election, ballot *Ballot) error {
+	if weightSource, _ := election.WeightSource(); weightSource != nil {
+		_, err := ballot.VerifyWeightedSignature(s.conf.DenominationKeys)
+		return err
+	}
+	return ballot.VerifyBlindSignature(s.conf.ClerkKey)
+}
+
+// electionIsOpen checks if the election is currently accepting ballots
+func (s *Server) electionIsOpen(election *Election) bool {
+	now := s.conf.Clock()
+	return now.After(election.Start) && now.Before(election.End)
+}
@@ -6,15 +6,17 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 	"path"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/cryptoballot/entropychecker"
 	"github.com/dlintw/goconf"
 	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/electionclerk/clerk"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/ratelimit"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/phayes/decryptpem"
 )
@@ -25,7 +27,7 @@ func bootstrap() {
 	if runtime.GOOS == "linux" {
 		err := entropychecker.WaitForEntropy()
 		if err != nil {
-			log.Fatal(err)
+			logger.Fatal(err)
 		}
 	}
 
@@ -34,21 +36,25 @@ func bootstrap() {
 	setUpOpt := flag.Bool("set-up-db", false, "Set up fresh database tables and schema. This should be run once before normal operations can occur.")
 	flag.Parse()
 
-
 	config, err := NewConfigFromFile(*configPathOpt)
 	if err != nil {
-		log.Fatal("Error parsing config file. ", err)
+		logger.WithError(err).Fatal("Error parsing config file")
 	}
 	conf = *config
 
+	err = clerk.ConfigureLogger(conf.log.level, conf.log.format)
+	if err != nil {
+		logger.WithError(err).Fatal("Error configuring logger")
+	}
+
 	// Connect to the database and set-up
 	db, err = sql.Open("mysql", conf.databaseConnectionString())
 	if err != nil {
-		log.Fatal("Database connection error: ", err)
+		logger.WithError(err).Fatal("Database connection error")
 	}
 	err = db.Ping()
 	if err != nil {
-		log.Fatal("Database connection error: ", err)
+		logger.WithError(err).Fatal("Database connection error")
 	}
 	// Set the maximum number of idle connections in the connection pool. `-1` means default of 2 idle connections in the pool
 	if conf.database.maxIdleConnections != -1 {
@@ -57,20 +63,21 @@ func bootstrap() {
 	// ConnMaxLifetime unit is second
 	db.SetConnMaxLifetime(time.Duration(conf.database.connMaxLifetime * 1000 * 1000 * 1000))
 
-
 	// If we are in 'set-up' mode, set-up the database and exit
 	if *setUpOpt {
-		_, err = db.Exec(schemaQuery)
-		if err != nil {
-			log.Fatal("Error loading database schema: ", err.Error())
-		}
-		_, err = db.Exec(schemaQueryIndex)
+		err = clerk.NewMySQLStore(db).SetUp()
 		if err != nil {
-			log.Fatal("Error loading database schema: ", err.Error())
+			logger.WithError(err).Fatal("Error loading database schema")
 		}
 		fmt.Println("Database set-up complete. Please run again without --set-up-db")
 		os.Exit(0)
 	}
+
+	// Bring a database set up by an older version up to date
+	err = clerk.NewMySQLStore(db).Migrate()
+	if err != nil {
+		logger.WithError(err).Fatal("Error migrating database schema")
+	}
 }
 
 func NewConfigFromFile(filepath string) (*Config, error) {
@@ -100,11 +107,57 @@ func NewConfigFromFile(filepath string) (*Config, error) {
 	}
 
 	// Parse did public key
-	config.didPublicKey, err = c.GetString("", "didPublicKey")
+	config.clerk.DIDPublicKey, err = c.GetString("", "didPublicKey")
 	if err != nil {
 		return nil, err
 	}
 
+	// Parse DID options. These are all optional
+	if c.HasOption("", "adminDIDs") {
+		adminDIDs, err := c.GetString("", "adminDIDs")
+		if err != nil {
+			return nil, err
+		}
+		for _, did := range strings.Split(adminDIDs, ",") {
+			did = strings.TrimSpace(did)
+			if !ValidDIDString(did) {
+				return nil, errors.New("Invalid DID in adminDIDs: " + did)
+			}
+			config.clerk.AdminDIDs = append(config.clerk.AdminDIDs, did)
+		}
+	}
+	if c.HasOption("", "didDocuments") {
+		didDocuments, err := c.GetString("", "didDocuments")
+		if err != nil {
+			return nil, err
+		}
+		config.clerk.Resolver = NewFileDIDResolver(didDocuments)
+	}
+	if c.HasOption("", "voterRolls") {
+		voterRolls, err := c.GetString("", "voterRolls")
+		if err != nil {
+			return nil, err
+		}
+		config.clerk.VoterRoll = clerk.VoterRollsFromDir(voterRolls)
+	}
+	if c.HasOption("", "revocationList") {
+		revocationList, err := c.GetString("", "revocationList")
+		if err != nil {
+			return nil, err
+		}
+		config.clerk.Revocations = &FileRevocationList{Path: revocationList}
+	}
+	if c.HasOption("", "denominationKeys") {
+		denominationKeys, err := c.GetString("", "denominationKeys")
+		if err != nil {
+			return nil, err
+		}
+		config.clerk.DenominationKeys, err = clerk.DenominationKeysFromDir(denominationKeys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Parse database config options
 	config.database.driver, err = c.GetString("database", "driver")
 	if err != nil {
@@ -134,6 +187,61 @@ func NewConfigFromFile(filepath string) (*Config, error) {
 		config.database.connMaxLifetime = 14440
 	}
 
+	// Parse logging options. Default to info level with text output.
+	config.log.level = "info"
+	if c.HasOption("log", "level") {
+		config.log.level, err = c.GetString("log", "level")
+		if err != nil {
+			return nil, err
+		}
+	}
+	config.log.format = "text"
+	if c.HasOption("log", "format") {
+		config.log.format, err = c.GetString("log", "format")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Parse rate limits and timeouts. See clerk/ratelimit.go
+	config.clerk.RateLimits.IP, err = ratelimit.ParseConfig(c, "ip", ratelimit.Limit{Rate: 10, Burst: 20})
+	if err != nil {
+		return nil, err
+	}
+	config.clerk.RateLimits.Key, err = ratelimit.ParseConfig(c, "key", ratelimit.Limit{Rate: 1, Burst: 5})
+	if err != nil {
+		return nil, err
+	}
+	if c.HasOption("ratelimit", "trust-proxy") {
+		config.clerk.RateLimits.TrustProxy, err = c.GetBool("ratelimit", "trust-proxy")
+		if err != nil {
+			return nil, err
+		}
+	}
+	timeouts := []struct {
+		option   string
+		timeout  *time.Duration
+		fallback int
+	}{
+		{"read-header-timeout", &config.clerk.Timeouts.ReadHeader, 10},
+		{"read-timeout", &config.clerk.Timeouts.Read, 30},
+		{"write-timeout", &config.clerk.Timeouts.Write, 300},
+		{"idle-timeout", &config.clerk.Timeouts.Idle, 120},
+	}
+	for _, t := range timeouts {
+		seconds := t.fallback
+		if c.HasOption("http", t.option) {
+			seconds, err = c.GetInt("http", t.option)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if seconds < 0 {
+			return nil, errors.New("http " + t.option + " must not be negative")
+		}
+		*t.timeout = time.Duration(seconds) * time.Second
+	}
+
 	// Ingest the private key into the global config object
 	config.signingKeyPath, err = c.GetString("", "signing-key")
 	if err != nil {
@@ -168,7 +276,7 @@ func configProcessFiles(config *Config) error {
 	if err != nil {
 		return err
 	}
-	config.signingKey, err = NewPrivateKeyFromBlock(signingKeyPEM)
+	config.clerk.SigningKey, err = NewPrivateKeyFromBlock(signingKeyPEM)
 	if err != nil {
 		return err
 	}
@@ -178,13 +286,13 @@ func configProcessFiles(config *Config) error {
 	if err != nil {
 		return err
 	}
-	config.adminUsers, err = NewUserSet(adminPEMBytes)
+	config.clerk.AdminUsers, err = NewUserSet(adminPEMBytes)
 	if err != nil {
 		return err
 	}
 
 	// Ingest the readme
-	config.readme, err = ioutil.ReadFile(config.readmePath)
+	config.clerk.Readme, err = ioutil.ReadFile(config.readmePath)
 	if err != nil {
 		return err
 	}
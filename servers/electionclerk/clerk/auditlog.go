@@ -0,0 +1,154 @@
+package clerk
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+const maxLogPageSize = 1000 // Maximum number of audit log entries that may be requested with `limit`
+
+// logEntry makes the audit log entry for an event, for the store to append as it saves the record the event is
+// about. The subject is the text of that record.
+func (s *Server) logEntry(entryType string, electionID string, subject string) LogEntryMaker {
+	return func(prev *LogEntry) (*LogEntry, error) {
+		entry := NextLogEntry(prev, s.conf.Clock(), entryType, electionID, subject)
+		var err error
+		entry.Signature, err = s.conf.SigningKey.SignString(entry.StringWithoutSignature())
+		if err != nil {
+			return nil, err
+		}
+		return entry, nil
+	}
+}
+
+// Handle a request for the audit log at `/log`. A segment of the log may be requested with the `from` and `limit`
+// query parameters, where `from` is the sequence number of the first entry. Entries are separated by "\n\n\n".
+// To verify a segment, a client needs the entry before it. See VerifyAuditLog
+//
+// Anyone polling the log could note when each signature entry appeared, and once an election has ended and its
+// signature requests are published, match each voter to the time they were signed for. So the log is only published
+// up to the first signature entry of an election that has not yet ended, the same as /sigs/ withholds the signature
+// requests. Everything from that entry on is published once the election ends.
+func (s *Server) logHandler(w http.ResponseWriter, r *http.Request) {
+	m := newHandlerMetric("logHandler")
+	defer m.observe()
+
+	if r.Method != "GET" {
+		writeError(w, r, m, http.StatusMethodNotAllowed, errClassMethod, "Method not allowed. Only GET is allowed here.")
+		return
+	}
+
+	from, limit, err := parseLogParams(r)
+	if err != nil {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, err.Error())
+		return
+	}
+
+	released, err := s.logIndex.released(s.store, s.conf.Clock())
+	if err != nil {
+		writeInternalError(w, r, m, errClassDatabase, err)
+		return
+	}
+	var entries []*LogEntry
+	if from < released {
+		if entries, err = s.store.GetLog(from, limit); err != nil {
+			writeInternalError(w, r, m, errClassDatabase, err)
+			return
+		}
+	}
+	for len(entries) != 0 && entries[len(entries)-1].Sequence >= released {
+		entries = entries[:len(entries)-1]
+	}
+	for i, entry := range entries {
+		if i != 0 {
+			w.Write([]byte("\n\n\n"))
+		}
+		w.Write([]byte(entry.String()))
+	}
+}
+
+// parseLogParams gets the `from` and `limit` query parameters for a segment of the audit log
+func parseLogParams(r *http.Request) (from uint64, limit int, err error) {
+	query := r.URL.Query()
+	if rawFrom := query.Get("from"); rawFrom != "" {
+		from, err = strconv.ParseUint(rawFrom, 10, 64)
+		if err != nil {
+			return 0, 0, errors.New("Invalid from. Must be the sequence number of an audit log entry")
+		}
+	}
+	if rawLimit := query.Get("limit"); rawLimit != "" {
+		limit, err = strconv.Atoi(rawLimit)
+		if err != nil || limit < 1 || limit > maxLogPageSize {
+			return 0, 0, errors.New("Invalid limit. Must be between 1 and " + strconv.Itoa(maxLogPageSize))
+		}
+	}
+	return from, limit, nil
+}
+
+// logIndex keeps track of the first signature entry of each election that has not ended, so that the audit log can
+// be published without those entries or any after them. Log entries and elections never change once saved, so the
+// index only reads the entries appended since it last looked, even when several clerks share the store.
+type logIndex struct {
+	mu             sync.Mutex
+	next           uint64               // Sequence of the next entry to read
+	firstSignature map[string]uint64    // Sequence of the first signature entry of each election not known to have ended
+	ends           map[string]time.Time // End of each election in firstSignature
+	ended          map[string]bool      // Elections that have ended, whose entries are never withheld again
+}
+
+func newLogIndex() *logIndex {
+	return &logIndex{
+		firstSignature: make(map[string]uint64),
+		ends:           make(map[string]time.Time),
+		ended:          make(map[string]bool),
+	}
+}
+
+// released gets the number of entries at the start of the log that may be published
+func (idx *logIndex) released(store Store, now time.Time) (uint64, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	entries, err := store.GetLog(idx.next, 0)
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		if _, ok := idx.firstSignature[entry.ElectionID]; entry.Type == LogSignatureIssued && !ok && !idx.ended[entry.ElectionID] {
+			idx.firstSignature[entry.ElectionID] = entry.Sequence
+		}
+		idx.next = entry.Sequence + 1
+	}
+
+	released := idx.next
+	for electionID, sequence := range idx.firstSignature {
+		end, ok := idx.ends[electionID]
+		if !ok {
+			rawElection, err := store.GetElection(electionID)
+			if err != nil {
+				return 0, err
+			}
+			election, err := NewElection(rawElection)
+			if err != nil {
+				return 0, err
+			}
+			end = election.End
+			idx.ends[electionID] = end
+		}
+		if !now.Before(end) {
+			delete(idx.firstSignature, electionID)
+			delete(idx.ends, electionID)
+			idx.ended[electionID] = true
+			continue
+		}
+		if sequence < released {
+			released = sequence
+		}
+	}
+	return released, nil
+}
@@ -0,0 +1,172 @@
+// Package clerk implements the election clerk's REST service.
+//
+// The election clerk stores elections and blind-signs ballots for voters. A Server is built from a Config and a
+// Store, so it may be run against MySQL by the electionclerk binary, or against an in-memory store in tests.
+package clerk
+
+import (
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/ratelimit"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Config holds everything the election clerk needs to serve requests
+type Config struct {
+	AdminUsers   UserSet          // Admin users. Published at /admins
+	Readme       []byte           // Static content for serving to the root readme (at "/")
+	SigningKey   PrivateKey       // Key used to blind-sign ballots, and denomination 1 in weighted elections
+	DIDPublicKey string           // Hex encoded did public key of the admin allowed to create elections
+	AdminDIDs    []string         // DIDs of further admins allowed to create elections. See isAdmin
+	Resolver     DIDResolver      // Resolves admin, voter and credential issuer DIDs. May be nil if DIDs are not used
+	Revocations  RevocationSource // Reports revoked voter credentials. May be nil if credentials are never revoked
+	Clock        func() time.Time // Returns the current time. Defaults to time.Now
+	Timeouts     Timeouts         // Timeouts for slow clients. See HTTPServer
+
+	// RateLimits throttle requests per client IP address, and per key that signs a request. See ratelimit.go
+	RateLimits struct {
+		IP         RateLimit
+		Key        RateLimit
+		TrustProxy bool // Take the client IP address from X-Forwarded-For, as set by a trusted reverse proxy
+	}
+
+	// DenominationKeys are the further keys used to blind-sign ballots in weighted elections, by denomination. See weight.go
+	DenominationKeys map[uint64]PrivateKey
+
+	// VoterRoll gets the voter roll for an election, or nil if any voter may take part. May be nil if no election has a roll.
+	VoterRoll func(electionID string) (VoterRoll, error)
+}
+
+// Server is the election clerk REST service
+type Server struct {
+	conf  Config
+	store Store
+
+	ipLimiter  *ratelimit.Limiter // See ratelimit.go
+	keyLimiter *ratelimit.Limiter
+	logIndex   *logIndex // See auditlog.go
+}
+
+// NewServer creates an election clerk that keeps its elections and signature requests in the given store
+func NewServer(conf Config, store Store) *Server {
+	if conf.Clock == nil {
+		conf.Clock = time.Now
+	}
+	return &Server{
+		conf:       conf,
+		store:      store,
+		ipLimiter:  ratelimit.New(conf.RateLimits.IP, conf.Clock),
+		keyLimiter: ratelimit.New(conf.RateLimits.Key, conf.Clock),
+		logIndex:   newLogIndex(),
+	}
+}
+
+// Handler gets an http.Handler that serves all of the election clerk's routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", withLogging("root", s.withRateLimit(s.rootHandler)))                       // Displays the readme
+	mux.Handle("/sign", withLogging("sign", s.withRateLimit(s.signHandler)))                   // Provides the ability to POST new Signature Requests. See signature-handler.go
+	mux.Handle("/sigs/", withLogging("sigs", s.withRateLimit(s.sigsHandler)))                  // Publishes Fulfilled Signature Requests once an election has ended. See signature-handler.go
+	mux.Handle("/election", withLogging("election", s.withRateLimit(s.electionHandler)))       // Send to election handler. Used for getting all elections
+	mux.Handle("/election/", withLogging("election", s.withRateLimit(s.electionHandler)))      // Creating elections and viewing election metadata. See election-handler.go
+	mux.Handle("/admins", withLogging("admins", s.withRateLimit(s.adminsHandler)))             // View admins, their public keys and their perms
+	mux.Handle("/publickey", withLogging("publickey", s.withRateLimit(s.publicKeyHandler)))    // Reports this servers public key
+	mux.Handle("/publickeys", withLogging("publickeys", s.withRateLimit(s.publicKeysHandler))) // Reports this servers public keys by denomination. See weight.go
+	mux.Handle("/log", withLogging("log", s.withRateLimit(s.logHandler)))                      // The hash-chained audit log of elections, signatures and results. See auditlog.go
+	mux.Handle("/openapi.json", withLogging("openapi", s.withRateLimit(s.openAPIHandler)))     // Describes the JSON API. See openapi.go
+	mux.Handle("/metrics", promhttp.Handler())                                                 // Prometheus metrics. See metrics.go
+	// @@TODO add a api so box can check if the election is exist or not
+	return mux
+}
+
+// When a user accesses "/" display the readme
+func (s *Server) rootHandler(w http.ResponseWriter, r *http.Request) {
+	if r.RequestURI != "/" {
+		writeError(w, r, nil, http.StatusNotFound, errClassNotFound, "404 Not Found.")
+		return
+	}
+	_, err := w.Write(s.conf.Readme)
+	if err != nil {
+		requestLogger(r).WithError(err).Error("Error writing readme")
+	}
+	return
+}
+
+// Display the public key used to sign ballots when a user asks for "/publickey"
+func (s *Server) publicKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, r, nil, http.StatusMethodNotAllowed, errClassMethod, "Method not allowed. Only GET is allowed here.")
+		return
+	}
+
+	publicKey, err := s.conf.SigningKey.PublicKey()
+	if err != nil {
+		writeInternalError(w, r, nil, errClassInternal, err)
+		return
+	}
+
+	pemBlock := pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: publicKey.Bytes(),
+	}
+	pem.Encode(w, &pemBlock)
+	return
+}
+
+// Display all admin user information when a user asks for "/admins"
+func (s *Server) adminsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, r, nil, http.StatusMethodNotAllowed, errClassMethod, "Method not allowed. Only GET is allowed here.")
+		return
+	}
+
+	fmt.Fprint(w, s.conf.AdminUsers)
+}
+
+// When a voter or an admin makes a priviledged request that requires verification
+// of their public-key, they are required to include the following HTTP headers:
+//  1. X-Public-Key: The user's base64 encoded public key.
+//  2. X-Signature: Signature for this request. The user should sign the HTTP request string which includes
+//     the method and the path (for example PUT /vote/1234/939fhdsjkksdkl0903f). This signature should be
+//     base64 encoded. The signature should use SHA256 as the hashing function.
+//
+// This function verifies that these headers are constructed properly and that the signature
+// cryptographically signs the request. This function does not check the cryptographic veracity of the body.
+func verifySignatureHeaders(r *http.Request) error {
+	rawpk := r.Header.Get("X-Public-Key")
+	if rawpk == "" {
+		return errors.New("Missing X-Public-Key header. ")
+	}
+	rawsig := r.Header.Get("X-Signature")
+	if rawsig == "" {
+		return errors.New("Missing X-Signature header. ")
+	}
+	sig, err := hex.DecodeString(rawsig)
+
+	if err != nil {
+		return errors.New("Error parsing X-Signature header. " + err.Error())
+	}
+
+	pub, err := hex.DecodeString(rawpk)
+	if err != nil {
+		return errors.New("invalid did public key")
+	}
+	publicKey, err := crypto.DecodePoint(pub)
+	if err != nil {
+		return err
+	}
+	didPublicKey := DIDPublicKey{PublicKey: *publicKey}
+	// Verify the signature against the request string. For example PUT /vote/1234/939fhdsjkksdkl0903f...
+	err = didPublicKey.VerifySignature(sig, []byte(r.Method+" "+r.RequestURI))
+	if err != nil {
+		return errors.New("Cryptographic verification of X-Signature header failed. " + err.Error())
+	}
+	return nil
+}
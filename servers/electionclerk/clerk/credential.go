@@ -0,0 +1,78 @@
+package clerk
+
+import (
+	"errors"
+	"net/http"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+var errCredentialDIDRequired = errors.New("This election requires credentials. Signature requests must give the voter's DID")
+
+// checkCredentials checks that the voter has presented the credentials the election requires, if any.
+// The presentation must be signed by the voter's DID for this election and request, and each required credential must
+// be issued to the voter by a trusted issuer, be within its validity period and not be revoked.
+// On failure the status code and error class to respond with are returned.
+func (s *Server) checkCredentials(sigReq *SignatureRequest, election *Election) (int, string, error) {
+	requirements := election.CredentialRequirements()
+	if !requirements.Required() {
+		return 0, "", nil
+	}
+	if sigReq.DID == "" {
+		return http.StatusForbidden, errClassForbidden, errCredentialDIDRequired
+	}
+	presentation, err := sigReq.GetPresentation()
+	if err != nil {
+		return http.StatusBadRequest, errClassBadRequest, err
+	}
+	if presentation == nil {
+		return http.StatusForbidden, errClassForbidden, ErrPresentationRequired
+	}
+
+	now := s.conf.Clock()
+	err = presentation.Verify(s.conf.Resolver, sigReq.ElectionID, sigReq.RequestID, now)
+	if err != nil {
+		return http.StatusForbidden, errClassVerification, err
+	}
+
+	// A revocation source that cannot be read is our problem, not the voter's
+	revocations := s.newRevocationCheck()
+	err = requirements.Check(presentation, s.conf.Resolver, revocations.orNil(), now)
+	if revocations != nil && revocations.err != nil {
+		return http.StatusInternalServerError, errClassInternal, revocations.err
+	}
+	if err != nil {
+		return http.StatusForbidden, errClassVerification, err
+	}
+	return 0, "", nil
+}
+
+// revocationCheck wraps a RevocationSource, keeping any error from the source
+type revocationCheck struct {
+	source RevocationSource
+	err    error
+}
+
+// newRevocationCheck wraps the configured revocation source. It is nil if there is none
+func (s *Server) newRevocationCheck() *revocationCheck {
+	if s.conf.Revocations == nil {
+		return nil
+	}
+	return &revocationCheck{source: s.conf.Revocations}
+}
+
+// orNil gets the check as a RevocationSource, which is nil rather than a nil *revocationCheck if there is no check
+func (check *revocationCheck) orNil() RevocationSource {
+	if check == nil {
+		return nil
+	}
+	return check
+}
+
+func (check *revocationCheck) IsRevoked(credentialID string) (bool, error) {
+	revoked, err := check.source.IsRevoked(credentialID)
+	if err != nil {
+		check.err = err
+	}
+	return revoked, err
+}
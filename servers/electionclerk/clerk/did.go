@@ -0,0 +1,101 @@
+package clerk
+
+import (
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+var (
+	errVoterDIDRequired = errors.New("This election has a voter roll. Signature requests must give the voter's DID")
+	errVoterNotOnRoll   = errors.New("The voter's DID is not on the voter roll for this election")
+)
+
+// VoterRollsFromDir gets voter rolls from a directory holding one roll per election, named <election-id>.
+// Rolls are read when they are needed, so rolls can be added for elections created after the clerk starts.
+func VoterRollsFromDir(dir string) func(electionID string) (VoterRoll, error) {
+	return func(electionID string) (VoterRoll, error) {
+		rawRoll, err := ioutil.ReadFile(filepath.Join(dir, electionID))
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return NewVoterRoll(rawRoll)
+	}
+}
+
+// isAdmin checks that the election was created by an admin.
+// The admin is either the one given by Config.DIDPublicKey, or one of Config.AdminDIDs. In the latter case the
+// election must name the admin's DID with the admin-did tag, and be signed with one of the DID's current keys.
+func (s *Server) isAdmin(election *Election) (bool, error) {
+	if s.conf.DIDPublicKey != "" && s.conf.DIDPublicKey == hex.EncodeToString(election.PublicKey) {
+		return true, nil
+	}
+	did := election.AdminDID()
+	if did == "" {
+		return false, nil
+	}
+	found := false
+	for _, adminDID := range s.conf.AdminDIDs {
+		if adminDID == did {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false, nil
+	}
+	err := AuthenticateDID(s.conf.Resolver, did, election.PublicKey, s.conf.Clock())
+	if isDIDAuthError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// checkVoter checks that the voter may have their ballot signed. If the election has a voter roll, the voter must
+// give a DID on the roll. If a DID is given, the public key the request was signed with must be one of its keys.
+// On failure the status code and error class to respond with are returned.
+func (s *Server) checkVoter(sigReq *SignatureRequest) (int, string, error) {
+	var roll VoterRoll
+	if s.conf.VoterRoll != nil {
+		var err error
+		roll, err = s.conf.VoterRoll(sigReq.ElectionID)
+		if err != nil {
+			return http.StatusInternalServerError, errClassInternal, err
+		}
+	}
+
+	if sigReq.DID == "" {
+		if roll != nil {
+			return http.StatusForbidden, errClassForbidden, errVoterDIDRequired
+		}
+		return 0, "", nil
+	}
+	if roll != nil && !roll.Contains(sigReq.DID) {
+		return http.StatusForbidden, errClassForbidden, errVoterNotOnRoll
+	}
+	err := AuthenticateDID(s.conf.Resolver, sigReq.DID, sigReq.PublicKey, s.conf.Clock())
+	if isDIDAuthError(err) {
+		return http.StatusForbidden, errClassVerification, err
+	}
+	if err != nil {
+		return http.StatusInternalServerError, errClassInternal, err
+	}
+	return 0, "", nil
+}
+
+// isDIDAuthError checks if the error means the DID could not be authenticated, rather than that resolution failed
+func isDIDAuthError(err error) bool {
+	switch err {
+	case ErrDIDInvalid, ErrDIDNotFound, ErrDIDDocumentMismatch, ErrDIDDocumentExpired, ErrDIDKeyNotAuthorized, ErrDIDResolverNotConfig:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package clerk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"net/http"
+	"strings"
+
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/httplog"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/ratelimit"
+)
+
+func (s *Server) electionHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse URL and route
+	urlparts := strings.Split(r.RequestURI, "/")
+
+	// If the user is asking for `/election` or `/election/` then give them all the elections
+	if r.RequestURI == "/election" || r.RequestURI == "/election/" {
+		s.handleGETAllElections(w, r)
+		return
+	}
+
+	// `/election/<election-id>/result` is the election's published result. See result-handler.go
+	if len(urlparts) == 4 && urlparts[3] == "result" {
+		s.resultHandler(w, r, urlparts[2])
+		return
+	}
+
+	// Check for the correct number of request parts
+	if len(urlparts) != 3 {
+		writeError(w, r, nil, http.StatusNotFound, errClassNotFound, "Invalid URL. 404 Not Found.")
+		return
+	}
+
+	// Get the electionID
+	electionID := urlparts[2]
+
+	// Check for valid election ID
+	if len(electionID) > MaxElectionIDSize || !ValidElectionID.MatchString(electionID) {
+		writeError(w, r, nil, http.StatusNotFound, errClassNotFound, "Invalid Election ID. 404 Not Found.")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.handleGETElection(w, r, electionID)
+	case "PUT":
+		s.handlePUTElection(w, r, electionID)
+	default:
+		writeError(w, r, nil, http.StatusMethodNotAllowed, errClassMethod, "Method not allowed")
+	}
+}
+
+func (s *Server) handlePUTElection(w http.ResponseWriter, r *http.Request, electionID string) {
+	m := newHandlerMetric("handlePUTElection")
+	defer m.observe()
+
+	err := verifySignatureHeaders(r)
+	if err != nil {
+		m.verificationFailed("request_signature")
+		writeError(w, r, m, http.StatusBadRequest, errClassVerification, err.Error())
+		return
+	}
+	if !s.limitKey(w, r, m, r.Header.Get("X-Public-Key")) {
+		return
+	}
+
+	body, err := ratelimit.ReadBody(w, r, maxElectionSize)
+	if err != nil {
+		writeBodyError(w, r, m, err)
+		return
+	}
+
+	body, err = httplog.TextBody(r, body, &Election{})
+	if err != nil {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, err.Error())
+		return
+	}
+	election, err := NewElection(body)
+	if err != nil {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, err.Error())
+		return
+	}
+	if election.ElectionID != electionID {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, "Election ID mismatch between body and URL")
+		return
+	}
+	if hex.EncodeToString(election.PublicKey) != r.Header.Get("X-Public-Key") {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, "Public Key mismatch between headers and body")
+		return
+	}
+	if _, err = election.WeightSource(); err != nil {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, err.Error())
+		return
+	}
+	if _, err = election.DecisionRules(); err != nil {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, err.Error())
+		return
+	}
+
+	// Verify the signature on the election
+	err = election.VerifySignature()
+	if err != nil {
+		m.verificationFailed("election_signature")
+		writeError(w, r, m, http.StatusBadRequest, errClassVerification, "Error verifying election signature. "+err.Error())
+		return
+	}
+
+	// Check to make sure this admin exists and has permission to administer elections
+	admin, err := s.isAdmin(election)
+	if err != nil {
+		writeInternalError(w, r, m, errClassInternal, err)
+		return
+	}
+	if !admin {
+		writeError(w, r, m, http.StatusForbidden, errClassForbidden, "Could not find admin with the provided public key of "+hex.EncodeToString(election.PublicKey))
+		return
+	}
+
+	// All checks pass. Save the election
+	err = s.store.SaveElection(election, s.logEntry(LogElectionCreated, election.ElectionID, election.String()))
+	if err == ErrElectionExist {
+		writeError(w, r, m, http.StatusConflict, errClassDuplicate, "Election with this ID already exists")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, m, errClassDatabase, err)
+		return
+	}
+	m.setElection(election.ElectionID)
+}
+
+func (s *Server) handleGETElection(w http.ResponseWriter, r *http.Request, electionID string) {
+	m := newHandlerMetric("handleGETElection")
+	defer m.observe()
+
+	rawElection, err := s.store.GetElection(electionID)
+	if err != nil {
+		if err == ErrNotFound {
+			writeError(w, r, m, http.StatusNotFound, errClassNotFound, "Could not find election with ID "+electionID)
+		} else {
+			writeInternalError(w, r, m, errClassDatabase, err)
+		}
+		return
+	}
+	m.setElection(electionID)
+	if httplog.WantsJSON(r) {
+		election, err := NewElection(rawElection)
+		if err != nil {
+			writeInternalError(w, r, m, errClassInternal, err)
+			return
+		}
+		writeJSON(w, r, m, election)
+		return
+	}
+	w.Write(rawElection)
+	return
+}
+
+func (s *Server) handleGETAllElections(w http.ResponseWriter, r *http.Request) {
+	m := newHandlerMetric("handleGETAllElections")
+	defer m.observe()
+
+	elections, err := s.store.GetAllElections()
+	if err != nil {
+		writeInternalError(w, r, m, errClassDatabase, err)
+		return
+	}
+	asJSON := httplog.WantsJSON(r)
+	etag := electionsETag(elections, asJSON)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Accept")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if asJSON {
+		parsed := []*Election{}
+		for _, rawElection := range elections {
+			election, err := NewElection(rawElection)
+			if err != nil {
+				writeInternalError(w, r, m, errClassInternal, err)
+				return
+			}
+			parsed = append(parsed, election)
+		}
+		writeJSON(w, r, m, parsed)
+		return
+	}
+	for i, rawElection := range elections {
+		if i != 0 {
+			w.Write([]byte("\n\n\n"))
+		}
+		w.Write(rawElection)
+	}
+	return
+}
+
+// electionsETag builds the ETag for the list of elections, in the text format or as JSON. Ballotboxes send it back
+// when they refresh their elections, so that the list is only sent again once an election has been created.
+func electionsETag(elections [][]byte, asJSON bool) string {
+	h := sha256.New()
+	for _, rawElection := range elections {
+		h.Write(rawElection)
+		h.Write([]byte("\n\n\n"))
+	}
+	if asJSON {
+		h.Write([]byte("json"))
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
@@ -0,0 +1,55 @@
+package clerk
+
+import (
+	"net/http"
+
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/httplog"
+	"github.com/sirupsen/logrus"
+)
+
+// Logging policy
+//
+// The election clerk knows which voter requested each signature, and the ballotbox knows when
+// each ballot was cast. Combining the two would let an operator link voters to their ballots.
+// To prevent the clerk's logs from contributing to such a correlation:
+//  - Voter public keys, DIDs and request-ids are never logged.
+//  - Remote addresses are never logged.
+//  - Request IDs are always generated by the server. A client-supplied X-Request-ID is ignored, since
+//    a client that sent the same ID to both servers would link the two log entries together.
+//  - Raw database errors are logged but never sent to clients.
+
+// Logger is used for all of the server's logs. It is configured with ConfigureLogger.
+var Logger = logrus.New()
+
+// ConfigureLogger sets the log level and format
+func ConfigureLogger(level string, format string) error {
+	return httplog.Configure(Logger, level, format)
+}
+
+// withLogging assigns a request ID to the request and logs the request once it has been handled. See httplog.WithLogging
+func withLogging(route string, handler http.HandlerFunc) http.Handler {
+	return httplog.WithLogging(Logger, route, handler)
+}
+
+// requestLogger gets a log entry tagged with the request's ID
+func requestLogger(r *http.Request) *logrus.Entry {
+	return httplog.RequestLogger(Logger, r)
+}
+
+// writeError writes an error response with a consistent body, and records the error class on the metric.
+// The message is sent to the client verbatim, so it should never contain database errors.
+func writeError(w http.ResponseWriter, r *http.Request, m *handlerMetric, status int, code string, message string) {
+	if m != nil {
+		m.fail(code)
+	}
+	httplog.WriteError(w, r, status, code, message)
+}
+
+// writeInternalError logs the error and writes a generic error response that does not leak the error details, and
+// records the error class on the metric. See httplog.WriteInternalError
+func writeInternalError(w http.ResponseWriter, r *http.Request, m *handlerMetric, code string, err error) {
+	if m != nil {
+		m.fail(code)
+	}
+	httplog.WriteInternalError(Logger, w, r, code, err)
+}
@@ -0,0 +1,155 @@
+package clerk
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+// MemoryStore keeps everything in memory. It is intended for testing.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	order     []string          // Election IDs in the order they were saved
+	elections map[string][]byte // Election text, keyed by election ID
+	results   map[string][]byte // Published election results, keyed by election ID
+	sigreqs   map[string][]*FulfilledSignatureRequest
+	log       []*LogEntry // Audit log, in sequence
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		elections: make(map[string][]byte),
+		results:   make(map[string][]byte),
+		sigreqs:   make(map[string][]*FulfilledSignatureRequest),
+	}
+}
+
+func (s *MemoryStore) ElectionExists(electionID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.elections[electionID]
+	return ok, nil
+}
+
+func (s *MemoryStore) GetElection(electionID string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rawElection, ok := s.elections[electionID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return rawElection, nil
+}
+
+func (s *MemoryStore) GetAllElections() ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	elections := make([][]byte, 0, len(s.order))
+	for _, electionID := range s.order {
+		elections = append(elections, s.elections[electionID])
+	}
+	return elections, nil
+}
+
+func (s *MemoryStore) SaveElection(election *Election, logEntry LogEntryMaker) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.elections[election.ElectionID]; ok {
+		return ErrElectionExist
+	}
+	if err := s.appendLogEntry(logEntry); err != nil {
+		return err
+	}
+	s.elections[election.ElectionID] = []byte(election.String())
+	s.order = append(s.order, election.ElectionID)
+	return nil
+}
+
+func (s *MemoryStore) GetResult(electionID string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rawResult, ok := s.results[electionID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return rawResult, nil
+}
+
+func (s *MemoryStore) SaveResult(result *ElectionResult, logEntry LogEntryMaker) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.elections[result.ElectionID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := s.results[result.ElectionID]; ok {
+		return ErrResultExist
+	}
+	if err := s.appendLogEntry(logEntry); err != nil {
+		return err
+	}
+	s.results[result.ElectionID] = []byte(result.String())
+	return nil
+}
+
+// appendLogEntry makes the next entry of the audit log and appends it. The caller must hold the write lock.
+func (s *MemoryStore) appendLogEntry(logEntry LogEntryMaker) error {
+	var prev *LogEntry
+	if len(s.log) != 0 {
+		prev = s.log[len(s.log)-1]
+	}
+	entry, err := logEntry(prev)
+	if err != nil {
+		return err
+	}
+	if entry.Sequence != uint64(len(s.log)) {
+		return ErrLogConflict
+	}
+	s.log = append(s.log, entry)
+	return nil
+}
+
+func (s *MemoryStore) GetLog(from uint64, limit int) ([]*LogEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if from >= uint64(len(s.log)) {
+		return []*LogEntry{}, nil
+	}
+	entries := s.log[from:]
+	if limit != 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return append([]*LogEntry{}, entries...), nil
+}
+
+func (s *MemoryStore) SaveSignatureRequest(request *FulfilledSignatureRequest, weight uint64, logEntry LogEntryMaker) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var issued uint64
+	for _, fulfilled := range s.sigreqs[request.ElectionID] {
+		if bytes.Equal(fulfilled.RequestID, request.RequestID) {
+			issued += fulfilled.Weight()
+		}
+	}
+	if issued+request.Weight() > weight || issued+request.Weight() < issued {
+		return ErrWeightExhausted
+	}
+	if err := s.appendLogEntry(logEntry); err != nil {
+		return err
+	}
+	s.sigreqs[request.ElectionID] = append(s.sigreqs[request.ElectionID], request)
+	return nil
+}
+
+func (s *MemoryStore) GetSignatureRequests(electionID string) ([]*FulfilledSignatureRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fulfilled := make([]*FulfilledSignatureRequest, len(s.sigreqs[electionID]))
+	copy(fulfilled, s.sigreqs[electionID])
+	sort.Slice(fulfilled, func(i, j int) bool {
+		return bytes.Compare(fulfilled[i].RequestID, fulfilled[j].RequestID) < 0
+	})
+	return fulfilled, nil
+}
@@ -0,0 +1,418 @@
+package clerk
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlDuplicateEntry is the error number MySQL gives when an insert breaks a unique index
+const mysqlDuplicateEntry = 1062
+
+const (
+	schemaQuery = `
+					CREATE TABLE elections (
+					  election_id varchar(128) UNIQUE NOT NULL,
+					  startdate timestamp NOT NULL,
+					  enddate timestamp NOT NULL,
+					  tags text,
+					  election text NOT NULL,
+					  result text
+					)ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+					`
+	schemaQueryIndex = `CREATE INDEX elections_id_idx ON elections (election_id);`
+
+	auditLogQuery = `
+					CREATE TABLE IF NOT EXISTS auditlog (
+					  sequence bigint unsigned PRIMARY KEY,
+					  entry text NOT NULL
+					)ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+					`
+
+	// The table is created outside the transaction that saves its election, since MySQL commits any open transaction
+	// when a table is created. If saving the election fails, the table is left empty for the next attempt.
+	sigreqsQuery = `CREATE TABLE IF NOT EXISTS sigreqs_<election-id> (
+					  request_id varchar(64) NOT NULL,
+					  public_key varchar(66) NOT NULL,
+					  did varchar(128) NOT NULL DEFAULT '',
+					  presentation text NOT NULL,
+					  denomination bigint unsigned NOT NULL DEFAULT 0,
+					  ballot_hash text NOT NULL,
+					  signature text NOT NULL,
+					  ballot_signature text NOT NULL,
+					  INDEX request_id_idx (request_id)
+					)ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+)
+
+// MySQLStore keeps elections in the `elections` table, and the fulfilled signature requests
+// for each election in a `sigreqs_<election-id>` table.
+type MySQLStore struct {
+	db *sql.DB
+}
+
+// NewMySQLStore creates a store using the given database connection
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+// SetUp creates the database schema. It should be run once against a fresh database.
+func (s *MySQLStore) SetUp() error {
+	_, err := s.db.Exec(schemaQuery)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(schemaQueryIndex)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(auditLogQuery)
+	return err
+}
+
+// sigreqsColumns are the columns added to the sigreqs tables since the schema was first released, in order, with
+// the column each goes after
+var sigreqsColumns = []struct{ name, definition, after string }{
+	{"did", "varchar(128) NOT NULL DEFAULT ''", "public_key"},
+	{"presentation", "text NOT NULL", "did"},
+	{"denomination", "bigint unsigned NOT NULL DEFAULT 0", "presentation"},
+}
+
+// Migrate brings a database set up by an older version of the clerk up to date. It is safe to run at every start:
+// each table and column is only added if it is missing.
+func (s *MySQLStore) Migrate() error {
+	if _, err := s.db.Exec(auditLogQuery); err != nil {
+		return err
+	}
+	if err := s.addColumn("elections", "result", "text", "election"); err != nil {
+		return err
+	}
+
+	rows, err := s.db.Query("SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name LIKE 'sigreqs\\_%'")
+	if err != nil {
+		return err
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		for _, column := range sigreqsColumns {
+			if err = s.addColumn(table, column.name, column.definition, column.after); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addColumn adds a column to a table after the given column, unless the table already has it
+func (s *MySQLStore) addColumn(table string, column string, definition string, after string) error {
+	var exists int
+	err := s.db.QueryRow("SELECT 1 FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?", table, column).Scan(&exists)
+	if err == nil {
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	_, err = s.db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition + " AFTER " + after)
+	return err
+}
+
+// Check to see if the election already exists in the database
+func (s *MySQLStore) ElectionExists(electionID string) (bool, error) {
+	var exists int
+	start := time.Now()
+	err := s.db.QueryRow("select 1 from elections where election_id = ? limit 1", electionID).Scan(&exists)
+	observeQuery("select_election_exists", start)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		} else {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (s *MySQLStore) GetElection(electionID string) ([]byte, error) {
+	var rawElection []byte
+	start := time.Now()
+	err := s.db.QueryRow("SELECT election FROM elections WHERE election_id = ?", electionID).Scan(&rawElection)
+	observeQuery("select_election", start)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return rawElection, err
+}
+
+func (s *MySQLStore) GetAllElections() ([][]byte, error) {
+	start := time.Now()
+	rows, err := s.db.Query("SELECT election FROM elections")
+	observeQuery("select_elections", start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var elections [][]byte
+	for rows.Next() {
+		var rawElection []byte
+		err := rows.Scan(&rawElection)
+		if err != nil {
+			return nil, err
+		}
+		elections = append(elections, rawElection)
+	}
+	return elections, rows.Err()
+}
+
+func (s *MySQLStore) SaveElection(election *Election, logEntry LogEntryMaker) error {
+	exists, err := s.ElectionExists(election.ElectionID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrElectionExist
+	}
+
+	buf := new(bytes.Buffer)
+	for key, value := range election.TagSet.Map() {
+		buf.WriteString(key)
+		buf.WriteString("=")
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+	}
+	tags := ""
+	if buf.Len() > 0 {
+		tags = string(buf.Bytes()[:(buf.Len() - 1)])
+	}
+
+	// Create the sigreqs table for storing signature requests
+	_, err = s.db.Exec(strings.Replace(sigreqsQuery, "<election-id>", election.ElectionID, -1))
+	if err != nil {
+		return err
+	}
+
+	return s.withLogEntry(logEntry, func(tx *sql.Tx) error {
+		start := time.Now()
+		_, err := tx.Exec("INSERT INTO elections (election_id, election, startdate, enddate, tags) VALUES (?, ?, ?, ?, ?)", election.ElectionID, election.String(), election.Start, election.End, tags)
+		observeQuery("insert_election", start)
+		if isDuplicateEntry(err) {
+			return ErrElectionExist
+		}
+		return err
+	})
+}
+
+func (s *MySQLStore) GetResult(electionID string) ([]byte, error) {
+	var rawResult []byte
+	start := time.Now()
+	err := s.db.QueryRow("SELECT result FROM elections WHERE election_id = ? AND result IS NOT NULL", electionID).Scan(&rawResult)
+	observeQuery("select_result", start)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return rawResult, err
+}
+
+// SaveResult only sets the result if it is still NULL, so that two admins publishing at once cannot both succeed
+func (s *MySQLStore) SaveResult(result *ElectionResult, logEntry LogEntryMaker) error {
+	return s.withLogEntry(logEntry, func(tx *sql.Tx) error {
+		start := time.Now()
+		res, err := tx.Exec("UPDATE elections SET result = ? WHERE election_id = ? AND result IS NULL", result.String(), result.ElectionID)
+		observeQuery("update_result", start)
+		if err != nil {
+			return err
+		}
+		updated, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if updated == 0 {
+			exists, err := s.ElectionExists(result.ElectionID)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return ErrNotFound
+			}
+			return ErrResultExist
+		}
+		return nil
+	})
+}
+
+// withLogEntry saves a record and appends its audit log entry in one transaction. The last entry is read with
+// SELECT ... FOR UPDATE, which holds the end of the log until the transaction ends, so clerks sharing the database
+// append one at a time. The primary key on sequence is a backstop: a conflict there rolls back the record too.
+func (s *MySQLStore) withLogEntry(logEntry LogEntryMaker, save func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		prev     *LogEntry
+		rawEntry []byte
+	)
+	start := time.Now()
+	err = tx.QueryRow("SELECT entry FROM auditlog ORDER BY sequence DESC LIMIT 1 FOR UPDATE").Scan(&rawEntry)
+	observeQuery("select_last_log_entry", start)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil {
+		if prev, err = NewLogEntry(rawEntry); err != nil {
+			return err
+		}
+	}
+
+	if err = save(tx); err != nil {
+		return err
+	}
+
+	entry, err := logEntry(prev)
+	if err != nil {
+		return err
+	}
+	start = time.Now()
+	_, err = tx.Exec("INSERT INTO auditlog (sequence, entry) VALUES (?, ?)", entry.Sequence, entry.String())
+	observeQuery("insert_log_entry", start)
+	if isDuplicateEntry(err) {
+		return ErrLogConflict
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *MySQLStore) GetLog(from uint64, limit int) ([]*LogEntry, error) {
+	query := "SELECT entry FROM auditlog WHERE sequence >= ? ORDER BY sequence"
+	args := []interface{}{from}
+	if limit != 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	start := time.Now()
+	rows, err := s.db.Query(query, args...)
+	observeQuery("select_log", start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*LogEntry{}
+	for rows.Next() {
+		var rawEntry []byte
+		if err = rows.Scan(&rawEntry); err != nil {
+			return nil, err
+		}
+		entry, err := NewLogEntry(rawEntry)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// SaveSignatureRequest adds up the weight already issued to the voter inside the transaction, after withLogEntry has
+// locked the end of the audit log. Every save holds that lock, so no other request can be saved between the check and
+// the insert, even by another clerk sharing the database.
+func (s *MySQLStore) SaveSignatureRequest(request *FulfilledSignatureRequest, weight uint64, logEntry LogEntryMaker) error {
+	return s.withLogEntry(logEntry, func(tx *sql.Tx) error {
+		var issued uint64
+		start := time.Now()
+		err := tx.QueryRow("select coalesce(sum(if(denomination = 0, 1, denomination)), 0) from sigreqs_"+request.ElectionID+" where request_id = ?", hex.EncodeToString(request.RequestID)).Scan(&issued)
+		observeQuery("select_sigreq_weight", start)
+		if err != nil {
+			return err
+		}
+		if issued+request.Weight() > weight || issued+request.Weight() < issued {
+			return ErrWeightExhausted
+		}
+
+		start = time.Now()
+		_, err = tx.Exec("insert into sigreqs_"+request.ElectionID+" (request_id, public_key, did, presentation, denomination, ballot_hash, signature, ballot_signature) values(?,?,?,?,?,?,?,?)", hex.EncodeToString(request.RequestID), hex.EncodeToString(request.PublicKey), request.DID, string(request.Presentation), request.Denomination, hex.EncodeToString(request.BlindBallot), hex.EncodeToString(request.Signature), hex.EncodeToString(request.BallotSignature))
+		observeQuery("insert_sigreq", start)
+		return err
+	})
+}
+
+func (s *MySQLStore) GetSignatureRequests(electionID string) ([]*FulfilledSignatureRequest, error) {
+	start := time.Now()
+	rows, err := s.db.Query("SELECT request_id, public_key, did, presentation, denomination, ballot_hash, signature, ballot_signature FROM sigreqs_" + electionID + " ORDER BY request_id")
+	observeQuery("select_sigreqs", start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fulfilled []*FulfilledSignatureRequest
+	for rows.Next() {
+		var requestID, publicKey, did, presentation, blindBallot, signature, ballotSignature string
+		var denomination uint64
+		err = rows.Scan(&requestID, &publicKey, &did, &presentation, &denomination, &blindBallot, &signature, &ballotSignature)
+		if err != nil {
+			return nil, err
+		}
+		sigReq, err := loadSRFromDB(electionID, requestID, publicKey, did, presentation, denomination, blindBallot, signature, ballotSignature)
+		if err != nil {
+			return nil, err
+		}
+		fulfilled = append(fulfilled, sigReq)
+	}
+	return fulfilled, rows.Err()
+}
+
+// loadSRFromDB rebuilds a FulfilledSignatureRequest from the hex encoded columns it was saved with. See SaveSignatureRequest.
+func loadSRFromDB(electionID, requestID, publicKey, did, presentation string, denomination uint64, blindBallot, signature, ballotSignature string) (*FulfilledSignatureRequest, error) {
+	var (
+		sigReq = SignatureRequest{ElectionID: electionID, DID: did, Denomination: denomination}
+		sig    []byte
+		err    error
+	)
+	if sigReq.RequestID, err = hex.DecodeString(requestID); err != nil {
+		return nil, err
+	}
+	if sigReq.PublicKey, err = hex.DecodeString(publicKey); err != nil {
+		return nil, err
+	}
+	if presentation != "" {
+		sigReq.Presentation = []byte(presentation)
+	}
+	if sigReq.BlindBallot, err = hex.DecodeString(blindBallot); err != nil {
+		return nil, err
+	}
+	if sigReq.Signature, err = hex.DecodeString(signature); err != nil {
+		return nil, err
+	}
+	if sig, err = hex.DecodeString(ballotSignature); err != nil {
+		return nil, err
+	}
+	return NewFulfilledSignatureRequestFromParts(sigReq, Signature(sig)), nil
+}
+
+// isDuplicateEntry checks if an insert failed because it broke a unique index
+func isDuplicateEntry(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	return ok && mysqlErr.Number == mysqlDuplicateEntry
+}
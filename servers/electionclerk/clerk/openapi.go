@@ -0,0 +1,136 @@
+package clerk
+
+import (
+	"net/http"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot/openapi"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/httplog"
+)
+
+// JSON API
+//
+// Elections and signature requests are accepted as JSON when the request's Content-Type is application/json, and
+// served as JSON when its Accept header includes application/json. Otherwise the text format is used, as before.
+// JSON bodies are converted to the text format as soon as they are read, so they are checked, stored and logged
+// exactly as text bodies are. See cryptoballot.ElectionJSON
+//
+// The JSON API is described by the OpenAPI document served at GET /openapi.json.
+
+// apiVersion is the version of the JSON API given in the OpenAPI document
+const apiVersion = "1"
+
+// writeJSON writes a JSON response, or an internal error if v cannot be encoded
+func writeJSON(w http.ResponseWriter, r *http.Request, m *handlerMetric, v interface{}) {
+	if err := httplog.WriteJSON(w, v); err != nil {
+		writeInternalError(w, r, m, errClassInternal, err)
+	}
+}
+
+// Serve the OpenAPI document describing the JSON API at "/openapi.json"
+func (s *Server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	m := newHandlerMetric("openAPIHandler")
+	defer m.observe()
+
+	if r.Method != "GET" {
+		writeError(w, r, m, http.StatusMethodNotAllowed, errClassMethod, "Method not allowed. Only GET is allowed here.")
+		return
+	}
+	writeJSON(w, r, m, openAPIDocument())
+}
+
+// openAPIDocument describes the election clerk's JSON API
+func openAPIDocument() *openapi.Document {
+	doc := openapi.New("Election Clerk", apiVersion,
+		"Creates elections and blind-signs ballots for voters. Every endpoint also accepts and serves the text format, "+
+			"which is the one that is signed. In JSON, binary fields are hex encoded and times are RFC3339.")
+
+	var (
+		election  = doc.AddSchema("Election", ElectionJSON{})
+		sigReq    = doc.AddSchema("SignatureRequest", SignatureRequestJSON{})
+		fulfilled = doc.AddSchema("FulfilledSignatureRequest", FulfilledSignatureRequestJSON{})
+		result    = doc.AddSchema("ElectionResult", ElectionResult{})
+		apiError  = doc.AddSchema("Error", httplog.ErrorResponse{})
+
+		electionID = openapi.PathParam("electionId", "Lowercase alphanumeric election ID")
+		publicKey  = openapi.HeaderParam("X-Public-Key", "Hex encoded DID public key of the election admin", true)
+		signature  = openapi.HeaderParam("X-Signature", `Hex encoded DID signature of the method and path, such as "PUT /election/<election-id>"`, true)
+	)
+	failed := func(description string) openapi.Response {
+		return openapi.Response{Description: description, Content: openapi.JSONContent(apiError)}
+	}
+
+	doc.Add("GET", "/election", &openapi.Operation{
+		Summary:     "List every election",
+		OperationID: "listElections",
+		Responses: map[string]openapi.Response{
+			"200": {Description: "Every election", Content: openapi.JSONContent(openapi.ArrayOf(election))},
+			"304": {Description: "No election has been created since the ETag given in If-None-Match"},
+		},
+	})
+	doc.Add("GET", "/election/{electionId}", &openapi.Operation{
+		Summary:     "Get an election",
+		OperationID: "getElection",
+		Parameters:  []openapi.Parameter{electionID},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The election", Content: openapi.JSONContent(election)},
+			"404": failed("The election does not exist"),
+		},
+	})
+	doc.Add("PUT", "/election/{electionId}", &openapi.Operation{
+		Summary:     "Create an election",
+		OperationID: "putElection",
+		Parameters:  []openapi.Parameter{electionID, publicKey, signature},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSONContent(election)},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The election was created"},
+			"400": failed("The election or its signature is invalid"),
+			"403": failed("The key is not an election admin"),
+			"409": failed("An election with this ID already exists"),
+		},
+	})
+	doc.Add("POST", "/sign", &openapi.Operation{
+		Summary:     "Have a blinded ballot signed",
+		OperationID: "signBallot",
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSONContent(sigReq)},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The signature request, with the clerk's blind signature", Content: openapi.JSONContent(fulfilled)},
+			"400": failed("The signature request or its signature is invalid"),
+			"403": failed("The voter may not vote in this election, or has already been signed for"),
+			"404": failed("The election does not exist"),
+		},
+	})
+	doc.Add("GET", "/sigs/{electionId}", &openapi.Operation{
+		Summary:     "List the fulfilled signature requests of an ended election",
+		OperationID: "listSignatureRequests",
+		Parameters:  []openapi.Parameter{electionID},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "Every fulfilled signature request, ordered by request ID", Content: openapi.JSONContent(openapi.ArrayOf(fulfilled))},
+			"403": failed("The election has not ended"),
+			"404": failed("The election does not exist"),
+		},
+	})
+	doc.Add("GET", "/election/{electionId}/result", &openapi.Operation{
+		Summary:     "Get the published result of an election",
+		OperationID: "getResult",
+		Parameters:  []openapi.Parameter{electionID},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The signed election result", Content: openapi.JSONContent(result)},
+			"404": failed("No result has been published"),
+		},
+	})
+	doc.Add("PUT", "/election/{electionId}/result", &openapi.Operation{
+		Summary:     "Publish the signed result of an ended election",
+		OperationID: "putResult",
+		Parameters:  []openapi.Parameter{electionID, publicKey, signature},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSONContent(result)},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The result was published"},
+			"400": failed("The result or its signature is invalid"),
+			"403": failed("The election has not ended, or the key is not the election's admin"),
+			"404": failed("The election does not exist"),
+			"409": failed("A result has already been published"),
+		},
+	})
+	return doc
+}
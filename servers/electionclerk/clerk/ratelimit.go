@@ -0,0 +1,95 @@
+package clerk
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/ratelimit"
+)
+
+// Abuse protection
+//
+// Every signature request and election costs the clerk a signature verification, and signing a ballot costs an RSA
+// signature, so requests are throttled per client IP address before any of that work is done. They are also
+// throttled per key, but only once the key's signature is verified, since anyone can claim any key. Request
+// bodies are bounded, and the http.Server built by HTTPServer times out slow clients.
+//
+// Like everything else, client IP addresses and keys are never logged or used as metric labels. See the ratelimit
+// package.
+
+const (
+	maxElectionSize = 64 * 1024 // Largest election accepted, in bytes
+
+	// Largest election result accepted, in bytes. Results grow with the square of the number of candidates
+	maxResultSize = 1024 * 1024
+)
+
+// RateLimit allows Rate requests per second on average, in bursts of up to Burst requests. A zero Rate means no limit.
+type RateLimit = ratelimit.Limit
+
+// Timeouts for the http.Server built by HTTPServer. Zero means no timeout. Write should leave room for streaming
+// every fulfilled signature request of a large election.
+type Timeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+}
+
+// HTTPServer builds an http.Server that serves the election clerk's routes at addr, with the configured timeouts
+func (s *Server) HTTPServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: s.conf.Timeouts.ReadHeader,
+		ReadTimeout:       s.conf.Timeouts.Read,
+		WriteTimeout:      s.conf.Timeouts.Write,
+		IdleTimeout:       s.conf.Timeouts.Idle,
+	}
+}
+
+// StartSweeper periodically forgets the rate limit buckets of clients that have not been seen for a while
+func (s *Server) StartSweeper() {
+	ratelimit.StartSweeper(ratelimit.SweepInterval, s.ipLimiter, s.keyLimiter)
+}
+
+// withRateLimit throttles requests by client IP address before passing them on to the handler
+func (s *Server) withRateLimit(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, retryAfter := s.ipLimiter.Allow(ratelimit.ClientIP(r, s.conf.RateLimits.TrustProxy)); !ok {
+			m := newHandlerMetric("rateLimit")
+			defer m.observe()
+			writeRateLimited(w, r, m, retryAfter)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// limitKey throttles requests by the key that signed them. It should only be called once the signature is verified,
+// so that forged requests cannot use up the bucket of someone else's key.
+// It writes a 429 response and returns false if the key has made too many requests.
+func (s *Server) limitKey(w http.ResponseWriter, r *http.Request, m *handlerMetric, key string) bool {
+	ok, retryAfter := s.keyLimiter.Allow(key)
+	if !ok {
+		writeRateLimited(w, r, m, retryAfter)
+	}
+	return ok
+}
+
+// writeRateLimited writes a 429 response, telling the client how many seconds to wait
+func writeRateLimited(w http.ResponseWriter, r *http.Request, m *handlerMetric, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	writeError(w, r, m, http.StatusTooManyRequests, errClassRateLimited, "Too many requests. Try again later")
+}
+
+// writeBodyError writes the response for an error from readBody
+func writeBodyError(w http.ResponseWriter, r *http.Request, m *handlerMetric, err error) {
+	if err == ratelimit.ErrBodyTooLarge {
+		writeError(w, r, m, http.StatusRequestEntityTooLarge, errClassTooLarge, err.Error())
+		return
+	}
+	writeInternalError(w, r, m, errClassInternal, err)
+}
@@ -0,0 +1,122 @@
+package clerk
+
+import (
+	"net/http"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/ratelimit"
+)
+
+// Handle `/election/<election-id>/result`. Once an election has ended its admin may PUT a signed result, which
+// anyone may then GET and check by tallying the election's ballots themselves. See tally.VerifyElectionResult
+func (s *Server) resultHandler(w http.ResponseWriter, r *http.Request, electionID string) {
+	if len(electionID) > MaxElectionIDSize || !ValidElectionID.MatchString(electionID) {
+		writeError(w, r, nil, http.StatusNotFound, errClassNotFound, "Invalid Election ID. 404 Not Found.")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.handleGETResult(w, r, electionID)
+	case "PUT":
+		s.handlePUTResult(w, r, electionID)
+	default:
+		writeError(w, r, nil, http.StatusMethodNotAllowed, errClassMethod, "Method not allowed")
+	}
+}
+
+func (s *Server) handlePUTResult(w http.ResponseWriter, r *http.Request, electionID string) {
+	m := newHandlerMetric("handlePUTResult")
+	defer m.observe()
+
+	err := verifySignatureHeaders(r)
+	if err != nil {
+		m.verificationFailed("request_signature")
+		writeError(w, r, m, http.StatusBadRequest, errClassVerification, err.Error())
+		return
+	}
+	if !s.limitKey(w, r, m, r.Header.Get("X-Public-Key")) {
+		return
+	}
+
+	body, err := ratelimit.ReadBody(w, r, maxResultSize)
+	if err != nil {
+		writeBodyError(w, r, m, err)
+		return
+	}
+
+	result, err := NewElectionResult(body)
+	if err != nil {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, err.Error())
+		return
+	}
+	if result.ElectionID != electionID {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, "Election ID mismatch between body and URL")
+		return
+	}
+	if result.PublicKey != r.Header.Get("X-Public-Key") {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, "Public Key mismatch between headers and body")
+		return
+	}
+
+	rawElection, err := s.store.GetElection(electionID)
+	if err != nil {
+		if err == ErrNotFound {
+			writeError(w, r, m, http.StatusNotFound, errClassNotFound, "Could not find election with ID "+electionID)
+		} else {
+			writeInternalError(w, r, m, errClassDatabase, err)
+		}
+		return
+	}
+	m.setElection(electionID)
+	election, err := NewElection(rawElection)
+	if err != nil {
+		writeInternalError(w, r, m, errClassInternal, err)
+		return
+	}
+	if s.conf.Clock().Before(election.End) {
+		writeError(w, r, m, http.StatusForbidden, errClassForbidden, "Results may only be published once the election has ended")
+		return
+	}
+
+	// Only the admin who created the election may publish its result
+	err = result.VerifyForElection(election)
+	if err == ErrResultWrongSigner {
+		writeError(w, r, m, http.StatusForbidden, errClassForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		m.verificationFailed("result_signature")
+		writeError(w, r, m, http.StatusBadRequest, errClassVerification, "Error verifying result signature. "+err.Error())
+		return
+	}
+
+	// All checks pass. Publish the result
+	err = s.store.SaveResult(result, s.logEntry(LogResultPublished, electionID, result.String()))
+	if err == ErrResultExist {
+		writeError(w, r, m, http.StatusConflict, errClassDuplicate, "A result has already been published for this election")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, m, errClassDatabase, err)
+		return
+	}
+}
+
+func (s *Server) handleGETResult(w http.ResponseWriter, r *http.Request, electionID string) {
+	m := newHandlerMetric("handleGETResult")
+	defer m.observe()
+
+	rawResult, err := s.store.GetResult(electionID)
+	if err != nil {
+		if err == ErrNotFound {
+			writeError(w, r, m, http.StatusNotFound, errClassNotFound, "No result has been published for election with ID "+electionID)
+		} else {
+			writeInternalError(w, r, m, errClassDatabase, err)
+		}
+		return
+	}
+	m.setElection(electionID)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(rawResult)
+}
@@ -0,0 +1,210 @@
+package clerk
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/httplog"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/internal/ratelimit"
+)
+
+// Handle a signature-request coming from a user
+func (s *Server) signHandler(w http.ResponseWriter, r *http.Request) {
+	m := newHandlerMetric("signHandler")
+	defer m.observe()
+
+	if r.Method != "POST" {
+		writeError(w, r, m, http.StatusMethodNotAllowed, errClassMethod, "Method not allowed. Only POST is allowed here.")
+		return
+	}
+
+	body, err := ratelimit.ReadBody(w, r, MaxSignatureRequestSize)
+	if err != nil {
+		writeBodyError(w, r, m, err)
+		return
+	}
+
+	body, err = httplog.TextBody(r, body, &SignatureRequest{})
+	if err != nil {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, err.Error())
+		return
+	}
+	signatureRequest, err := NewSignatureRequest(body)
+	if err != nil {
+		writeError(w, r, m, http.StatusBadRequest, errClassBadRequest, err.Error())
+		return
+	}
+	// Check to make sure the election exists
+	rawElection, err := s.store.GetElection(signatureRequest.ElectionID)
+	if err != nil {
+		if err == ErrNotFound {
+			writeError(w, r, m, http.StatusNotFound, errClassNotFound, "Could not find election with ID "+signatureRequest.ElectionID)
+		} else {
+			writeInternalError(w, r, m, errClassDatabase, err)
+		}
+		return
+	}
+	m.setElection(signatureRequest.ElectionID)
+	election, err := NewElection(rawElection)
+	if err != nil {
+		writeInternalError(w, r, m, errClassInternal, err)
+		return
+	}
+
+	if err = signatureRequest.VerifySignature(); err != nil {
+		m.verificationFailed("voter_signature")
+		writeError(w, r, m, http.StatusBadRequest, errClassVerification, err.Error())
+		return
+	}
+	if !s.limitKey(w, r, m, string(signatureRequest.RequestID)) {
+		return
+	}
+
+	// Check the voter's DID and that they are on the voter roll, if the election has one. See did.go
+	if status, errClass, err := s.checkVoter(signatureRequest); err != nil {
+		if status == http.StatusInternalServerError {
+			writeInternalError(w, r, m, errClass, err)
+		} else {
+			if errClass == errClassVerification {
+				m.verificationFailed("voter_did")
+			}
+			writeError(w, r, m, status, errClass, err.Error())
+		}
+		return
+	}
+
+	// Check the voter's credentials, if the election requires any. See credential.go
+	if status, errClass, err := s.checkCredentials(signatureRequest, election); err != nil {
+		if status == http.StatusInternalServerError {
+			writeInternalError(w, r, m, errClass, err)
+		} else {
+			if errClass == errClassVerification {
+				m.verificationFailed("voter_credential")
+			}
+			writeError(w, r, m, status, errClass, err.Error())
+		}
+		return
+	}
+
+	// Get the voter's weight, and check the denomination requested. See weight.go
+	weight, status, errClass, err := s.checkWeight(signatureRequest, election)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			writeInternalError(w, r, m, errClass, err)
+		} else {
+			if errClass == errClassVerification {
+				m.verificationFailed("voter_weight")
+			}
+			writeError(w, r, m, status, errClass, err.Error())
+		}
+		return
+	}
+
+	// Sign the ballot with the key for its denomination
+	signingKey, err := s.signingKey(signatureRequest.Weight())
+	if err != nil {
+		writeInternalError(w, r, m, errClassInternal, err)
+		return
+	}
+	ballotSig, err := signingKey.BlindSign(signatureRequest.BlindBallot)
+	if err != nil {
+		writeInternalError(w, r, m, errClassInternal, err)
+		return
+	}
+
+	// Create a fulfilled signature request
+	fulfilled := &FulfilledSignatureRequest{
+		SignatureRequest: *signatureRequest,
+		BallotSignature:  ballotSig,
+	}
+
+	// The request is only saved along with its audit log entry, so that a voter can retry if either fails. The
+	// store refuses it if the voter has already been issued signatures for all of their weight
+	err = s.store.SaveSignatureRequest(fulfilled, weight, s.logEntry(LogSignatureIssued, fulfilled.ElectionID, fulfilled.String()))
+	if err == ErrWeightExhausted {
+		if signatureRequest.Denomination == 0 {
+			writeError(w, r, m, http.StatusBadRequest, errClassDuplicate, errAlreadySigned.Error())
+		} else {
+			writeError(w, r, m, http.StatusForbidden, errClassDuplicate, errWeightExceeded.Error())
+		}
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, m, errClassDatabase, err)
+		return
+	}
+	signaturesIssued.WithLabelValues(signatureRequest.ElectionID).Inc()
+
+	if httplog.WantsJSON(r) {
+		writeJSON(w, r, m, fulfilled)
+		return
+	}
+	fmt.Fprint(w, fulfilled.String())
+	return
+}
+
+// Handle a request for all the fulfilled signature requests for an election.
+// These are only published once the election has ended, so that anyone can check that the number of
+// ballots cast matches the number of signatures issued.
+func (s *Server) sigsHandler(w http.ResponseWriter, r *http.Request) {
+	m := newHandlerMetric("sigsHandler")
+	defer m.observe()
+
+	if r.Method != "GET" {
+		writeError(w, r, m, http.StatusMethodNotAllowed, errClassMethod, "Method not allowed. Only GET is allowed here.")
+		return
+	}
+
+	urlparts := strings.Split(r.URL.Path, "/")
+	if len(urlparts) != 3 {
+		writeError(w, r, m, http.StatusNotFound, errClassNotFound, "Invalid URL. 404 Not Found.")
+		return
+	}
+	electionID := urlparts[2]
+	if len(electionID) > MaxElectionIDSize || !ValidElectionID.MatchString(electionID) {
+		writeError(w, r, m, http.StatusNotFound, errClassNotFound, "Invalid Election ID. 404 Not Found.")
+		return
+	}
+
+	rawElection, err := s.store.GetElection(electionID)
+	if err != nil {
+		if err == ErrNotFound {
+			writeError(w, r, m, http.StatusNotFound, errClassNotFound, "Could not find election with ID "+electionID)
+		} else {
+			writeInternalError(w, r, m, errClassDatabase, err)
+		}
+		return
+	}
+	m.setElection(electionID)
+	election, err := NewElection(rawElection)
+	if err != nil {
+		writeInternalError(w, r, m, errClassInternal, err)
+		return
+	}
+	if s.conf.Clock().Before(election.End) {
+		writeError(w, r, m, http.StatusForbidden, errClassForbidden, "Signature requests are published once the election has ended")
+		return
+	}
+
+	fulfilled, err := s.store.GetSignatureRequests(electionID)
+	if err != nil {
+		writeInternalError(w, r, m, errClassDatabase, err)
+		return
+	}
+	if httplog.WantsJSON(r) {
+		if fulfilled == nil {
+			fulfilled = []*FulfilledSignatureRequest{}
+		}
+		writeJSON(w, r, m, fulfilled)
+		return
+	}
+
+	for i, sigReq := range fulfilled {
+		if i != 0 {
+			w.Write([]byte("\n\n\n"))
+		}
+		w.Write([]byte(sigReq.String()))
+	}
+}
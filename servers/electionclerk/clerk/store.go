@@ -0,0 +1,58 @@
+package clerk
+
+import (
+	"errors"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+var (
+	ErrNotFound      = errors.New("clerk: not found")
+	ErrElectionExist = errors.New("clerk: election already exists")
+	ErrResultExist   = errors.New("clerk: election result already published")
+	ErrLogConflict   = errors.New("clerk: audit log entry already exists")
+
+	// ErrWeightExhausted is returned when saving a signature request that would take the voter over their weight
+	ErrWeightExhausted = errors.New("clerk: voter has already been issued signatures for all of their weight")
+)
+
+// LogEntryMaker makes the signed audit log entry for a record as it is saved, given the entry it follows. Prev is nil
+// if the log is empty. Stores call it while holding the end of the log, so that no other entry can be appended
+// between reading prev and appending the new entry, even by another clerk sharing the database.
+type LogEntryMaker func(prev *LogEntry) (*LogEntry, error)
+
+// Store is where the election clerk keeps elections and fulfilled signature requests.
+// See MySQLStore for the store used in production, and MemoryStore for testing.
+type Store interface {
+	// ElectionExists checks if an election has been saved
+	ElectionExists(electionID string) (bool, error)
+
+	// GetElection gets the text of an election. ErrNotFound is returned if there is no such election.
+	GetElection(electionID string) ([]byte, error)
+
+	// GetAllElections gets the text of every election
+	GetAllElections() ([][]byte, error)
+
+	// SaveElection saves a new election, and appends its audit log entry in the same transaction.
+	// ErrElectionExist is returned if the election ID is taken.
+	SaveElection(election *Election, logEntry LogEntryMaker) error
+
+	// GetResult gets the published result of an election. ErrNotFound is returned if no result has been published.
+	GetResult(electionID string) ([]byte, error)
+
+	// SaveResult publishes the result of an election, and appends its audit log entry in the same transaction.
+	// Results cannot be replaced, so ErrResultExist is returned if the election already has one.
+	SaveResult(result *ElectionResult, logEntry LogEntryMaker) error
+
+	// GetLog gets the entries of the audit log in sequence, starting at from. A limit of zero means no limit.
+	GetLog(from uint64, limit int) ([]*LogEntry, error)
+
+	// SaveSignatureRequest saves a fulfilled signature request, and appends its audit log entry in the same transaction.
+	// Weight is the voter's weight, which is 1 in unweighted elections. The weights of the fulfilled requests with the
+	// same request-id, which identifies the voter, are added up in the same transaction, and ErrWeightExhausted is
+	// returned if this request would take them over the voter's weight. Requests without a denomination weigh 1.
+	SaveSignatureRequest(request *FulfilledSignatureRequest, weight uint64, logEntry LogEntryMaker) error
+
+	// GetSignatureRequests gets all the fulfilled signature requests for an election, ordered by request-id
+	GetSignatureRequests(electionID string) ([]*FulfilledSignatureRequest, error)
+}
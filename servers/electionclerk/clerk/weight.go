@@ -0,0 +1,169 @@
+package clerk
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	. "github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+var (
+	errDenominationUnweighted = errors.New("This election is not weighted. Signature requests must not give a denomination")
+	errWeightDIDRequired      = errors.New("This election is weighted. Signature requests must give the voter's DID")
+	errWeightNoVoterRoll      = errors.New("This election is weighted by its voter roll, but has no voter roll")
+	errWeightExceeded         = errors.New("The voter has already been issued signatures for all of their weight")
+	errAlreadySigned          = errors.New("already received fulfilled signature request")
+)
+
+// DenominationKeysFromDir reads the keys for weighted elections from a directory holding one PEM encoded private
+// key per denomination, named <denomination>.pem. For example 10.pem and 100.pem. Denomination 1 is the clerk's
+// main signing key, and is not read from the directory.
+func DenominationKeysFromDir(dir string) (map[uint64]PrivateKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[uint64]PrivateKey, len(paths))
+	for _, path := range paths {
+		denomination, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), ".pem"), 10, 64)
+		if err != nil || denomination < 2 {
+			return nil, fmt.Errorf("Denomination key %s must be named <denomination>.pem, with a denomination of at least 2", path)
+		}
+		rawKey, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keys[denomination], err = NewPrivateKey(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("Denomination key %s: %s", path, err)
+		}
+	}
+	return keys, nil
+}
+
+// signingKey gets the key for blind-signing ballots of the given denomination
+func (s *Server) signingKey(denomination uint64) (PrivateKey, error) {
+	if denomination == 1 {
+		return s.conf.SigningKey, nil
+	}
+	key, ok := s.conf.DenominationKeys[denomination]
+	if !ok {
+		return nil, ErrDenominationUnknown
+	}
+	return key, nil
+}
+
+// publicKeys gets the public keys for every denomination, including the main signing key as denomination 1
+func (s *Server) publicKeys() (DenominationKeys, error) {
+	keys := DenominationKeys{}
+	var err error
+	if keys[1], err = s.conf.SigningKey.PublicKey(); err != nil {
+		return nil, err
+	}
+	for denomination, key := range s.conf.DenominationKeys {
+		if keys[denomination], err = key.PublicKey(); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// Display the public keys used to sign ballots of each denomination when a user asks for "/publickeys".
+// See DenominationKeys for the format.
+func (s *Server) publicKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, r, nil, http.StatusMethodNotAllowed, errClassMethod, "Method not allowed. Only GET is allowed here.")
+		return
+	}
+
+	keys, err := s.publicKeys()
+	if err != nil {
+		writeInternalError(w, r, nil, errClassInternal, err)
+		return
+	}
+	fmt.Fprint(w, keys)
+}
+
+// checkWeight checks that the voter may have a ballot of the requested denomination signed, and gets the voter's
+// weight. In unweighted elections each voter gets a single signature of denomination 1, so their weight is 1. In
+// weighted elections a voter may get any number of signatures, as long as their denominations add up to no more
+// than the voter's weight. The voter's weight comes from the voter roll or from a claim in one of their credentials.
+// See WeightSource. The store checks the weight already issued as it saves the request, so that concurrent requests
+// from the same voter cannot together be signed for more than their weight. See Store.SaveSignatureRequest.
+// The voter and their credentials must have been checked already.
+// On failure the status code and error class to respond with are returned.
+func (s *Server) checkWeight(sigReq *SignatureRequest, election *Election) (uint64, int, string, error) {
+	source, err := election.WeightSource()
+	if err != nil {
+		return 0, http.StatusInternalServerError, errClassInternal, err
+	}
+
+	if source == nil {
+		if sigReq.Denomination != 0 {
+			return 0, http.StatusBadRequest, errClassBadRequest, errDenominationUnweighted
+		}
+		return 1, 0, "", nil
+	}
+
+	if _, err = s.signingKey(sigReq.Weight()); err != nil {
+		return 0, http.StatusBadRequest, errClassBadRequest, err
+	}
+	if sigReq.DID == "" {
+		return 0, http.StatusForbidden, errClassForbidden, errWeightDIDRequired
+	}
+	status, errClass, weight, err := s.voterWeight(sigReq, election, source)
+	if err != nil {
+		return 0, status, errClass, err
+	}
+	if sigReq.Weight() > weight {
+		return 0, http.StatusForbidden, errClassDuplicate, errWeightExceeded
+	}
+	return weight, 0, "", nil
+}
+
+// voterWeight gets the voter's weight from the election's weight source
+func (s *Server) voterWeight(sigReq *SignatureRequest, election *Election, source *WeightSource) (int, string, uint64, error) {
+	if source.FromVoterRoll() {
+		var roll VoterRoll
+		if s.conf.VoterRoll != nil {
+			var err error
+			if roll, err = s.conf.VoterRoll(sigReq.ElectionID); err != nil {
+				return http.StatusInternalServerError, errClassInternal, 0, err
+			}
+		}
+		if roll == nil {
+			return http.StatusForbidden, errClassForbidden, 0, errWeightNoVoterRoll
+		}
+		if !roll.Contains(sigReq.DID) {
+			return http.StatusForbidden, errClassForbidden, 0, errVoterNotOnRoll
+		}
+		return 0, "", roll.Weight(sigReq.DID), nil
+	}
+
+	presentation, err := sigReq.GetPresentation()
+	if err != nil {
+		return http.StatusBadRequest, errClassBadRequest, 0, err
+	}
+	if presentation == nil {
+		return http.StatusForbidden, errClassForbidden, 0, ErrPresentationRequired
+	}
+
+	revocations := s.newRevocationCheck()
+	cred, err := election.CredentialRequirements().Find(presentation, source.CredentialType, s.conf.Resolver, revocations.orNil(), s.conf.Clock())
+	if revocations != nil && revocations.err != nil {
+		return http.StatusInternalServerError, errClassInternal, 0, revocations.err
+	}
+	if err != nil {
+		return http.StatusForbidden, errClassVerification, 0, err
+	}
+	weight, err := cred.ClaimWeight(source.Claim)
+	if err != nil {
+		return http.StatusForbidden, errClassVerification, 0, err
+	}
+	return 0, "", weight, nil
+}
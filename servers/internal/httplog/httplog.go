@@ -0,0 +1,173 @@
+// Package httplog holds the request logging, error responses and JSON content negotiation shared by the ballotbox
+// and the election clerk.
+//
+// Each server keeps its own Logger and its own logging policy, which says what it must never log. The middleware
+// here only ever logs the name of the route, the method, the status and the duration, under a request ID that is
+// always generated by the server. A client-supplied X-Request-ID is ignored, since a client that sent the same ID
+// to both servers would link the two log entries together.
+package httplog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot/openapi"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInvalidFormat is returned by Configure for a format other than text or json
+var ErrInvalidFormat = errors.New("Invalid log format. Must be either text or json")
+
+type contextKey int
+
+const requestIDKey contextKey = 0
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Configure sets a logger's level and format
+func Configure(logger *logrus.Logger, level string, format string) error {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	logger.SetLevel(lvl)
+
+	switch format {
+	case "json":
+		logger.Formatter = &logrus.JSONFormatter{}
+	case "text", "":
+		logger.Formatter = &logrus.TextFormatter{}
+	default:
+		return ErrInvalidFormat
+	}
+	return nil
+}
+
+// WithLogging assigns a request ID to the request and logs the request once it has been handled.
+// Only the name of the route is logged, never the full path.
+func WithLogging(logger *logrus.Logger, route string, handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := newRequestID()
+		w.Header().Set("X-Request-ID", requestID)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey, requestID))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		handler(rec, r)
+
+		RequestLogger(logger, r).WithFields(logrus.Fields{
+			"route":    route,
+			"method":   r.Method,
+			"status":   rec.status,
+			"duration": time.Since(start).String(),
+		}).Info("request handled")
+	})
+}
+
+// RequestLogger gets a log entry tagged with the request's ID
+func RequestLogger(logger *logrus.Logger, r *http.Request) *logrus.Entry {
+	if requestID, ok := r.Context().Value(requestIDKey).(string); ok {
+		return logger.WithField("request_id", requestID)
+	}
+	return logrus.NewEntry(logger)
+}
+
+// WriteError writes an error response with a consistent body.
+// The message is sent to the client verbatim, so it should never contain database errors.
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code string, message string) {
+	requestID, _ := r.Context().Value(requestIDKey).(string)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Code:      code,
+		Message:   message,
+		RequestID: requestID,
+	})
+}
+
+// WriteInternalError logs the error and writes a generic error response that does not leak the error details
+func WriteInternalError(logger *logrus.Logger, w http.ResponseWriter, r *http.Request, code string, err error) {
+	RequestLogger(logger, r).WithError(err).WithField("code", code).Error("internal error")
+	WriteError(w, r, http.StatusInternalServerError, code, "Internal server error")
+}
+
+// WriteJSON writes a JSON response. If v cannot be encoded nothing is written, and the error is returned.
+func WriteJSON(w http.ResponseWriter, v interface{}) error {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(encoded)
+	return nil
+}
+
+// IsJSON checks whether the request body is JSON
+func IsJSON(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == openapi.MediaTypeJSON
+}
+
+// WantsJSON checks whether the client accepts a JSON response
+func WantsJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, _ := mime.ParseMediaType(strings.TrimSpace(accept)); mediaType == openapi.MediaTypeJSON {
+			return true
+		}
+	}
+	return false
+}
+
+// TextBody converts a JSON request body to the text format by decoding it into v. Text bodies are returned as they
+// are.
+func TextBody(r *http.Request, body []byte, v fmt.Stringer) ([]byte, error) {
+	if !IsJSON(r) {
+		return body, nil
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return nil, err
+	}
+	return []byte(v.String()), nil
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder records the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets handlers that stream their response flush it through the recorder
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
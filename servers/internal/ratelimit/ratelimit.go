@@ -0,0 +1,178 @@
+// Package ratelimit holds the request throttling shared by the ballotbox and the election clerk: token buckets keyed
+// by client IP address or key, finding the client's IP address, reading bounded request bodies, and the [ratelimit]
+// config options.
+//
+// Client IP addresses and keys are never logged or used as metric labels. They are only kept in memory while their
+// bucket is not full, and at most MaxBuckets of them at once. Full buckets are forgotten by StartSweeper.
+package ratelimit
+
+import (
+	"container/list"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dlintw/goconf"
+)
+
+// MaxBuckets is the most buckets a Limiter keeps. When it is full, the least recently used bucket is forgotten to
+// make room for a new one, so that a flood from many addresses cannot grow the limiter without bound.
+const MaxBuckets = 10000
+
+// SweepInterval is how often the servers sweep their limiters
+const SweepInterval = time.Minute
+
+// ErrBodyTooLarge is returned by ReadBody for a body larger than the limit
+var ErrBodyTooLarge = errors.New("Request body is too large")
+
+// Limit allows Rate requests per second on average, in bursts of up to Burst requests. A zero Rate means no limit.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Limiter is a set of token buckets, keyed by client IP address or key
+type Limiter struct {
+	limit   Limit
+	clock   func() time.Time
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	recent  *list.List // Buckets, most recently used first
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// New creates a Limiter. The clock gives the current time.
+func New(limit Limit, clock func() time.Time) *Limiter {
+	return &Limiter{limit: limit, clock: clock, buckets: make(map[string]*list.Element), recent: list.New()}
+}
+
+// Allow takes a token from the key's bucket. If the bucket is empty it returns false, along with how long until
+// a token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.limit.Rate == 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock()
+	element, ok := l.buckets[key]
+	if !ok {
+		if l.recent.Len() >= MaxBuckets {
+			l.remove(l.recent.Back())
+		}
+		element = l.recent.PushFront(&bucket{key: key, tokens: float64(l.limit.Burst), last: now})
+		l.buckets[key] = element
+	}
+	l.recent.MoveToFront(element)
+	b := element.Value.(*bucket)
+	b.tokens = math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.limit.Rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// StartSweeper periodically sweeps the given limiters
+func StartSweeper(interval time.Duration, limiters ...*Limiter) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		for range ticker.C {
+			for _, l := range limiters {
+				l.Sweep()
+			}
+		}
+	}()
+}
+
+// Sweep forgets the buckets that have certainly refilled, since a new bucket would be the same. An empty bucket
+// refills in Burst/Rate seconds. Buckets are kept least recently used last, so Sweep stops at the first bucket used
+// more recently than that, and only ever looks at the buckets it forgets and one more.
+func (l *Limiter) Sweep() {
+	if l.limit.Rate == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock()
+	refill := time.Duration(float64(l.limit.Burst) / l.limit.Rate * float64(time.Second))
+	for element := l.recent.Back(); element != nil; element = l.recent.Back() {
+		if now.Sub(element.Value.(*bucket).last) < refill {
+			break
+		}
+		l.remove(element)
+	}
+}
+
+func (l *Limiter) remove(element *list.Element) {
+	delete(l.buckets, element.Value.(*bucket).key)
+	l.recent.Remove(element)
+}
+
+// Len gets the number of buckets the limiter holds
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.recent.Len()
+}
+
+// ClientIP gets the address of the client. Behind a reverse proxy, the address the proxy added to X-Forwarded-For
+// is used instead, if the proxy is trusted.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			hops := strings.Split(forwarded, ",")
+			return strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ReadBody reads a request body of up to limit bytes. ErrBodyTooLarge is returned for a larger body.
+func ReadBody(w http.ResponseWriter, r *http.Request, limit int) ([]byte, error) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, int64(limit)))
+	if err != nil && len(body) == limit {
+		return nil, ErrBodyTooLarge
+	}
+	return body, err
+}
+
+// ParseConfig parses the <prefix>-rate and <prefix>-burst options of the [ratelimit] section, falling back to the
+// given limit
+func ParseConfig(c *goconf.ConfigFile, prefix string, limit Limit) (Limit, error) {
+	var err error
+	if c.HasOption("ratelimit", prefix+"-rate") {
+		limit.Rate, err = c.GetFloat64("ratelimit", prefix+"-rate")
+		if err != nil {
+			return limit, err
+		}
+	}
+	if c.HasOption("ratelimit", prefix+"-burst") {
+		limit.Burst, err = c.GetInt("ratelimit", prefix+"-burst")
+		if err != nil {
+			return limit, err
+		}
+	}
+	if limit.Rate < 0 || (limit.Rate > 0 && limit.Burst < 1) {
+		return limit, errors.New("ratelimit " + prefix + "-rate must not be negative, and " +
+			prefix + "-burst must be at least 1")
+	}
+	return limit, nil
+}
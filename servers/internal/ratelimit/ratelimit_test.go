@@ -0,0 +1,178 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlintw/goconf"
+)
+
+// testClock is a clock that only moves when told to
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func TestAllow(t *testing.T) {
+	clock := &testClock{now: time.Unix(1500000000, 0)}
+	limiter := New(Limit{Rate: 1, Burst: 3}, clock.Now)
+
+	// A new client gets a full burst, then has to wait
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow("a"); !ok {
+			t.Fatalf("Expected request %d of the burst to be allowed", i)
+		}
+	}
+	ok, retryAfter := limiter.Allow("a")
+	if ok {
+		t.Fatal("Expected the request after the burst to be refused")
+	}
+	if retryAfter != time.Second {
+		t.Errorf("Expected to retry after 1s, got %v", retryAfter)
+	}
+
+	// Other clients have their own bucket
+	if ok, _ := limiter.Allow("b"); !ok {
+		t.Error("Expected another client to be allowed")
+	}
+
+	// The bucket refills at the rate
+	clock.now = clock.now.Add(time.Second)
+	if ok, _ := limiter.Allow("a"); !ok {
+		t.Error("Expected a request to be allowed once a token refilled")
+	}
+	if ok, _ := limiter.Allow("a"); ok {
+		t.Error("Expected only one token to have refilled")
+	}
+}
+
+func TestAllowNoLimit(t *testing.T) {
+	limiter := New(Limit{}, time.Now)
+	for i := 0; i < 100; i++ {
+		if ok, _ := limiter.Allow("a"); !ok {
+			t.Fatal("Expected every request to be allowed without a limit")
+		}
+	}
+	if limiter.Len() != 0 {
+		t.Errorf("Expected no buckets without a limit, got %d", limiter.Len())
+	}
+}
+
+func TestSweep(t *testing.T) {
+	clock := &testClock{now: time.Unix(1500000000, 0)}
+	limiter := New(Limit{Rate: 1, Burst: 5}, clock.Now)
+
+	limiter.Allow("old")
+	clock.now = clock.now.Add(3 * time.Second)
+	limiter.Allow("new")
+
+	// Allow never sweeps by itself
+	clock.now = clock.now.Add(2 * time.Second)
+	limiter.Allow("new")
+	if limiter.Len() != 2 {
+		t.Fatalf("Expected 2 buckets before sweeping, got %d", limiter.Len())
+	}
+
+	// Only buckets that have had Burst/Rate seconds to refill are forgotten
+	limiter.Sweep()
+	if limiter.Len() != 1 {
+		t.Errorf("Expected 1 bucket after the first sweep, got %d", limiter.Len())
+	}
+	clock.now = clock.now.Add(time.Hour)
+	limiter.Sweep()
+	if limiter.Len() != 0 {
+		t.Errorf("Expected no buckets after the second sweep, got %d", limiter.Len())
+	}
+}
+
+func TestMaxBuckets(t *testing.T) {
+	clock := &testClock{now: time.Unix(1500000000, 0)}
+	limiter := New(Limit{Rate: 1, Burst: 1}, clock.Now)
+
+	limiter.Allow("first")
+	for i := 1; i < MaxBuckets; i++ {
+		limiter.Allow(strconv.Itoa(i))
+	}
+	if limiter.Len() != MaxBuckets {
+		t.Fatalf("Expected %d buckets, got %d", MaxBuckets, limiter.Len())
+	}
+
+	// Using the first bucket again keeps it, so the least recently used bucket is forgotten instead
+	if ok, _ := limiter.Allow("first"); ok {
+		t.Fatal("Expected the first client's bucket to be empty")
+	}
+	limiter.Allow("overflow")
+	if limiter.Len() != MaxBuckets {
+		t.Errorf("Expected the limiter to stay at %d buckets, got %d", MaxBuckets, limiter.Len())
+	}
+	if ok, _ := limiter.Allow("first"); ok {
+		t.Error("Expected the first client's bucket to be kept")
+	}
+	if ok, _ := limiter.Allow("1"); !ok {
+		t.Error("Expected the least recently used bucket to be forgotten")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.1")
+
+	if ip := ClientIP(r, false); ip != "192.0.2.1" {
+		t.Errorf("Expected the remote address, got %s", ip)
+	}
+	if ip := ClientIP(r, true); ip != "203.0.113.1" {
+		t.Errorf("Expected the address added by the proxy, got %s", ip)
+	}
+	r.Header.Del("X-Forwarded-For")
+	if ip := ClientIP(r, true); ip != "192.0.2.1" {
+		t.Errorf("Expected the remote address without X-Forwarded-For, got %s", ip)
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader("12345"))
+	body, err := ReadBody(httptest.NewRecorder(), r, 5)
+	if err != nil || string(body) != "12345" {
+		t.Errorf("Expected the whole body, got %q %v", body, err)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader("123456"))
+	if _, err = ReadBody(httptest.NewRecorder(), r, 5); err != ErrBodyTooLarge {
+		t.Errorf("Expected ErrBodyTooLarge, got %v", err)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	defaults := Limit{Rate: 10, Burst: 20}
+
+	c := goconf.NewConfigFile()
+	limit, err := ParseConfig(c, "ip", defaults)
+	if err != nil || limit != defaults {
+		t.Errorf("Expected the defaults, got %v %v", limit, err)
+	}
+
+	c.AddOption("ratelimit", "ip-rate", "0.5")
+	c.AddOption("ratelimit", "ip-burst", "2")
+	limit, err = ParseConfig(c, "ip", defaults)
+	if err != nil || limit != (Limit{Rate: 0.5, Burst: 2}) {
+		t.Errorf("Expected the configured limit, got %v %v", limit, err)
+	}
+	if limit, err = ParseConfig(c, "key", defaults); err != nil || limit != defaults {
+		t.Errorf("Expected the defaults for another prefix, got %v %v", limit, err)
+	}
+
+	for _, bad := range [][2]string{{"ip-rate", "-1"}, {"ip-burst", "0"}, {"ip-rate", "fast"}} {
+		c := goconf.NewConfigFile()
+		c.AddOption("ratelimit", bad[0], bad[1])
+		if _, err = ParseConfig(c, "ip", defaults); err == nil {
+			t.Errorf("Expected an error for %s = %s", bad[0], bad[1])
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package vectors
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+// ConformanceVectors are the vectors in conformance.json. Binary values are hex encoded, and the text forms are as
+// they are sent to the servers.
+type ConformanceVectors struct {
+	Elections     []ElectionVector     `json:"elections"`
+	DIDSignatures []DIDSignatureVector `json:"didSignatures"`
+	Malformed     []MalformedVector    `json:"malformed"`
+}
+
+// ElectionVector is an election's text form and the fields it reads as
+type ElectionVector struct {
+	Text       string   `json:"text"`
+	ElectionID string   `json:"electionId"`
+	Start      int64    `json:"start"` // Unix time
+	End        int64    `json:"end"`   // Unix time
+	Tags       []string `json:"tags"`  // Each as key=value
+	PublicKey  string   `json:"publicKey"`
+	AdminDID   string   `json:"adminDid"`
+	Signed     bool     `json:"signed"`
+	Valid      bool     `json:"valid"` // The admin's signature verifies with PublicKey
+}
+
+// DIDSignatureVector is a signature of Message, and whether it verifies with PublicKey. Valid signatures are made
+// deterministically (RFC 6979) with PrivateKey, so an implementation signing the same way gets the same signature.
+type DIDSignatureVector struct {
+	PrivateKey string `json:"privateKey"`
+	PublicKey  string `json:"publicKey"`
+	Message    string `json:"message"`
+	Signature  string `json:"signature"`
+	Valid      bool   `json:"valid"`
+}
+
+// MalformedVector is an input that must be refused, and the errors it is refused with. Errors are named as in the
+// cryptoballot package, most general first, and the error returned must be all of them. See Errors.
+type MalformedVector struct {
+	Kind        string   `json:"kind"` // One of the Kind constants
+	Description string   `json:"description"`
+	Input       string   `json:"input"`
+	Errors      []string `json:"errors"`
+}
+
+// Kinds of malformed input, by the function that reads them
+const (
+	KindElection                  = "election"                  // cryptoballot.NewElection
+	KindBallot                    = "ballot"                    // cryptoballot.NewBallot
+	KindSignatureRequest          = "signatureRequest"          // cryptoballot.NewSignatureRequest
+	KindFulfilledSignatureRequest = "fulfilledSignatureRequest" // cryptoballot.NewFulfilledSignatureRequest
+)
+
+// Errors are the cryptoballot errors malformed vectors name
+var Errors = map[string]error{
+	"ErrEelectionInvalid":                 cryptoballot.ErrEelectionInvalid,
+	"ErrElectionIDTooBig":                 cryptoballot.ErrElectionIDTooBig,
+	"ErrElectionIDInvalid":                cryptoballot.ErrElectionIDInvalid,
+	"ErrElectionStartInvalid":             cryptoballot.ErrElectionStartInvalid,
+	"ErrElectionEndInvalid":               cryptoballot.ErrElectionEndInvalid,
+	"ErrElectionInvalidTagSet":            cryptoballot.ErrElectionInvalidTagSet,
+	"ErrElectionInvalidKey":               cryptoballot.ErrElectionInvalidKey,
+	"ErrElectionInvalidSig":               cryptoballot.ErrElectionInvalidSig,
+	"ErrBallotTooBig":                     cryptoballot.ErrBallotTooBig,
+	"ErrBallotInvalid":                    cryptoballot.ErrBallotInvalid,
+	"ErrBallotIDTooBig":                   cryptoballot.ErrBallotIDTooBig,
+	"ErrBallotIDInvalid":                  cryptoballot.ErrBallotIDInvalid,
+	"ErrBallotInvalidVote":                cryptoballot.ErrBallotInvalidVote,
+	"ErrBallotInvalidTagSet":              cryptoballot.ErrBallotInvalidTagSet,
+	"ErrBallotInvalidSig":                 cryptoballot.ErrBallotInvalidSig,
+	"ErrVoteTooManyOptions":               cryptoballot.ErrVoteTooManyOptions,
+	"ErrVoteOptionEmpty":                  cryptoballot.ErrVoteOptionEmpty,
+	"ErrVoteMarkerNotAlone":               cryptoballot.ErrVoteMarkerNotAlone,
+	"ErrVoteMarkerUnknown":                cryptoballot.ErrVoteMarkerUnknown,
+	"ErrTagMalformed":                     cryptoballot.ErrTagMalformed,
+	"ErrTagKeyNotFound":                   cryptoballot.ErrTagKeyNotFound,
+	"ErrTagValNotFound":                   cryptoballot.ErrTagValNotFound,
+	"ErrSignatureBase64":                  cryptoballot.ErrSignatureBase64,
+	"ErrSignatureTooShort":                cryptoballot.ErrSignatureTooShort,
+	"ErrSignatureRequestTooBig":           cryptoballot.ErrSignatureRequestTooBig,
+	"ErrSignatureRequestInvalid":          cryptoballot.ErrSignatureRequestInvalid,
+	"ErrSignatureRequestPublicKey":        cryptoballot.ErrSignatureRequestPublicKey,
+	"ErrSignatureRequestID":               cryptoballot.ErrSignatureRequestID,
+	"ErrSignatureRequestDID":              cryptoballot.ErrSignatureRequestDID,
+	"ErrSignatureRequestVP":               cryptoballot.ErrSignatureRequestVP,
+	"ErrSignatureRequestBallotHash":       cryptoballot.ErrSignatureRequestBallotHash,
+	"ErrSignatureRequestSigInvalid":       cryptoballot.ErrSignatureRequestSigInvalid,
+	"ErrBlindBallotBase64":                cryptoballot.ErrBlindBallotBase64,
+	"ErrDenominationInvalid":              cryptoballot.ErrDenominationInvalid,
+	"ErrFulfilledSignatureRequestInvalid": cryptoballot.ErrFulfilledSignatureRequestInvalid,
+}
+
+var (
+	// Fixed so that the election signatures stay the same each time the vectors are made
+	testAdminKey = "9f3b6c2e1a4d7f0b5e8c3a6d9f2b5e8c1a4d7f0b3e6c9a2d5f8b1e4c7a0d3f6b"
+	testAdminDID = "did:elastos:iAdminVectors"
+
+	// The key and messages from RFC 6979 section A.2.5, for checking the deterministic signatures themselves
+	rfc6979Key      = "c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721"
+	rfc6979Messages = []string{"sample", "test"}
+
+	testStart = time.Date(2019, time.March, 1, 9, 0, 0, 0, time.FixedZone("", 8*60*60))
+	testEnd   = time.Date(2019, time.March, 8, 21, 30, 0, 0, time.FixedZone("", 8*60*60))
+)
+
+// GenerateConformance makes the conformance vectors. They depend only on the cryptoballot package, so unlike
+// GenerateClient no clerk key is needed. Signed ballots and Signature Requests are in the client vectors.
+func GenerateConformance() (*ConformanceVectors, error) {
+	vectors := &ConformanceVectors{}
+
+	admin, err := cryptoballot.NewDIDPrivateKeyFromHex(testAdminKey)
+	if err != nil {
+		return nil, err
+	}
+	adminPub, err := admin.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// Elections, signed and unsigned, with and without tags, and one with another election's signature
+	tagged := cryptoballot.Election{
+		ElectionID: "vectorelection",
+		Start:      testStart,
+		End:        testEnd,
+		TagSet: cryptoballot.TagSet{
+			{Key: []byte(cryptoballot.ElectionDIDTag), Value: []byte(testAdminDID)},
+			{Key: []byte(cryptoballot.ElectionCandidatesTag), Value: []byte("Santa Clause,Tooth Fairy,Krampus")},
+		},
+		PublicKey: adminPub.Bytes(),
+	}
+	untagged := cryptoballot.Election{ElectionID: "vector_election_2", Start: testStart, End: testEnd, PublicKey: adminPub.Bytes()}
+	unsigned := tagged
+	tagged.Signature = signDeterministic(admin, tagged.StringWithoutSignature())
+	untagged.Signature = signDeterministic(admin, untagged.StringWithoutSignature())
+	forged := untagged
+	forged.ElectionID = "vector_election_3"
+	for _, election := range []cryptoballot.Election{tagged, untagged, unsigned, forged} {
+		vectors.Elections = append(vectors.Elections, electionVector(&election))
+	}
+
+	// DID signatures with each key, then one of them spoiled in each way a signature can be
+	for _, hexKey := range append([]string{rfc6979Key}, testDIDKeys...) {
+		key, err := cryptoballot.NewDIDPrivateKeyFromHex(hexKey)
+		if err != nil {
+			return nil, err
+		}
+		pub, err := key.GetPublicKeyFromPrivateKey()
+		if err != nil {
+			return nil, err
+		}
+		messages := []string{"", "POST /sign", tagged.StringWithoutSignature()}
+		if hexKey == rfc6979Key {
+			messages = rfc6979Messages
+		}
+		for _, message := range messages {
+			vectors.DIDSignatures = append(vectors.DIDSignatures, DIDSignatureVector{
+				PrivateKey: key.Hex(),
+				PublicKey:  pub.Hex(),
+				Message:    message,
+				Signature:  hex.EncodeToString(signDeterministic(key, message)),
+				Valid:      true,
+			})
+		}
+	}
+	valid := vectors.DIDSignatures[len(vectors.DIDSignatures)-2]
+	otherKey := vectors.DIDSignatures[0]
+	signature, _ := hex.DecodeString(valid.Signature)
+	flipped := append([]byte{}, signature...)
+	flipped[len(flipped)-1] ^= 0x01
+	for _, invalid := range []DIDSignatureVector{
+		{PublicKey: valid.PublicKey, Message: valid.Message + " ", Signature: valid.Signature},
+		{PublicKey: valid.PublicKey, Message: valid.Message, Signature: hex.EncodeToString(flipped)},
+		{PublicKey: valid.PublicKey, Message: valid.Message, Signature: hex.EncodeToString(signature[:len(signature)-1])},
+		{PublicKey: otherKey.PublicKey, Message: valid.Message, Signature: valid.Signature},
+	} {
+		vectors.DIDSignatures = append(vectors.DIDSignatures, invalid)
+	}
+
+	vectors.Malformed = append(vectors.Malformed, malformedElections(tagged.String(), hex.EncodeToString(tagged.Signature))...)
+	vectors.Malformed = append(vectors.Malformed, malformedBallots()...)
+	vectors.Malformed = append(vectors.Malformed, malformedSignatureRequests(adminPub)...)
+	return vectors, nil
+}
+
+// LoadConformance reads conformance vectors from a file
+func LoadConformance(filename string) (*ConformanceVectors, error) {
+	var vectors ConformanceVectors
+	if err := load(filename, &vectors); err != nil {
+		return nil, err
+	}
+	return &vectors, nil
+}
+
+// Save writes conformance vectors to a file as indented JSON
+func (vectors *ConformanceVectors) Save(filename string) error {
+	return save(filename, vectors)
+}
+
+// Marshal gets conformance vectors as they are saved
+func (vectors *ConformanceVectors) Marshal() ([]byte, error) {
+	return marshal(vectors)
+}
+
+// electionVector gets the fields an election reads as
+func electionVector(election *cryptoballot.Election) ElectionVector {
+	vector := ElectionVector{
+		Text:       election.String(),
+		ElectionID: election.ElectionID,
+		Start:      election.Start.Unix(),
+		End:        election.End.Unix(),
+		PublicKey:  hex.EncodeToString(election.PublicKey),
+		Signed:     election.HasSignature(),
+		Valid:      election.HasSignature() && election.VerifySignature() == nil,
+	}
+	if election.HasTagSet() {
+		vector.AdminDID = election.AdminDID()
+	}
+	for _, tag := range election.TagSet {
+		vector.Tags = append(vector.Tags, tag.String())
+	}
+	return vector
+}
+
+func malformedElections(good string, signature string) []MalformedVector {
+	parts := strings.Split(good, "\n\n")
+	with := func(i int, part string) string {
+		changed := append([]string{}, parts...)
+		changed[i] = part
+		return strings.Join(changed, "\n\n")
+	}
+	return []MalformedVector{
+		{KindElection, "Too few parts", strings.Join(parts[:3], "\n\n"), []string{"ErrEelectionInvalid"}},
+		{KindElection, "Too many parts", good + "\n\n" + signature, []string{"ErrEelectionInvalid"}},
+		{KindElection, "Election ID too long", with(0, strings.Repeat("a", cryptoballot.MaxElectionIDSize+1)), []string{"ErrElectionIDTooBig"}},
+		{KindElection, "Uppercase election ID", with(0, "VectorElection"), []string{"ErrElectionIDInvalid"}},
+		{KindElection, "Start is not RFC 1123", with(1, "2019-03-01T09:00:00+08:00"), []string{"ErrElectionStartInvalid"}},
+		{KindElection, "End has no numeric zone", with(2, "Fri, 08 Mar 2019 21:30:00 CST"), []string{"ErrElectionEndInvalid"}},
+		{KindElection, "Tag without a value", with(3, parts[3]+"\nquorum="), []string{"ErrElectionInvalidTagSet", "ErrTagValNotFound"}},
+		{KindElection, "Public key is not hex", with(4, "admin"), []string{"ErrElectionInvalidKey"}},
+		{KindElection, "Signature is not hex", with(5, "signature"), []string{"ErrElectionInvalidSig"}},
+	}
+}
+
+func malformedBallots() []MalformedVector {
+	signature := base64.StdEncoding.EncodeToString(make([]byte, 256))
+	tooManyOptions := strings.TrimSuffix(strings.Repeat("Krampus\n", cryptoballot.MaxVoteOptions+1), "\n")
+	return []MalformedVector{
+		{KindBallot, "Too big", "vectorelection\n\nballot1\n\n" + strings.Repeat("K", cryptoballot.MaxBallotSize), []string{"ErrBallotTooBig"}},
+		{KindBallot, "Too few parts", "vectorelection\n\nballot1", []string{"ErrBallotInvalid"}},
+		{KindBallot, "Too many parts", "vectorelection\n\nballot1\n\nKrampus\n\ncolor=blue\n\n" + signature + "\n\n" + signature, []string{"ErrBallotInvalid"}},
+		{KindBallot, "Election ID too long", strings.Repeat("a", cryptoballot.MaxElectionIDSize+1) + "\n\nballot1\n\nKrampus", []string{"ErrElectionIDTooBig"}},
+		{KindBallot, "Election ID with a space", "vector election\n\nballot1\n\nKrampus", []string{"ErrElectionIDInvalid"}},
+		{KindBallot, "Ballot ID too long", "vectorelection\n\n" + strings.Repeat("b", cryptoballot.MaxBallotIDSize+1) + "\n\nKrampus", []string{"ErrBallotIDTooBig"}},
+		{KindBallot, "Ballot ID with a space", "vectorelection\n\nballot 1\n\nKrampus", []string{"ErrBallotIDInvalid"}},
+		{KindBallot, "Empty vote option", "vectorelection\n\nballot1\n\nKrampus\n", []string{"ErrBallotInvalidVote", "ErrVoteOptionEmpty"}},
+		{KindBallot, "Too many vote options", "vectorelection\n\nballot1\n\n" + tooManyOptions, []string{"ErrBallotInvalidVote", "ErrVoteTooManyOptions"}},
+		{KindBallot, "Marker with a candidate", "vectorelection\n\nballot1\n\n@abstain\nKrampus", []string{"ErrBallotInvalidVote", "ErrVoteMarkerNotAlone"}},
+		{KindBallot, "Unknown marker", "vectorelection\n\nballot1\n\n@maybe", []string{"ErrBallotInvalidVote", "ErrVoteMarkerUnknown"}},
+		{KindBallot, "Tag without =", "vectorelection\n\nballot1\n\nKrampus\n\nnotatag", []string{"ErrBallotInvalidTagSet", "ErrTagMalformed"}},
+		{KindBallot, "Tag without a key", "vectorelection\n\nballot1\n\nKrampus\n\ncolor=blue\n=blue", []string{"ErrBallotInvalidTagSet", "ErrTagKeyNotFound"}},
+		{KindBallot, "Signature is not base64", "vectorelection\n\nballot1\n\nKrampus\n\ncolor=blue\n\n" + strings.Replace(signature, "A", "!", 1), []string{"ErrBallotInvalidSig", "ErrSignatureBase64"}},
+		{KindBallot, "Signature too short", "vectorelection\n\nballot1\n\nKrampus\n\ncolor=blue\n\n" + base64.StdEncoding.EncodeToString(make([]byte, 64)), []string{"ErrBallotInvalidSig", "ErrSignatureTooShort"}},
+	}
+}
+
+// malformedSignatureRequests makes malformed Signature Requests and Fulfilled Signature Requests from a well formed
+// Signature Request. The signatures are the right shape but sign nothing, since they are never verified.
+func malformedSignatureRequests(pub cryptoballot.DIDPublicKey) []MalformedVector {
+	blindBallot := base64.StdEncoding.EncodeToString(make([]byte, 256))
+	signature := hex.EncodeToString(make([]byte, 64))
+	ballotSignature := base64.StdEncoding.EncodeToString(make([]byte, 256))
+	presentation := `{"holder":"` + testDIDs[0] + `","type":"VerifiablePresentation"}`
+
+	byKey := "vectorelection\n\n" + hex.EncodeToString(pub.RequestID()) + "\n\n" + pub.Hex()
+	byDID := "vectorelection\n\n" + hex.EncodeToString(cryptoballot.DIDRequestID(testDIDs[0])) + "\n\n" + pub.Hex() + "\n\n" + testDIDs[0]
+	otherID := hex.EncodeToString(cryptoballot.DIDRequestID(testDIDs[1]))
+	return []MalformedVector{
+		{KindSignatureRequest, "Too big", byKey + "\n\n" + strings.Repeat("A", cryptoballot.MaxSignatureRequestSize), []string{"ErrSignatureRequestTooBig"}},
+		{KindSignatureRequest, "Too few parts", byKey, []string{"ErrSignatureRequestInvalid"}},
+		{KindSignatureRequest, "Too many parts", byKey + "\n\n" + blindBallot + "\n\n" + signature + "\n\n" + signature, []string{"ErrSignatureRequestInvalid"}},
+		{KindSignatureRequest, "DID with a 0", "vectorelection\n\n" + otherID + "\n\n" + pub.Hex() + "\n\ndid:elastos:i0VoterVectors\n\n" + blindBallot, []string{"ErrSignatureRequestDID"}},
+		{KindSignatureRequest, "Presentation without a DID", byKey + "\n\n" + presentation + "\n\n" + blindBallot, []string{"ErrSignatureRequestVP"}},
+		{KindSignatureRequest, "Presentation held by another DID", strings.Replace(byDID, testDIDs[0], testDIDs[1], -1) + "\n\n" + presentation + "\n\n" + blindBallot, []string{"ErrSignatureRequestVP"}},
+		{KindSignatureRequest, "Zero denomination", byKey + "\n\ndenomination:0\n\n" + blindBallot, []string{"ErrDenominationInvalid"}},
+		{KindSignatureRequest, "Denomination is not a number", byKey + "\n\ndenomination:ten\n\n" + blindBallot, []string{"ErrDenominationInvalid"}},
+		{KindSignatureRequest, "Public key is not hex", "vectorelection\n\n" + hex.EncodeToString(pub.RequestID()) + "\n\nvoter\n\n" + blindBallot, []string{"ErrSignatureRequestPublicKey"}},
+		{KindSignatureRequest, "Request ID of another DID", "vectorelection\n\n" + otherID + "\n\n" + pub.Hex() + "\n\n" + testDIDs[0] + "\n\n" + blindBallot, []string{"ErrSignatureRequestID"}},
+		{KindSignatureRequest, "Request ID of the DID without the DID", "vectorelection\n\n" + otherID + "\n\n" + pub.Hex() + "\n\n" + blindBallot, []string{"ErrSignatureRequestID"}},
+		{KindSignatureRequest, "Request ID is not hex", "vectorelection\n\n" + strings.Repeat("x", 64) + "\n\n" + pub.Hex() + "\n\n" + blindBallot, []string{"ErrSignatureRequestID"}},
+		{KindSignatureRequest, "Blind ballot is not base64", byKey + "\n\n" + strings.Replace(blindBallot, "A", "!", 1), []string{"ErrSignatureRequestBallotHash", "ErrBlindBallotBase64"}},
+		{KindSignatureRequest, "Signature is not hex", byKey + "\n\n" + blindBallot + "\n\nsignature", []string{"ErrSignatureRequestSigInvalid"}},
+		{KindFulfilledSignatureRequest, "Too few parts", byKey + "\n\n" + blindBallot + "\n\n" + signature, []string{"ErrFulfilledSignatureRequestInvalid"}},
+		{KindFulfilledSignatureRequest, "Signature Request is not signed", byDID + "\n\n" + blindBallot + "\n\n" + ballotSignature, []string{"ErrFulfilledSignatureRequestInvalid"}},
+		{KindFulfilledSignatureRequest, "Malformed Signature Request", strings.Replace(byDID, testDIDs[0], testDIDs[1], -1) + "\n\n" + blindBallot + "\n\n" + signature + "\n\n" + ballotSignature, []string{"ErrSignatureRequestID"}},
+		{KindFulfilledSignatureRequest, "Ballot signature is not base64", byKey + "\n\n" + blindBallot + "\n\n" + signature + "\n\n" + strings.Replace(ballotSignature, "A", "!", 1), []string{"ErrSignatureBase64"}},
+		{KindFulfilledSignatureRequest, "Ballot signature too short", byKey + "\n\n" + blindBallot + "\n\n" + signature + "\n\n" + base64.StdEncoding.EncodeToString(make([]byte, 64)), []string{"ErrSignatureTooShort"}},
+	}
+}
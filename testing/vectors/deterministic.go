@@ -0,0 +1,114 @@
+package vectors
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+// Signing and blinding are randomised in the cryptoballot package. The vectors are made with the deterministic
+// equivalents below instead, so that making them again gives the same files unless the protocol has changed.
+
+// signDeterministic signs the message with a DID key as DIDPrivateKey.SignString does, but with the nonce derived
+// from the key and message as in RFC 6979. This is what the Java library does, so both give the same signature.
+func signDeterministic(key cryptoballot.DIDPrivateKey, message string) []byte {
+	curve := elliptic.P256()
+	n := curve.Params().N
+	d := new(big.Int).SetBytes(key)
+	digest := sha256.Sum256([]byte(message))
+	e := new(big.Int).SetBytes(digest[:])
+
+	// RFC 6979 section 3.2. With SHA256 and P-256 the hash and the group order are both 256 bits long
+	x := intToOctets(d)
+	h := intToOctets(new(big.Int).Mod(e, n))
+	v := make([]byte, sha256.Size)
+	for i := range v {
+		v[i] = 0x01
+	}
+	k := make([]byte, sha256.Size)
+	k = hmacSHA256(k, v, []byte{0x00}, x, h)
+	v = hmacSHA256(k, v)
+	k = hmacSHA256(k, v, []byte{0x01}, x, h)
+	v = hmacSHA256(k, v)
+
+	for {
+		v = hmacSHA256(k, v)
+		nonce := new(big.Int).SetBytes(v)
+		if nonce.Sign() > 0 && nonce.Cmp(n) < 0 {
+			rx, _ := curve.ScalarBaseMult(v)
+			r := new(big.Int).Mod(rx, n)
+			s := new(big.Int).Mul(r, d)
+			s.Add(s, e)
+			s.Mul(s, new(big.Int).ModInverse(nonce, n))
+			s.Mod(s, n)
+			if r.Sign() != 0 && s.Sign() != 0 {
+				signature := make([]byte, crypto.SignatureLength)
+				rBytes, sBytes := r.Bytes(), s.Bytes()
+				copy(signature[crypto.SignerLength-len(rBytes):], rBytes)
+				copy(signature[crypto.SignatureLength-len(sBytes):], sBytes)
+				return signature
+			}
+		}
+		k = hmacSHA256(k, v, []byte{0x00})
+		v = hmacSHA256(k, v)
+	}
+}
+
+// blindDeterministic blinds a full-domain-hashed ballot as rsablind.Blind does, with the blinding factor derived
+// from the seed. The blinded ballot is not padded, and the clerk refuses short ones, so factors are tried in turn
+// until the blinded ballot is the full size of the key.
+func blindDeterministic(key *rsa.PublicKey, hashed []byte, seed string) (blinded []byte, unblinder []byte) {
+	m := new(big.Int).SetBytes(hashed)
+	e := big.NewInt(int64(key.E))
+	size := (key.N.BitLen() + 7) / 8
+	for counter := uint32(0); ; counter++ {
+		r := new(big.Int).SetBytes(expand(seed, counter, size))
+		r.Mod(r, key.N)
+		inverse := new(big.Int).ModInverse(r, key.N)
+		if r.Cmp(big.NewInt(1)) <= 0 || inverse == nil {
+			continue
+		}
+		c := new(big.Int).Exp(r, e, key.N)
+		c.Mul(c, m)
+		c.Mod(c, key.N)
+		if len(c.Bytes()) == size {
+			return c.Bytes(), inverse.Bytes()
+		}
+	}
+}
+
+// expand stretches the seed to size bytes with SHA256 in counter mode
+func expand(seed string, counter uint32, size int) []byte {
+	var out []byte
+	for block := uint32(0); len(out) < size; block++ {
+		var suffix [8]byte
+		binary.BigEndian.PutUint32(suffix[:4], counter)
+		binary.BigEndian.PutUint32(suffix[4:], block)
+		sum := sha256.Sum256(append([]byte(seed), suffix[:]...))
+		out = append(out, sum[:]...)
+	}
+	return out[:size]
+}
+
+// hmacSHA256 gets the HMAC-SHA256 of the data concatenated
+func hmacSHA256(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// intToOctets gets the integer as 32 big-endian bytes
+func intToOctets(i *big.Int) []byte {
+	b := i.Bytes()
+	octets := make([]byte, 32)
+	copy(octets[len(octets)-len(b):], b)
+	return octets
+}
@@ -0,0 +1,45 @@
+// genvectors makes the test vectors in testing/vectors. It is run by go generate from that directory:
+//
+//	go generate ./testing/vectors
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/testing/vectors"
+)
+
+func main() {
+	clerkKeyFile := flag.String("clerk-key", "../data/ballot-clerk-private.pem", "The election clerk's private key")
+	dir := flag.String("dir", ".", "Directory to write client.json and conformance.json to")
+	flag.Parse()
+
+	PEMData, err := ioutil.ReadFile(*clerkKeyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	clerkKey, err := cryptoballot.NewPrivateKey(PEMData)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client, err := vectors.GenerateClient(clerkKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = client.Save(filepath.Join(*dir, "client.json")); err != nil {
+		log.Fatal(err)
+	}
+
+	conformance, err := vectors.GenerateConformance()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = conformance.Save(filepath.Join(*dir, "conformance.json")); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,309 @@
+// Package vectors holds test vectors shared by the Go and Java implementations, so that the two cannot drift apart.
+//
+// client.json has the voter's side of the protocol: DID keys, full-domain-hashes, blinding, ballots and Signature
+// Requests. conformance.json has elections, DID signatures, and malformed inputs with the error each is refused
+// with. Both are made from the cryptoballot package with the election clerk key in testing/data. Signing and
+// blinding are deterministic here, so making them again gives the same files unless the protocol has changed.
+// After changing a text format or any of the cryptography, run:
+//
+//	go generate ./testing/vectors
+//
+// The test checks that the files are up to date, and checks them against the cryptoballot package. The Java tests
+// in java_clients check them against the Java library.
+package vectors
+
+//go:generate go run ./genvectors
+
+import (
+	"crypto"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/cryptoballot/fdh"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+)
+
+// ClientVectors are the vectors in client.json. Binary values are hex encoded, except ClerkKey and the text forms,
+// which are as they are sent to the servers.
+type ClientVectors struct {
+	ClerkKey                   string                            `json:"clerkKey"` // The election clerk's base64 encoded public key
+	DIDKeys                    []DIDKeyVector                    `json:"didKeys"`
+	DIDRequestIDs              []DIDRequestIDVector              `json:"didRequestIds"`
+	FDH                        []FDHVector                       `json:"fdh"`
+	Blinding                   []BlindingVector                  `json:"blinding"`
+	Ballots                    []BallotVector                    `json:"ballots"`
+	SignatureRequests          []SignatureRequestVector          `json:"signatureRequests"`
+	FulfilledSignatureRequests []FulfilledSignatureRequestVector `json:"fulfilledSignatureRequests"`
+}
+
+// DIDKeyVector is a DID private key, the compressed public key and request ID derived from it, and its signature of
+// Message
+type DIDKeyVector struct {
+	PrivateKey string `json:"privateKey"`
+	PublicKey  string `json:"publicKey"`
+	RequestID  string `json:"requestId"`
+	Message    string `json:"message"`
+	Signature  string `json:"signature"`
+}
+
+// DIDRequestIDVector is the request ID of a voter who gives their DID
+type DIDRequestIDVector struct {
+	DID       string `json:"did"`
+	RequestID string `json:"requestId"`
+}
+
+// FDHVector is a SHA256 full-domain-hash of Message, BitLength bits long
+type FDHVector struct {
+	Message   string `json:"message"`
+	BitLength int    `json:"bitLength"`
+	Hash      string `json:"hash"`
+}
+
+// BlindingVector is a ballot blinded with the clerk key, the clerk's blind signature, and the unblinded signature
+type BlindingVector struct {
+	Ballot         string `json:"ballot"` // Unsigned ballot
+	Hash           string `json:"hash"`   // Full-domain-hash of the ballot, half the size of the clerk key
+	Blinded        string `json:"blinded"`
+	Unblinder      string `json:"unblinder"`
+	BlindSignature string `json:"blindSignature"`
+	Signature      string `json:"signature"`
+	SignedBallot   string `json:"signedBallot"`
+}
+
+// BallotVector is a ballot's text form and the fields it reads as
+type BallotVector struct {
+	Text         string   `json:"text"`
+	ElectionID   string   `json:"electionId"`
+	BallotID     string   `json:"ballotId"`
+	Vote         []string `json:"vote"`
+	Tags         []string `json:"tags"` // Each as key=value
+	Denomination uint64   `json:"denomination"`
+	Signed       bool     `json:"signed"` // Signed with the clerk key
+}
+
+// SignatureRequestVector is a signed Signature Request's text form and the fields it reads as
+type SignatureRequestVector struct {
+	Text         string `json:"text"`
+	ElectionID   string `json:"electionId"`
+	RequestID    string `json:"requestId"`
+	PublicKey    string `json:"publicKey"`
+	DID          string `json:"did"`
+	Presentation string `json:"presentation"`
+	Denomination uint64 `json:"denomination"`
+	BlindBallot  string `json:"blindBallot"`
+}
+
+// FulfilledSignatureRequestVector is a Fulfilled Signature Request signed with the clerk key
+type FulfilledSignatureRequestVector struct {
+	Text            string `json:"text"`
+	BallotSignature string `json:"ballotSignature"`
+}
+
+// Load reads client vectors from a file
+func Load(filename string) (*ClientVectors, error) {
+	var vectors ClientVectors
+	if err := load(filename, &vectors); err != nil {
+		return nil, err
+	}
+	return &vectors, nil
+}
+
+// Save writes client vectors to a file as indented JSON
+func (vectors *ClientVectors) Save(filename string) error {
+	return save(filename, vectors)
+}
+
+// Marshal gets client vectors as they are saved
+func (vectors *ClientVectors) Marshal() ([]byte, error) {
+	return marshal(vectors)
+}
+
+func load(filename string, vectors interface{}) error {
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, vectors)
+}
+
+func save(filename string, vectors interface{}) error {
+	raw, err := marshal(vectors)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, raw, 0644)
+}
+
+func marshal(vectors interface{}) ([]byte, error) {
+	raw, err := json.MarshalIndent(vectors, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(raw, '\n'), nil
+}
+
+var (
+	testDIDs = []string{"did:elastos:iVoterAVectors", "did:elastos:iVoterBVectors"}
+
+	// Fixed so that the public keys and request IDs stay the same each time the vectors are made
+	testDIDKeys = []string{
+		"c87509a1c067bbde78beb793e6fa76530b6382a4c0241e5e4a9ec0a0f44dc0d3",
+		"2b5f1d8e4c7a69b03d1e8f2a6c4b7d9e0f3a5c8b1d4e7f2a9c6b3d0e5f8a1c4b",
+	}
+
+	testBallots = []*cryptoballot.Ballot{
+		{ElectionID: "vectorelection", BallotID: "ballot1", Vote: cryptoballot.Vote{"Santa Clause", "Tooth Fairy", "Krampus"}},
+		{ElectionID: "vectorelection", BallotID: "ballot2", Vote: cryptoballot.Vote{"Krampus"},
+			TagSet: cryptoballot.TagSet{{Key: []byte("color"), Value: []byte("blue")}}},
+		{ElectionID: "vectorelection", BallotID: "ballot3", Vote: cryptoballot.Vote{cryptoballot.VoteAbstain}},
+		{ElectionID: "vectorelection", BallotID: "ballot4", Vote: cryptoballot.Vote{}},
+	}
+)
+
+// GenerateClient makes a new set of vectors, signing with the given election clerk key
+func GenerateClient(clerkKey cryptoballot.PrivateKey) (*ClientVectors, error) {
+	clerkPub, err := clerkKey.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	keylen, err := clerkPub.KeyLength()
+	if err != nil {
+		return nil, err
+	}
+	vectors := &ClientVectors{ClerkKey: clerkPub.String()}
+
+	var voters []cryptoballot.DIDPrivateKey
+	for _, hexKey := range testDIDKeys {
+		key, err := cryptoballot.NewDIDPrivateKeyFromHex(hexKey)
+		if err != nil {
+			return nil, err
+		}
+		pub, err := key.GetPublicKeyFromPrivateKey()
+		if err != nil {
+			return nil, err
+		}
+		signature := signDeterministic(key, "POST /sign")
+		voters = append(voters, key)
+		vectors.DIDKeys = append(vectors.DIDKeys, DIDKeyVector{
+			PrivateKey: key.Hex(),
+			PublicKey:  pub.Hex(),
+			RequestID:  hex.EncodeToString(pub.RequestID()),
+			Message:    "POST /sign",
+			Signature:  hex.EncodeToString(signature),
+		})
+	}
+	for _, did := range testDIDs {
+		vectors.DIDRequestIDs = append(vectors.DIDRequestIDs, DIDRequestIDVector{DID: did, RequestID: hex.EncodeToString(cryptoballot.DIDRequestID(did))})
+	}
+
+	for _, message := range []string{"", "POST /sign", testBallots[0].String()} {
+		for _, bitLength := range []int{256, 1024, keylen / 2} {
+			vectors.FDH = append(vectors.FDH, FDHVector{Message: message, BitLength: bitLength, Hash: hex.EncodeToString(fdh.Sum(crypto.SHA256, bitLength, []byte(message)))})
+		}
+	}
+
+	for i, template := range testBallots {
+		ballot := *template
+		if i == 1 {
+			ballot.SetDenomination(10)
+		}
+		blinded, unblinder, blindSignature, err := blindSign(clerkKey, clerkPub, &ballot)
+		if err != nil {
+			return nil, err
+		}
+		signed := ballot
+		if err = signed.Unblind(clerkPub, blindSignature, unblinder); err != nil {
+			return nil, err
+		}
+		vectors.Blinding = append(vectors.Blinding, BlindingVector{
+			Ballot:         ballot.String(),
+			Hash:           hex.EncodeToString(fdh.Sum(crypto.SHA256, keylen/2, []byte(ballot.String()))),
+			Blinded:        hex.EncodeToString(blinded),
+			Unblinder:      hex.EncodeToString(unblinder),
+			BlindSignature: hex.EncodeToString(blindSignature),
+			Signature:      hex.EncodeToString(signed.Signature),
+			SignedBallot:   signed.String(),
+		})
+		vectors.Ballots = append(vectors.Ballots, ballotVector(&ballot), ballotVector(&signed))
+
+		// Each voter asks for the ballot to be signed, the second giving their DID
+		voter := voters[i%len(voters)]
+		pub, err := voter.GetPublicKeyFromPrivateKey()
+		if err != nil {
+			return nil, err
+		}
+		sigReq := cryptoballot.SignatureRequest{
+			ElectionID:  ballot.ElectionID,
+			RequestID:   pub.RequestID(),
+			PublicKey:   pub.Bytes(),
+			BlindBallot: blinded,
+		}
+		if i%len(voters) == 1 {
+			sigReq.DID = testDIDs[i/len(voters)%len(testDIDs)]
+			sigReq.RequestID = cryptoballot.DIDRequestID(sigReq.DID)
+			sigReq.Presentation = []byte(`{"holder":"` + sigReq.DID + `","type":"VerifiablePresentation"}`)
+		}
+		sigReq.Denomination, err = ballot.Denomination()
+		if err != nil {
+			return nil, err
+		}
+		if sigReq.Denomination == 1 {
+			sigReq.Denomination = 0
+		}
+		sigReq.Signature = signDeterministic(voter, sigReq.StringWithoutSignature())
+		vectors.SignatureRequests = append(vectors.SignatureRequests, SignatureRequestVector{
+			Text:         sigReq.String(),
+			ElectionID:   sigReq.ElectionID,
+			RequestID:    hex.EncodeToString(sigReq.RequestID),
+			PublicKey:    hex.EncodeToString(sigReq.PublicKey),
+			DID:          sigReq.DID,
+			Presentation: string(sigReq.Presentation),
+			Denomination: sigReq.Denomination,
+			BlindBallot:  hex.EncodeToString(sigReq.BlindBallot),
+		})
+		fulfilled := cryptoballot.NewFulfilledSignatureRequestFromParts(sigReq, blindSignature)
+		vectors.FulfilledSignatureRequests = append(vectors.FulfilledSignatureRequests, FulfilledSignatureRequestVector{
+			Text:            fulfilled.String(),
+			BallotSignature: hex.EncodeToString(blindSignature),
+		})
+	}
+	return vectors, nil
+}
+
+// blindSign blinds the ballot and signs it with the clerk key, as the voter and the clerk do. The blinding factor is
+// derived from the ballot ID.
+func blindSign(clerkKey cryptoballot.PrivateKey, clerkPub cryptoballot.PublicKey, ballot *cryptoballot.Ballot) (cryptoballot.BlindBallot, []byte, cryptoballot.Signature, error) {
+	keylen, err := clerkPub.KeyLength()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pub, err := clerkPub.GetCryptoKey()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	hashed := fdh.Sum(crypto.SHA256, keylen/2, []byte(ballot.StringWithoutSignature()))
+	blinded, unblinder := blindDeterministic(pub, hashed, ballot.BallotID)
+	blindSignature, err := clerkKey.BlindSign(blinded)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return blinded, unblinder, blindSignature, nil
+}
+
+// ballotVector gets the fields a ballot reads as
+func ballotVector(ballot *cryptoballot.Ballot) BallotVector {
+	denomination, _ := ballot.Denomination()
+	vector := BallotVector{
+		Text:         ballot.String(),
+		ElectionID:   ballot.ElectionID,
+		BallotID:     ballot.BallotID,
+		Vote:         []string(ballot.Vote),
+		Denomination: denomination,
+		Signed:       ballot.HasSignature(),
+	}
+	for _, tag := range ballot.TagSet {
+		vector.Tags = append(vector.Tags, tag.String())
+	}
+	return vector
+}
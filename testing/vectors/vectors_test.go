@@ -0,0 +1,268 @@
+package vectors
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/cryptoballot/fdh"
+	"github.com/cryptoballot/rsablind"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/phayes/errors"
+)
+
+// The vectors are made deterministically, so making them again must give the files as they are
+func TestVectorsUpToDate(t *testing.T) {
+	PEMData, err := ioutil.ReadFile("../data/ballot-clerk-private.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clerkKey, err := cryptoballot.NewPrivateKey(PEMData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := GenerateClient(clerkKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conformance, err := GenerateConformance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for filename, vectors := range map[string]interface {
+		Marshal() ([]byte, error)
+	}{"client.json": client, "conformance.json": conformance} {
+		made, err := vectors.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(made, saved) {
+			t.Errorf("%s is out of date. Run go generate ./testing/vectors", filename)
+		}
+	}
+}
+
+// Check the deterministic signatures against RFC 6979 section A.2.5
+func TestSignDeterministic(t *testing.T) {
+	key, err := cryptoballot.NewDIDPrivateKeyFromHex(rfc6979Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "efd48b2aacb6a8fd1140dd9cd45e81d69d2c877b56aaf991c34d0ea84eaf3716" + "f7cb1c942d657c41d436c7a1b6e29f65f3e900dbb9aff4064dc4ab2f843acda8"
+	if signature := hex.EncodeToString(signDeterministic(key, "sample")); signature != expected {
+		t.Errorf("Expected signature %s, got %s", expected, signature)
+	}
+}
+
+func TestClientVectors(t *testing.T) {
+	vectors, err := Load("client.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clerkPub, err := cryptoballot.NewPublicKey([]byte(vectors.ClerkKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keylen, err := clerkPub.KeyLength()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, vector := range vectors.DIDKeys {
+		key, err := cryptoballot.NewDIDPrivateKeyFromHex(vector.PrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := key.GetPublicKeyFromPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pub.Hex() != vector.PublicKey || hex.EncodeToString(pub.RequestID()) != vector.RequestID {
+			t.Errorf("DID key %s: expected public key %s and request ID %s, got %s and %x", vector.PrivateKey, vector.PublicKey, vector.RequestID, pub.Hex(), pub.RequestID())
+		}
+		if err = pub.VerifySignature(mustHex(t, vector.Signature), []byte(vector.Message)); err != nil {
+			t.Errorf("DID key %s: %v", vector.PrivateKey, err)
+		}
+		if signature := hex.EncodeToString(signDeterministic(key, vector.Message)); signature != vector.Signature {
+			t.Errorf("DID key %s: expected signature %s, got %s", vector.PrivateKey, vector.Signature, signature)
+		}
+	}
+
+	for _, vector := range vectors.DIDRequestIDs {
+		if requestID := hex.EncodeToString(cryptoballot.DIDRequestID(vector.DID)); requestID != vector.RequestID {
+			t.Errorf("%s: expected request ID %s, got %s", vector.DID, vector.RequestID, requestID)
+		}
+	}
+
+	for _, vector := range vectors.FDH {
+		if hash := hex.EncodeToString(fdh.Sum(crypto.SHA256, vector.BitLength, []byte(vector.Message))); hash != vector.Hash {
+			t.Errorf("FDH of %q to %d bits: expected %s, got %s", vector.Message, vector.BitLength, vector.Hash, hash)
+		}
+	}
+
+	pub, err := clerkPub.GetCryptoKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, vector := range vectors.Blinding {
+		if hash := hex.EncodeToString(fdh.Sum(crypto.SHA256, keylen/2, []byte(vector.Ballot))); hash != vector.Hash {
+			t.Errorf("Blinding %d: expected hash %s, got %s", i, vector.Hash, hash)
+		}
+		if err = rsablind.VerifyBlindSignature(pub, mustHex(t, vector.Blinded), mustHex(t, vector.BlindSignature)); err != nil {
+			t.Errorf("Blinding %d: blind signature: %v", i, err)
+		}
+		if unblinded := hex.EncodeToString(rsablind.Unblind(pub, mustHex(t, vector.BlindSignature), mustHex(t, vector.Unblinder))); unblinded != vector.Signature {
+			t.Errorf("Blinding %d: expected unblinded signature %s, got %s", i, vector.Signature, unblinded)
+		}
+		signed, err := cryptoballot.NewBallot([]byte(vector.SignedBallot))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signed.StringWithoutSignature() != vector.Ballot || hex.EncodeToString(signed.Signature) != vector.Signature {
+			t.Errorf("Blinding %d: signed ballot does not match", i)
+		}
+		if err = signed.VerifyBlindSignature(clerkPub); err != nil {
+			t.Errorf("Blinding %d: %v", i, err)
+		}
+	}
+
+	for _, vector := range vectors.Ballots {
+		ballot, err := cryptoballot.NewBallot([]byte(vector.Text))
+		if err != nil {
+			t.Errorf("%q: %v", vector.Text, err)
+			continue
+		}
+		if got := ballotVector(ballot); !reflect.DeepEqual(got, vector) {
+			t.Errorf("Ballot read differently:\n%+v\n%+v", vector, got)
+		}
+		if vector.Signed {
+			if err = ballot.VerifyBlindSignature(clerkPub); err != nil {
+				t.Errorf("%s: %v", vector.BallotID, err)
+			}
+		}
+	}
+
+	for _, vector := range vectors.SignatureRequests {
+		sigReq, err := cryptoballot.NewSignatureRequest([]byte(vector.Text))
+		if err != nil {
+			t.Errorf("%q: %v", vector.Text, err)
+			continue
+		}
+		got := SignatureRequestVector{
+			Text:         sigReq.String(),
+			ElectionID:   sigReq.ElectionID,
+			RequestID:    hex.EncodeToString(sigReq.RequestID),
+			PublicKey:    hex.EncodeToString(sigReq.PublicKey),
+			DID:          sigReq.DID,
+			Presentation: string(sigReq.Presentation),
+			Denomination: sigReq.Denomination,
+			BlindBallot:  hex.EncodeToString(sigReq.BlindBallot),
+		}
+		if got != vector {
+			t.Errorf("Signature Request read differently:\n%+v\n%+v", vector, got)
+		}
+		if err = sigReq.VerifySignature(); err != nil {
+			t.Errorf("Signature Request %s: %v", vector.RequestID, err)
+		}
+	}
+
+	for i, vector := range vectors.FulfilledSignatureRequests {
+		fulfilled, err := cryptoballot.NewFulfilledSignatureRequest([]byte(vector.Text))
+		if err != nil {
+			t.Errorf("Fulfilled Signature Request %d: %v", i, err)
+			continue
+		}
+		if fulfilled.String() != vector.Text || hex.EncodeToString(fulfilled.BallotSignature) != vector.BallotSignature {
+			t.Errorf("Fulfilled Signature Request %d read differently", i)
+		}
+		if err = fulfilled.VerifyBallotSignature(clerkPub); err != nil {
+			t.Errorf("Fulfilled Signature Request %d: %v", i, err)
+		}
+	}
+}
+
+func TestConformanceVectors(t *testing.T) {
+	vectors, err := LoadConformance("conformance.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, vector := range vectors.Elections {
+		election, err := cryptoballot.NewElection([]byte(vector.Text))
+		if err != nil {
+			t.Errorf("%q: %v", vector.Text, err)
+			continue
+		}
+		if got := electionVector(election); !reflect.DeepEqual(got, vector) {
+			t.Errorf("Election read differently:\n%+v\n%+v", vector, got)
+		}
+	}
+
+	for _, vector := range vectors.DIDSignatures {
+		pub, err := cryptoballot.NewDIDPublicKey(mustHex(t, vector.PublicKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = pub.VerifySignature(mustHex(t, vector.Signature), []byte(vector.Message))
+		if vector.Valid && err != nil {
+			t.Errorf("Signature %s of %q: %v", vector.Signature, vector.Message, err)
+		}
+		if !vector.Valid && err == nil {
+			t.Errorf("Signature %s of %q verifies, but should not", vector.Signature, vector.Message)
+		}
+		if vector.PrivateKey != "" {
+			key, err := cryptoballot.NewDIDPrivateKeyFromHex(vector.PrivateKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signature := hex.EncodeToString(signDeterministic(key, vector.Message)); signature != vector.Signature {
+				t.Errorf("Signature of %q: expected %s, got %s", vector.Message, vector.Signature, signature)
+			}
+		}
+	}
+
+	for _, vector := range vectors.Malformed {
+		var err error
+		switch vector.Kind {
+		case KindElection:
+			_, err = cryptoballot.NewElection([]byte(vector.Input))
+		case KindBallot:
+			_, err = cryptoballot.NewBallot([]byte(vector.Input))
+		case KindSignatureRequest:
+			_, err = cryptoballot.NewSignatureRequest([]byte(vector.Input))
+		case KindFulfilledSignatureRequest:
+			_, err = cryptoballot.NewFulfilledSignatureRequest([]byte(vector.Input))
+		default:
+			t.Errorf("%s: unknown kind %s", vector.Description, vector.Kind)
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s %s: read without error", vector.Kind, vector.Description)
+			continue
+		}
+		for _, name := range vector.Errors {
+			expected, ok := Errors[name]
+			if !ok {
+				t.Errorf("%s %s: unknown error %s", vector.Kind, vector.Description, name)
+			} else if !errors.Is(err, expected) {
+				t.Errorf("%s %s: expected %s, got %v", vector.Kind, vector.Description, name, err)
+			}
+		}
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(strconv.Quote(s) + ": " + err.Error())
+	}
+	return b
+}
@@ -0,0 +1,473 @@
+// Package webtest runs whole elections against in-process election clerk and ballotbox servers.
+//
+// Both servers are served with net/http/httptest using in-memory stores, and are driven through the same
+// clients used by the command line tools, so a full election runs in a single `go test` without a database.
+package webtest
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/go_clients/util"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/ballotbox/box"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/electionclerk/clerk"
+)
+
+// Clock is a controllable clock shared by both servers, so that elections can be ended without sleeping
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// Now gets the current time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// AdminDID is the DID of the harness admin. It is allowed to create elections, as long as they name it in the admin-did tag.
+const AdminDID = "did:elastos:iHarnessAdmin"
+
+// Harness runs an election clerk and a ballotbox.
+// The ballotbox only learns about elections when it starts, so create elections before calling StartBallotBox,
+// or give the ballotbox an election TTL and advance the clock past it.
+type Harness struct {
+	T           testing.TB
+	Clock       *Clock
+	Admin       cryptoballot.DIDPrivateKey // Admin allowed to create elections. It is also the first key of AdminDID
+	DIDs        *cryptoballot.FileDIDResolver
+	voterRolls  string // Directory of voter rolls
+	revoked     string // File listing revoked credential ids
+	Clerk       *httptest.Server
+	ClerkClient *util.BallotclerkClient
+	ClerkKey    cryptoballot.PublicKey
+	signingKey  cryptoballot.PrivateKey       // Private key of ClerkKey. See SignBallot
+	ClerkKeys   cryptoballot.DenominationKeys // Clerk keys for weighted elections. Denominations are 1, 10 and 100
+	Box         *httptest.Server              // Nil until StartBallotBox is called
+	BoxClient   *util.BallotBoxClient
+	BoxServer   *box.Server
+	mirrors     []*httptest.Server // Mirrors started with StartMirror
+}
+
+// Mirror is an additional ballotbox started with StartMirror
+type Mirror struct {
+	Server *box.Server
+	HTTP   *httptest.Server
+	Client *util.BallotBoxClient
+}
+
+// NewHarness starts an election clerk. Call Close once done.
+func NewHarness(t testing.TB) *Harness {
+	dir, err := ioutil.TempDir("", "webtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Harness{
+		T:          t,
+		Clock:      &Clock{now: time.Now().Truncate(time.Second)},
+		Admin:      NewDIDKey(t),
+		DIDs:       cryptoballot.NewFileDIDResolver(filepath.Join(dir, "dids")),
+		voterRolls: filepath.Join(dir, "voter-rolls"),
+		revoked:    filepath.Join(dir, "revoked-credentials"),
+	}
+	for _, sub := range []string{h.DIDs.Dir, h.voterRolls} {
+		if err = os.Mkdir(sub, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.SetDIDKeys(AdminDID, h.Admin)
+
+	adminPub, err := h.Admin.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	PEMData, err := ioutil.ReadFile("../data/ballot-clerk-private.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingKey, err := cryptoballot.NewPrivateKey(PEMData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.signingKey = signingKey
+	h.ClerkKey, err = signingKey.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.ClerkKeys = cryptoballot.DenominationKeys{1: h.ClerkKey}
+	denominationKeys := testDenominationKeys(t)
+	for denomination, key := range denominationKeys {
+		h.ClerkKeys[denomination], err = key.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clerkServer := clerk.NewServer(clerk.Config{
+		SigningKey:       signingKey,
+		DenominationKeys: denominationKeys,
+		DIDPublicKey:     hex.EncodeToString(adminPub.Bytes()),
+		AdminDIDs:        []string{AdminDID},
+		Resolver:         h.DIDs,
+		VoterRoll:        clerk.VoterRollsFromDir(h.voterRolls),
+		Revocations:      &cryptoballot.FileRevocationList{Path: h.revoked},
+		Clock:            h.Clock.Now,
+	}, clerk.NewMemoryStore())
+	h.Clerk = httptest.NewServer(clerkServer.Handler())
+	h.ClerkClient = util.NewBallotclerkClient(h.Clerk.URL)
+
+	return h
+}
+
+// StartBallotBox starts a ballotbox, which pulls the clerk key and the list of elections from the election clerk.
+// Mixing options may be set on conf. Everything else is filled in.
+func (h *Harness) StartBallotBox(conf box.Config) {
+	conf.Clock = h.Clock.Now
+	err := conf.UpdateFromClerk(h.Clerk.URL)
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	h.BoxServer = box.NewServer(conf, box.NewMemoryStore())
+	h.Box = httptest.NewServer(h.BoxServer.Handler())
+	h.BoxClient = util.NewBallotBoxClient(h.Box.URL)
+}
+
+// StartMirror starts another ballotbox in the same way as StartBallotBox. Peers and a tree head key may be set on
+// conf.Mirror. Mirrors only pull from their peers when Gossip is called on their Server.
+func (h *Harness) StartMirror(conf box.Config) *Mirror {
+	conf.Clock = h.Clock.Now
+	err := conf.UpdateFromClerk(h.Clerk.URL)
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	mirror := &Mirror{Server: box.NewServer(conf, box.NewMemoryStore())}
+	mirror.HTTP = httptest.NewServer(mirror.Server.Handler())
+	mirror.Client = util.NewBallotBoxClient(mirror.HTTP.URL)
+	h.mirrors = append(h.mirrors, mirror.HTTP)
+	return mirror
+}
+
+// Close shuts down all of the servers
+func (h *Harness) Close() {
+	h.Clerk.Close()
+	if h.Box != nil {
+		h.Box.Close()
+	}
+	for _, mirror := range h.mirrors {
+		mirror.Close()
+	}
+	os.RemoveAll(filepath.Dir(h.voterRolls))
+}
+
+// SetDIDKeys publishes a DID document giving the DID the keys. Call it again with new keys to rotate them.
+func (h *Harness) SetDIDKeys(did string, keys ...cryptoballot.DIDPrivateKey) {
+	var publicKeys []cryptoballot.DIDPublicKey
+	for _, key := range keys {
+		publicKey, err := key.GetPublicKeyFromPrivateKey()
+		if err != nil {
+			h.T.Fatal(err)
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+	err := h.DIDs.Save(cryptoballot.NewDIDDocumentForKeys(did, publicKeys...))
+	if err != nil {
+		h.T.Fatal(err)
+	}
+}
+
+// SetVoterRoll limits an election to the voters with the given DIDs. A DID may be followed by a space and the voter's weight
+func (h *Harness) SetVoterRoll(electionID string, dids ...string) {
+	err := ioutil.WriteFile(filepath.Join(h.voterRolls, electionID), []byte(strings.Join(dids, "\n")), 0600)
+	if err != nil {
+		h.T.Fatal(err)
+	}
+}
+
+// Revoke adds a credential to the clerk's revocation list
+func (h *Harness) Revoke(credentialID string) {
+	f, err := os.OpenFile(h.revoked, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.WriteString(credentialID + "\n"); err != nil {
+		h.T.Fatal(err)
+	}
+}
+
+// IssueCredential issues a credential of the given type to the subject, signed with the issuer's key.
+// The credential is valid from now until expires, or forever if expires is zero.
+func (h *Harness) IssueCredential(issuer string, issuerKey cryptoballot.DIDPrivateKey, id, subject, credentialType string, expires time.Time) *cryptoballot.Credential {
+	return h.IssueCredentialWithClaims(issuer, issuerKey, id, subject, credentialType, nil, expires)
+}
+
+// IssueCredentialWithClaims issues a credential as IssueCredential does, making the given claims about the subject
+func (h *Harness) IssueCredentialWithClaims(issuer string, issuerKey cryptoballot.DIDPrivateKey, id, subject, credentialType string, claims map[string]interface{}, expires time.Time) *cryptoballot.Credential {
+	cred, err := cryptoballot.NewUnsignedCredential(id, issuer, subject, []string{credentialType}, claims, h.Clock.Now(), expires)
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	if err = cred.Sign(issuerKey, issuer+"#primary"); err != nil {
+		h.T.Fatal(err)
+	}
+	return cred
+}
+
+// CreateElection creates an election, signed by the admin, that is open for the given duration
+func (h *Harness) CreateElection(electionID string, open time.Duration) *cryptoballot.Election {
+	return h.CreateElectionWithTags(electionID, open, nil)
+}
+
+// CreateElectionWithTags creates an election with the given tags, signed by the admin
+func (h *Harness) CreateElectionWithTags(electionID string, open time.Duration, tags cryptoballot.TagSet) *cryptoballot.Election {
+	election, err := h.createElection(electionID, open, tags, h.Admin)
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	return election
+}
+
+// CreateElectionAs creates an election signed with the key. If did is given the election names it as the admin.
+// Any error from the election clerk is returned.
+func (h *Harness) CreateElectionAs(electionID string, open time.Duration, did string, key cryptoballot.DIDPrivateKey) (*cryptoballot.Election, error) {
+	var tags cryptoballot.TagSet
+	if did != "" {
+		tags = cryptoballot.TagSet{{Key: []byte(cryptoballot.ElectionDIDTag), Value: []byte(did)}}
+	}
+	return h.createElection(electionID, open, tags, key)
+}
+
+func (h *Harness) createElection(electionID string, open time.Duration, tags cryptoballot.TagSet, key cryptoballot.DIDPrivateKey) (*cryptoballot.Election, error) {
+	publicKey, err := key.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	election := &cryptoballot.Election{
+		ElectionID: electionID,
+		Start:      h.Clock.Now().Add(-time.Minute),
+		End:        h.Clock.Now().Add(open),
+		PublicKey:  publicKey.Bytes(),
+		TagSet:     tags,
+	}
+	election.Signature, err = key.SignString(election.String())
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	return election, h.ClerkClient.PutElection(election, key)
+}
+
+// Vote has a new voter cast a ballot, going through the same blind-signing process as the voter command.
+// The signed ballot is returned along with any error from the election clerk or the ballotbox.
+func (h *Harness) Vote(electionID string, ballotID string, vote cryptoballot.Vote) (*cryptoballot.Ballot, error) {
+	return h.VoteAs(electionID, ballotID, vote, "", NewDIDKey(h.T))
+}
+
+// VoteAs casts a ballot with the voter's key. If did is given the voter is identified by it instead of by their key.
+func (h *Harness) VoteAs(electionID string, ballotID string, vote cryptoballot.Vote, did string, voter cryptoballot.DIDPrivateKey) (*cryptoballot.Ballot, error) {
+	return h.VoteWithCredentials(electionID, ballotID, vote, did, voter)
+}
+
+// VoteWithCredentials casts a ballot as VoteAs does, presenting the credentials to the election clerk if any are given
+func (h *Harness) VoteWithCredentials(electionID string, ballotID string, vote cryptoballot.Vote, did string, voter cryptoballot.DIDPrivateKey, credentials ...*cryptoballot.Credential) (*cryptoballot.Ballot, error) {
+	ballot := &cryptoballot.Ballot{
+		ElectionID: electionID,
+		BallotID:   ballotID,
+		Vote:       vote,
+	}
+	return ballot, h.castBallot(ballot, 1, did, voter, credentials)
+}
+
+// VoteWeighted casts ballots with the given total weight in a weighted election, the same as `voter vote --weight`.
+// The weight is split into denominations, and a ballot is cast for each. The ballots cast before any error are returned.
+func (h *Harness) VoteWeighted(electionID string, ballotID string, vote cryptoballot.Vote, weight uint64, did string, voter cryptoballot.DIDPrivateKey, credentials ...*cryptoballot.Credential) ([]*cryptoballot.Ballot, error) {
+	denominations, err := h.ClerkKeys.Split(weight)
+	if err != nil {
+		return nil, err
+	}
+	var ballots []*cryptoballot.Ballot
+	for i, denomination := range denominations {
+		ballot := &cryptoballot.Ballot{
+			ElectionID: electionID,
+			BallotID:   ballotID + "-" + strconv.Itoa(i),
+			Vote:       vote,
+		}
+		ballot.SetDenomination(denomination)
+		if err = h.castBallot(ballot, denomination, did, voter, credentials); err != nil {
+			return ballots, err
+		}
+		ballots = append(ballots, ballot)
+	}
+	return ballots, nil
+}
+
+// castBallot gets the ballot signed at the given denomination and casts it
+func (h *Harness) castBallot(ballot *cryptoballot.Ballot, denomination uint64, did string, voter cryptoballot.DIDPrivateKey, credentials []*cryptoballot.Credential) error {
+	voterPub, err := voter.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		h.T.Fatal(err)
+	}
+
+	clerkKey, err := h.ClerkKeys.Key(denomination)
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	blindBallot, unblinder, err := ballot.Blind(clerkKey)
+	if err != nil {
+		h.T.Fatal(err)
+	}
+
+	electionID := ballot.ElectionID
+	signatureRequest := &cryptoballot.SignatureRequest{
+		ElectionID:  electionID,
+		RequestID:   voterPub.RequestID(),
+		PublicKey:   voterPub.Bytes(),
+		BlindBallot: blindBallot,
+	}
+	if denomination != 1 {
+		signatureRequest.Denomination = denomination
+	}
+	if did != "" {
+		signatureRequest.DID = did
+		signatureRequest.RequestID = cryptoballot.DIDRequestID(did)
+	}
+	if len(credentials) != 0 {
+		presentation, err := cryptoballot.CreatePresentation(did, credentials, electionID, signatureRequest.RequestID, voter, did)
+		if err != nil {
+			h.T.Fatal(err)
+		}
+		signatureRequest.Presentation = []byte(presentation.String())
+	}
+	signatureRequest.Signature, err = voter.SignString(signatureRequest.String())
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	fulfilled, err := h.ClerkClient.PostSignatureRequest(signatureRequest, voter)
+	if err != nil {
+		return err
+	}
+
+	err = ballot.Unblind(clerkKey, fulfilled.BallotSignature, unblinder)
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	return h.BoxClient.PutBallot(ballot)
+}
+
+// SignBallot signs a ballot with the clerk's key directly, without a voter or a signature request, for casting many
+// ballots quickly. The clerk does not know about the ballot, so it is not counted in an audit.
+func (h *Harness) SignBallot(ballot *cryptoballot.Ballot) {
+	for {
+		blindBallot, unblinder, err := ballot.Blind(h.ClerkKey)
+		if err != nil {
+			h.T.Fatal(err)
+		}
+		// BlindSign refuses a blinded ballot that is shorter than the key, so such a ballot is blinded again
+		signature, err := h.signingKey.BlindSign(blindBallot)
+		if err != nil {
+			continue
+		}
+		if err = ballot.Unblind(h.ClerkKey, signature, unblinder); err != nil {
+			h.T.Fatal(err)
+		}
+		return
+	}
+}
+
+// Bundle fetches everything needed to audit an election and signs it with the admin's key, the same as `admin export`
+func (h *Harness) Bundle(electionID string) *cryptoballot.Bundle {
+	election, err := h.ClerkClient.GetElection(electionID)
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	clerkKey, err := h.ClerkClient.GetPublicKey()
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	fulfilled, err := h.ClerkClient.GetAllSignatureRequests(electionID)
+	if err != nil {
+		h.T.Fatal(err)
+	}
+
+	bundle := &cryptoballot.Bundle{
+		Election: *election,
+		ClerkKey: clerkKey,
+	}
+	if weightSource, _ := election.WeightSource(); weightSource != nil {
+		bundle.DenominationKeys, err = h.ClerkClient.GetDenominationKeys()
+		if err != nil {
+			h.T.Fatal(err)
+		}
+	}
+	for _, sigReq := range fulfilled {
+		bundle.SignatureRequests = append(bundle.SignatureRequests, *sigReq)
+	}
+	it := h.BoxClient.IterateBallots(electionID, 0)
+	defer it.Close()
+	for it.Next() {
+		bundle.Ballots = append(bundle.Ballots, *it.Ballot())
+	}
+	if err = it.Err(); err != nil {
+		h.T.Fatal(err)
+	}
+	bundle.MerkleRoot = bundle.ComputeMerkleRoot()
+
+	adminPub, err := h.Admin.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	bundle.PublicKey = adminPub.Bytes()
+	bundle.Signature, err = h.Admin.SignString(bundle.StringWithoutSignature())
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	return bundle
+}
+
+var (
+	denominationKeysOnce sync.Once
+	denominationKeys     map[uint64]cryptoballot.PrivateKey
+	denominationKeysErr  error
+)
+
+// testDenominationKeys gets clerk keys for denominations 10 and 100. They are generated once and shared by every harness.
+func testDenominationKeys(t testing.TB) map[uint64]cryptoballot.PrivateKey {
+	denominationKeysOnce.Do(func() {
+		denominationKeys = map[uint64]cryptoballot.PrivateKey{}
+		for _, denomination := range []uint64{10, 100} {
+			denominationKeys[denomination], denominationKeysErr = cryptoballot.GeneratePrivateKey(2048)
+			if denominationKeysErr != nil {
+				return
+			}
+		}
+	})
+	if denominationKeysErr != nil {
+		t.Fatal(denominationKeysErr)
+	}
+	return denominationKeys
+}
+
+// NewDIDKey generates a random DID private key
+func NewDIDKey(t testing.TB) cryptoballot.DIDPrivateKey {
+	priv, err := cryptoballot.GenerateDIDPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
@@ -1,28 +1,1258 @@
 package webtest
 
 import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot/client"
+	"github.com/elastos/Elastos.Service.DIDVote/cryptoballot/tally"
+	"github.com/elastos/Elastos.Service.DIDVote/go_clients/util"
+	"github.com/elastos/Elastos.Service.DIDVote/servers/ballotbox/box"
+	"github.com/phayes/errors"
 )
 
-// TestWebElection compiles and runs electionclerk and ballotbox, then tests a small election using botyh
-func TestWebElection(m *testing.T) {
+var testVotes = []cryptoballot.Vote{
+	{"Santa Clause", "Tooth Fairy", "Krampus"},
+	{"Santa Clause", "Krampus", "Tooth Fairy"},
+	{"Tooth Fairy", "Santa Clause", "Krampus"},
+	{"Krampus", "Santa Clause", "Tooth Fairy"},
+	{"Santa Clause", "Tooth Fairy", "Krampus"},
+}
+
+// TestWebElection creates an election, has voters get their ballots signed and cast them,
+// then ends the election, exports a bundle, and audits and tallies it.
+func TestWebElection(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	h.CreateElection("testelection", time.Hour)
+	h.StartBallotBox(box.Config{})
+
+	for i, vote := range testVotes {
+		ballot, err := h.Vote("testelection", "ballot"+strconv.Itoa(i), vote)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The ballot is published right away
+		published, err := h.BoxClient.GetBallot("testelection", ballot.BallotID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if published.String() != ballot.String() {
+			t.Errorf("Published ballot %s does not match the ballot cast", ballot.BallotID)
+		}
+	}
+
+	// Signature requests are not published until the election ends
+	_, err := h.ClerkClient.GetAllSignatureRequests("testelection")
+	if err == nil {
+		t.Error("Signature requests published before the election ended")
+	}
+
+	h.Clock.Advance(2 * time.Hour)
+
+	// Ballots can no longer be cast
+	_, err = h.Vote("testelection", "latecomer", testVotes[0])
+	if err == nil {
+		t.Error("Ballot accepted after the election ended")
+	}
+
+	report := tally.Audit(h.Bundle("testelection"), nil, 0)
+	if !report.OK() {
+		t.Fatal(report)
+	}
+	if !report.SignedByAdmin {
+		t.Error("Bundle should be signed by the election admin")
+	}
+	if report.Ballots != len(testVotes) {
+		t.Errorf("Expected %d ballots, found %d", len(testVotes), report.Ballots)
+	}
+	// The late voter was still issued a signature, even though their ballot was rejected
+	if report.SignatureRequests != len(testVotes)+1 {
+		t.Errorf("Expected %d signature requests, found %d", len(testVotes)+1, report.SignatureRequests)
+	}
+	if len(report.Result.Winners) != 1 || report.Result.Winners[0] != "Santa Clause" {
+		t.Errorf("Expected Santa Clause to win, got %v", report.Result.Winners)
+	}
+}
+
+// TestWebElectionDuplicateBallot checks that a ballot-id can only be used once
+func TestWebElectionDuplicateBallot(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	h.CreateElection("testelection", time.Hour)
+	h.StartBallotBox(box.Config{})
+
+	_, err := h.Vote("testelection", "duplicate", testVotes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.Vote("testelection", "duplicate", testVotes[1])
+	if err == nil {
+		t.Fatal("Ballot with a duplicate ballot-id was accepted")
+	}
+
+	ballots, err := h.BoxClient.GetAllBallots("testelection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ballots) != 1 {
+		t.Fatalf("Expected 1 ballot, found %d", len(ballots))
+	}
+}
+
+// TestWebElectionMixing checks that ballots are held back until a full batch has been cast, or the election ends
+func TestWebElectionMixing(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	h.CreateElection("testelection", time.Hour)
+	conf := box.Config{}
+	conf.Mixing.Enabled = true
+	conf.Mixing.BatchSize = 3
+	conf.Mixing.Interval = time.Hour
+	h.StartBallotBox(conf)
+
+	countBallots := func() int {
+		ballots, err := h.BoxClient.GetAllBallots("testelection")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(ballots)
+	}
+
+	for i, vote := range testVotes {
+		_, err := h.Vote("testelection", "ballot"+strconv.Itoa(i), vote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.BoxServer.Mix()
+
+		// Ballots are published in batches of 3
+		if expected := (i + 1) / 3 * 3; countBallots() != expected {
+			t.Fatalf("Expected %d published ballots after %d votes, found %d", expected, i+1, countBallots())
+		}
+	}
+
+	// Once the election ends the rest are published
+	h.Clock.Advance(2 * time.Hour)
+	h.BoxServer.Mix()
+	if countBallots() != len(testVotes) {
+		t.Fatalf("Expected all %d ballots to be published, found %d", len(testVotes), countBallots())
+	}
+
+	report := tally.Audit(h.Bundle("testelection"), nil, 0)
+	if !report.OK() {
+		t.Fatal(report)
+	}
+}
+
+// TestWebElectionDIDs runs an election for admins and voters identified by DIDs, with a voter roll, and checks
+// that keys can be rotated without letting a voter get a second ballot signed
+func TestWebElectionDIDs(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	// The admin rotates their key. The new key can create elections under the admin's DID
+	newAdminKey := NewDIDKey(t)
+	h.SetDIDKeys(AdminDID, newAdminKey)
+	_, err := h.CreateElectionAs("didelection", time.Hour, AdminDID, newAdminKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.CreateElectionAs("impostor", time.Hour, AdminDID, NewDIDKey(t))
+	if err == nil {
+		t.Error("Election created with a key that does not belong to the admin's DID")
+	}
+
+	alice, bob, mallory := "did:elastos:iA1ice", "did:elastos:iBob", "did:elastos:iMa11ory"
+	aliceKey, bobKey, malloryKey := NewDIDKey(t), NewDIDKey(t), NewDIDKey(t)
+	h.SetDIDKeys(alice, aliceKey)
+	h.SetDIDKeys(bob, bobKey)
+	h.SetDIDKeys(mallory, malloryKey)
+	h.SetVoterRoll("didelection", alice, bob)
+	h.StartBallotBox(box.Config{})
+
+	_, err = h.VoteAs("didelection", "alice", testVotes[0], alice, aliceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.VoteAs("didelection", "mallory", testVotes[1], mallory, malloryKey)
+	if err == nil {
+		t.Error("Voter not on the voter roll got a ballot signed")
+	}
+	_, err = h.Vote("didelection", "anonymous", testVotes[1])
+	if err == nil {
+		t.Error("Voter without a DID got a ballot signed in an election with a voter roll")
+	}
+	_, err = h.VoteAs("didelection", "bobimpostor", testVotes[1], bob, malloryKey)
+	if err == nil {
+		t.Error("Voter got a ballot signed with a key that does not belong to their DID")
+	}
+
+	// Alice rotates her key. Her old key no longer works, and her new key is still recognised as her
+	newAliceKey := NewDIDKey(t)
+	h.SetDIDKeys(alice, newAliceKey)
+	_, err = h.VoteAs("didelection", "alice2", testVotes[1], alice, aliceKey)
+	if err == nil {
+		t.Error("Voter got a ballot signed with a rotated key")
+	}
+	_, err = h.VoteAs("didelection", "alice3", testVotes[1], alice, newAliceKey)
+	if err == nil {
+		t.Error("Voter got a second ballot signed by rotating their key")
+	}
+
+	_, err = h.VoteAs("didelection", "bob", testVotes[2], bob, bobKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Clock.Advance(2 * time.Hour)
+	report := tally.Audit(h.Bundle("didelection"), nil, 0)
+	if !report.OK() {
+		t.Fatal(report)
+	}
+	if report.Ballots != 2 || report.SignatureRequests != 2 {
+		t.Errorf("Expected 2 ballots and 2 signature requests, found %d and %d", report.Ballots, report.SignatureRequests)
+	}
+}
+
+func TestWebElectionCredentials(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	issuer, rogue := "did:elastos:iC1ub", "did:elastos:iRogue"
+	issuerKey, rogueKey := NewDIDKey(t), NewDIDKey(t)
+	h.SetDIDKeys(issuer, issuerKey)
+	h.SetDIDKeys(rogue, rogueKey)
+	h.CreateElectionWithTags("members", time.Hour, cryptoballot.TagSet{
+		{Key: []byte(cryptoballot.ElectionCredentialTypesTag), Value: []byte("MemberCredential")},
+		{Key: []byte(cryptoballot.ElectionCredentialIssuersTag), Value: []byte(issuer)},
+	})
+	h.StartBallotBox(box.Config{})
+
+	alice, bob, carol, dave := "did:elastos:iA1ice", "did:elastos:iBob", "did:elastos:iCaro1", "did:elastos:iDave"
+	aliceKey, bobKey, carolKey, daveKey := NewDIDKey(t), NewDIDKey(t), NewDIDKey(t), NewDIDKey(t)
+	for did, key := range map[string]cryptoballot.DIDPrivateKey{alice: aliceKey, bob: bobKey, carol: carolKey, dave: daveKey} {
+		h.SetDIDKeys(did, key)
+	}
+	expires := h.Clock.Now().Add(30 * time.Minute)
+
+	// Alice is a member
+	_, err := h.VoteWithCredentials("members", "alice", testVotes[0], alice, aliceKey,
+		h.IssueCredential(issuer, issuerKey, "urn:member:alice", alice, "MemberCredential", expires))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob has no credentials, then presents someone else's, then one from an untrusted issuer, then the wrong type
+	_, err = h.VoteAs("members", "bob", testVotes[1], bob, bobKey)
+	if err == nil {
+		t.Error("Voter without credentials got a ballot signed")
+	}
+	_, err = h.VoteWithCredentials("members", "bob", testVotes[1], bob, bobKey,
+		h.IssueCredential(issuer, issuerKey, "urn:member:carol", carol, "MemberCredential", expires))
+	if err == nil {
+		t.Error("Voter got a ballot signed with a credential issued to someone else")
+	}
+	_, err = h.VoteWithCredentials("members", "bob", testVotes[1], bob, bobKey,
+		h.IssueCredential(rogue, rogueKey, "urn:member:bob", bob, "MemberCredential", expires))
+	if err == nil {
+		t.Error("Voter got a ballot signed with a credential from an untrusted issuer")
+	}
+	_, err = h.VoteWithCredentials("members", "bob", testVotes[1], bob, bobKey,
+		h.IssueCredential(issuer, issuerKey, "urn:guest:bob", bob, "GuestCredential", expires))
+	if err == nil {
+		t.Error("Voter got a ballot signed with a credential of the wrong type")
+	}
+
+	// Carol's membership is revoked
+	h.Revoke("urn:member:carol")
+	_, err = h.VoteWithCredentials("members", "carol", testVotes[1], carol, carolKey,
+		h.IssueCredential(issuer, issuerKey, "urn:member:carol", carol, "MemberCredential", expires))
+	if err == nil {
+		t.Error("Voter got a ballot signed with a revoked credential")
+	}
+
+	// Dave's membership expires before they vote
+	daveCredential := h.IssueCredential(issuer, issuerKey, "urn:member:dave", dave, "MemberCredential", expires)
+	h.Clock.Advance(45 * time.Minute)
+	_, err = h.VoteWithCredentials("members", "dave", testVotes[1], dave, daveKey, daveCredential)
+	if err == nil {
+		t.Error("Voter got a ballot signed with an expired credential")
+	}
+
+	h.Clock.Advance(time.Hour)
+	report := tally.Audit(h.Bundle("members"), nil, 0)
+	if !report.OK() {
+		t.Fatal(report)
+	}
+	if report.Ballots != 1 || report.SignatureRequests != 1 {
+		t.Errorf("Expected 1 ballot and 1 signature request, found %d and %d", report.Ballots, report.SignatureRequests)
+	}
+}
+
+// TestWebElectionWeighted runs elections weighted by the voter roll and by a credential claim. Heavy voters split their
+// weight into ballots of the clerk's denominations, and cannot get more weight signed than they have.
+func TestWebElectionWeighted(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	alice, bob, carol := "did:elastos:iA1ice", "did:elastos:iBob", "did:elastos:iCaro1"
+	aliceKey, bobKey, carolKey := NewDIDKey(t), NewDIDKey(t), NewDIDKey(t)
+	for did, key := range map[string]cryptoballot.DIDPrivateKey{alice: aliceKey, bob: bobKey, carol: carolKey} {
+		h.SetDIDKeys(did, key)
+	}
+
+	issuer, issuerKey := "did:elastos:iRegistry", NewDIDKey(t)
+	h.SetDIDKeys(issuer, issuerKey)
+	h.CreateElectionWithTags("roll", time.Hour, cryptoballot.TagSet{
+		{Key: []byte(cryptoballot.ElectionWeightSourceTag), Value: []byte(cryptoballot.WeightSourceVoterRoll)},
+	})
+	h.CreateElectionWithTags("shares", time.Hour, cryptoballot.TagSet{
+		{Key: []byte(cryptoballot.ElectionCredentialTypesTag), Value: []byte("ShareholderCredential")},
+		{Key: []byte(cryptoballot.ElectionCredentialIssuersTag), Value: []byte(issuer)},
+		{Key: []byte(cryptoballot.ElectionWeightSourceTag), Value: []byte("credential:ShareholderCredential/shares")},
+	})
+	_, err := h.createElection("badweight", time.Hour, cryptoballot.TagSet{
+		{Key: []byte(cryptoballot.ElectionWeightSourceTag), Value: []byte("credential:ShareholderCredential/shares")},
+	}, h.Admin)
+	if err == nil {
+		t.Error("Election weighted by a credential it does not require was created")
+	}
+	h.SetVoterRoll("roll", alice, bob, carol+" 12")
+	h.StartBallotBox(box.Config{})
+
+	// Alice and Bob prefer Santa Clause, but Carol outweighs them
+	for voter, key := range map[string]cryptoballot.DIDPrivateKey{alice: aliceKey, bob: bobKey} {
+		if _, err := h.VoteWeighted("roll", voter, testVotes[0], 1, voter, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ballots, err := h.VoteWeighted("roll", carol, testVotes[2], 12, carol, carolKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ballots) != 3 {
+		t.Errorf("Expected a weight of 12 to be cast as 3 ballots, got %d", len(ballots))
+	}
+	if _, err = h.VoteWeighted("roll", "carol-again", testVotes[2], 1, carol, carolKey); err == nil {
+		t.Error("Voter got more weight signed than they have")
+	}
+	if _, err = h.VoteWeighted("roll", "alice-again", testVotes[0], 10, alice, aliceKey); err == nil {
+		t.Error("Voter got a ballot signed for a denomination larger than their weight")
+	}
+
+	// In the shareholder election weights come from the credential
+	shares := h.IssueCredentialWithClaims(issuer, issuerKey, "urn:shares:alice", alice, "ShareholderCredential", map[string]interface{}{"shares": 110}, time.Time{})
+	if _, err = h.VoteWeighted("shares", "alice", testVotes[0], 110, alice, aliceKey, shares); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = h.VoteWeighted("shares", "alice-again", testVotes[0], 1, alice, aliceKey, shares); err == nil {
+		t.Error("Shareholder got more weight signed than their shares")
+	}
+	if _, err = h.VoteWeighted("shares", "bob", testVotes[2], 1, bob, bobKey); err == nil {
+		t.Error("Voter without a shareholder credential got a ballot signed")
+	}
+
+	h.Clock.Advance(2 * time.Hour)
+	report := tally.Audit(h.Bundle("roll"), nil, 0)
+	if !report.OK() {
+		t.Fatal(report)
+	}
+	if report.Ballots != 5 || report.Weight != 14 || report.IssuedWeight != 14 {
+		t.Errorf("Expected 5 ballots with a weight of 14, found %d with %d", report.Ballots, report.Weight)
+	}
+	if len(report.Result.Winners) != 1 || report.Result.Winners[0] != "Tooth Fairy" {
+		t.Errorf("Expected Tooth Fairy to win, got %v", report.Result.Winners)
+	}
+
+	report = tally.Audit(h.Bundle("shares"), nil, 0)
+	if !report.OK() {
+		t.Fatal(report)
+	}
+	if report.Ballots != 2 || report.Weight != 110 {
+		t.Errorf("Expected 2 ballots with a weight of 110, found %d with %d", report.Ballots, report.Weight)
+	}
+}
+
+// TestWebElectionWeightedConcurrent has a voter send many signature requests at once, which together ask for more
+// than their weight. The clerk must not sign more than the voter's weight, however the requests interleave.
+func TestWebElectionWeightedConcurrent(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	dave, daveKey := "did:elastos:iDave", NewDIDKey(t)
+	h.SetDIDKeys(dave, daveKey)
+	h.CreateElectionWithTags("concurrent", time.Hour, cryptoballot.TagSet{
+		{Key: []byte(cryptoballot.ElectionWeightSourceTag), Value: []byte(cryptoballot.WeightSourceVoterRoll)},
+	})
+	h.SetVoterRoll("concurrent", dave+" 5")
+	h.StartBallotBox(box.Config{})
+
+	const requests = 20
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		signed int
+	)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := h.VoteWeighted("concurrent", "dave"+strconv.Itoa(i), testVotes[0], 1, dave, daveKey); err == nil {
+				mu.Lock()
+				signed++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if signed > 5 {
+		t.Errorf("Voter with a weight of 5 got %d ballots signed", signed)
+	}
+
+	h.Clock.Advance(2 * time.Hour)
+	fulfilled, err := h.ClerkClient.GetAllSignatureRequests("concurrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var issued uint64
+	for _, sigReq := range fulfilled {
+		issued += sigReq.Weight()
+	}
+	if issued > 5 {
+		t.Errorf("Voter with a weight of 5 was issued signatures for a weight of %d", issued)
+	}
+}
+
+// TestWebElectionResult has the admin publish a signed result once the election ends, which anyone can then fetch
+// and verify against the ballots
+func TestWebElectionResult(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	h.CreateElection("resultelection", time.Hour)
+	h.CreateElection("openelection", 24*time.Hour)
+	h.StartBallotBox(box.Config{})
+	for i, vote := range testVotes {
+		if _, err := h.Vote("resultelection", "ballot"+strconv.Itoa(i), vote); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// signResult builds the result from an audit of the bundle and signs it with the given key
+	signResult := func(bundle *cryptoballot.Bundle, key cryptoballot.DIDPrivateKey) *cryptoballot.ElectionResult {
+		report := tally.Audit(bundle, nil, 0)
+		if !report.OK() {
+			t.Fatal(report)
+		}
+		result, err := tally.NewElectionResult(bundle, report, "webtest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature, err := key.SignString(result.StringWithoutSignature())
+		if err != nil {
+			t.Fatal(err)
+		}
+		result.Signature = hex.EncodeToString(signature)
+		return result
+	}
+
+	h.Clock.Advance(2 * time.Hour)
+	bundle := h.Bundle("resultelection")
+	result := signResult(bundle, h.Admin)
+
+	// Results cannot be published while the election is open
+	open := *result
+	open.ElectionID = "openelection"
+	signature, err := h.Admin.SignString(open.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+	open.Signature = hex.EncodeToString(signature)
+	if err = h.ClerkClient.PutResult(&open, h.Admin); err == nil {
+		t.Error("Result published before the election ended")
+	}
+
+	// Only the election admin may publish the result
+	if err = h.ClerkClient.PutResult(signResult(bundle, NewDIDKey(t)), h.Admin); err == nil {
+		t.Error("Result signed by someone other than the election admin was published")
+	}
+
+	if err = h.ClerkClient.PutResult(result, h.Admin); err != nil {
+		t.Fatal(err)
+	}
+	if err = h.ClerkClient.PutResult(result, h.Admin); err == nil {
+		t.Error("Result published twice")
+	}
+
+	// Anyone can fetch the result and check it against the ballots
+	published, err := h.ClerkClient.GetResult("resultelection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tally.VerifyElectionResult(published, h.Bundle("resultelection"), nil, 0); err != nil {
+		t.Error(err)
+	}
+	if len(published.Winners) != 1 || published.Winners[0] != "Santa Clause" {
+		t.Errorf("Expected Santa Clause to win, got %v", published.Winners)
+	}
+}
+
+// TestWebElectionAuditLog checks that the clerk logs the election and every signature it issues, that signature
+// entries are withheld until the election ends, and that the published signature requests match the log
+func TestWebElectionAuditLog(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	h.CreateElection("logelection", time.Hour)
+	h.StartBallotBox(box.Config{})
+	for i, vote := range testVotes {
+		if _, err := h.Vote("logelection", "ballot"+strconv.Itoa(i), vote); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// While the election is open, the log stops before its first signature entry, and so does any segment
+	h.CreateElection("laterelection", time.Hour)
+	entries, err := h.ClerkClient.GetLog(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Type != cryptoballot.LogElectionCreated {
+		t.Fatalf("Expected only the election entry before the election ends, got %d entries", len(entries))
+	}
+	if segment, err := h.ClerkClient.GetLog(2, 0); err != nil || len(segment) != 0 {
+		t.Errorf("Expected no entries from 2 before the election ends, got %d %v", len(segment), err)
+	}
+	h.Clock.Advance(2 * time.Hour)
+
+	entries, err = h.ClerkClient.GetLog(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cryptoballot.VerifyAuditLog(entries, h.ClerkKey, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(testVotes)+2 || entries[0].Type != cryptoballot.LogElectionCreated {
+		t.Fatalf("Expected both elections and %d signatures to be logged, got %d entries", len(testVotes), len(entries))
+	}
+
+	// Segments verify against the entry before them
+	segment, err := h.ClerkClient.GetLog(2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segment) != 2 || segment[0].Sequence != 2 {
+		t.Fatalf("Expected entries 2 and 3, got %d entries", len(segment))
+	}
+	if err = cryptoballot.VerifyAuditLog(segment, h.ClerkKey, entries[1]); err != nil {
+		t.Error(err)
+	}
+
+	fulfilled, err := h.ClerkClient.GetAllSignatureRequests("logelection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatureRequests := make([]cryptoballot.FulfilledSignatureRequest, len(fulfilled))
+	for i, sigReq := range fulfilled {
+		signatureRequests[i] = *sigReq
+	}
+	if err = cryptoballot.CheckLoggedSignatureRequests(entries, "logelection", signatureRequests); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestWebElectionAnchor anchors an election while it is open and once it has ended and settled, the same as
+// `admin anchor`, then checks the published data against the anchored commitments, the same as `audit --receipts`
+func TestWebElectionAnchor(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	h.CreateElection("anchorelection", time.Hour)
+	h.StartBallotBox(box.Config{})
+	anchor := &cryptoballot.FileAnchor{Path: filepath.Join(filepath.Dir(h.voterRolls), "anchor")}
+
+	// commit anchors the election as currently published by the clerk and the ballotbox
+	commit := func(final bool) string {
+		entries, err := h.ClerkClient.GetLog(0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		election, err := h.ClerkClient.GetElection("anchorelection")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ballots []cryptoballot.Ballot
+		it := h.BoxClient.IterateBallots("anchorelection", 0)
+		for it.Next() {
+			ballots = append(ballots, *it.Ballot())
+		}
+		it.Close()
+		if err = it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		commitment, err := cryptoballot.NewCommitmentForElection(election, entries, ballots, h.Clock.Now(), final)
+		if err != nil {
+			t.Fatal(err)
+		}
+		receipt, err := anchor.Commit(commitment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return receipt
+	}
+
+	for i, vote := range testVotes {
+		if i == 2 {
+			commit(false)
+		}
+		if _, err := h.Vote("anchorelection", "ballot"+strconv.Itoa(i), vote); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.Clock.Advance(2 * time.Hour)
+	commit(true)
+
+	entries, err := h.ClerkClient.GetLog(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := h.Bundle("anchorelection")
+	for _, receipt := range []string{"0", "1"} {
+		commitment, err := anchor.Lookup(receipt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = commitment.Verify(bundle, entries); err != nil {
+			t.Errorf("Receipt %s: %v", receipt, err)
+		}
+	}
+
+	// A ballot removed after the election ended is caught by the final commitment. The interim commitment does
+	// not check the ballots
+	bundle.Ballots = bundle.Ballots[1:]
+	if commitment, _ := anchor.Lookup("0"); commitment.Verify(bundle, entries) != nil {
+		t.Error("Interim commitment should not check the ballots")
+	}
+	if commitment, _ := anchor.Lookup("1"); !errors.Is(commitment.Verify(bundle, entries), cryptoballot.ErrCommitmentBallots) {
+		t.Error("Expected ErrCommitmentBallots for a ballot removed after the election ended")
+	}
+}
+
+// TestWebElectionMirrors runs an election across three ballotbox mirrors. Ballots cast to one mirror reach the
+// mirrors that pull from it, each mirror's tree head matches its ballots, and a mirror that is missing a ballot is caught.
+func TestWebElectionMirrors(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	h.CreateElection("mirrorelection", time.Hour)
+	var conf box.Config
+	conf.Mirror.Key = NewDIDKey(t)
+	h.StartBallotBox(conf)
+	conf.Mirror.Key = NewDIDKey(t)
+	conf.Mirror.Peers = []string{h.Box.URL}
+	second := h.StartMirror(conf)
+	conf.Mirror.Key = NewDIDKey(t)
+	conf.Mirror.Peers = []string{h.Box.URL, second.HTTP.URL}
+	third := h.StartMirror(conf)
+
+	// published gets a mirror's ballots and checks them against its signed tree head, as `audit --mirror` does
+	published := func(client *util.BallotBoxClient) []cryptoballot.Ballot {
+		head, err := client.GetTreeHead("mirrorelection")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ballots []cryptoballot.Ballot
+		it := client.IterateBallots("mirrorelection", 0)
+		for it.Next() {
+			ballots = append(ballots, *it.Ballot())
+		}
+		it.Close()
+		if err = it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if err = head.VerifySignature(); err != nil {
+			t.Error(err)
+		}
+		if err = head.VerifyBallots(ballots); err != nil {
+			t.Errorf("%s: %v", client.BaseURL, err)
+		}
+		return ballots
+	}
+
+	// The mirrors' trees are loaded while empty, and are then added to as ballots are cast and pulled
+	for _, client := range []*util.BallotBoxClient{h.BoxClient, second.Client, third.Client} {
+		if n := len(published(client)); n != 0 {
+			t.Fatalf("Expected no ballots, found %d", n)
+		}
+	}
+
+	for i, vote := range testVotes[1:] {
+		if _, err := h.Vote("mirrorelection", "ballot"+strconv.Itoa(i), vote); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// One voter only reaches the second mirror
+	primary := h.BoxClient
+	h.BoxClient = second.Client
+	if _, err := h.Vote("mirrorelection", "secondonly", testVotes[0]); err != nil {
+		t.Fatal(err)
+	}
+	h.BoxClient = primary
+
+	second.Server.Gossip()
+	third.Server.Gossip()
+	if n := len(published(second.Client)); n != len(testVotes) {
+		t.Errorf("Expected the second mirror to pull every ballot, found %d of %d", n, len(testVotes))
+	}
+	if n := len(published(third.Client)); n != len(testVotes) {
+		t.Errorf("Expected the third mirror to pull every ballot, found %d of %d", n, len(testVotes))
+	}
+
+	// Pulling again publishes nothing new
+	second.Server.Gossip()
+	if n := len(published(second.Client)); n != len(testVotes) {
+		t.Errorf("Expected gossip to be idempotent, found %d ballots", n)
+	}
+
+	// The first ballotbox does not pull from the others, so it is missing the ballot cast only to the second mirror
+	discrepancies := cryptoballot.CompareMirrors(map[string][]cryptoballot.Ballot{
+		h.Box.URL:       published(h.BoxClient),
+		second.HTTP.URL: published(second.Client),
+		third.HTTP.URL:  published(third.Client),
+	})
+	if len(discrepancies) != 1 || discrepancies[0].BallotID != "secondonly" || len(discrepancies[0].MissingFrom) != 1 || discrepancies[0].MissingFrom[0] != h.Box.URL {
+		t.Errorf("Expected secondonly to be missing from the first ballotbox, got %v", discrepancies)
+	}
+}
+
+// TestWebElectionRegistry checks that the ballotbox pulls an election created after it started when a ballot arrives
+// for it, but not more than once a second, that a stale registry is pulled again without holding up lookups, that
+// elections can be looked up and pulled while ballots are being cast, and that the clerk only sends the elections
+// again once they have changed
+func TestWebElectionRegistry(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	h.CreateElection("firstelection", time.Hour)
+	registry := &box.ElectionRegistry{TTL: time.Minute}
+	h.StartBallotBox(box.Config{Elections: registry})
+
+	h.CreateElection("secondelection", time.Hour)
+	if _, err := h.Vote("secondelection", "early", testVotes[0]); err == nil {
+		t.Fatal("Ballot accepted for an unknown election within a second of the last pull")
+	}
+	h.Clock.Advance(time.Second)
+	if _, err := h.Vote("secondelection", "late", testVotes[0]); err != nil {
+		t.Fatalf("Ballot refused for an unknown election after a second: %v", err)
+	}
+
+	// Once the TTL has passed, a lookup of a known election starts a pull in the background
+	h.CreateElection("backgroundelection", time.Hour)
+	h.Clock.Advance(time.Minute)
+	if _, ok := registry.Get("firstelection"); !ok {
+		t.Fatal("Expected firstelection")
+	}
+	for i := 0; len(registry.Map()) != 3; i++ {
+		if i == 100 {
+			t.Fatal("Expected the background pull to find backgroundelection")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Elections are looked up, pulled and created while ballots are cast
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				registry.Get("thirdelection")
+				registry.All()
+				if j%5 == 0 {
+					if err := registry.Refresh(); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			if _, err := h.Vote("firstelection", "concurrent"+strconv.Itoa(i), testVotes[i]); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	h.CreateElection("thirdelection", time.Hour)
+	h.Clock.Advance(time.Minute)
+	wg.Wait()
+
+	if _, ok := registry.Get("thirdelection"); !ok {
+		t.Error("Expected the election created while ballots were cast to have been pulled")
+	}
+	if elections := registry.All(); len(elections) != 4 {
+		t.Errorf("Expected 4 elections, found %d", len(elections))
+	}
 
-	// Do cleanup on panic
-	defer func() {
-		if r := recover(); r != nil {
-			Fail("Panic: ", r)
+	// The clerk answers 304 Not Modified until another election is created
+	getElections := func(etag string) *http.Response {
+		req, err := http.NewRequest("GET", h.Clerk.URL+"/election", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-None-Match", etag)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
 		}
-	}()
+		resp.Body.Close()
+		return resp
+	}
+	etag := getElections("").Header.Get("ETag")
+	if resp := getElections(etag); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("Expected 304 from the clerk with a matching If-None-Match, got %s", resp.Status)
+	}
+	h.CreateElection("fourthelection", time.Hour)
+	if resp := getElections(etag); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 from the clerk once an election was created, got %s", resp.Status)
+	}
+}
+
+// TestWebBatchVote casts a batch of ballots, and checks that each ballot is given the result it would have been given
+// if it had been cast on its own
+func TestWebBatchVote(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	h.CreateElection("batchelection", time.Hour)
+	h.CreateElection("otherelection", time.Hour)
+	h.StartBallotBox(box.Config{})
 
-	runCommandSync("go", "build", "-race", "../../servers/electionclerk")
-	runCommandSync("go", "build", "-race", "../../servers/ballotbox")
-	runCommandSync("createdb", "--host=localhost", "--username=postgres", "--port=9856", "cryptoballot_webtest_electionclerk")
-	runCommandSync("createdb", "--host=localhost", "--username=postgres", "--port=9856", "cryptoballot_webtest_ballotbox")
-	runCommandSync("./electionclerk", "--config=electionclerk.conf", "--set-up-db")
+	newBallot := func(electionID string, ballotID string, vote cryptoballot.Vote) *cryptoballot.Ballot {
+		ballot := &cryptoballot.Ballot{ElectionID: electionID, BallotID: ballotID, Vote: vote}
+		h.SignBallot(ballot)
+		return ballot
+	}
+	var batch []*cryptoballot.Ballot
+	for i, vote := range testVotes {
+		batch = append(batch, newBallot("batchelection", "batch"+strconv.Itoa(i), vote))
+	}
+	cast := newBallot("batchelection", "cast", testVotes[0])
+	if err := h.BoxClient.PutBallot(cast); err != nil {
+		t.Fatal(err)
+	}
+	tampered := newBallot("batchelection", "tampered", testVotes[0])
+	tampered.Vote = testVotes[1]
+	batch = append(batch,
+		batch[0], // Cast earlier in the same batch
+		cast,     // Already published
+		tampered, // The signature does not match
+		newBallot("otherelection", "elsewhere", testVotes[0]),
+	)
 
-	// Test end-to-end
-	testEndToEnd()
+	results, err := h.BoxClient.PutBallots("batchelection", batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range testVotes {
+		if results[i] != nil {
+			t.Errorf("Ballot %s refused: %v", batch[i].BallotID, results[i])
+		}
+	}
+	for i, status := range []string{"403", "403", "400", "400"} {
+		result := results[len(testVotes)+i]
+		if result == nil || !strings.Contains(result.Error(), "ballotbox: "+status) {
+			t.Errorf("Expected ballot %s to be refused with %s, got %v", batch[len(testVotes)+i].BallotID, status, result)
+		}
+	}
+	ballots, err := h.BoxClient.GetAllBallots("batchelection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ballots) != len(testVotes)+1 {
+		t.Errorf("Expected %d ballots, found %d", len(testVotes)+1, len(ballots))
+	}
+
+	// As JSON, a ballot that cannot be read is refused on its own
+	jsonBallot, err := json.Marshal(newBallot("batchelection", "jsonballot", testVotes[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("POST", h.Box.URL+"/vote/batchelection", strings.NewReader(`[`+string(jsonBallot)+`,{"ballotId":"nothing"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var jsonResults []struct {
+		BallotID string `json:"ballotId"`
+		Status   int    `json:"status"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&jsonResults); err != nil {
+		t.Fatal(err)
+	}
+	if len(jsonResults) != 2 || jsonResults[0].BallotID != "jsonballot" || jsonResults[0].Status != http.StatusOK || jsonResults[1].Status != http.StatusBadRequest {
+		t.Errorf("Expected the first JSON ballot to be published and the second refused, got %+v", jsonResults)
+	}
+
+	// When mixing, ballots are held back, and ballot-ids that are pending are refused
+	var conf box.Config
+	conf.Mixing.Enabled = true
+	conf.Mixing.BatchSize = 100
+	conf.Batch.MaxBallots = 2
+	mixer := h.StartMirror(conf)
+	pending := []*cryptoballot.Ballot{newBallot("batchelection", "pending0", testVotes[0]), newBallot("batchelection", "pending1", testVotes[1])}
+	if results, err = mixer.Client.PutBallots("batchelection", pending); err != nil || results[0] != nil || results[1] != nil {
+		t.Errorf("Expected both ballots to be held back, got %v %v", results, err)
+	}
+	if results, err = mixer.Client.PutBallots("batchelection", pending[:1]); err != nil || results[0] == nil {
+		t.Errorf("Expected a pending ballot-id to be refused, got %v %v", results, err)
+	}
+	if published, err := mixer.Client.GetAllBallots("batchelection"); err != nil || len(published) != 0 {
+		t.Errorf("Expected no ballots to be published while mixing, got %d %v", len(published), err)
+	}
+	if _, err = mixer.Client.PutBallots("batchelection", batch[:3]); err == nil {
+		t.Error("Batch with more than max-ballots ballots was accepted")
+	}
+}
 
-	// We're done
-	Success()
+// TestWebRateLimit checks that the ballotbox throttles clients with 429 responses, that forged requests do not
+// use up the bucket of the key they claim, and that oversized ballots are refused
+func TestWebRateLimit(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	h.CreateElection("limitelection", time.Hour)
+	var conf box.Config
+	conf.RateLimits.IP = box.RateLimit{Rate: 1, Burst: 2}
+	conf.RateLimits.Key = box.RateLimit{Rate: 1, Burst: 1}
+	h.StartBallotBox(conf)
+
+	get := func() *http.Response {
+		resp, err := http.Get(h.Box.URL + "/vote/limitelection")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	for i := 0; i < 2; i++ {
+		if resp := get(); resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200 within the burst, got %s", resp.Status)
+		}
+	}
+	resp := get()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "1" {
+		t.Errorf("Expected 429 with Retry-After: 1 once the burst was used, got %s with Retry-After: %q", resp.Status, resp.Header.Get("Retry-After"))
+	}
+	h.Clock.Advance(time.Second)
+	if resp = get(); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 once a token was refilled, got %s", resp.Status)
+	}
+
+	h.Clock.Advance(10 * time.Second)
+	victim, err := NewDIDKey(t).GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("GET", h.Box.URL+"/vote/limitelection/forged", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Public-Key", victim.Hex())
+		req.Header.Set("X-Signature", "00")
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for a forged signature, got %s", resp.Status)
+		}
+	}
+
+	h.Clock.Advance(10 * time.Second)
+	req, err := http.NewRequest("PUT", h.Box.URL+"/vote/limitelection/big", strings.NewReader(strings.Repeat("x", cryptoballot.MaxBallotSize+1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for an oversized ballot, got %s", resp.Status)
+	}
+}
+
+// TestWebJSON runs an election through the JSON API, as a web or mobile client would
+func TestWebJSON(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	// request sends a JSON body, if one is given, and decodes a JSON response into out, if given
+	request := func(method, url string, body interface{}, header http.Header, out interface{}) *http.Response {
+		var encoded []byte
+		if body != nil {
+			var err error
+			if encoded, err = json.Marshal(body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		req, err := http.NewRequest(method, url, bytes.NewReader(encoded))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key := range header {
+			req.Header.Set(key, header.Get(key))
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if out != nil && resp.StatusCode == http.StatusOK {
+			if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatalf("%s %s: %v", method, url, err)
+			}
+		}
+		return resp
+	}
+
+	// The admin creates the election
+	adminPub, err := h.Admin.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	election := &cryptoballot.Election{
+		ElectionID: "jsonelection",
+		Start:      h.Clock.Now().Add(-time.Minute),
+		End:        h.Clock.Now().Add(time.Hour),
+		PublicKey:  adminPub.Bytes(),
+	}
+	election.Signature, err = h.Admin.SignString(election.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestSignature, err := h.Admin.SignString("PUT /election/jsonelection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := http.Header{}
+	signed.Set("X-Public-Key", hex.EncodeToString(adminPub.Bytes()))
+	signed.Set("X-Signature", hex.EncodeToString(requestSignature))
+	if resp := request("PUT", h.Clerk.URL+"/election/jsonelection", election.JSON(), signed, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 creating an election as JSON, got %s", resp.Status)
+	}
+	var fetched cryptoballot.Election
+	request("GET", h.Clerk.URL+"/election/jsonelection", nil, nil, &fetched)
+	if fetched.String() != election.String() {
+		t.Errorf("Election did not round trip through JSON:\n%s\n%s", election, fetched)
+	}
+	h.StartBallotBox(box.Config{})
+
+	// A voter gets their ballot signed and casts it
+	voter := NewDIDKey(t)
+	voterPub, err := voter.GetPublicKeyFromPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ballot := &cryptoballot.Ballot{ElectionID: "jsonelection", BallotID: "jsonballot", Vote: testVotes[0]}
+	blindBallot, unblinder, err := ballot.Blind(h.ClerkKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigReq := &cryptoballot.SignatureRequest{
+		ElectionID:  "jsonelection",
+		RequestID:   voterPub.RequestID(),
+		PublicKey:   voterPub.Bytes(),
+		BlindBallot: blindBallot,
+	}
+	sigReq.Signature, err = voter.SignString(sigReq.StringWithoutSignature())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fulfilled cryptoballot.FulfilledSignatureRequest
+	if resp := request("POST", h.Clerk.URL+"/sign", sigReq.JSON(), nil, &fulfilled); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 posting a signature request as JSON, got %s", resp.Status)
+	}
+	if err = ballot.Unblind(h.ClerkKey, fulfilled.BallotSignature, unblinder); err != nil {
+		t.Fatal(err)
+	}
+	if resp := request("PUT", h.Box.URL+"/vote/jsonelection/jsonballot", ballot.JSON(), nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 casting a ballot as JSON, got %s", resp.Status)
+	}
+
+	// The ballot is published, and reads the same in either format
+	var ballots []cryptoballot.Ballot
+	resp := request("GET", h.Box.URL+"/vote/jsonelection", nil, nil, &ballots)
+	if len(ballots) != 1 || ballots[0].String() != ballot.String() || resp.Trailer.Get("X-Stream-Status") != "complete" {
+		t.Errorf("Expected the ballot as a JSON array, got %v with stream status %q", ballots, resp.Trailer.Get("X-Stream-Status"))
+	}
+	published, err := h.BoxClient.GetBallot("jsonelection", "jsonballot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published.String() != ballot.String() {
+		t.Errorf("Ballot cast as JSON did not read back as text:\n%s\n%s", ballot, published)
+	}
+
+	// A ballot that would read differently as text is refused
+	badBallot := ballot.JSON()
+	badBallot.BallotID = "badballot"
+	badBallot.Vote = []string{"Santa Clause\nKrampus"}
+	if resp = request("PUT", h.Box.URL+"/vote/jsonelection/badballot", badBallot, nil, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a vote with a line break, got %s", resp.Status)
+	}
+
+	// Both servers describe their JSON API
+	for url, path := range map[string]string{h.Clerk.URL: "/sign", h.Box.URL: "/vote/{electionId}/{ballotId}"} {
+		var doc struct {
+			OpenAPI string                     `json:"openapi"`
+			Paths   map[string]json.RawMessage `json:"paths"`
+		}
+		request("GET", url+"/openapi.json", nil, nil, &doc)
+		if doc.OpenAPI == "" || doc.Paths[path] == nil {
+			t.Errorf("Expected %s in the OpenAPI document of %s, got %v", path, url, doc)
+		}
+	}
+}
+
+// TestWebVoterClient has a voter go through every step of voting with the client package, casting to the
+// ballotbox and a mirror, then verify that both publish the ballot
+func TestWebVoterClient(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+
+	h.CreateElection("clientelection", time.Hour)
+	h.StartBallotBox(box.Config{})
+	mirror := h.StartMirror(box.Config{})
+	voter := client.NewVoter(NewDIDKey(t), h.Clerk.URL, h.Box.URL, mirror.HTTP.URL)
+	ctx := context.Background()
+
+	election, err := voter.FetchElection(ctx, "clientelection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = voter.FetchElection(ctx, "noelection"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing election, got %v", err)
+	}
+
+	ballot := &cryptoballot.Ballot{ElectionID: election.ElectionID, BallotID: "clientballot", Vote: testVotes[0]}
+	prepared, err := voter.PrepareBallot(ctx, ballot, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fulfilled, err := voter.RequestSignature(ctx, prepared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := voter.Unblind(prepared, fulfilled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is published before the ballot is cast
+	if err = voter.Verify(ctx, ballot); !errors.Is(err, client.ErrNotPublished) {
+		t.Errorf("Expected ErrNotPublished before casting, got %v", err)
+	}
+	if err = voter.Cast(ctx, signed); err != nil {
+		t.Fatal(err)
+	}
+	if err = voter.Verify(ctx, ballot); err != nil {
+		t.Error(err)
+	}
+
+	// The clerk only signs once for each voter, and the ballotboxes only accept a ballot-id once
+	if _, err = voter.RequestSignature(ctx, prepared); !errors.Is(err, client.ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate signing twice, got %v", err)
+	}
+	err = voter.Cast(ctx, signed)
+	castErr, ok := err.(*client.CastError)
+	if !ok || castErr.Cast() || len(castErr.Refused) != 2 {
+		t.Errorf("Expected both ballotboxes to refuse the ballot a second time, got %v", err)
+	}
+}
+
+// benchmarkBallots is the number of ballots cast in each iteration of the ballot ingestion benchmarks
+const benchmarkBallots = 500
+
+// BenchmarkWebPutVote casts ballots one at a time with PUT, and reports the ballots cast per second
+func BenchmarkWebPutVote(b *testing.B) {
+	benchmarkCast(b, func(client *util.BallotBoxClient, ballots []*cryptoballot.Ballot) error {
+		for _, ballot := range ballots {
+			if err := client.PutBallot(ballot); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// BenchmarkWebBatchVote casts the same ballots as BenchmarkWebPutVote, in batches of 100
+func BenchmarkWebBatchVote(b *testing.B) {
+	benchmarkCast(b, func(client *util.BallotBoxClient, ballots []*cryptoballot.Ballot) error {
+		for start := 0; start < len(ballots); start += 100 {
+			results, err := client.PutBallots("benchelection", ballots[start:start+100])
+			if err != nil {
+				return err
+			}
+			for _, err = range results {
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// benchmarkCast signs benchmarkBallots ballots, then casts them to a new ballotbox in each iteration
+func benchmarkCast(b *testing.B, cast func(client *util.BallotBoxClient, ballots []*cryptoballot.Ballot) error) {
+	h := NewHarness(b)
+	defer h.Close()
+
+	h.CreateElection("benchelection", time.Hour)
+	ballots := make([]*cryptoballot.Ballot, benchmarkBallots)
+	for i := range ballots {
+		ballots[i] = &cryptoballot.Ballot{ElectionID: "benchelection", BallotID: "bench" + strconv.Itoa(i), Vote: testVotes[i%len(testVotes)]}
+		h.SignBallot(ballots[i])
+	}
+
+	var elapsed time.Duration
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		mirror := h.StartMirror(box.Config{})
+		b.StartTimer()
+		start := time.Now()
+		if err := cast(mirror.Client, ballots); err != nil {
+			b.Fatal(err)
+		}
+		elapsed += time.Since(start)
+	}
+	b.ReportMetric(float64(b.N*len(ballots))/elapsed.Seconds(), "ballots/sec")
 }